@@ -0,0 +1,184 @@
+package lens
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// AdminStatus describes the tracer state reported by the admin handler
+type AdminStatus struct {
+	Enabled    bool   `json:"enabled"`
+	Level      string `json:"level"`
+	Verbose    bool   `json:"verbose"`
+	Writers    int    `json:"writers"`
+	Filters    int    `json:"filters"`
+	Processors int    `json:"processors"`
+}
+
+// AdminHandler returns an HTTP handler for controlling the tracer at
+// runtime. Every request must carry token, either as
+// "Authorization: Bearer <token>" or in the X-Lens-Token header; with an
+// empty token all requests are rejected.
+//
+//	GET    /status    current state as JSON
+//	POST   /enable    enable tracing
+//	POST   /disable   disable tracing
+//	POST   /level     set the level from the body or ?level=, e.g. debug
+//	PUT    /filters   replace runtime filters with a FilterConfig JSON body
+//	DELETE /filters   remove runtime filters
+//	POST   /flush     flush all writers
+//	GET    /writers   writer statistics as JSON
+//
+// Mount it under a prefix with http.StripPrefix:
+//
+//	mux.Handle("/debug/lens/", http.StripPrefix("/debug/lens", tracer.AdminHandler(token)))
+func (t *TracerImpl) AdminHandler(token string) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		writeAdminJSON(w, t.adminStatus())
+	})
+
+	mux.HandleFunc("POST /enable", func(w http.ResponseWriter, r *http.Request) {
+		t.Enable()
+		writeAdminJSON(w, t.adminStatus())
+	})
+
+	mux.HandleFunc("POST /disable", func(w http.ResponseWriter, r *http.Request) {
+		t.Disable()
+		writeAdminJSON(w, t.adminStatus())
+	})
+
+	mux.HandleFunc("POST /level", func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("level")
+		if name == "" {
+			body, err := io.ReadAll(io.LimitReader(r.Body, 64))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			name = strings.TrimSpace(string(body))
+		}
+
+		level, err := ParseLevel(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		t.SetLevel(level)
+		writeAdminJSON(w, t.adminStatus())
+	})
+
+	mux.HandleFunc("PUT /filters", func(w http.ResponseWriter, r *http.Request) {
+		var config FilterConfig
+		decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&config); err != nil {
+			http.Error(w, fmt.Sprintf("invalid filter config: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		filters, err := config.build()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		t.setAdminFilters(filters)
+		writeAdminJSON(w, t.adminStatus())
+	})
+
+	mux.HandleFunc("DELETE /filters", func(w http.ResponseWriter, r *http.Request) {
+		t.setAdminFilters(nil)
+		writeAdminJSON(w, t.adminStatus())
+	})
+
+	mux.HandleFunc("POST /flush", func(w http.ResponseWriter, r *http.Request) {
+		if err := t.flushWriters(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeAdminJSON(w, t.adminStatus())
+	})
+
+	mux.HandleFunc("GET /writers", func(w http.ResponseWriter, r *http.Request) {
+		writeAdminJSON(w, t.WriterStats())
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !adminAuthorized(r, token) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// adminAuthorized checks the request token in constant time
+func adminAuthorized(r *http.Request, token string) bool {
+	if token == "" {
+		return false
+	}
+
+	presented := r.Header.Get("X-Lens-Token")
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		presented = bearer
+	}
+	return subtle.ConstantTimeCompare([]byte(presented), []byte(token)) == 1
+}
+
+// writeAdminJSON writes v as a JSON response
+func writeAdminJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// adminStatus captures the current tracer state
+func (t *TracerImpl) adminStatus() AdminStatus {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	return AdminStatus{
+		Enabled:    t.enabled,
+		Level:      t.level.String(),
+		Verbose:    t.verbose(),
+		Writers:    len(t.writers),
+		Filters:    len(t.filters),
+		Processors: len(t.processors),
+	}
+}
+
+// setAdminFilters replaces the filters installed through the admin handler
+func (t *TracerImpl) setAdminFilters(filters []Filter) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.filters = removeItems(t.filters, t.adminFilters)
+	t.filters = appendCopy(t.filters, filters...)
+	t.adminFilters = filters
+}
+
+// flushWriters flushes every writer, returning the combined errors
+func (t *TracerImpl) flushWriters() error {
+	t = t.root()
+	t.mutex.RLock()
+	writers := append([]Writer(nil), t.writers...)
+	t.mutex.RUnlock()
+
+	var errs []error
+	for _, writer := range writers {
+		err := writer.Flush()
+		t.dispatcher.flushed(writer, err)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("failed to flush writers: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package analyze
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/baretech/lens"
+)
+
+// DiffOptions controls which changes a trace diff reports as regressions
+type DiffOptions struct {
+	// DurationThreshold is the mean duration increase, in percent, above
+	// which a function is reported as slower
+	DurationThreshold float64
+	// MinDuration ignores duration changes of functions whose mean
+	// duration is below it in both traces, where noise dominates
+	MinDuration time.Duration
+	// CallThreshold is the call count change, in percent, above which a
+	// function is reported; zero reports any change
+	CallThreshold float64
+}
+
+// DefaultDiffOptions reports functions more than 10% slower
+var DefaultDiffOptions = DiffOptions{
+	DurationThreshold: 10,
+	MinDuration:       time.Microsecond,
+}
+
+// FunctionStats summarizes the calls to one function in a trace
+type FunctionStats struct {
+	Calls         int           `json:"calls"`
+	Errors        int           `json:"errors"`
+	Timed         int           `json:"timed"`
+	TotalDuration time.Duration `json:"total_duration"`
+}
+
+// MeanDuration returns the mean duration of the timed calls
+func (s FunctionStats) MeanDuration() time.Duration {
+	if s.Timed == 0 {
+		return 0
+	}
+	return s.TotalDuration / time.Duration(s.Timed)
+}
+
+// FunctionDiff compares one function between two traces
+type FunctionDiff struct {
+	Function      string        `json:"function"`
+	Old           FunctionStats `json:"old"`
+	New           FunctionStats `json:"new"`
+	CallChange    float64       `json:"call_change_percent"`
+	DurationDelta time.Duration `json:"duration_delta"`
+	// DurationChange is the percent change of the mean duration
+	DurationChange float64  `json:"duration_change_percent"`
+	NewErrors      []string `json:"new_errors,omitempty"`
+	Regressions    []string `json:"regressions,omitempty"`
+}
+
+// DiffReport is the result of comparing two traces
+type DiffReport struct {
+	Functions []FunctionDiff `json:"functions"`
+	// Added and Removed list functions traced in only one of the traces
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// Regressions returns the functions with at least one regression
+func (r *DiffReport) Regressions() []FunctionDiff {
+	var regressions []FunctionDiff
+	for _, fn := range r.Functions {
+		if len(fn.Regressions) > 0 {
+			regressions = append(regressions, fn)
+		}
+	}
+	return regressions
+}
+
+// functionTrace collects the stats and distinct errors of a function
+type functionTrace struct {
+	stats  FunctionStats
+	errors map[string]bool
+}
+
+// collectFunctions groups call, return and error events by function
+func collectFunctions(events []lens.Event) map[string]*functionTrace {
+	functions := make(map[string]*functionTrace)
+	get := func(name string) *functionTrace {
+		fn, ok := functions[name]
+		if !ok {
+			fn = &functionTrace{errors: make(map[string]bool)}
+			functions[name] = fn
+		}
+		return fn
+	}
+
+	for _, event := range events {
+		if event.Function == "" {
+			continue
+		}
+		switch event.Type {
+		case lens.EventFunctionCall, lens.EventMethodCall:
+			get(event.Function).stats.Calls++
+		case lens.EventFunctionReturn, lens.EventError, lens.EventPanic:
+			fn := get(event.Function)
+			if event.Duration > 0 {
+				fn.stats.Timed++
+				fn.stats.TotalDuration += event.Duration
+			}
+			if event.Error != "" || event.Type != lens.EventFunctionReturn {
+				fn.stats.Errors++
+				fn.errors[event.Error] = true
+			}
+		}
+	}
+	return functions
+}
+
+// Diff matches two traces by function and compares call counts, mean
+// durations and errors. Errors whose message does not occur for the same
+// function in the old trace are reported as new.
+func Diff(oldEvents, newEvents []lens.Event, options DiffOptions) *DiffReport {
+	oldFunctions := collectFunctions(oldEvents)
+	newFunctions := collectFunctions(newEvents)
+
+	report := &DiffReport{}
+	for name, newFn := range newFunctions {
+		oldFn, ok := oldFunctions[name]
+		if !ok {
+			report.Added = append(report.Added, name)
+			continue
+		}
+		report.Functions = append(report.Functions, compareFunction(name, oldFn, newFn, options))
+	}
+	for name := range oldFunctions {
+		if _, ok := newFunctions[name]; !ok {
+			report.Removed = append(report.Removed, name)
+		}
+	}
+
+	sort.Slice(report.Functions, func(i, j int) bool {
+		return report.Functions[i].Function < report.Functions[j].Function
+	})
+	sort.Strings(report.Added)
+	sort.Strings(report.Removed)
+	return report
+}
+
+// compareFunction compares a function present in both traces
+func compareFunction(name string, oldFn, newFn *functionTrace, options DiffOptions) FunctionDiff {
+	diff := FunctionDiff{
+		Function:   name,
+		Old:        oldFn.stats,
+		New:        newFn.stats,
+		CallChange: percentChange(float64(oldFn.stats.Calls), float64(newFn.stats.Calls)),
+	}
+
+	if diff.Old.Calls != diff.New.Calls && abs(diff.CallChange) > options.CallThreshold {
+		diff.Regressions = append(diff.Regressions,
+			fmt.Sprintf("calls %d -> %d (%+.1f%%)", diff.Old.Calls, diff.New.Calls, diff.CallChange))
+	}
+
+	oldMean, newMean := diff.Old.MeanDuration(), diff.New.MeanDuration()
+	if diff.Old.Timed > 0 && diff.New.Timed > 0 {
+		diff.DurationDelta = newMean - oldMean
+		diff.DurationChange = percentChange(float64(oldMean), float64(newMean))
+		aboveFloor := oldMean >= options.MinDuration || newMean >= options.MinDuration
+		if aboveFloor && diff.DurationChange > options.DurationThreshold {
+			diff.Regressions = append(diff.Regressions,
+				fmt.Sprintf("mean duration %v -> %v (%+.1f%%)", oldMean, newMean, diff.DurationChange))
+		}
+	}
+
+	for message := range newFn.errors {
+		if !oldFn.errors[message] {
+			diff.NewErrors = append(diff.NewErrors, message)
+		}
+	}
+	sort.Strings(diff.NewErrors)
+	for _, message := range diff.NewErrors {
+		diff.Regressions = append(diff.Regressions, "new error: "+message)
+	}
+
+	return diff
+}
+
+// percentChange returns the change from old to new in percent
+func percentChange(old, new float64) float64 {
+	if old == 0 {
+		if new == 0 {
+			return 0
+		}
+		return 100
+	}
+	return (new - old) / old * 100
+}
+
+// abs returns the absolute value of f
+func abs(f float64) float64 {
+	if f < 0 {
+		return -f
+	}
+	return f
+}
+
+// FormatDiff renders a diff report as text, listing regressions first.
+// Unchanged functions are only included when verbose is set.
+func FormatDiff(report *DiffReport, verbose bool) string {
+	var b strings.Builder
+
+	regressions := report.Regressions()
+	if len(regressions) == 0 {
+		b.WriteString("no regressions\n")
+	} else {
+		fmt.Fprintf(&b, "%d regressed function(s):\n", len(regressions))
+		for _, fn := range regressions {
+			fmt.Fprintf(&b, "  %s\n", fn.Function)
+			for _, regression := range fn.Regressions {
+				fmt.Fprintf(&b, "    %s\n", regression)
+			}
+		}
+	}
+
+	if verbose {
+		b.WriteString("\nfunctions:\n")
+		for _, fn := range report.Functions {
+			fmt.Fprintf(&b, "  %-40s calls %d -> %d  mean %v -> %v (%+.1f%%)  errors %d -> %d\n",
+				fn.Function, fn.Old.Calls, fn.New.Calls,
+				fn.Old.MeanDuration(), fn.New.MeanDuration(), fn.DurationChange,
+				fn.Old.Errors, fn.New.Errors)
+		}
+	}
+
+	if len(report.Added) > 0 {
+		b.WriteString("\nadded:\n")
+		for _, name := range report.Added {
+			fmt.Fprintf(&b, "  %s\n", name)
+		}
+	}
+	if len(report.Removed) > 0 {
+		b.WriteString("\nremoved:\n")
+		for _, name := range report.Removed {
+			fmt.Fprintf(&b, "  %s\n", name)
+		}
+	}
+
+	return b.String()
+}
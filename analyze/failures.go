@@ -0,0 +1,170 @@
+package analyze
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/baretech/lens"
+	"github.com/baretech/lens/reader"
+)
+
+// Failure is an error or panic with the chain of calls that led to it
+type Failure struct {
+	// Event is the error, panic or failed return
+	Event lens.Event `json:"event"`
+	// Path lists the calls open on the goroutine when it failed, outermost
+	// first
+	Path []FailureStep `json:"path"`
+	// EndedCall is set when the failure is the return of the last call on
+	// the path, rather than an error raised within it
+	EndedCall bool `json:"ended_call"`
+}
+
+// FailureStep is one call on the path to a failure
+type FailureStep struct {
+	Function   string        `json:"function"`
+	Component  string        `json:"component,omitempty"`
+	Arguments  []interface{} `json:"arguments,omitempty"`
+	Timestamp  time.Time     `json:"timestamp"`
+	SourceFile string        `json:"source_file,omitempty"`
+	SourceLine int           `json:"source_line,omitempty"`
+	// Duration and Error come from the call's return, if it returned
+	Duration time.Duration `json:"duration,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Returned bool          `json:"returned"`
+}
+
+// callOutcome is how a call ended
+type callOutcome struct {
+	duration time.Duration
+	err      string
+}
+
+// Failures reconstructs, for every error and panic in a trace, the calls
+// open on its goroutine when it happened, with their arguments and how
+// each of them ended. An error propagating up through its callers is
+// reported once, for the innermost call that failed, with the errors the
+// callers returned recorded on their steps.
+func Failures(events []lens.Event) []Failure {
+	sorted := make([]lens.Event, len(events))
+	copy(sorted, events)
+	reader.Sort(sorted)
+
+	stacks := make(map[int][]lens.Event)
+	outcomes := make(map[string]callOutcome)
+	// Calls on the path of a reported failure
+	reported := make(map[string]bool)
+
+	var failures []Failure
+	var paths [][]lens.Event
+	record := func(event lens.Event, path []lens.Event, endedCall bool) {
+		for _, call := range path {
+			reported[call.ID] = true
+		}
+		failures = append(failures, Failure{Event: event, EndedCall: endedCall})
+		paths = append(paths, path)
+	}
+
+	for _, event := range sorted {
+		stack := stacks[event.Goroutine]
+
+		switch event.Type {
+		case lens.EventFunctionCall, lens.EventMethodCall:
+			stacks[event.Goroutine] = append(stack, event)
+			continue
+		case lens.EventFunctionReturn, lens.EventError:
+			index := -1
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].TraceID == event.TraceID {
+					index = i
+					break
+				}
+			}
+			if index >= 0 {
+				call := stack[index]
+				path := append([]lens.Event(nil), stack[:index+1]...)
+				// Calls above it never returned, e.g. after a recovered panic
+				stacks[event.Goroutine] = stack[:index]
+				outcomes[call.ID] = callOutcome{duration: event.Duration, err: event.Error}
+				if failed(event) && !reported[call.ID] {
+					record(event, path, true)
+				}
+				continue
+			}
+		}
+
+		if failed(event) {
+			record(event, append([]lens.Event(nil), stack...), false)
+		}
+	}
+
+	for i, path := range paths {
+		steps := make([]FailureStep, len(path))
+		for j, call := range path {
+			outcome, returned := outcomes[call.ID]
+			steps[j] = FailureStep{
+				Function:   call.Function,
+				Component:  call.Component,
+				Arguments:  call.Arguments,
+				Timestamp:  call.Timestamp,
+				SourceFile: call.SourceFile,
+				SourceLine: call.SourceLine,
+				Duration:   outcome.duration,
+				Error:      outcome.err,
+				Returned:   returned,
+			}
+		}
+		failures[i].Path = steps
+	}
+	return failures
+}
+
+// failed reports whether an event is an error or panic
+func failed(event lens.Event) bool {
+	return event.Type == lens.EventError || event.Type == lens.EventPanic || event.Error != ""
+}
+
+// FormatFailures renders failures as narratives: each failure, then the
+// calls that led to it, indented by nesting
+func FormatFailures(failures []Failure) string {
+	if len(failures) == 0 {
+		return "no failures\n"
+	}
+
+	var b strings.Builder
+	for i, failure := range failures {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		event := failure.Event
+		fmt.Fprintf(&b, "failure %d: %s %q at %s on goroutine %d\n", i+1, event.Type,
+			event.Error, event.Timestamp.Format("15:04:05.000"), event.Goroutine)
+
+		for depth, step := range failure.Path {
+			indent := strings.Repeat("  ", depth+1)
+			fmt.Fprintf(&b, "%s%s(%s)", indent, step.Function, formatValues(step.Arguments))
+			switch {
+			case !step.Returned:
+				b.WriteString(" did not return")
+			case step.Error != "":
+				fmt.Fprintf(&b, " failed after %v: %s", step.Duration, step.Error)
+			default:
+				fmt.Fprintf(&b, " returned after %v", step.Duration)
+			}
+			if step.SourceFile != "" {
+				fmt.Fprintf(&b, " [%s:%d]", step.SourceFile, step.SourceLine)
+			}
+			b.WriteString("\n")
+		}
+
+		if !failure.EndedCall {
+			where := ""
+			if event.Function != "" {
+				where = " in " + event.Function
+			}
+			fmt.Fprintf(&b, "%s-> %s%s\n", strings.Repeat("  ", len(failure.Path)+1), event.Type, where)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,174 @@
+package analyze
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/baretech/lens"
+	"github.com/baretech/lens/reader"
+)
+
+// CallGraph is the weighted call graph of a trace
+type CallGraph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// GraphNode is a function in a call graph
+type GraphNode struct {
+	Function string `json:"function"`
+	Calls    int    `json:"calls"`
+	// Duration is the time spent in the function's calls, callees included
+	Duration time.Duration `json:"duration"`
+}
+
+// GraphEdge is a caller calling a callee, weighted by the calls made and
+// the time the callee spent in them
+type GraphEdge struct {
+	Caller   string        `json:"caller"`
+	Callee   string        `json:"callee"`
+	Calls    int           `json:"calls"`
+	Duration time.Duration `json:"duration"`
+}
+
+// BuildCallGraph builds the call graph of a trace. The caller of a call
+// is the traced call open on its goroutine, or else the caller function
+// recorded on the event, so calls from untraced code have a caller too.
+// Nodes and edges are ordered by duration, longest first.
+func BuildCallGraph(events []lens.Event) *CallGraph {
+	sorted := make([]lens.Event, len(events))
+	copy(sorted, events)
+	reader.Sort(sorted)
+
+	nodes := make(map[string]*GraphNode)
+	edges := make(map[[2]string]*GraphEdge)
+	node := func(function string) *GraphNode {
+		n, ok := nodes[function]
+		if !ok {
+			n = &GraphNode{Function: function}
+			nodes[function] = n
+		}
+		return n
+	}
+
+	type openCall struct {
+		event lens.Event
+		edge  *GraphEdge
+	}
+	stacks := make(map[int][]openCall)
+
+	for _, event := range sorted {
+		stack := stacks[event.Goroutine]
+
+		switch event.Type {
+		case lens.EventFunctionCall, lens.EventMethodCall:
+			if event.Function == "" {
+				continue
+			}
+			node(event.Function).Calls++
+
+			caller := event.CallerFunction
+			if len(stack) > 0 {
+				caller = stack[len(stack)-1].event.Function
+			}
+			var edge *GraphEdge
+			if caller != "" {
+				node(caller)
+				key := [2]string{caller, event.Function}
+				edge = edges[key]
+				if edge == nil {
+					edge = &GraphEdge{Caller: caller, Callee: event.Function}
+					edges[key] = edge
+				}
+				edge.Calls++
+			}
+			stacks[event.Goroutine] = append(stack, openCall{event: event, edge: edge})
+
+		case lens.EventFunctionReturn, lens.EventError:
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].event.TraceID != event.TraceID {
+					continue
+				}
+				call := stack[i]
+				node(call.event.Function).Duration += event.Duration
+				if call.edge != nil {
+					call.edge.Duration += event.Duration
+				}
+				// Calls above it never returned, e.g. after a recovered panic
+				stacks[event.Goroutine] = stack[:i]
+				break
+			}
+		}
+	}
+
+	graph := &CallGraph{}
+	for _, n := range nodes {
+		graph.Nodes = append(graph.Nodes, *n)
+	}
+	for _, e := range edges {
+		graph.Edges = append(graph.Edges, *e)
+	}
+	sort.Slice(graph.Nodes, func(i, j int) bool {
+		a, b := graph.Nodes[i], graph.Nodes[j]
+		if a.Duration != b.Duration {
+			return a.Duration > b.Duration
+		}
+		return a.Function < b.Function
+	})
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		a, b := graph.Edges[i], graph.Edges[j]
+		if a.Duration != b.Duration {
+			return a.Duration > b.Duration
+		}
+		if a.Caller != b.Caller {
+			return a.Caller < b.Caller
+		}
+		return a.Callee < b.Callee
+	})
+	return graph
+}
+
+// FormatDOT renders a call graph in the GraphViz DOT language, which
+// Gephi also reads. Nodes and edges are labelled with their calls and
+// durations, and edges are drawn thicker the more time they account for,
+// so hot paths stand out.
+func FormatDOT(graph *CallGraph) string {
+	var longest time.Duration
+	for _, edge := range graph.Edges {
+		if edge.Duration > longest {
+			longest = edge.Duration
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("digraph calls {\n")
+	b.WriteString("  node [shape=box, fontname=\"Helvetica\"];\n")
+	b.WriteString("  edge [fontname=\"Helvetica\", fontsize=10];\n")
+	for _, node := range graph.Nodes {
+		name := node.Function[strings.LastIndex(node.Function, "/")+1:]
+		label := name
+		// Untraced callers only have the calls they made
+		if node.Calls > 0 {
+			label = fmt.Sprintf("%s\n%d calls, %v", name, node.Calls, node.Duration)
+		}
+		fmt.Fprintf(&b, "  %s [label=%s];\n", dotQuote(node.Function), dotQuote(label))
+	}
+	for _, edge := range graph.Edges {
+		width := 1.0
+		if longest > 0 {
+			width += 4 * float64(edge.Duration) / float64(longest)
+		}
+		fmt.Fprintf(&b, "  %s -> %s [label=%s, weight=%d, penwidth=%.1f];\n",
+			dotQuote(edge.Caller), dotQuote(edge.Callee),
+			dotQuote(fmt.Sprintf("%d calls\n%v", edge.Calls, edge.Duration)), edge.Calls, width)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// dotQuote quotes a DOT identifier
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
@@ -0,0 +1,221 @@
+package analyze
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/baretech/lens"
+	"github.com/baretech/lens/reader"
+)
+
+// TagSource holds the name of the trace a merged event came from
+const TagSource = "lens.source"
+
+// MergeInput is one process's trace to merge
+type MergeInput struct {
+	// Name identifies the process, usually its trace file
+	Name   string
+	Events []lens.Event
+}
+
+// MergeOptions controls how traces are merged
+type MergeOptions struct {
+	// AdjustSkew shifts each trace's clock so that requests it served
+	// fall within the calls that sent them
+	AdjustSkew bool
+}
+
+// MergeResult is a cross-process trace
+type MergeResult struct {
+	Events []lens.Event
+	// Offsets holds the clock adjustment applied to each input, by name
+	Offsets map[string]time.Duration
+	// Reconciled counts the requests whose events were unified under one
+	// lens trace ID
+	Reconciled int
+}
+
+// interval is the span of time a request was seen in one trace
+type interval struct {
+	start, end time.Time
+}
+
+// Merge combines traces recorded by several processes into one. Events
+// carrying the same propagated correlation ID - a W3C trace ID or request
+// ID stamped by lens.CorrelationIDs - are given a shared lens trace ID.
+// With AdjustSkew, each input after the first is shifted by the median
+// offset that places its requests inside the matching ones already
+// merged. Events are ordered by adjusted timestamp and renumbered, and
+// each is tagged with its input's name.
+func Merge(inputs []MergeInput, options MergeOptions) *MergeResult {
+	result := &MergeResult{Offsets: make(map[string]time.Duration)}
+
+	// Canonical lens trace ID and time span of each correlation ID
+	traceIDs := make(map[string]string)
+	merged := make(map[string]interval)
+	reconciled := make(map[string]bool)
+
+	for _, input := range inputs {
+		events := append([]lens.Event(nil), input.Events...)
+		reader.Sort(events)
+
+		var offset time.Duration
+		if options.AdjustSkew && len(merged) > 0 {
+			offset = skewOffset(merged, correlationIntervals(events))
+		}
+		result.Offsets[input.Name] = offset
+
+		// Map this input's trace IDs to the canonical ID of their request
+		rename := make(map[string]string)
+		for _, event := range events {
+			key := correlationKey(event)
+			if key == "" {
+				continue
+			}
+			if canonical, ok := traceIDs[key]; ok {
+				if canonical != event.TraceID {
+					rename[event.TraceID] = canonical
+					reconciled[key] = true
+				}
+			} else {
+				traceIDs[key] = event.TraceID
+			}
+		}
+
+		for i := range events {
+			event := &events[i]
+			event.Timestamp = event.Timestamp.Add(offset)
+			if canonical, ok := rename[event.TraceID]; ok {
+				event.TraceID = canonical
+			}
+			event.Tags = withSource(event.Tags, input.Name)
+		}
+
+		for key, span := range correlationIntervals(events) {
+			if current, ok := merged[key]; ok {
+				if current.start.Before(span.start) {
+					span.start = current.start
+				}
+				if current.end.After(span.end) {
+					span.end = current.end
+				}
+			}
+			merged[key] = span
+		}
+
+		result.Events = append(result.Events, events...)
+	}
+
+	// Inputs were appended in order, so a stable sort keeps each one's
+	// emission order for equal timestamps
+	sort.SliceStable(result.Events, func(i, j int) bool {
+		return result.Events[i].Timestamp.Before(result.Events[j].Timestamp)
+	})
+	for i := range result.Events {
+		result.Events[i].Seq = uint64(i + 1)
+	}
+
+	result.Reconciled = len(reconciled)
+	return result
+}
+
+// correlationKey returns the propagated ID that links an event to the same
+// request in other processes, or ""
+func correlationKey(event lens.Event) string {
+	if id, ok := event.Tags[lens.TagW3CTraceID].(string); ok && id != "" {
+		return "w3c:" + id
+	}
+	if id, ok := event.Tags[lens.TagRequestID].(string); ok && id != "" {
+		return "request:" + id
+	}
+	return ""
+}
+
+// correlationIntervals returns the time span of each correlation ID in
+// events
+func correlationIntervals(events []lens.Event) map[string]interval {
+	intervals := make(map[string]interval)
+	for _, event := range events {
+		key := correlationKey(event)
+		if key == "" {
+			continue
+		}
+		start := event.Timestamp.Add(-event.Duration)
+		span, ok := intervals[key]
+		if !ok {
+			intervals[key] = interval{start: start, end: event.Timestamp}
+			continue
+		}
+		if start.Before(span.start) {
+			span.start = start
+		}
+		if event.Timestamp.After(span.end) {
+			span.end = event.Timestamp
+		}
+		intervals[key] = span
+	}
+	return intervals
+}
+
+// skewOffset estimates the clock offset of a trace from the requests it
+// shares with the merged traces. The shorter of each pair of matching
+// intervals is taken to be the request served, which must lie inside the
+// longer one; pairs that already nest need no shift, and pairs that do not
+// are centered. The median over all pairs resists outliers.
+func skewOffset(merged, incoming map[string]interval) time.Duration {
+	var shifts []time.Duration
+	for key, in := range incoming {
+		ref, ok := merged[key]
+		if !ok {
+			continue
+		}
+
+		refDuration := ref.end.Sub(ref.start)
+		inDuration := in.end.Sub(in.start)
+		switch {
+		case !in.start.Before(ref.start) && !in.end.After(ref.end),
+			!ref.start.Before(in.start) && !ref.end.After(in.end):
+			shifts = append(shifts, 0)
+		case inDuration <= refDuration:
+			// The incoming request was served within the merged one
+			shifts = append(shifts, ref.start.Add((refDuration-inDuration)/2).Sub(in.start))
+		default:
+			// The incoming request sent the merged one
+			shifts = append(shifts, ref.start.Add(-(inDuration-refDuration)/2).Sub(in.start))
+		}
+	}
+
+	if len(shifts) == 0 {
+		return 0
+	}
+	sort.Slice(shifts, func(i, j int) bool { return shifts[i] < shifts[j] })
+	return shifts[len(shifts)/2]
+}
+
+// withSource returns a copy of tags with the source name set
+func withSource(tags map[string]interface{}, name string) map[string]interface{} {
+	copied := make(map[string]interface{}, len(tags)+1)
+	for k, v := range tags {
+		copied[k] = v
+	}
+	copied[TagSource] = name
+	return copied
+}
+
+// FormatMerge summarizes a merge for display
+func FormatMerge(result *MergeResult) string {
+	names := make([]string, 0, len(result.Offsets))
+	for name := range result.Offsets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "merged %d events, %d requests reconciled\n", len(result.Events), result.Reconciled)
+	for _, name := range names {
+		fmt.Fprintf(&b, "  %s: clock offset %v\n", name, result.Offsets[name])
+	}
+	return b.String()
+}
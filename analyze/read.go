@@ -0,0 +1,26 @@
+// Package analyze provides offline analysis of lens trace files
+package analyze
+
+import (
+	"io"
+
+	"github.com/baretech/lens"
+	"github.com/baretech/lens/reader"
+)
+
+// ReadEvents reads newline-delimited JSON events as written by
+// JSONFileWriter, upgrading events from older schema versions
+func ReadEvents(r io.Reader) ([]lens.Event, error) {
+	return reader.ReadEvents(r)
+}
+
+// ReadFile reads all events from a trace file
+func ReadFile(path string) ([]lens.Event, error) {
+	return reader.ReadFile(path)
+}
+
+// SortEvents orders events as they were emitted, by sequence number where
+// recorded and otherwise by timestamp
+func SortEvents(events []lens.Event) {
+	reader.Sort(events)
+}
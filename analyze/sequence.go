@@ -0,0 +1,229 @@
+package analyze
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/baretech/lens"
+	"github.com/baretech/lens/reader"
+)
+
+// DiagramFormat selects the sequence diagram syntax
+type DiagramFormat string
+
+const (
+	FormatMermaid  DiagramFormat = "mermaid"
+	FormatPlantUML DiagramFormat = "plantuml"
+)
+
+// maxLabelLength bounds argument and return value labels
+const maxLabelLength = 40
+
+// callerParticipant is used when a call has no traced caller
+const callerParticipant = "Caller"
+
+// message is a single arrow in a sequence diagram
+type message struct {
+	from   string
+	to     string
+	label  string
+	isCall bool
+}
+
+// SequenceDiagram renders call and return events as a sequence diagram.
+// Components become participants; calls and returns become arrows, with
+// return arrows annotated with the call duration.
+func SequenceDiagram(events []lens.Event, format DiagramFormat) (string, error) {
+	participants, messages := buildSequence(events)
+
+	switch format {
+	case FormatMermaid:
+		return renderMermaid(participants, messages), nil
+	case FormatPlantUML:
+		return renderPlantUML(participants, messages), nil
+	default:
+		return "", fmt.Errorf("unknown diagram format: %s", format)
+	}
+}
+
+// buildSequence replays events in emission order, tracking open calls on a
+// stack per goroutine
+func buildSequence(events []lens.Event) ([]string, []message) {
+	sorted := make([]lens.Event, len(events))
+	copy(sorted, events)
+	reader.Sort(sorted)
+
+	var participants []string
+	seen := make(map[string]bool)
+	addParticipant := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			participants = append(participants, name)
+		}
+	}
+
+	type openCall struct {
+		traceID     string
+		participant string
+		caller      string
+	}
+
+	stacks := make(map[int][]openCall)
+	var messages []message
+
+	for _, event := range sorted {
+		switch event.Type {
+		case lens.EventFunctionCall, lens.EventMethodCall:
+			stack := stacks[event.Goroutine]
+			caller := callerParticipant
+			if len(stack) > 0 {
+				caller = stack[len(stack)-1].participant
+			}
+			callee := Participant(event)
+
+			addParticipant(caller)
+			addParticipant(callee)
+
+			messages = append(messages, message{
+				from:   caller,
+				to:     callee,
+				label:  fmt.Sprintf("%s(%s)", shortFunction(event.Function), truncate(formatValues(event.Arguments))),
+				isCall: true,
+			})
+			stacks[event.Goroutine] = append(stack, openCall{traceID: event.TraceID, participant: callee, caller: caller})
+
+		case lens.EventFunctionReturn, lens.EventError:
+			// Find the matching call; unmatched returns (e.g. spans) are skipped
+			stack := stacks[event.Goroutine]
+			index := -1
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].traceID == event.TraceID {
+					index = i
+					break
+				}
+			}
+			if index < 0 {
+				continue
+			}
+
+			call := stack[index]
+			stacks[event.Goroutine] = append(stack[:index], stack[index+1:]...)
+
+			label := truncate(formatValues(event.ReturnValue))
+			if event.Error != "" {
+				label = "error: " + truncate(event.Error)
+			}
+			if event.Duration > 0 {
+				label = strings.TrimSpace(fmt.Sprintf("%s (%v)", label, event.Duration))
+			}
+
+			messages = append(messages, message{
+				from:  call.participant,
+				to:    call.caller,
+				label: label,
+			})
+		}
+	}
+
+	return participants, messages
+}
+
+// Participant returns the diagram participant for an event: its component,
+// or the receiver type or package derived from the function name
+func Participant(event lens.Event) string {
+	if event.Component != "" {
+		return event.Component
+	}
+
+	name := event.Function
+	if slash := strings.LastIndex(name, "/"); slash >= 0 {
+		name = name[slash+1:]
+	}
+
+	parts := strings.Split(name, ".")
+	if len(parts) >= 3 {
+		// pkg.(*Type).Method or pkg.Type.Method
+		return strings.Trim(parts[1], "(*)")
+	}
+	if len(parts) > 0 && parts[0] != "" {
+		return parts[0]
+	}
+	return "unknown"
+}
+
+// shortFunction strips the package path and receiver from a function name
+func shortFunction(name string) string {
+	if dot := strings.LastIndex(name, "."); dot >= 0 {
+		return name[dot+1:]
+	}
+	return name
+}
+
+// formatValues formats argument or return values as a comma separated list
+func formatValues(values []interface{}) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprintf("%v", v)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// truncate shortens a label and strips characters that break diagram syntax
+func truncate(label string) string {
+	label = strings.NewReplacer("\n", " ", ";", ",", "#", "").Replace(label)
+	if utf8.RuneCountInString(label) > maxLabelLength {
+		label = string([]rune(label)[:maxLabelLength-3]) + "..."
+	}
+	return label
+}
+
+// renderMermaid renders a Mermaid sequence diagram
+func renderMermaid(participants []string, messages []message) string {
+	var b strings.Builder
+	aliases := participantAliases(participants)
+
+	b.WriteString("sequenceDiagram\n")
+	for _, p := range participants {
+		fmt.Fprintf(&b, "    participant %s as %s\n", aliases[p], p)
+	}
+	for _, m := range messages {
+		arrow := "-->>"
+		if m.isCall {
+			arrow = "->>"
+		}
+		fmt.Fprintf(&b, "    %s%s%s: %s\n", aliases[m.from], arrow, aliases[m.to], m.label)
+	}
+
+	return b.String()
+}
+
+// renderPlantUML renders a PlantUML sequence diagram
+func renderPlantUML(participants []string, messages []message) string {
+	var b strings.Builder
+	aliases := participantAliases(participants)
+
+	b.WriteString("@startuml\n")
+	for _, p := range participants {
+		fmt.Fprintf(&b, "participant \"%s\" as %s\n", p, aliases[p])
+	}
+	for _, m := range messages {
+		arrow := "-->"
+		if m.isCall {
+			arrow = "->"
+		}
+		fmt.Fprintf(&b, "%s %s %s : %s\n", aliases[m.from], arrow, aliases[m.to], m.label)
+	}
+	b.WriteString("@enduml\n")
+
+	return b.String()
+}
+
+// participantAliases assigns syntax-safe identifiers to participants
+func participantAliases(participants []string) map[string]string {
+	aliases := make(map[string]string, len(participants))
+	for i, p := range participants {
+		aliases[p] = fmt.Sprintf("P%d", i)
+	}
+	return aliases
+}
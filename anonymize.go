@@ -0,0 +1,141 @@
+package lens
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"unicode"
+)
+
+// AnonymizeProcessor makes traces safe to share outside your organization,
+// e.g. attached to a vendor bug report. Names are replaced by keyed
+// hashes, identifier by identifier, so "billing.(*Invoice).Total" becomes
+// something like "hd2c1a0f3.(*h9a7f6b21).h42d1e0c7": the same name always
+// gets the same hash, and methods of one type still share a prefix.
+// Values, errors and source file paths are removed. IDs, timings,
+// goroutines and depths are kept, so the trace keeps its structure.
+type AnonymizeProcessor struct {
+	salt []byte
+}
+
+// NewAnonymizeProcessor creates a processor hashing names with salt. Use
+// the same salt to keep hashes comparable across traces, and a secret one
+// so nobody can recover names by hashing guesses.
+func NewAnonymizeProcessor(salt string) *AnonymizeProcessor {
+	return &AnonymizeProcessor{salt: []byte(salt)}
+}
+
+// Process anonymizes an event
+func (p *AnonymizeProcessor) Process(event Event) Event {
+	event.Component = p.Name(event.Component)
+	event.Function = p.Name(event.Function)
+	event.Variable = p.Name(event.Variable)
+	event.SourceFunction = p.Name(event.SourceFunction)
+	event.CallerFunction = p.Name(event.CallerFunction)
+	event.SourceFile, event.SourceLine = "", 0
+	event.CallerFile, event.CallerLine = "", 0
+
+	event.Arguments = maskValues(event.Arguments)
+	event.ReturnValue = maskValues(event.ReturnValue)
+	if event.OldValue != nil {
+		event.OldValue = DefaultRedaction
+	}
+	if event.NewValue != nil {
+		event.NewValue = DefaultRedaction
+	}
+	if event.Error != "" {
+		event.Error = DefaultRedaction
+	}
+
+	if len(event.StackTrace) > 0 {
+		stack := make([]string, len(event.StackTrace))
+		for i, frame := range event.StackTrace {
+			// Frames are "file:line function"; keep the function only
+			_, function, _ := strings.Cut(frame, " ")
+			stack[i] = p.Name(function)
+		}
+		event.StackTrace = stack
+	}
+
+	if len(event.Changes) > 0 {
+		changes := make([]FieldChange, len(event.Changes))
+		for i, change := range event.Changes {
+			changes[i] = FieldChange{Path: p.Name(change.Path), Old: DefaultRedaction, New: DefaultRedaction}
+		}
+		event.Changes = changes
+	}
+
+	event.Tags = p.maskMap(event.Tags)
+	event.Params = p.maskMap(event.Params)
+
+	if len(event.Links) > 0 {
+		links := make([]Link, len(event.Links))
+		for i, link := range event.Links {
+			links[i] = Link{TraceID: link.TraceID, SpanID: link.SpanID, Attributes: p.maskMap(link.Attributes)}
+		}
+		event.Links = links
+	}
+
+	return event
+}
+
+// Name returns the anonymized form of a name, to find a function of
+// yours in an anonymized trace
+func (p *AnonymizeProcessor) Name(name string) string {
+	if name == "" {
+		return ""
+	}
+
+	var b strings.Builder
+	start := -1
+	for i, r := range name {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			b.WriteString(p.hash(name[start:i]))
+			start = -1
+		}
+		b.WriteRune(r)
+	}
+	if start >= 0 {
+		b.WriteString(p.hash(name[start:]))
+	}
+	return b.String()
+}
+
+// hash returns a short keyed hash of one identifier
+func (p *AnonymizeProcessor) hash(identifier string) string {
+	mac := hmac.New(sha256.New, p.salt)
+	mac.Write([]byte(identifier))
+	return "h" + hex.EncodeToString(mac.Sum(nil)[:4])
+}
+
+// maskMap returns a copy of m with anonymized keys and masked values
+func (p *AnonymizeProcessor) maskMap(m map[string]interface{}) map[string]interface{} {
+	if len(m) == 0 {
+		return m
+	}
+	masked := make(map[string]interface{}, len(m))
+	for k := range m {
+		masked[p.Name(k)] = DefaultRedaction
+	}
+	return masked
+}
+
+// maskValues returns a copy of values with every element replaced, so the
+// number of arguments and results is kept
+func maskValues(values []interface{}) []interface{} {
+	if len(values) == 0 {
+		return values
+	}
+	masked := make([]interface{}, len(values))
+	for i := range masked {
+		masked[i] = DefaultRedaction
+	}
+	return masked
+}
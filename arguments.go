@@ -0,0 +1,94 @@
+package lens
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// maxArgumentMatches bounds the calls an ArgumentFilter remembers between
+// their call and return events. Calls whose return never reaches the
+// filter, because another filter dropped it or the call panicked, would
+// otherwise accumulate.
+const maxArgumentMatches = 4096
+
+// ArgumentFilter keeps only the calls whose arguments match a predicate,
+// e.g. calls for one customer, for targeted debugging in production
+// without tracing everything. The return and error events of a kept call
+// are kept with it; those of other calls are dropped. Arguments are
+// matched as recorded, after any ValueEncoder has converted them. Events
+// of functions the filter does not cover pass.
+type ArgumentFilter struct {
+	match     func(event Event) bool
+	functions []string
+	matches   matchCache
+	// Trace IDs of kept calls still running
+	kept     sync.Map
+	keptSize atomic.Int32
+}
+
+// NewArgumentFilter creates a filter keeping calls whose arguments
+// satisfy match
+func NewArgumentFilter(match func(args []interface{}) bool) *ArgumentFilter {
+	return &ArgumentFilter{match: func(event Event) bool {
+		return match(event.Arguments)
+	}}
+}
+
+// NewParamFilter creates a filter keeping calls whose argument named param
+// satisfies match. Calls without such a parameter are dropped. Parameter
+// names are recorded with WithParamNames.
+func NewParamFilter(param string, match func(value interface{}) bool) *ArgumentFilter {
+	return &ArgumentFilter{match: func(event Event) bool {
+		value, ok := event.Params[param]
+		return ok && match(value)
+	}}
+}
+
+// ForFunctions restricts the filter to functions matching the patterns,
+// as FunctionFilter matches them; other functions pass
+func (f *ArgumentFilter) ForFunctions(patterns ...string) *ArgumentFilter {
+	f.functions = append(f.functions, patterns...)
+	f.matches.reset()
+	return f
+}
+
+// ShouldTrace determines if an event should be traced
+func (f *ArgumentFilter) ShouldTrace(event Event) bool {
+	if !f.covers(event.Function) {
+		return true
+	}
+
+	switch event.Type {
+	case EventFunctionCall, EventMethodCall:
+		if !f.match(event) {
+			return false
+		}
+		if f.keptSize.Load() >= maxArgumentMatches {
+			f.kept.Clear()
+			f.keptSize.Store(0)
+		}
+		if _, loaded := f.kept.LoadOrStore(event.TraceID, struct{}{}); !loaded {
+			f.keptSize.Add(1)
+		}
+		return true
+	case EventFunctionReturn:
+		if _, ok := f.kept.LoadAndDelete(event.TraceID); ok {
+			f.keptSize.Add(-1)
+			return true
+		}
+		return false
+	default:
+		_, ok := f.kept.Load(event.TraceID)
+		return ok
+	}
+}
+
+// covers reports whether the filter applies to function
+func (f *ArgumentFilter) covers(function string) bool {
+	if len(f.functions) == 0 {
+		return true
+	}
+	return f.matches.lookup(function, func() bool {
+		return matchPatterns(function, f.functions, nil)
+	})
+}
@@ -0,0 +1,43 @@
+// Package autotrace receives the trace calls injected at compile time by
+// lens-build. Import it from your main package and hand it a tracer:
+//
+//	import "github.com/baretech/lens/autotrace"
+//
+//	func main() {
+//		autotrace.SetTracer(lens.New(lens.WithWriter(lens.NewConsoleWriter(true))))
+//		...
+//	}
+//
+// and build with: go build -a -toolexec lens-build ./...
+package autotrace
+
+import (
+	"sync/atomic"
+	_ "unsafe" // for go:linkname
+
+	"github.com/baretech/lens"
+)
+
+// tracer receives injected events; nil disables tracing
+var tracer atomic.Pointer[lens.TracerImpl]
+
+// SetTracer sets the tracer that receives injected events
+func SetTracer(t *lens.TracerImpl) {
+	tracer.Store(t)
+}
+
+// noop is returned when no tracer is configured
+func noop() {}
+
+// enter is called at the start of every instrumented function.
+// Instrumented packages reference it via go:linkname, so it is
+// exported to the linker under its full symbol name.
+//
+//go:linkname enter
+func enter(function string) func() {
+	t := tracer.Load()
+	if t == nil {
+		return noop
+	}
+	return t.Enter(function)
+}
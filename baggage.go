@@ -0,0 +1,102 @@
+package lens
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// BaggageHeader is the W3C header that carries baggage between services
+const BaggageHeader = "baggage"
+
+// BaggageTagPrefix prefixes the tags baggage entries are stamped as
+const BaggageTagPrefix = "baggage."
+
+// SetBaggage returns a copy of ctx carrying key=value as baggage. Baggage
+// follows the request across services through ContextFromHeaders and
+// HeadersFromContext, and the correlation enricher stamps it onto every
+// event traced with the context.
+func SetBaggage(ctx context.Context, key, value string) context.Context {
+	current := baggageFromContext(ctx)
+	baggage := make(map[string]string, len(current)+1)
+	for k, v := range current {
+		baggage[k] = v
+	}
+	baggage[key] = value
+	return context.WithValue(ctx, baggageKey, baggage)
+}
+
+// BaggageFromContext returns a copy of the baggage stored in ctx
+func BaggageFromContext(ctx context.Context) map[string]string {
+	current := baggageFromContext(ctx)
+	if len(current) == 0 {
+		return nil
+	}
+	baggage := make(map[string]string, len(current))
+	for k, v := range current {
+		baggage[k] = v
+	}
+	return baggage
+}
+
+// baggageFromContext returns the baggage stored in ctx, which must not be
+// modified
+func baggageFromContext(ctx context.Context) map[string]string {
+	baggage, _ := ctx.Value(baggageKey).(map[string]string)
+	return baggage
+}
+
+// HeadersFromContext sets the X-Request-ID, traceparent, baggage and lens
+// span headers from ctx, for outgoing requests, as Inject does. It is the
+// inverse of ContextFromHeaders.
+func HeadersFromContext(ctx context.Context, header http.Header) {
+	Inject(ctx, HeaderCarrier(header))
+}
+
+// formatBaggage encodes baggage as a W3C baggage header value
+func formatBaggage(baggage map[string]string) string {
+	if len(baggage) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(baggage))
+	for k := range baggage {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	members := make([]string, len(keys))
+	for i, k := range keys {
+		members[i] = url.PathEscape(k) + "=" + url.PathEscape(baggage[k])
+	}
+	return strings.Join(members, ",")
+}
+
+// parseBaggage decodes W3C baggage header values into ctx. Member
+// properties are ignored and malformed members are skipped.
+func parseBaggage(ctx context.Context, values []string) context.Context {
+	for _, value := range values {
+		for _, member := range strings.Split(value, ",") {
+			// Properties follow the value, separated by ";"
+			if i := strings.IndexByte(member, ';'); i >= 0 {
+				member = member[:i]
+			}
+			key, val, ok := strings.Cut(member, "=")
+			if !ok {
+				continue
+			}
+			key, err := url.PathUnescape(strings.TrimSpace(key))
+			if err != nil || key == "" {
+				continue
+			}
+			val, err = url.PathUnescape(strings.TrimSpace(val))
+			if err != nil {
+				continue
+			}
+			ctx = SetBaggage(ctx, key, val)
+		}
+	}
+	return ctx
+}
@@ -0,0 +1,70 @@
+package lens_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/baretech/lens"
+)
+
+//go:noinline
+func benchAdd(a, b int) int {
+	return a + b
+}
+
+// discardWriter drops every event
+type discardWriter struct{}
+
+func (discardWriter) Write(lens.Event) error { return nil }
+func (discardWriter) Flush() error           { return nil }
+func (discardWriter) Close() error           { return nil }
+
+// benchmarkWrapped times calls of benchAdd wrapped by tracer
+func benchmarkWrapped(b *testing.B, tracer *lens.TracerImpl) {
+	b.Cleanup(func() { tracer.Close(context.Background()) })
+	add := tracer.Wrap(benchAdd).(func(a, b int) int)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		add(i, 1)
+	}
+}
+
+func BenchmarkUnwrapped(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		benchAdd(i, 1)
+	}
+}
+
+func BenchmarkWrappedDisabled(b *testing.B) {
+	tracer := lens.New(lens.WithWriter(discardWriter{}))
+	add := tracer.Wrap(benchAdd).(func(a, b int) int)
+	tracer.Disable()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		add(i, 1)
+	}
+}
+
+func BenchmarkWrappedFilteredOut(b *testing.B) {
+	tracer := lens.New(lens.WithWriter(discardWriter{}))
+	tracer.AddFilter(lens.IncludeFunctions("nothing.*"))
+	benchmarkWrapped(b, tracer)
+}
+
+func BenchmarkWrappedSampledOut(b *testing.B) {
+	tracer := lens.New(lens.WithWriter(discardWriter{}))
+	tracer.AddFilter(lens.Sample(0))
+	benchmarkWrapped(b, tracer)
+}
+
+func BenchmarkWrappedDiscard(b *testing.B) {
+	benchmarkWrapped(b, lens.New(lens.WithWriter(discardWriter{})))
+}
+
+func BenchmarkWrappedRingBuffer(b *testing.B) {
+	benchmarkWrapped(b, lens.New(lens.WithWriter(lens.NewRingBufferWriter(1024))))
+}
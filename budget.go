@@ -0,0 +1,97 @@
+package lens
+
+import (
+	"strings"
+	"time"
+)
+
+// Tags set on EventBudgetExceeded events
+const (
+	TagBudget        = "budget"
+	TagBudgetOverage = "budget.overage"
+)
+
+// SetBudget sets a latency budget for a function. Wrapped calls that take
+// longer emit an EventBudgetExceeded event after their return event, with
+// the budget and overage recorded as tags. function is matched against
+// the traced name with or without its package path and pointer receiver,
+// so "Calculator.CalculateTax" matches a wrapped Calculator method as well
+// as "github.com/acme/tax.(*Calculator).CalculateTax". A budget of zero
+// or less removes it.
+func (t *TracerImpl) SetBudget(function string, budget time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if budget <= 0 {
+		delete(t.budgets, function)
+		return
+	}
+	if t.budgets == nil {
+		t.budgets = make(map[string]time.Duration)
+	}
+	t.budgets[function] = budget
+}
+
+// budgetFor returns the latency budget of a traced function
+func (t *TracerImpl) budgetFor(function string) (time.Duration, bool) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	if len(t.budgets) == 0 {
+		return 0, false
+	}
+	for _, name := range budgetNames(function) {
+		if budget, ok := t.budgets[name]; ok {
+			return budget, true
+		}
+	}
+	return 0, false
+}
+
+// budgetNames returns the names a budget for function may be set under,
+// from most to least specific
+func budgetNames(function string) []string {
+	names := []string{function}
+
+	short := function[strings.LastIndex(function, "/")+1:]
+	if short != function {
+		names = append(names, short)
+	}
+
+	plain := strings.NewReplacer("(*", "", ")", "").Replace(short)
+	if plain != short {
+		names = append(names, plain)
+	}
+
+	// pkg.Type.Method also matches Type.Method
+	if parts := strings.SplitN(plain, ".", 2); len(parts) == 2 && strings.Contains(parts[1], ".") {
+		names = append(names, parts[1])
+	}
+	return names
+}
+
+// checkBudget emits EventBudgetExceeded when a return event took longer
+// than its function's budget
+func (t *TracerImpl) checkBudget(ret Event) {
+	budget, ok := t.budgetFor(ret.Function)
+	if !ok || ret.Duration <= budget {
+		return
+	}
+
+	tags := make(map[string]interface{}, len(ret.Tags)+2)
+	for k, v := range ret.Tags {
+		tags[k] = v
+	}
+	tags[TagBudget] = budget.String()
+	tags[TagBudgetOverage] = (ret.Duration - budget).String()
+
+	event := ret
+	event.ID = generateEventID()
+	event.Timestamp = time.Now()
+	event.Type = EventBudgetExceeded
+	event.ReturnValue = nil
+	event.Allocs = 0
+	event.AllocBytes = 0
+	event.Tags = tags
+	t.TraceEvent(event)
+}
@@ -0,0 +1,142 @@
+package lens
+
+// PreFilter is implemented by filters that can decide from an event's
+// type, trace ID, component, function and variable alone. Tracers consult
+// them before capturing arguments, source locations and goroutine state,
+// so calls they reject cost little more than the call itself. Events that
+// pass are still checked with ShouldTrace once fully captured. Calls are
+// first checked without a trace ID, which is only generated for calls that
+// pass, so filters deciding by trace ID should keep probes without one.
+type PreFilter interface {
+	Filter
+	ShouldCapture(probe Event) bool
+}
+
+// eventLevel returns the least verbose level at which events of a type
+// are traced
+func eventLevel(eventType EventType) Level {
+	switch eventType {
+	case EventFunctionCall, EventMethodCall, EventFunctionReturn:
+		return LevelInfo
+	case EventFieldAccess:
+		// Only the accessors lensgen generates for chosen structs emit
+		// these, so they are traced with the writes of the same fields
+		return LevelInfo
+	case EventError, EventPanic:
+		return LevelError
+	case EventBlocked, EventBudgetExceeded, EventSlowCall, EventRecursion, EventQuotaExceeded,
+		EventWriterUnhealthy:
+		return LevelWarn
+	case EventChannelOperation, EventLockOperation, EventSliceOperation, EventMapOperation:
+		return LevelDebug
+	case EventVariableRead:
+		return LevelTrace
+	default:
+		level, _ := registeredLevel(eventType)
+		return level
+	}
+}
+
+// shouldCapture reports whether an event described by probe, with any of
+// the given types, could be traced. It runs the cheap checks - enabled,
+// muted functions, level and pre-filters - so wrappers can skip capture
+// work for events that would be dropped anyway. Triggers see every event
+// and budgets time every call, so either keeps capture on.
+func (t *TracerImpl) shouldCapture(probe Event, types ...EventType) bool {
+	return t.captures(probe, types, false)
+}
+
+// captureCall reports whether a call of function in component is traced,
+// returning the trace ID it is traced under. The ID is only generated once
+// the call passes the checks not needing one, so calls turned away by the
+// switches, the level or package and function filters cost no ID; pre-filters
+// deciding by trace ID, such as sampling, run in a second pass.
+func (t *TracerImpl) captureCall(component, function string, types ...EventType) (string, bool) {
+	probe := Event{Component: component, Function: function}
+	if !t.shouldCapture(probe, types...) {
+		return "", false
+	}
+	probe.TraceID = generateTraceID()
+	if !t.shouldCapture(probe, types...) {
+		return "", false
+	}
+	return probe.TraceID, true
+}
+
+// captures is shouldCapture, skipping the level check if a child tracer
+// with a level of its own already made it
+func (t *TracerImpl) captures(probe Event, types []EventType, levelChecked bool) bool {
+	if compiledOut || !t.enabled || t.switches.muted(probe.Function) {
+		return false
+	}
+	if t.parent != nil {
+		return t.childCaptures(probe, types, levelChecked)
+	}
+
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	if t.closed {
+		return false
+	}
+	if len(t.triggers) > 0 || len(t.budgets) > 0 {
+		return true
+	}
+
+	for _, eventType := range types {
+		if !levelChecked && eventLevel(eventType) > t.level {
+			continue
+		}
+		probe.Type = eventType
+		if t.verbose() || t.preFilter(probe) {
+			return true
+		}
+	}
+	return false
+}
+
+// preFilter runs the pre-filters among the tracer's filters; the caller
+// must hold the mutex
+func (t *TracerImpl) preFilter(probe Event) bool {
+	for _, filter := range t.filters {
+		if pre, ok := filter.(PreFilter); ok && !pre.ShouldCapture(probe) {
+			return false
+		}
+	}
+	return true
+}
+
+// ShouldCapture implements PreFilter
+func (f *PackageFilter) ShouldCapture(probe Event) bool {
+	return f.ShouldTrace(probe)
+}
+
+// ShouldCapture implements PreFilter
+func (f *FunctionFilter) ShouldCapture(probe Event) bool {
+	return f.ShouldTrace(probe)
+}
+
+// ShouldCapture implements PreFilter
+func (f *EventTypeFilter) ShouldCapture(probe Event) bool {
+	return f.ShouldTrace(probe)
+}
+
+// ShouldCapture implements PreFilter. Probes without a trace ID pass, as
+// the decision is made once the ID is known.
+func (f *SamplingFilter) ShouldCapture(probe Event) bool {
+	if probe.TraceID == "" {
+		return true
+	}
+	return f.ShouldTrace(probe)
+}
+
+// ShouldCapture implements PreFilter, checking the filters that are
+// pre-filters themselves
+func (f *CompositeFilter) ShouldCapture(probe Event) bool {
+	for _, filter := range f.filters {
+		if pre, ok := filter.(PreFilter); ok && !pre.ShouldCapture(probe) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,123 @@
+package lens
+
+import (
+	"fmt"
+	"time"
+)
+
+// TracedChan is a channel proxy that traces sends and receives.
+// Go cannot substitute a channel transparently, so code opts in by
+// using Send/Recv instead of the <- operator.
+type TracedChan[T any] struct {
+	ch     chan T
+	tracer *TracerImpl
+	name   string
+}
+
+// NewTracedChan wraps ch for tracing under name
+func NewTracedChan[T any](tracer *TracerImpl, ch chan T, name string) *TracedChan[T] {
+	return &TracedChan[T]{
+		ch:     ch,
+		tracer: tracer,
+		name:   name,
+	}
+}
+
+// Chan returns the underlying channel
+func (c *TracedChan[T]) Chan() chan T {
+	return c.ch
+}
+
+// Send sends value on the channel
+func (c *TracedChan[T]) Send(value T) {
+	// Fast path: the send does not block
+	select {
+	case c.ch <- value:
+		c.traceOperation("send", 0)
+		return
+	default:
+	}
+
+	start := time.Now()
+	stop := c.watchBlocked("send")
+	c.ch <- value
+	stop()
+	c.traceOperation("send", time.Since(start))
+}
+
+// Recv receives a value from the channel; ok is false if it was closed
+func (c *TracedChan[T]) Recv() (value T, ok bool) {
+	// Fast path: a value is already available
+	select {
+	case value, ok = <-c.ch:
+		c.traceOperation("recv", 0)
+		return value, ok
+	default:
+	}
+
+	start := time.Now()
+	stop := c.watchBlocked("recv")
+	value, ok = <-c.ch
+	stop()
+	c.traceOperation("recv", time.Since(start))
+	return value, ok
+}
+
+// Close closes the underlying channel
+func (c *TracedChan[T]) Close() {
+	close(c.ch)
+	c.traceOperation("close", 0)
+}
+
+// watchBlocked arms a timer that reports the operation if it stays blocked
+// past the tracer's block threshold, and returns a func that disarms it
+func (c *TracedChan[T]) watchBlocked(op string) func() {
+	return c.tracer.watchBlocked(c.name, fmt.Sprintf("%s.%s", c.name, op))
+}
+
+// traceOperation emits a channel operation event
+func (c *TracedChan[T]) traceOperation(op string, duration time.Duration) {
+	c.tracer.TraceEvent(Event{
+		ID:        generateEventID(),
+		TraceID:   generateTraceID(),
+		Timestamp: time.Now(),
+		Type:      EventChannelOperation,
+		Component: c.name,
+		Function:  fmt.Sprintf("%s.%s", c.name, op),
+		Duration:  duration,
+		Goroutine: getGoroutineID(),
+	})
+}
+
+// watchBlocked arms a timer that emits EventBlocked for function if the
+// calling goroutine stays blocked past the block threshold, and returns a
+// func that disarms it
+func (t *TracerImpl) watchBlocked(component, function string) func() {
+	threshold := t.blockThreshold
+	if threshold <= 0 {
+		return func() {}
+	}
+
+	// Capture the stack now, from the goroutine that is about to block
+	stack := getStackTrace(4)
+	goroutine := getGoroutineID()
+	start := time.Now()
+
+	timer := time.AfterFunc(threshold, func() {
+		t.TraceEvent(Event{
+			ID:         generateEventID(),
+			TraceID:    generateTraceID(),
+			Timestamp:  time.Now(),
+			Type:       EventBlocked,
+			Component:  component,
+			Function:   function,
+			Duration:   time.Since(start),
+			StackTrace: stack,
+			Goroutine:  goroutine,
+		})
+	})
+
+	return func() {
+		timer.Stop()
+	}
+}
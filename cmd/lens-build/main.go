@@ -0,0 +1,253 @@
+// Command lens-build injects lens trace calls at compile time.
+//
+// Use it as a toolexec wrapper:
+//
+//	go build -a -toolexec lens-build ./...
+//
+// For every compiled package matching the configured include patterns,
+// each function body gets a leading
+//
+//	defer __lens_enter("pkg/path.Func")()
+//
+// call, inserted on the line of the opening brace so that line numbers
+// in the rest of the file are unchanged. The hook is resolved at link
+// time to github.com/baretech/lens/autotrace, which the main package must
+// import and configure with autotrace.SetTracer.
+//
+// Patterns are read from the JSON file named by LENS_BUILD_CONFIG, or from
+// the nearest lens-build.json found walking up from the working directory:
+//
+//	{"include": ["main", "example.com/app/..."], "exclude": ["example.com/app/internal/gen/..."]}
+//
+// Note that the compiler sees main packages under the import path "main".
+// The -a flag forces a rebuild, since the Go build cache does not know
+// about the configuration file.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// configFileName is the default configuration file name
+const configFileName = "lens-build.json"
+
+// hookFileName is the extra file added to instrumented packages
+const hookFileName = "lens_autotrace_hook.go"
+
+// Config selects the packages to instrument
+type Config struct {
+	Include []string `json:"include"`
+	Exclude []string `json:"exclude"`
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: go build -toolexec lens-build [packages]")
+		os.Exit(2)
+	}
+
+	tool, args := os.Args[1], os.Args[2:]
+
+	cleanup := func() {}
+	if isCompile(tool) && !isVersionQuery(args) {
+		rewritten, done, err := instrument(args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "lens-build: %v\n", err)
+			os.Exit(1)
+		}
+		args, cleanup = rewritten, done
+	}
+
+	code := runTool(tool, args)
+	cleanup()
+	os.Exit(code)
+}
+
+// runTool runs the wrapped tool and returns its exit code
+func runTool(tool string, args []string) int {
+	cmd := exec.Command(tool, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return exitErr.ExitCode()
+		}
+		fmt.Fprintf(os.Stderr, "lens-build: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// isCompile reports whether tool is the Go compiler
+func isCompile(tool string) bool {
+	name := strings.TrimSuffix(filepath.Base(tool), ".exe")
+	return name == "compile"
+}
+
+// isVersionQuery reports whether the go command is only asking for the tool version
+func isVersionQuery(args []string) bool {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-V") {
+			return true
+		}
+	}
+	return false
+}
+
+// instrument rewrites the Go files of a compile invocation when its package
+// is selected, returning the new arguments and a cleanup function
+func instrument(args []string) ([]string, func(), error) {
+	noop := func() {}
+
+	importPath := flagValue(args, "-p")
+	if importPath == "" || !eligible(importPath) {
+		return args, noop, nil
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return nil, noop, err
+	}
+	if config == nil || !config.matches(importPath) {
+		return args, noop, nil
+	}
+
+	tmpDir, err := os.MkdirTemp("", "lens-build-")
+	if err != nil {
+		return nil, noop, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	cleanup := func() { os.RemoveAll(tmpDir) }
+
+	var packageName string
+	rewritten := make([]string, 0, len(args)+1)
+	for i, arg := range args {
+		// Instrumented packages declare a bodyless hook, which -complete forbids
+		if arg == "-complete" {
+			continue
+		}
+		if !strings.HasSuffix(arg, ".go") {
+			rewritten = append(rewritten, arg)
+			continue
+		}
+
+		out, name, err := rewriteFile(arg, importPath, tmpDir, i)
+		if err != nil {
+			cleanup()
+			return nil, noop, err
+		}
+		packageName = name
+		rewritten = append(rewritten, out)
+	}
+
+	if packageName == "" {
+		cleanup()
+		return args, noop, nil
+	}
+
+	hook := filepath.Join(tmpDir, hookFileName)
+	if err := os.WriteFile(hook, []byte(hookSource(packageName)), 0644); err != nil {
+		cleanup()
+		return nil, noop, fmt.Errorf("failed to write hook file: %w", err)
+	}
+	rewritten = append(rewritten, hook)
+
+	return rewritten, cleanup, nil
+}
+
+// flagValue returns the value following name in args
+func flagValue(args []string, name string) string {
+	for i, arg := range args {
+		if arg == name && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// eligible excludes packages that must never be instrumented
+func eligible(importPath string) bool {
+	if importPath == "main" {
+		return true
+	}
+	// Standard library packages have no dot in their first element
+	first := strings.SplitN(importPath, "/", 2)[0]
+	if !strings.Contains(first, ".") {
+		return false
+	}
+	// Instrumenting lens itself would recurse
+	return importPath != "github.com/baretech/lens" && !strings.HasPrefix(importPath, "github.com/baretech/lens/")
+}
+
+// loadConfig reads the configuration file, returning nil if there is none
+func loadConfig() (*Config, error) {
+	configPath := os.Getenv("LENS_BUILD_CONFIG")
+	if configPath == "" {
+		configPath = findConfig()
+	}
+	if configPath == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", configPath, err)
+	}
+	return &config, nil
+}
+
+// findConfig walks up from the working directory looking for the config file
+func findConfig() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for {
+		candidate := filepath.Join(dir, configFileName)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// matches reports whether importPath is included and not excluded
+func (c *Config) matches(importPath string) bool {
+	for _, pattern := range c.Exclude {
+		if matchPattern(pattern, importPath) {
+			return false
+		}
+	}
+	for _, pattern := range c.Include {
+		if matchPattern(pattern, importPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPattern matches an import path against a go-style pattern, where a
+// trailing /... matches the package and everything below it
+func matchPattern(pattern, importPath string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "/..."); ok {
+		return importPath == prefix || strings.HasPrefix(importPath, prefix+"/")
+	}
+	matched, _ := path.Match(pattern, importPath)
+	return matched
+}
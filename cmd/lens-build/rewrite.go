@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// hookName is the package-local name of the injected hook
+const hookName = "__lens_enter"
+
+// hookTarget is the linker symbol implementing the hook
+const hookTarget = "github.com/baretech/lens/autotrace.enter"
+
+// insertion is a piece of text inserted at a byte offset
+type insertion struct {
+	offset int
+	text   string
+}
+
+// rewriteFile injects hook calls into every function of src, writing the
+// result into tmpDir. It returns the new path and the package name.
+func rewriteFile(src, importPath, tmpDir string, index int) (string, string, error) {
+	base := filepath.Base(src)
+	// cgo-generated files are left untouched
+	if strings.HasPrefix(base, "_cgo_") || strings.HasSuffix(base, ".cgo1.go") {
+		return src, "", nil
+	}
+
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read %s: %w", src, err)
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, src, data, parser.ParseComments)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to parse %s: %w", src, err)
+	}
+
+	var insertions []insertion
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil || hasDirective(fn) {
+			continue
+		}
+
+		name := qualifiedName(importPath, fn)
+		insertions = append(insertions, insertion{
+			offset: fset.Position(fn.Body.Lbrace).Offset + 1,
+			text:   fmt.Sprintf(" defer %s(%q)();", hookName, name),
+		})
+	}
+
+	sort.Slice(insertions, func(i, j int) bool {
+		return insertions[i].offset > insertions[j].offset
+	})
+	for _, ins := range insertions {
+		data = append(data[:ins.offset], append([]byte(ins.text), data[ins.offset:]...)...)
+	}
+
+	// Keep positions pointing at the original file
+	absSrc, err := filepath.Abs(src)
+	if err != nil {
+		absSrc = src
+	}
+	content := append([]byte(fmt.Sprintf("//line %s:1\n", absSrc)), data...)
+
+	out := filepath.Join(tmpDir, fmt.Sprintf("%d_%s", index, base))
+	if err := os.WriteFile(out, content, 0644); err != nil {
+		return "", "", fmt.Errorf("failed to write %s: %w", out, err)
+	}
+
+	return out, file.Name.Name, nil
+}
+
+// hasDirective reports whether fn carries a //go: compiler directive such
+// as nosplit or linkname, which instrumentation could break
+func hasDirective(fn *ast.FuncDecl) bool {
+	if fn.Doc == nil {
+		return false
+	}
+	for _, comment := range fn.Doc.List {
+		if strings.HasPrefix(comment.Text, "//go:") {
+			return true
+		}
+	}
+	return false
+}
+
+// qualifiedName returns the runtime-style name of fn, e.g. pkg.(*T).Method
+func qualifiedName(importPath string, fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return importPath + "." + fn.Name.Name
+	}
+
+	recv := fn.Recv.List[0].Type
+	pointer := false
+	if star, ok := recv.(*ast.StarExpr); ok {
+		pointer = true
+		recv = star.X
+	}
+	// Strip type parameters from generic receivers
+	switch r := recv.(type) {
+	case *ast.IndexExpr:
+		recv = r.X
+	case *ast.IndexListExpr:
+		recv = r.X
+	}
+
+	typeName := "?"
+	if ident, ok := recv.(*ast.Ident); ok {
+		typeName = ident.Name
+	}
+	if pointer {
+		typeName = "(*" + typeName + ")"
+	}
+	return importPath + "." + typeName + "." + fn.Name.Name
+}
+
+// hookSource returns the file declaring the linkname'd hook for a package
+func hookSource(packageName string) string {
+	return fmt.Sprintf(`// Code generated by lens-build. DO NOT EDIT.
+
+package %s
+
+import _ "unsafe"
+
+//go:linkname %s %s
+func %s(function string) func()
+`, packageName, hookName, hookTarget, hookName)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/baretech/lens"
+	"github.com/baretech/lens/analyze"
+)
+
+// runAnonymize implements "lens anonymize"
+func runAnonymize(args []string) error {
+	flags := flag.NewFlagSet("anonymize", flag.ContinueOnError)
+	salt := flags.String("salt", os.Getenv("LENS_ANONYMIZE_SALT"), "secret salt for name hashes (default $LENS_ANONYMIZE_SALT)")
+	output := flags.String("o", "", "output file (default stdout)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: lens anonymize [-salt secret] [-o file] trace.json")
+	}
+	if *salt == "" {
+		fmt.Fprintln(os.Stderr, "lens anonymize: warning: without a salt, names can be recovered by hashing guesses")
+	}
+
+	events, err := analyze.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	anonymizer := lens.NewAnonymizeProcessor(*salt)
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, event := range events {
+		if err := encoder.Encode(anonymizer.Process(event)); err != nil {
+			return fmt.Errorf("failed to marshal event: %w", err)
+		}
+	}
+
+	return writeOutput(*output, buf.String())
+}
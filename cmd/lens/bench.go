@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"text/tabwriter"
+	"time"
+
+	"github.com/baretech/lens"
+)
+
+// benchScenario is a way of calling the benchmarked function
+type benchScenario struct {
+	name string
+	// setup returns the function to call and a func releasing what it used
+	setup func(dir string) (func(a, b int) int, func(), error)
+}
+
+// benchAdd is the benchmarked function, cheap enough that the cost of
+// tracing it dominates
+func benchAdd(a, b int) int {
+	return a + b
+}
+
+// discardWriter is a writer doing nothing, isolating the cost of tracing
+// from that of output
+type discardWriter struct{}
+
+func (discardWriter) Write(event lens.Event) error { return nil }
+func (discardWriter) Flush() error                 { return nil }
+func (discardWriter) Close() error                 { return nil }
+
+// wrapped sets up a scenario calling benchAdd wrapped by a tracer with
+// options, configured further by configure if not nil
+func wrapped(configure func(tracer *lens.TracerImpl, dir string) error, options ...lens.Option) func(dir string) (func(a, b int) int, func(), error) {
+	return func(dir string) (func(a, b int) int, func(), error) {
+		tracer := lens.New(options...)
+		add := tracer.Wrap(benchAdd).(func(a, b int) int)
+		if configure != nil {
+			if err := configure(tracer, dir); err != nil {
+				return nil, nil, err
+			}
+		}
+		return add, func() { tracer.Close(context.Background()) }, nil
+	}
+}
+
+// benchScenarios lists the scenarios from cheapest to costliest
+var benchScenarios = []benchScenario{
+	{"unwrapped", func(dir string) (func(a, b int) int, func(), error) {
+		return benchAdd, func() {}, nil
+	}},
+	{"wrapped, disabled", wrapped(func(tracer *lens.TracerImpl, dir string) error {
+		tracer.Disable()
+		return nil
+	})},
+	{"wrapped, filtered out", wrapped(func(tracer *lens.TracerImpl, dir string) error {
+		tracer.AddFilter(lens.IncludeFunctions("nothing.*"))
+		return nil
+	})},
+	{"wrapped, discard writer", wrapped(nil, lens.WithWriter(discardWriter{}))},
+	{"wrapped, ring buffer", wrapped(nil, lens.WithWriter(lens.NewRingBufferWriter(1024)))},
+	{"wrapped, JSON file", wrapped(func(tracer *lens.TracerImpl, dir string) error {
+		writer, err := lens.NewJSONFileWriter(filepath.Join(dir, "bench.json"))
+		if err != nil {
+			return err
+		}
+		tracer.AddWriter(writer)
+		return nil
+	})},
+}
+
+// runBench implements "lens bench"
+func runBench(args []string) error {
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return fmt.Errorf("usage: lens bench")
+	}
+
+	dir, err := os.MkdirTemp("", "lens-bench")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(out, "scenario\tns/op\tB/op\tallocs/op\tadded\tcapture\tfilter\twrite\t")
+
+	var baseline int64
+	for _, scenario := range benchScenarios {
+		add, release, err := scenario.setup(dir)
+		if err != nil {
+			return fmt.Errorf("failed to set up %q: %w", scenario.name, err)
+		}
+		lens.ResetOverhead()
+		result := testing.Benchmark(func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				add(i, 1)
+			}
+		})
+		release()
+
+		if baseline == 0 {
+			baseline = result.NsPerOp()
+		}
+		// Per event costs measured while the scenario ran
+		overhead := lens.Overhead()
+		fmt.Fprintf(out, "%s\t%d\t%d\t%d\t%v\t%v\t%v\t%v\t\n", scenario.name, result.NsPerOp(),
+			result.AllocedBytesPerOp(), result.AllocsPerOp(), time.Duration(result.NsPerOp()-baseline),
+			overhead.Capture, overhead.Filter, overhead.Write)
+	}
+	return out.Flush()
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/baretech/lens"
+	"github.com/baretech/lens/analyze"
+	"github.com/baretech/lens/reader"
+)
+
+// keysEnv names the environment variable holding decryption keys, as
+// comma-separated id:base64 pairs, for every command
+const keysEnv = "LENS_DECRYPTION_KEYS"
+
+// runDecrypt implements "lens decrypt"
+func runDecrypt(args []string) error {
+	flags := flag.NewFlagSet("decrypt", flag.ContinueOnError)
+	keysFile := flags.String("keys", "", "file of id:base64 keys, one per line (default $"+keysEnv+")")
+	output := flags.String("o", "", "output file (default stdout)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: lens decrypt [-keys file] [-o file] trace.json")
+	}
+
+	if *keysFile != "" {
+		data, err := os.ReadFile(*keysFile)
+		if err != nil {
+			return fmt.Errorf("failed to read keys: %w", err)
+		}
+		if err := setKeys(strings.Split(string(data), "\n")); err != nil {
+			return err
+		}
+	}
+
+	events, err := analyze.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, event := range events {
+		if event.Type == lens.EventEncrypted {
+			return fmt.Errorf("no keys to decrypt event %s: set -keys or $%s", event.ID, keysEnv)
+		}
+		if err := encoder.Encode(event); err != nil {
+			return fmt.Errorf("failed to marshal event: %w", err)
+		}
+	}
+
+	return writeOutput(*output, buf.String())
+}
+
+// setKeys sets the keys trace files are decrypted with, skipping blank
+// entries
+func setKeys(entries []string) error {
+	var keys []lens.EncryptionKey
+	for _, entry := range entries {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		key, err := lens.ParseEncryptionKey(entry)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+
+	keyring, err := lens.NewKeyring(keys...)
+	if err != nil {
+		return err
+	}
+	reader.SetKeyring(keyring)
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+
+	"github.com/baretech/lens/analyze"
+)
+
+// runDiff implements "lens diff". It exits non-zero when regressions are
+// found so it can gate CI builds.
+func runDiff(args []string) error {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	threshold := flags.Float64("threshold", analyze.DefaultDiffOptions.DurationThreshold, "mean duration increase in percent reported as a regression")
+	callThreshold := flags.Float64("call-threshold", 0, "call count change in percent reported as a regression")
+	minDuration := flags.Duration("min-duration", analyze.DefaultDiffOptions.MinDuration, "ignore duration changes of functions faster than this")
+	format := flags.String("format", "text", "report format: text or json")
+	verbose := flags.Bool("v", false, "list unchanged functions in text reports")
+	output := flags.String("o", "", "output file (default stdout)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 2 {
+		return fmt.Errorf("usage: lens diff [-threshold pct] [-call-threshold pct] [-min-duration d] [-format text|json] [-v] [-o file] old.json new.json")
+	}
+
+	oldEvents, err := analyze.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	newEvents, err := analyze.ReadFile(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	report := analyze.Diff(oldEvents, newEvents, analyze.DiffOptions{
+		DurationThreshold: *threshold,
+		CallThreshold:     *callThreshold,
+		MinDuration:       *minDuration,
+	})
+
+	var out string
+	switch *format {
+	case "text":
+		out = analyze.FormatDiff(report, *verbose)
+	case "json":
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal report: %w", err)
+		}
+		out = string(data) + "\n"
+	default:
+		return fmt.Errorf("unknown report format: %s", *format)
+	}
+
+	if err := writeOutput(*output, out); err != nil {
+		return err
+	}
+
+	if regressions := report.Regressions(); len(regressions) > 0 {
+		return fmt.Errorf("%d regressed function(s)", len(regressions))
+	}
+	return nil
+}
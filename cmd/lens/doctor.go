@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/baretech/lens"
+	"github.com/baretech/lens/analyze"
+)
+
+// doctorReport prints the findings of "lens doctor" and counts problems
+type doctorReport struct {
+	out      io.Writer
+	problems int
+}
+
+// ok reports a check that passed
+func (r *doctorReport) ok(format string, args ...interface{}) {
+	fmt.Fprintf(r.out, "ok    %s\n", fmt.Sprintf(format, args...))
+}
+
+// warn reports something likely to keep events from being traced
+func (r *doctorReport) warn(format string, args ...interface{}) {
+	fmt.Fprintf(r.out, "warn  %s\n", fmt.Sprintf(format, args...))
+}
+
+// fail reports a problem
+func (r *doctorReport) fail(format string, args ...interface{}) {
+	r.problems++
+	fmt.Fprintf(r.out, "FAIL  %s\n", fmt.Sprintf(format, args...))
+}
+
+// runDoctor implements "lens doctor"
+func runDoctor(args []string) error {
+	flags := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	configPath := flags.String("config", "", "configuration file (default LENS_* environment variables)")
+	overhead := flags.Bool("overhead", true, "measure the overhead of the configuration")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	report := &doctorReport{out: os.Stdout}
+
+	var config *lens.Config
+	var err error
+	if *configPath != "" {
+		config, err = lens.LoadConfig(*configPath)
+	} else {
+		config, err = lens.ConfigFromEnv()
+	}
+	if err != nil {
+		report.fail("configuration: %v", err)
+	} else {
+		if *configPath != "" {
+			report.ok("configuration loaded from %s", *configPath)
+		} else {
+			report.ok("configuration loaded from the environment")
+		}
+		checkSettings(report, config)
+		checkWriters(report, config.Writers)
+		checkFilters(report, config.Filters)
+		if *overhead {
+			checkOverhead(report, config)
+		}
+	}
+
+	functions := make(map[string]bool)
+	for _, path := range flags.Args() {
+		checkTrace(report, path, functions)
+	}
+	if config != nil && len(functions) > 0 {
+		checkPatternMatches(report, config.Filters, functions)
+	}
+
+	if report.problems > 0 {
+		return fmt.Errorf("%d problem(s) found", report.problems)
+	}
+	return nil
+}
+
+// checkSettings checks the settings that turn tracing off altogether
+func checkSettings(report *doctorReport, config *lens.Config) {
+	if config.Enabled != nil && !*config.Enabled {
+		report.warn("tracing is disabled by the configuration")
+	}
+
+	level := lens.LevelInfo
+	if config.Level != "" {
+		parsed, err := lens.ParseLevel(config.Level)
+		if err != nil {
+			report.fail("level: %v", err)
+			return
+		}
+		level = parsed
+	}
+	if level < lens.LevelInfo {
+		report.warn("level %s drops wrapped calls, which are traced at info", level)
+	} else {
+		report.ok("level %s", level)
+	}
+
+	if rate := config.Sampling.Rate; rate != nil && *rate == 0 {
+		report.warn("sampling rate 0 drops every trace")
+	}
+}
+
+// checkWriters opens the target of each writer, removing what opening it
+// created
+func checkWriters(report *doctorReport, writers []lens.WriterConfig) {
+	if len(writers) == 0 {
+		report.warn("no writers configured: events are discarded unless writers are added in code")
+		return
+	}
+
+	for _, wc := range writers {
+		name := writerName(wc)
+		created := ""
+		if wc.Path != "" {
+			created = createdBy(wc.Path)
+		}
+
+		tracer := lens.New()
+		err := tracer.ApplyConfig(&lens.Config{Writers: []lens.WriterConfig{wc}})
+		if err == nil {
+			err = tracer.Close(context.Background())
+		}
+		if created != "" {
+			os.RemoveAll(created)
+		}
+
+		if err != nil {
+			report.fail("writer %s: %v", name, err)
+			continue
+		}
+		report.ok("writer %s opens", name)
+	}
+}
+
+// createdBy returns the outermost directory or file that creating path
+// would create, or "" if path exists
+func createdBy(path string) string {
+	created := ""
+	for path = filepath.Clean(path); ; path = filepath.Dir(path) {
+		if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			return created
+		}
+		created = path
+		if filepath.Dir(path) == path {
+			return created
+		}
+	}
+}
+
+// writerName describes a writer by its type and target
+func writerName(wc lens.WriterConfig) string {
+	switch {
+	case wc.Path != "":
+		return wc.Type + ":" + wc.Path
+	case wc.Address != "":
+		return wc.Type + ":" + wc.Network + "://" + wc.Address
+	}
+	return wc.Type
+}
+
+// checkFilters validates the filter patterns and settings
+func checkFilters(report *doctorReport, filters lens.FilterConfig) {
+	patterns := map[string][]string{
+		"include_packages":  filters.IncludePackages,
+		"exclude_packages":  filters.ExcludePackages,
+		"include_functions": filters.IncludeFunctions,
+		"exclude_functions": filters.ExcludeFunctions,
+		"include_sources":   filters.IncludeSources,
+		"exclude_sources":   filters.ExcludeSources,
+	}
+	names := make([]string, 0, len(patterns))
+	for name := range patterns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	valid := true
+	for _, name := range names {
+		for _, pattern := range patterns[name] {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				report.fail("%s pattern %q: %v", name, pattern, err)
+				valid = false
+				continue
+			}
+			// Function names are qualified by their package, and patterns
+			// match the whole name
+			if strings.HasSuffix(name, "_functions") && !strings.Contains(pattern, ".") && strings.Trim(pattern, "*") != "" {
+				report.warn("%s pattern %q matches no function: names are qualified by their package, e.g. main.%s", name, pattern, pattern)
+			}
+		}
+	}
+
+	// Building the filters validates the rest of the settings
+	tracer := lens.New()
+	defer tracer.Close(context.Background())
+	if err := tracer.ApplyConfig(&lens.Config{Filters: filters}); err != nil {
+		report.fail("filters: %v", err)
+		return
+	}
+	if valid {
+		report.ok("filters are valid")
+	}
+}
+
+// checkOverhead measures the cost of a wrapped call with the configured
+// level, filters and processors, writing to a writer discarding events
+func checkOverhead(report *doctorReport, config *lens.Config) {
+	measured := *config
+	measured.Writers = nil
+	tracer := lens.New(lens.WithWriter(discardWriter{}))
+	if err := tracer.ApplyConfig(&measured); err != nil {
+		// Reported by the other checks
+		return
+	}
+	defer tracer.Close(context.Background())
+	add := tracer.Wrap(benchAdd).(func(a, b int) int)
+
+	baseline := testing.Benchmark(func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			benchAdd(i, 1)
+		}
+	})
+	lens.ResetOverhead()
+	result := testing.Benchmark(func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			add(i, 1)
+		}
+	})
+	costs := lens.Overhead()
+
+	added := time.Duration(result.NsPerOp() - baseline.NsPerOp())
+	report.ok("tracing adds about %v per call (capture %v, filter %v, write %v per event); run lens bench to compare writers",
+		added, costs.Capture, costs.Filter, costs.Write)
+}
+
+// checkTrace checks that a trace file can be read and that its source
+// paths resolve on this machine, adding the functions it calls to functions
+func checkTrace(report *doctorReport, path string, functions map[string]bool) {
+	events, err := analyze.ReadFile(path)
+	if err != nil {
+		report.fail("trace %s: %v", path, err)
+		return
+	}
+	if len(events) == 0 {
+		report.warn("trace %s has no events", path)
+		return
+	}
+	report.ok("trace %s has %d events", path, len(events))
+
+	sources := make(map[string]bool)
+	for _, event := range events {
+		if event.SourceFile != "" {
+			sources[event.SourceFile] = true
+		}
+		if event.Type == lens.EventFunctionCall || event.Type == lens.EventMethodCall {
+			functions[event.Function] = true
+		}
+	}
+
+	if len(sources) == 0 {
+		report.warn("trace %s records no source locations", path)
+	} else {
+		var missing []string
+		for source := range sources {
+			if _, err := os.Stat(source); err != nil {
+				missing = append(missing, source)
+			}
+		}
+		sort.Strings(missing)
+		switch {
+		case len(missing) == 0:
+			report.ok("trace %s: all %d source files found", path, len(sources))
+		case len(missing) == len(sources):
+			report.warn("trace %s: none of its %d source files are found here, e.g. %s; it was recorded on another machine or with trimmed paths, so its file:line references will not open here",
+				path, len(sources), missing[0])
+		default:
+			report.warn("trace %s: %d of its %d source files are not found here, e.g. %s",
+				path, len(missing), len(sources), missing[0])
+		}
+	}
+}
+
+// checkPatternMatches reports include patterns matching none of the
+// functions called in the traces
+func checkPatternMatches(report *doctorReport, filters lens.FilterConfig, functions map[string]bool) {
+	for _, pattern := range filters.IncludeFunctions {
+		matched := false
+		for function := range functions {
+			if ok, _ := filepath.Match(pattern, function); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			report.warn("include_functions pattern %q matches none of the %d functions in the traces", pattern, len(functions))
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+
+	"github.com/baretech/lens/analyze"
+)
+
+// runFailures implements "lens failures"
+func runFailures(args []string) error {
+	flags := flag.NewFlagSet("failures", flag.ContinueOnError)
+	format := flags.String("format", "text", "report format: text or json")
+	output := flags.String("o", "", "output file (default stdout)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: lens failures [-format text|json] [-o file] trace.json")
+	}
+
+	events, err := analyze.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	failures := analyze.Failures(events)
+
+	var out string
+	switch *format {
+	case "text":
+		out = analyze.FormatFailures(failures)
+	case "json":
+		data, err := json.MarshalIndent(failures, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal failures: %w", err)
+		}
+		out = string(data) + "\n"
+	default:
+		return fmt.Errorf("unknown report format: %s", *format)
+	}
+
+	return writeOutput(*output, out)
+}
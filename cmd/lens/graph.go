@@ -0,0 +1,28 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/baretech/lens/analyze"
+)
+
+// runGraph implements "lens graph"
+func runGraph(args []string) error {
+	flags := flag.NewFlagSet("graph", flag.ContinueOnError)
+	output := flags.String("o", "", "output file (default stdout)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: lens graph [-o file] trace.json")
+	}
+
+	events, err := analyze.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(*output, analyze.FormatDOT(analyze.BuildCallGraph(events)))
+}
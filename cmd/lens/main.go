@@ -0,0 +1,112 @@
+// Command lens provides tools for working with lens trace files
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// command is a lens subcommand
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+// commands maps subcommand names to their implementations
+var commands = map[string]command{
+	"anonymize": {
+		usage: "strip names, values and paths from a trace file for sharing",
+		run:   runAnonymize,
+	},
+	"bench": {
+		usage: "measure the cost of tracing a call on this machine",
+		run:   runBench,
+	},
+	"decrypt": {
+		usage: "decrypt a trace file written through an EncryptedWriter",
+		run:   runDecrypt,
+	},
+	"diff": {
+		usage: "compare two trace files and report regressions",
+		run:   runDiff,
+	},
+	"doctor": {
+		usage: "diagnose why a tracing setup traces nothing, or too much",
+		run:   runDoctor,
+	},
+	"failures": {
+		usage: "explain each error and panic with the calls that led to it",
+		run:   runFailures,
+	},
+	"graph": {
+		usage: "export the call graph of a trace file for GraphViz or Gephi",
+		run:   runGraph,
+	},
+	"merge": {
+		usage: "merge trace files from several processes into one",
+		run:   runMerge,
+	},
+	"serve": {
+		usage: "browse trace files in a web viewer",
+		run:   runServe,
+	},
+	"sequence": {
+		usage: "render a trace file as a Mermaid or PlantUML sequence diagram",
+		run:   runSequence,
+	},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "lens: unknown command %q\n", os.Args[1])
+		printUsage()
+		os.Exit(2)
+	}
+
+	if keys := os.Getenv(keysEnv); keys != "" {
+		if err := setKeys(strings.Split(keys, ",")); err != nil {
+			fmt.Fprintf(os.Stderr, "lens: %s: %v\n", keysEnv, err)
+			os.Exit(2)
+		}
+	}
+
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "lens %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+// printUsage prints the list of available subcommands
+func printUsage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr, "usage: lens <command> [arguments]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].usage)
+	}
+}
+
+// writeOutput writes data to path, or stdout when path is empty
+func writeOutput(path string, data string) error {
+	if path == "" {
+		_, err := fmt.Print(data)
+		return err
+	}
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/baretech/lens/analyze"
+)
+
+// runMerge implements "lens merge"
+func runMerge(args []string) error {
+	flags := flag.NewFlagSet("merge", flag.ContinueOnError)
+	noSkew := flags.Bool("no-skew", false, "do not adjust for clock skew between processes")
+	output := flags.String("o", "", "output file (default stdout)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() < 2 {
+		return fmt.Errorf("usage: lens merge [-no-skew] [-o file] a.json b.json...")
+	}
+
+	inputs := make([]analyze.MergeInput, flags.NArg())
+	for i, path := range flags.Args() {
+		events, err := analyze.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		inputs[i] = analyze.MergeInput{Name: filepath.Base(path), Events: events}
+	}
+
+	result := analyze.Merge(inputs, analyze.MergeOptions{AdjustSkew: !*noSkew})
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, event := range result.Events {
+		if err := encoder.Encode(event); err != nil {
+			return fmt.Errorf("failed to marshal event: %w", err)
+		}
+	}
+
+	if err := writeOutput(*output, buf.String()); err != nil {
+		return err
+	}
+	fmt.Fprint(os.Stderr, analyze.FormatMerge(result))
+	return nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/baretech/lens/analyze"
+)
+
+// runSequence implements "lens sequence"
+func runSequence(args []string) error {
+	flags := flag.NewFlagSet("sequence", flag.ContinueOnError)
+	format := flags.String("format", "mermaid", "diagram format: mermaid or plantuml")
+	output := flags.String("o", "", "output file (default stdout)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: lens sequence [-format mermaid|plantuml] [-o file] trace.json")
+	}
+
+	events, err := analyze.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	diagram, err := analyze.SequenceDiagram(events, analyze.DiagramFormat(*format))
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(*output, diagram)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/baretech/lens"
+	"github.com/baretech/lens/reader"
+)
+
+// runServe implements "lens serve"
+func runServe(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := flags.String("addr", "localhost:7070", "address to listen on")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: lens serve [-addr host:port] dir|trace.json")
+	}
+
+	path := flags.Arg(0)
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to open traces: %w", err)
+	}
+
+	// Files are read again on every request, so new traces show on reload
+	source := func(ctx context.Context) ([]lens.Event, error) {
+		if info.IsDir() {
+			return reader.ReadDir(path)
+		}
+		return reader.ReadFile(path)
+	}
+
+	fmt.Fprintf(os.Stderr, "serving %s on http://%s/\n", path, *addr)
+	return http.ListenAndServe(*addr, lens.NewViewerHandler(source))
+}
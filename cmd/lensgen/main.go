@@ -0,0 +1,426 @@
+// Command lensgen generates traced wrappers for Go packages.
+//
+// Package mode emits a <package>_traced.go file declaring a Traced struct
+// whose fields are lens-wrapped versions of the package's exported
+// functions and constructors:
+//
+//	lensgen -pkg ./internal/billing -all
+//
+// Callers then use billing.NewTraced(tracer).Charge(...) instead of
+// wrapping each function individually.
+//
+// With -structs, it also emits traced accessors for the exported fields of
+// the named structs, recording reads as field_access events and writes as
+// variable_write events:
+//
+//	lensgen -pkg ./internal/billing -structs Invoice
+//
+// billing.TraceInvoice(tracer, inv).SetTotal(42) then sets inv.Total.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// tracedSuffix marks generated files, which are skipped when parsing
+const tracedSuffix = "_traced.go"
+
+// tracedFunc is an exported function selected for wrapping
+type tracedFunc struct {
+	name     string
+	funcType string
+	params   []string
+	// imports maps package names used in the signature to import specs
+	imports map[string]string
+}
+
+// tracedStruct is an exported struct selected for accessors
+type tracedStruct struct {
+	name   string
+	fields []tracedField
+	// imports maps package names used in field types to import specs
+	imports map[string]string
+}
+
+// tracedField is an exported field of a tracedStruct
+type tracedField struct {
+	name      string
+	fieldType string
+}
+
+func main() {
+	pkgDir := flag.String("pkg", ".", "package directory to process")
+	all := flag.Bool("all", false, "wrap every exported function and constructor")
+	funcs := flag.String("funcs", "", "comma separated list of functions to wrap")
+	structs := flag.String("structs", "", "comma separated list of structs to generate field accessors for")
+	output := flag.String("o", "", "output file (default <dir>/<package>_traced.go)")
+	flag.Parse()
+
+	if !*all && *funcs == "" && *structs == "" {
+		fmt.Fprintln(os.Stderr, "lensgen: one of -all, -funcs or -structs is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(*pkgDir, *all, *funcs, *structs, *output); err != nil {
+		fmt.Fprintf(os.Stderr, "lensgen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run parses the package and writes the generated file
+func run(pkgDir string, all bool, funcs string, structs string, output string) error {
+	pkgName, found, foundStructs, err := parsePackage(pkgDir)
+	if err != nil {
+		return err
+	}
+
+	var selected []tracedFunc
+	if all || funcs != "" {
+		selected, err = selectFuncs(found, all, funcs)
+		if err != nil {
+			return err
+		}
+	}
+
+	var selectedStructs []tracedStruct
+	if structs != "" {
+		selectedStructs, err = selectStructs(foundStructs, structs)
+		if err != nil {
+			return err
+		}
+	}
+
+	src, err := generate(pkgName, selected, selectedStructs)
+	if err != nil {
+		return err
+	}
+
+	if output == "" {
+		output = filepath.Join(pkgDir, pkgName+tracedSuffix)
+	}
+	if err := os.WriteFile(output, src, 0644); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	return nil
+}
+
+// parsePackage returns the package name and its exported, non-generic
+// functions and structs
+func parsePackage(dir string) (string, []tracedFunc, []tracedStruct, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(info os.FileInfo) bool {
+		name := info.Name()
+		return !strings.HasSuffix(name, "_test.go") && !strings.HasSuffix(name, tracedSuffix)
+	}, 0)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("failed to parse package: %w", err)
+	}
+	if len(pkgs) != 1 {
+		return "", nil, nil, fmt.Errorf("expected one package in %s, found %d", dir, len(pkgs))
+	}
+
+	var pkgName string
+	var funcs []tracedFunc
+	var structs []tracedStruct
+	for name, pkg := range pkgs {
+		pkgName = name
+		for _, file := range pkg.Files {
+			fileImports := importsByName(file)
+			for _, decl := range file.Decls {
+				if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.TYPE {
+					found, err := parseStructs(fset, gen, fileImports)
+					if err != nil {
+						return "", nil, nil, err
+					}
+					structs = append(structs, found...)
+					continue
+				}
+
+				fn, ok := decl.(*ast.FuncDecl)
+				if !ok || fn.Recv != nil || !fn.Name.IsExported() {
+					continue
+				}
+				// Generic functions must be instantiated before they can be wrapped
+				if fn.Type.TypeParams != nil && len(fn.Type.TypeParams.List) > 0 {
+					continue
+				}
+
+				var buf bytes.Buffer
+				if err := printer.Fprint(&buf, fset, fn.Type); err != nil {
+					return "", nil, nil, fmt.Errorf("failed to print %s: %w", fn.Name.Name, err)
+				}
+				funcs = append(funcs, tracedFunc{
+					name:     fn.Name.Name,
+					funcType: buf.String(),
+					params:   paramNames(fn.Type.Params),
+					imports:  usedImports(fn.Type, fileImports),
+				})
+			}
+		}
+	}
+
+	sort.Slice(funcs, func(i, j int) bool {
+		return funcs[i].name < funcs[j].name
+	})
+	sort.Slice(structs, func(i, j int) bool {
+		return structs[i].name < structs[j].name
+	})
+
+	return pkgName, funcs, structs, nil
+}
+
+// parseStructs returns the exported, non-generic structs declared in a
+// type declaration, with their exported named fields
+func parseStructs(fset *token.FileSet, gen *ast.GenDecl, fileImports map[string]string) ([]tracedStruct, error) {
+	var structs []tracedStruct
+	for _, spec := range gen.Specs {
+		typeSpec := spec.(*ast.TypeSpec)
+		structType, ok := typeSpec.Type.(*ast.StructType)
+		if !ok || !typeSpec.Name.IsExported() || typeSpec.TypeParams != nil {
+			continue
+		}
+
+		ts := tracedStruct{name: typeSpec.Name.Name, imports: make(map[string]string)}
+		for _, field := range structType.Fields.List {
+			var buf bytes.Buffer
+			if err := printer.Fprint(&buf, fset, field.Type); err != nil {
+				return nil, fmt.Errorf("failed to print %s field type: %w", ts.name, err)
+			}
+			// Embedded fields have no name of their own to trace
+			for _, ident := range field.Names {
+				if !ident.IsExported() {
+					continue
+				}
+				ts.fields = append(ts.fields, tracedField{name: ident.Name, fieldType: buf.String()})
+				for name, spec := range usedImports(field.Type, fileImports) {
+					ts.imports[name] = spec
+				}
+			}
+		}
+		structs = append(structs, ts)
+	}
+	return structs, nil
+}
+
+// paramNames lists the parameter names of a signature, using argN for
+// unnamed and blank parameters like lens does at runtime
+func paramNames(fields *ast.FieldList) []string {
+	var names []string
+	for _, field := range fields.List {
+		if len(field.Names) == 0 {
+			names = append(names, fmt.Sprintf("arg%d", len(names)))
+			continue
+		}
+		for _, ident := range field.Names {
+			if ident.Name == "_" {
+				names = append(names, fmt.Sprintf("arg%d", len(names)))
+			} else {
+				names = append(names, ident.Name)
+			}
+		}
+	}
+	return names
+}
+
+// importsByName maps the local name of each import in file to its import spec
+func importsByName(file *ast.File) map[string]string {
+	imports := make(map[string]string)
+	for _, spec := range file.Imports {
+		path := strings.Trim(spec.Path.Value, `"`)
+		name := path[strings.LastIndex(path, "/")+1:]
+		importSpec := spec.Path.Value
+		if spec.Name != nil {
+			name = spec.Name.Name
+			importSpec = name + " " + spec.Path.Value
+		}
+		imports[name] = importSpec
+	}
+	return imports
+}
+
+// usedImports returns the imports referenced by qualified identifiers in node
+func usedImports(node ast.Node, fileImports map[string]string) map[string]string {
+	used := make(map[string]string)
+	ast.Inspect(node, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if ident, ok := sel.X.(*ast.Ident); ok {
+			if spec, ok := fileImports[ident.Name]; ok {
+				used[ident.Name] = spec
+			}
+		}
+		return true
+	})
+	return used
+}
+
+// selectFuncs filters the found functions by the -all / -funcs flags
+func selectFuncs(found []tracedFunc, all bool, names string) ([]tracedFunc, error) {
+	if all {
+		return found, nil
+	}
+
+	byName := make(map[string]tracedFunc, len(found))
+	for _, fn := range found {
+		byName[fn.name] = fn
+	}
+
+	var selected []tracedFunc
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		fn, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("exported function %q not found", name)
+		}
+		selected = append(selected, fn)
+	}
+	return selected, nil
+}
+
+// selectStructs filters the found structs by the -structs flag
+func selectStructs(found []tracedStruct, names string) ([]tracedStruct, error) {
+	byName := make(map[string]tracedStruct, len(found))
+	for _, st := range found {
+		byName[st.name] = st
+	}
+
+	var selected []tracedStruct
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		st, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("exported struct %q not found", name)
+		}
+		selected = append(selected, st)
+	}
+	return selected, nil
+}
+
+// generate renders the traced wrapper file
+func generate(pkgName string, funcs []tracedFunc, structs []tracedStruct) ([]byte, error) {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "// Code generated by lensgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkgName)
+	imports := map[string]bool{`"github.com/baretech/lens"`: true}
+	for _, fn := range funcs {
+		for _, spec := range fn.imports {
+			imports[spec] = true
+		}
+	}
+	for _, st := range structs {
+		for _, spec := range st.imports {
+			imports[spec] = true
+		}
+	}
+	specs := make([]string, 0, len(imports))
+	for spec := range imports {
+		specs = append(specs, spec)
+	}
+	sort.Strings(specs)
+
+	fmt.Fprintf(&b, "import (\n")
+	for _, spec := range specs {
+		fmt.Fprintf(&b, "\t%s\n", spec)
+	}
+	fmt.Fprintf(&b, ")\n\n")
+
+	// Registered names let lens.WithParamNames work without the source
+	var registrations []string
+	for _, fn := range funcs {
+		if len(fn.params) == 0 {
+			continue
+		}
+		quoted := make([]string, len(fn.params))
+		for i, param := range fn.params {
+			quoted[i] = fmt.Sprintf("%q", param)
+		}
+		registrations = append(registrations, fmt.Sprintf("lens.RegisterParamNames(%s, %s)", fn.name, strings.Join(quoted, ", ")))
+	}
+	if len(registrations) > 0 {
+		fmt.Fprintf(&b, "func init() {\n")
+		for _, registration := range registrations {
+			fmt.Fprintf(&b, "\t%s\n", registration)
+		}
+		fmt.Fprintf(&b, "}\n\n")
+	}
+
+	if len(funcs) > 0 {
+		fmt.Fprintf(&b, "// Traced holds lens-wrapped versions of the package's exported functions\n")
+		fmt.Fprintf(&b, "type Traced struct {\n")
+		for _, fn := range funcs {
+			fmt.Fprintf(&b, "\t%s %s\n", fn.name, fn.funcType)
+		}
+		fmt.Fprintf(&b, "}\n\n")
+
+		fmt.Fprintf(&b, "// NewTraced wraps every function with tracer under the %q component\n", pkgName)
+		fmt.Fprintf(&b, "func NewTraced(tracer lens.Tracer) *Traced {\n")
+		fmt.Fprintf(&b, "\treturn &Traced{\n")
+		for _, fn := range funcs {
+			fmt.Fprintf(&b, "\t\t%s: tracer.WrapWithName(%s, %q).(%s),\n", fn.name, fn.name, pkgName, fn.funcType)
+		}
+		fmt.Fprintf(&b, "\t}\n")
+		fmt.Fprintf(&b, "}\n\n")
+	}
+
+	for _, st := range structs {
+		generateAccessors(&b, st)
+	}
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format generated code: %w", err)
+	}
+	return src, nil
+}
+
+// generateAccessors renders the traced accessors of a struct
+func generateAccessors(b *bytes.Buffer, st tracedStruct) {
+	accessors := "Traced" + st.name
+	fmt.Fprintf(b, "// %s traces reads and writes of the exported fields of %s\n", accessors, st.name)
+	fmt.Fprintf(b, "type %s struct {\n", accessors)
+	fmt.Fprintf(b, "\tv      *%s\n", st.name)
+	fmt.Fprintf(b, "\ttracer lens.Tracer\n")
+	fmt.Fprintf(b, "\t// Traces reads, if tracer can\n")
+	fmt.Fprintf(b, "\tfields lens.FieldAccessTracer\n")
+	fmt.Fprintf(b, "}\n\n")
+
+	fmt.Fprintf(b, "// Trace%s returns traced accessors for the fields of v\n", st.name)
+	fmt.Fprintf(b, "func Trace%s(tracer lens.Tracer, v *%s) *%s {\n", st.name, st.name, accessors)
+	fmt.Fprintf(b, "\tfields, _ := tracer.(lens.FieldAccessTracer)\n")
+	fmt.Fprintf(b, "\treturn &%s{v: v, tracer: tracer, fields: fields}\n", accessors)
+	fmt.Fprintf(b, "}\n\n")
+
+	for _, field := range st.fields {
+		variable := st.name + "." + field.name
+
+		fmt.Fprintf(b, "// %s returns %s, tracing a field access\n", field.name, variable)
+		fmt.Fprintf(b, "func (a *%s) %s() %s {\n", accessors, field.name, field.fieldType)
+		fmt.Fprintf(b, "\tif a.fields != nil {\n")
+		fmt.Fprintf(b, "\t\ta.fields.TraceFieldAccess(%q, a.v.%s)\n", variable, field.name)
+		fmt.Fprintf(b, "\t}\n")
+		fmt.Fprintf(b, "\treturn a.v.%s\n", field.name)
+		fmt.Fprintf(b, "}\n\n")
+
+		fmt.Fprintf(b, "// Set%s sets %s, tracing a variable write\n", field.name, variable)
+		fmt.Fprintf(b, "func (a *%s) Set%s(value %s) {\n", accessors, field.name, field.fieldType)
+		fmt.Fprintf(b, "\told := a.v.%s\n", field.name)
+		fmt.Fprintf(b, "\ta.v.%s = value\n", field.name)
+		fmt.Fprintf(b, "\ta.tracer.TraceVariable(%q, old, value)\n", variable)
+		fmt.Fprintf(b, "}\n\n")
+	}
+}
@@ -0,0 +1,128 @@
+package lens
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+)
+
+// CompressionStream compresses what is written to it. Flush writes
+// everything written so far as a complete block, so a reader of the
+// partially written output can decompress it, and Close ends the stream.
+// gzip.Writer and the zstd encoders of common libraries implement it.
+type CompressionStream interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// Compressor starts a compression stream writing to w. Lens has no zstd
+// implementation of its own; adapt the encoder you already use:
+//
+//	func(w io.Writer) (lens.CompressionStream, error) { return zstd.NewWriter(w) }
+type Compressor func(w io.Writer) (CompressionStream, error)
+
+// Gzip returns a compressor writing gzip at level, e.g.
+// gzip.DefaultCompression
+func Gzip(level int) Compressor {
+	return func(w io.Writer) (CompressionStream, error) {
+		return gzip.NewWriterLevel(w, level)
+	}
+}
+
+// compressible is implemented by the writers whose file output
+// NewCompressedWriter can compress
+type compressible interface {
+	Writer
+	compress(compressor Compressor) error
+}
+
+// CompressedWriter is a file-based writer whose output is compressed as
+// it is streamed to disk, which cuts the size of verbose traces many
+// times over. Flush ends a compressed block, so the file written so far
+// decompresses even while the writer is still running. Writing to a file
+// that already holds uncompressed events makes it unreadable; use a new
+// file, or one compressed the same way.
+type CompressedWriter struct {
+	inner Writer
+}
+
+// NewCompressedWriter compresses the output of inner, which must be a
+// JSONFileWriter or CSVWriter with nothing written yet
+func NewCompressedWriter(inner Writer, compressor Compressor) (*CompressedWriter, error) {
+	target, ok := inner.(compressible)
+	if !ok {
+		return nil, fmt.Errorf("%T does not write to a file", inner)
+	}
+	if err := target.compress(compressor); err != nil {
+		return nil, err
+	}
+	return &CompressedWriter{inner: inner}, nil
+}
+
+// Write writes an event to the inner writer
+func (w *CompressedWriter) Write(event Event) error {
+	return w.inner.Write(event)
+}
+
+// Flush flushes the inner writer, ending a compressed block
+func (w *CompressedWriter) Flush() error {
+	return w.inner.Flush()
+}
+
+// Close closes the inner writer, ending the compressed stream
+func (w *CompressedWriter) Close() error {
+	return w.inner.Close()
+}
+
+// fileOutput is the file of a file-based writer, written through a
+// compression stream once one is set
+type fileOutput struct {
+	file   *os.File
+	stream CompressionStream
+}
+
+// compress starts compressing what is written from now on
+func (o *fileOutput) compress(compressor Compressor) error {
+	if o.stream != nil {
+		return fmt.Errorf("output is already compressed")
+	}
+	stream, err := compressor(o.file)
+	if err != nil {
+		return fmt.Errorf("failed to start compression: %w", err)
+	}
+	o.stream = stream
+	return nil
+}
+
+// Write writes to the file, compressed if a stream is set
+func (o *fileOutput) Write(p []byte) (int, error) {
+	if o.stream != nil {
+		return o.stream.Write(p)
+	}
+	return o.file.Write(p)
+}
+
+// Sync ends the current compressed block and commits the file to disk
+func (o *fileOutput) Sync() error {
+	if o.stream != nil {
+		if err := o.stream.Flush(); err != nil {
+			return fmt.Errorf("failed to flush compressed output: %w", err)
+		}
+	}
+	return o.file.Sync()
+}
+
+// Close ends the compressed stream and closes the file
+func (o *fileOutput) Close() error {
+	var err error
+	if o.stream != nil {
+		if err = o.stream.Close(); err != nil {
+			err = fmt.Errorf("failed to close compressed output: %w", err)
+		}
+	}
+	if closeErr := o.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
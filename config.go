@@ -0,0 +1,572 @@
+package lens
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config describes a tracer in a form that can be loaded from a file or
+// the environment
+type Config struct {
+	Enabled   *bool           `json:"enabled,omitempty"`
+	Level     string          `json:"level,omitempty"`
+	Writers   []WriterConfig  `json:"writers,omitempty"`
+	Filters   FilterConfig    `json:"filters"`
+	Sampling  SamplingConfig  `json:"sampling"`
+	Redaction RedactionConfig `json:"redaction"`
+}
+
+// WriterConfig describes a single writer. Type is one of console, json,
+// sharded, syslog, journald, ring or stats; the other fields apply
+// depending on it.
+type WriterConfig struct {
+	Type     string `json:"type"`
+	Path     string `json:"path,omitempty"`
+	Colored  *bool  `json:"colored,omitempty"`
+	Network  string `json:"network,omitempty"`
+	Address  string `json:"address,omitempty"`
+	Facility int    `json:"facility,omitempty"`
+	Capacity int    `json:"capacity,omitempty"`
+	// Template for the console writer, see NewConsoleWriterWithTemplate
+	Template string `json:"template,omitempty"`
+	// Value rendering and repeat collapsing for the console writer
+	MaxValueWidth   int  `json:"max_value_width,omitempty"`
+	MaxElements     int  `json:"max_elements,omitempty"`
+	PrettyStructs   bool `json:"pretty_structs,omitempty"`
+	CollapseRepeats bool `json:"collapse_repeats,omitempty"`
+	// Batching for the json writer
+	BatchSize     int    `json:"batch_size,omitempty"`
+	FlushInterval string `json:"flush_interval,omitempty"`
+	// Hourly directories for the sharded writer, whose Path is a directory
+	ShardByHour bool `json:"shard_by_hour,omitempty"`
+}
+
+// FilterConfig describes the filters applied to events
+type FilterConfig struct {
+	IncludePackages  []string `json:"include_packages,omitempty"`
+	ExcludePackages  []string `json:"exclude_packages,omitempty"`
+	IncludeFunctions []string `json:"include_functions,omitempty"`
+	ExcludeFunctions []string `json:"exclude_functions,omitempty"`
+	IncludeSources   []string `json:"include_sources,omitempty"`
+	ExcludeSources   []string `json:"exclude_sources,omitempty"`
+	MinDuration      string   `json:"min_duration,omitempty"`
+	EventTypes       []string `json:"event_types,omitempty"`
+	ExcludeNoise     bool     `json:"exclude_noise,omitempty"`
+	// Expr keeps events matching an expression, see NewExprFilter
+	Expr string `json:"expr,omitempty"`
+	// TimeWindows only allow tracing within windows, read in TimeZone,
+	// see ParseTimeWindows
+	TimeWindows []string `json:"time_windows,omitempty"`
+	TimeZone    string   `json:"time_zone,omitempty"`
+}
+
+// SamplingConfig describes trace sampling
+type SamplingConfig struct {
+	Rate *float64 `json:"rate,omitempty"`
+}
+
+// RedactionConfig describes values masked before events are written
+type RedactionConfig struct {
+	Functions   []string `json:"functions,omitempty"`
+	Variables   []string `json:"variables,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Replacement string   `json:"replacement,omitempty"`
+}
+
+// configComponents holds what a Config contributed to a tracer, so a
+// reload can replace it without touching writers and filters added in code
+type configComponents struct {
+	// Level and enabled as set by the config, or nil to keep the tracer's
+	level      *Level
+	enabled    *bool
+	writers    []Writer
+	filters    []Filter
+	processors []Processor
+}
+
+// LoadConfig reads a configuration file. Files ending in .yaml or .yml
+// are parsed as YAML, everything else as JSON.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		value, err := parseYAML(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+		}
+		if data, err = json.Marshal(value); err != nil {
+			return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+		}
+	}
+
+	config := &Config{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// ConfigFromEnv builds a configuration from LENS_* environment variables.
+// If LENS_CONFIG names a file it is loaded first and the other variables
+// override it:
+//
+//	LENS_ENABLED            true or false
+//	LENS_LEVEL              off, error, warn, info, debug or trace
+//	LENS_WRITERS            comma-separated type[:arg], e.g.
+//	                        console,json:/var/log/lens.json,syslog:udp://localhost:514,ring:1000
+//	LENS_INCLUDE_PACKAGES   comma-separated patterns, likewise
+//	LENS_EXCLUDE_PACKAGES   LENS_INCLUDE_FUNCTIONS, LENS_EXCLUDE_FUNCTIONS,
+//	                        LENS_INCLUDE_SOURCES and LENS_EXCLUDE_SOURCES
+//	LENS_MIN_DURATION       e.g. 10ms
+//	LENS_EVENT_TYPES        comma-separated event types
+//	LENS_EXCLUDE_NOISE      true or false
+//	LENS_SAMPLE_RATE        fraction of traces to keep, 0 to 1
+//	LENS_REDACT_FUNCTIONS   comma-separated patterns, likewise
+//	LENS_REDACT_VARIABLES   and LENS_REDACT_TAGS
+func ConfigFromEnv() (*Config, error) {
+	config := &Config{}
+	if path := os.Getenv("LENS_CONFIG"); path != "" {
+		loaded, err := LoadConfig(path)
+		if err != nil {
+			return nil, err
+		}
+		config = loaded
+	}
+
+	if value, ok := os.LookupEnv("LENS_ENABLED"); ok {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LENS_ENABLED: %w", err)
+		}
+		config.Enabled = &enabled
+	}
+	if value, ok := os.LookupEnv("LENS_LEVEL"); ok {
+		config.Level = value
+	}
+	if value, ok := os.LookupEnv("LENS_WRITERS"); ok {
+		writers, err := parseWriterList(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LENS_WRITERS: %w", err)
+		}
+		config.Writers = writers
+	}
+
+	lists := map[string]*[]string{
+		"LENS_INCLUDE_PACKAGES":  &config.Filters.IncludePackages,
+		"LENS_EXCLUDE_PACKAGES":  &config.Filters.ExcludePackages,
+		"LENS_INCLUDE_FUNCTIONS": &config.Filters.IncludeFunctions,
+		"LENS_EXCLUDE_FUNCTIONS": &config.Filters.ExcludeFunctions,
+		"LENS_INCLUDE_SOURCES":   &config.Filters.IncludeSources,
+		"LENS_EXCLUDE_SOURCES":   &config.Filters.ExcludeSources,
+		"LENS_EVENT_TYPES":       &config.Filters.EventTypes,
+		"LENS_REDACT_FUNCTIONS":  &config.Redaction.Functions,
+		"LENS_REDACT_VARIABLES":  &config.Redaction.Variables,
+		"LENS_REDACT_TAGS":       &config.Redaction.Tags,
+	}
+	for name, target := range lists {
+		if value, ok := os.LookupEnv(name); ok {
+			*target = splitList(value)
+		}
+	}
+
+	if value, ok := os.LookupEnv("LENS_MIN_DURATION"); ok {
+		config.Filters.MinDuration = value
+	}
+	if value, ok := os.LookupEnv("LENS_EXCLUDE_NOISE"); ok {
+		noise, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LENS_EXCLUDE_NOISE: %w", err)
+		}
+		config.Filters.ExcludeNoise = noise
+	}
+	if value, ok := os.LookupEnv("LENS_SAMPLE_RATE"); ok {
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LENS_SAMPLE_RATE: %w", err)
+		}
+		config.Sampling.Rate = &rate
+	}
+
+	return config, nil
+}
+
+// parseWriterList parses the LENS_WRITERS syntax
+func parseWriterList(value string) ([]WriterConfig, error) {
+	var writers []WriterConfig
+	for _, item := range splitList(value) {
+		kind, arg, _ := strings.Cut(item, ":")
+		writer := WriterConfig{Type: kind}
+
+		switch kind {
+		case "console":
+			if arg == "plain" {
+				colored := false
+				writer.Colored = &colored
+			}
+		case "json":
+			writer.Path = arg
+		case "syslog":
+			network, address, ok := strings.Cut(arg, "://")
+			if !ok {
+				return nil, fmt.Errorf("syslog writer needs network://address, got %q", arg)
+			}
+			writer.Network, writer.Address = network, address
+		case "ring":
+			if arg != "" {
+				capacity, err := strconv.Atoi(arg)
+				if err != nil {
+					return nil, fmt.Errorf("invalid ring capacity %q", arg)
+				}
+				writer.Capacity = capacity
+			}
+		}
+
+		writers = append(writers, writer)
+	}
+	return writers, nil
+}
+
+// splitList splits a comma-separated list, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// build creates the writers, filters and processors described by the config
+func (c *Config) build() (*configComponents, error) {
+	components := &configComponents{enabled: c.Enabled}
+	if c.Level != "" {
+		level, err := ParseLevel(c.Level)
+		if err != nil {
+			return nil, err
+		}
+		components.level = &level
+	}
+
+	for _, wc := range c.Writers {
+		writer, err := wc.build()
+		if err != nil {
+			components.close()
+			return nil, err
+		}
+		components.writers = append(components.writers, writer)
+	}
+
+	filters, err := c.Filters.build()
+	if err != nil {
+		components.close()
+		return nil, err
+	}
+	components.filters = filters
+
+	if c.Sampling.Rate != nil {
+		if *c.Sampling.Rate < 0 || *c.Sampling.Rate > 1 {
+			components.close()
+			return nil, fmt.Errorf("sampling rate must be between 0 and 1, got %v", *c.Sampling.Rate)
+		}
+		components.filters = append(components.filters, Sample(*c.Sampling.Rate))
+	}
+
+	r := c.Redaction
+	if len(r.Functions) > 0 || len(r.Variables) > 0 || len(r.Tags) > 0 {
+		redaction := NewRedactionProcessor().
+			RedactFunctions(r.Functions...).
+			RedactVariables(r.Variables...).
+			RedactTags(r.Tags...)
+		if r.Replacement != "" {
+			redaction.WithReplacement(r.Replacement)
+		}
+		components.processors = append(components.processors, redaction)
+	}
+
+	return components, nil
+}
+
+// build creates the writer described by the config
+func (c WriterConfig) build() (Writer, error) {
+	switch c.Type {
+	case "console":
+		var options []ConsoleWriterOption
+		if c.MaxValueWidth > 0 {
+			options = append(options, WithConsoleMaxValueWidth(c.MaxValueWidth))
+		}
+		if c.MaxElements > 0 {
+			options = append(options, WithConsoleMaxElements(c.MaxElements))
+		}
+		if c.PrettyStructs {
+			options = append(options, WithConsolePrettyStructs())
+		}
+		if c.CollapseRepeats {
+			options = append(options, WithConsoleCollapseRepeats())
+		}
+		if c.Template != "" {
+			return NewConsoleWriterWithTemplate(c.Template, options...)
+		}
+		colored := true
+		if c.Colored != nil {
+			colored = *c.Colored
+		}
+		return NewConsoleWriter(colored, options...), nil
+	case "json":
+		if c.Path == "" {
+			return nil, fmt.Errorf("json writer needs a path")
+		}
+		options := []JSONFileWriterOption{WithJSONBatchSize(c.BatchSize)}
+		if c.FlushInterval != "" {
+			interval, err := time.ParseDuration(c.FlushInterval)
+			if err != nil {
+				return nil, fmt.Errorf("invalid flush_interval: %w", err)
+			}
+			options = append(options, WithJSONFlushInterval(interval))
+		}
+		return NewJSONFileWriter(c.Path, options...)
+	case "sharded":
+		if c.Path == "" {
+			return nil, fmt.Errorf("sharded writer needs a path")
+		}
+		var options []TraceShardedWriterOption
+		if c.ShardByHour {
+			options = append(options, WithShardByHour())
+		}
+		return NewTraceShardedWriter(c.Path, options...)
+	case "syslog":
+		facility := FacilityUser
+		if c.Facility != 0 {
+			facility = SyslogFacility(c.Facility)
+		}
+		return NewSyslogWriter(c.Network, c.Address, facility)
+	case "journald":
+		return NewJournaldWriter()
+	case "ring":
+		capacity := c.Capacity
+		if capacity <= 0 {
+			capacity = 1000
+		}
+		return NewRingBufferWriter(capacity), nil
+	case "stats":
+		return NewStatsCollector(), nil
+	}
+	return nil, fmt.Errorf("unknown writer type %q", c.Type)
+}
+
+// build creates the filters described by the config
+func (c FilterConfig) build() ([]Filter, error) {
+	var filters []Filter
+
+	if len(c.IncludePackages) > 0 || len(c.ExcludePackages) > 0 {
+		filters = append(filters, NewPackageFilter().
+			IncludePackages(c.IncludePackages...).
+			ExcludePackages(c.ExcludePackages...))
+	}
+	if len(c.IncludeFunctions) > 0 || len(c.ExcludeFunctions) > 0 {
+		filters = append(filters, NewFunctionFilter().
+			IncludeFunctions(c.IncludeFunctions...).
+			ExcludeFunctions(c.ExcludeFunctions...))
+	}
+	if len(c.IncludeSources) > 0 || len(c.ExcludeSources) > 0 {
+		filters = append(filters, NewSourceFilter().
+			IncludeSources(c.IncludeSources...).
+			ExcludeSources(c.ExcludeSources...))
+	}
+	if c.MinDuration != "" {
+		duration, err := time.ParseDuration(c.MinDuration)
+		if err != nil {
+			return nil, fmt.Errorf("invalid min_duration: %w", err)
+		}
+		filters = append(filters, MinDuration(duration))
+	}
+	if len(c.EventTypes) > 0 {
+		types := make([]EventType, len(c.EventTypes))
+		for i, name := range c.EventTypes {
+			types[i] = EventType(name)
+		}
+		filters = append(filters, OnlyEventTypes(types...))
+	}
+	if c.Expr != "" {
+		filter, err := NewExprFilter(c.Expr)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	if c.ExcludeNoise {
+		filters = append(filters, ExcludeCommonNoise())
+	}
+	if len(c.TimeWindows) > 0 {
+		location := time.Local
+		if c.TimeZone != "" {
+			var err error
+			if location, err = time.LoadLocation(c.TimeZone); err != nil {
+				return nil, fmt.Errorf("invalid time_zone: %w", err)
+			}
+		}
+		filter, err := ParseTimeWindows(location, c.TimeWindows...)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+
+	return filters, nil
+}
+
+// close closes every writer created for the config
+func (c *configComponents) close() {
+	for _, writer := range c.writers {
+		writer.Flush()
+		writer.Close()
+	}
+}
+
+// NewFromConfig creates a tracer from a configuration file. Options are
+// applied before the configuration.
+func NewFromConfig(path string, options ...Option) (*TracerImpl, error) {
+	config, err := LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return newFromConfig(config, options)
+}
+
+// FromEnv creates a tracer configured by LENS_* environment variables
+// (see ConfigFromEnv). Options are applied before the configuration.
+func FromEnv(options ...Option) (*TracerImpl, error) {
+	config, err := ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return newFromConfig(config, options)
+}
+
+// newFromConfig creates a tracer and applies config to it. A tracer the
+// config cannot be applied to is closed, stopping its goroutines.
+func newFromConfig(config *Config, options []Option) (*TracerImpl, error) {
+	tracer := New(options...)
+	if err := tracer.ApplyConfig(config); err != nil {
+		return nil, errors.Join(err, tracer.Close(context.Background()))
+	}
+	return tracer, nil
+}
+
+// ApplyConfig applies a configuration to the tracer. Writers, filters and
+// processors from a previously applied configuration are replaced, and the
+// replaced writers are flushed and closed; those added in code are kept.
+// The level and enabled state only change if the configuration sets them.
+// On error the tracer is left unchanged.
+func (t *TracerImpl) ApplyConfig(config *Config) error {
+	components, err := config.build()
+	if err != nil {
+		return fmt.Errorf("failed to apply config: %w", err)
+	}
+
+	t.mutex.Lock()
+	previous := t.configured
+	if previous != nil {
+		t.writers = removeItems(t.writers, previous.writers)
+		t.filters = removeItems(t.filters, previous.filters)
+		t.processors = removeItems(t.processors, previous.processors)
+	}
+	if components.level != nil {
+		t.level = *components.level
+	}
+	if components.enabled != nil {
+		t.enabled = *components.enabled && !compiledOut
+	}
+	t.writers = appendCopy(t.writers, components.writers...)
+	t.filters = appendCopy(t.filters, components.filters...)
+	t.processors = appendCopy(t.processors, components.processors...)
+	t.configured = components
+	t.mutex.Unlock()
+
+	if previous != nil {
+		t.dispatcher.remove(previous.writers)
+		previous.close()
+	}
+	return nil
+}
+
+// removeItems returns items without any element of remove
+func removeItems[T comparable](items, remove []T) []T {
+	kept := make([]T, 0, len(items))
+	for _, item := range items {
+		found := false
+		for _, r := range remove {
+			if item == r {
+				found = true
+				break
+			}
+		}
+		if !found {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// ReloadOnSignal reloads the configuration file at path whenever one of
+// sigs is received. A configuration that fails to load is reported as an
+// error event and the current one is kept. The returned function stops
+// listening.
+func (t *TracerImpl) ReloadOnSignal(path string, sigs ...os.Signal) func() {
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sigs...)
+
+	go func() {
+		for {
+			select {
+			case <-ch:
+				t.reloadConfig(path)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
+
+// reloadConfig loads and applies the configuration file at path
+func (t *TracerImpl) reloadConfig(path string) {
+	config, err := LoadConfig(path)
+	if err == nil {
+		err = t.ApplyConfig(config)
+	}
+	if err != nil {
+		t.TraceEvent(Event{
+			ID:        generateEventID(),
+			TraceID:   generateTraceID(),
+			Timestamp: time.Now(),
+			Type:      EventError,
+			Component: "lens.config",
+			Error:     err.Error(),
+			Goroutine: getGoroutineID(),
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package lens
+
+import "testing"
+
+// closeWriter records whether it was closed
+type closeWriter struct {
+	closed bool
+}
+
+func (w *closeWriter) Write(Event) error { return nil }
+func (w *closeWriter) Flush() error      { return nil }
+func (w *closeWriter) Close() error      { w.closed = true; return nil }
+
+func TestNewFromConfigClosesTracerOnError(t *testing.T) {
+	writer := &closeWriter{}
+	config := &Config{Writers: []WriterConfig{{Type: "unknown"}}}
+
+	tracer, err := newFromConfig(config, []Option{WithWriter(writer)})
+	if err == nil {
+		t.Fatal("newFromConfig accepted an unknown writer type")
+	}
+	if tracer != nil {
+		t.Error("newFromConfig returned a tracer with its error")
+	}
+	if !writer.closed {
+		t.Error("the tracer created for the config was not closed")
+	}
+}
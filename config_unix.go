@@ -0,0 +1,12 @@
+//go:build unix
+
+package lens
+
+import "syscall"
+
+// ReloadOnSIGHUP reloads the configuration file at path whenever the
+// process receives SIGHUP (kill -HUP <pid>). The returned function stops
+// listening.
+func (t *TracerImpl) ReloadOnSIGHUP(path string) func() {
+	return t.ReloadOnSignal(path, syscall.SIGHUP)
+}
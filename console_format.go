@@ -0,0 +1,246 @@
+package lens
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ConsoleWriterOption configures a ConsoleWriter
+type ConsoleWriterOption func(*ConsoleWriter)
+
+// WithConsoleMaxValueWidth shortens each argument, result, variable and
+// tag value to n characters, ending in "..."
+func WithConsoleMaxValueWidth(n int) ConsoleWriterOption {
+	return func(w *ConsoleWriter) {
+		w.format.maxWidth = n
+	}
+}
+
+// WithConsoleMaxElements shows at most n elements of slices, arrays and
+// maps, followed by the number left out, e.g. [1 2 3 ...+997]
+func WithConsoleMaxElements(n int) ConsoleWriterOption {
+	return func(w *ConsoleWriter) {
+		w.format.maxElements = n
+	}
+}
+
+// WithConsolePrettyStructs prints structs over several lines, one named
+// field per line, indented under the event
+func WithConsolePrettyStructs() ConsoleWriterOption {
+	return func(w *ConsoleWriter) {
+		w.format.pretty = true
+	}
+}
+
+// WithConsoleCollapseRepeats prints an event repeated back to back once,
+// followed by a "repeated xN" line when a different event arrives or the
+// writer is flushed. Events are identical when they only differ in ID,
+// timestamp, duration and allocations.
+func WithConsoleCollapseRepeats() ConsoleWriterOption {
+	return func(w *ConsoleWriter) {
+		w.collapse = true
+	}
+}
+
+// valueFormat controls how the console renders recorded values. The zero
+// value renders them with %v.
+type valueFormat struct {
+	maxWidth    int
+	maxElements int
+	pretty      bool
+}
+
+// value formats a single value
+func (f valueFormat) value(value interface{}) string {
+	if f.maxElements <= 0 && !f.pretty {
+		return f.truncate(fmt.Sprint(value))
+	}
+	var b strings.Builder
+	f.write(&b, reflect.ValueOf(value), 0)
+	return f.truncate(b.String())
+}
+
+// values formats a list of arguments or results
+func (f valueFormat) values(values []interface{}) string {
+	if f == (valueFormat{}) {
+		return fmt.Sprint(values)
+	}
+	formatted := make([]string, len(values))
+	for i, value := range values {
+		formatted[i] = f.value(value)
+	}
+	return "[" + strings.Join(formatted, " ") + "]"
+}
+
+// changes formats the fields changed in a state diff or variable write
+func (f valueFormat) changes(changes []FieldChange) []string {
+	formatted := make([]string, len(changes))
+	for i, change := range changes {
+		formatted[i] = fmt.Sprintf("%s: %s -> %s", change.Path, f.value(change.Old), f.value(change.New))
+	}
+	return formatted
+}
+
+// write renders v like %v, limiting elements and laying out structs over
+// several lines as configured
+func (f valueFormat) write(b *strings.Builder, v reflect.Value, depth int) {
+	if !v.IsValid() {
+		b.WriteString("<nil>")
+		return
+	}
+	if v.CanInterface() {
+		switch v.Interface().(type) {
+		case error, fmt.Stringer:
+			fmt.Fprint(b, v.Interface())
+			return
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		f.write(b, v.Elem(), depth)
+	case reflect.Pointer:
+		// Like %v, only follow the outermost pointer
+		if depth > 0 || v.IsNil() {
+			fmt.Fprintf(b, "%v", v)
+			return
+		}
+		switch v.Elem().Kind() {
+		case reflect.Struct, reflect.Slice, reflect.Array, reflect.Map:
+			b.WriteByte('&')
+			f.write(b, v.Elem(), depth)
+		default:
+			fmt.Fprintf(b, "%v", v)
+		}
+	case reflect.Slice, reflect.Array:
+		shown := f.limit(v.Len())
+		b.WriteByte('[')
+		for i := 0; i < shown; i++ {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			f.write(b, v.Index(i), depth+1)
+		}
+		f.writeMore(b, v.Len()-shown)
+		b.WriteByte(']')
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+		})
+		shown := f.limit(len(keys))
+		b.WriteString("map[")
+		for i, key := range keys[:shown] {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			f.write(b, key, depth+1)
+			b.WriteByte(':')
+			f.write(b, v.MapIndex(key), depth+1)
+		}
+		f.writeMore(b, len(keys)-shown)
+		b.WriteByte(']')
+	case reflect.Struct:
+		f.writeStruct(b, v, depth)
+	default:
+		fmt.Fprintf(b, "%v", v)
+	}
+}
+
+// writeStruct renders a struct on one line, like %v, or in pretty mode
+// with one named field per line
+func (f valueFormat) writeStruct(b *strings.Builder, v reflect.Value, depth int) {
+	if !f.pretty || v.NumField() == 0 {
+		b.WriteByte('{')
+		for i := 0; i < v.NumField(); i++ {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			f.write(b, v.Field(i), depth+1)
+		}
+		b.WriteByte('}')
+		return
+	}
+
+	b.WriteString("{\n")
+	for i := 0; i < v.NumField(); i++ {
+		b.WriteString(strings.Repeat("  ", depth+1))
+		b.WriteString(v.Type().Field(i).Name)
+		b.WriteString(": ")
+		f.write(b, v.Field(i), depth+1)
+		b.WriteByte('\n')
+	}
+	b.WriteString(strings.Repeat("  ", depth))
+	b.WriteByte('}')
+}
+
+// limit returns how many of n elements to show
+func (f valueFormat) limit(n int) int {
+	if f.maxElements > 0 && n > f.maxElements {
+		return f.maxElements
+	}
+	return n
+}
+
+// writeMore notes elements left out
+func (f valueFormat) writeMore(b *strings.Builder, more int) {
+	if more > 0 {
+		fmt.Fprintf(b, " ...+%d", more)
+	}
+}
+
+// truncate shortens s to the maximum width, line by line in pretty mode
+func (f valueFormat) truncate(s string) string {
+	if f.maxWidth <= 0 {
+		return s
+	}
+	if f.pretty && strings.Contains(s, "\n") {
+		lines := strings.Split(s, "\n")
+		for i, line := range lines {
+			lines[i] = truncateString(line, f.maxWidth)
+		}
+		return strings.Join(lines, "\n")
+	}
+	return truncateString(s, f.maxWidth)
+}
+
+// truncateString shortens s to n characters, ending in "..."
+func truncateString(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	if n <= 3 {
+		return string(runes[:n])
+	}
+	return string(runes[:n-3]) + "..."
+}
+
+// repeatKey identifies an event for collapsing repeats
+func (w *ConsoleWriter) repeatKey(event Event) (string, error) {
+	event.ID = ""
+	event.Seq = 0
+	event.Timestamp = time.Time{}
+	event.Duration = 0
+	event.Allocs, event.AllocBytes = 0, 0
+	return w.formatEvent(event)
+}
+
+// writeRepeats prints how often the last event was repeated, if it was.
+// The caller holds the mutex.
+func (w *ConsoleWriter) writeRepeats() {
+	if w.repeats == 0 {
+		return
+	}
+	last := w.lastRepeat
+	line := fmt.Sprintf("[%s] %s... repeated x%d", last.Timestamp.Format("15:04:05.000"), indent(last), w.repeats)
+	if w.colored {
+		line = colorize(eventColor(last.Type), line)
+	}
+	fmt.Println(line)
+	w.repeats = 0
+}
@@ -0,0 +1,136 @@
+package lens
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// DefaultConsoleTemplate reproduces the plain console format
+const DefaultConsoleTemplate = `[{{.Timestamp.Format "15:04:05.000"}}] {{indent .}}{{.Type}} {{details .}}`
+
+// ANSI color codes used by the console writer
+var consoleColors = map[string]string{
+	"reset":  "\033[0m",
+	"red":    "\033[31m",
+	"green":  "\033[32m",
+	"yellow": "\033[33m",
+	"blue":   "\033[34m",
+	"purple": "\033[35m",
+	"cyan":   "\033[36m",
+}
+
+// eventColor returns the name of the color used for an event type
+func eventColor(eventType EventType) string {
+	switch eventType {
+	case EventError, EventPanic, EventBlocked, EventBudgetExceeded, EventRecursion:
+		return "red"
+	case EventFunctionCall, EventMethodCall:
+		return "blue"
+	case EventFunctionReturn:
+		return "green"
+	case EventVariableRead, EventVariableWrite, EventFieldAccess:
+		return "yellow"
+	default:
+		return "cyan"
+	}
+}
+
+// NewConsoleWriterWithTemplate creates a console writer that formats each
+// event with a text/template executed over the Event. Besides the Event
+// fields, templates can use:
+//
+//	color "red" s     wrap s in an ANSI color (red, green, yellow, blue, purple, cyan)
+//	colorFor . s      wrap s in the color of the event's type
+//	truncate n v      format v and shorten it to n characters
+//	relpath path      path relative to the working directory
+//	base path         last element of path
+//	short name        function name without its package path
+//	indent .          indentation for the event's call depth
+//	details .         the details of the default format
+//	tags .            the event's tags as key=value pairs
+//	json v            v encoded as JSON
+//
+// For example:
+//
+//	{{.Timestamp.Format "15:04:05"}} {{colorFor . .Type}} {{short .Function}} {{.Duration}}
+func NewConsoleWriterWithTemplate(tmpl string, options ...ConsoleWriterOption) (*ConsoleWriter, error) {
+	w := &ConsoleWriter{}
+	parsed, err := template.New("console").Funcs(w.templateFuncs()).Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse console template: %w", err)
+	}
+	w.template = parsed
+	for _, option := range options {
+		option(w)
+	}
+	return w, nil
+}
+
+// templateFuncs returns the helper functions available to console
+// templates. details and tags render values as the writer's options say.
+func (w *ConsoleWriter) templateFuncs() template.FuncMap {
+	wd, _ := os.Getwd()
+
+	return template.FuncMap{
+		"color": func(name string, value interface{}) string {
+			return colorize(name, fmt.Sprint(value))
+		},
+		"colorFor": func(event Event, value interface{}) string {
+			return colorize(eventColor(event.Type), fmt.Sprint(value))
+		},
+		"truncate": func(n int, value interface{}) string {
+			s := fmt.Sprint(value)
+			if n <= 3 || len(s) <= n {
+				return s
+			}
+			return s[:n-3] + "..."
+		},
+		"relpath": func(path string) string {
+			if wd == "" || path == "" {
+				return path
+			}
+			if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+				return rel
+			}
+			return path
+		},
+		"base": filepath.Base,
+		"short": func(name string) string {
+			return name[strings.LastIndex(name, "/")+1:]
+		},
+		"indent": indent,
+		"details": func(event Event) string {
+			return w.format.details(event)
+		},
+		"tags": func(event Event) string {
+			return strings.TrimPrefix(w.format.tags(event), " ")
+		},
+		"json": func(value interface{}) (string, error) {
+			data, err := json.Marshal(value)
+			return string(data), err
+		},
+	}
+}
+
+// colorize wraps s in the named ANSI color
+func colorize(name, s string) string {
+	code, ok := consoleColors[name]
+	if !ok {
+		return s
+	}
+	return code + s + consoleColors["reset"]
+}
+
+// formatTemplate formats an event with the writer's template
+func (w *ConsoleWriter) formatTemplate(event Event) (string, error) {
+	var b bytes.Buffer
+	if err := w.template.Execute(&b, event); err != nil {
+		return "", fmt.Errorf("failed to execute console template: %w", err)
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
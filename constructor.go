@@ -0,0 +1,72 @@
+package lens
+
+import "reflect"
+
+// WrapConstructor wraps a constructor, e.g.
+//
+//	newCalculator := tracer.WrapConstructor(NewCalculator).(func(int) *Calculator)
+//
+// Calls to it are traced as for Wrap, and the objects it returns are
+// wrapped under their type name, so every object a factory produces is
+// traced without code at its call sites: pointers to structs are wrapped
+// with WrapDeep, and results of an interface type registered with
+// RegisterProxy are replaced by their proxy. Other results, such as
+// errors, are returned unchanged. Anything but a func is returned
+// unchanged.
+func (t *TracerImpl) WrapConstructor(constructor interface{}) interface{} {
+	if compiledOut || !t.enabled {
+		return constructor
+	}
+	fnValue := reflect.ValueOf(constructor)
+	if fnValue.Kind() != reflect.Func || fnValue.IsNil() {
+		return constructor
+	}
+
+	traced := reflect.ValueOf(t.wrapFunction(constructor, ""))
+	wrapper := reflect.MakeFunc(fnValue.Type(), func(args []reflect.Value) []reflect.Value {
+		results := callFunc(traced, args)
+		for i, result := range results {
+			results[i] = t.wrapProduct(result)
+		}
+		return results
+	})
+	return wrapper.Interface()
+}
+
+// wrapProduct wraps an object returned by a constructor under its type
+// name, returning the value to hand to the caller in its place
+func (t *TracerImpl) wrapProduct(result reflect.Value) reflect.Value {
+	switch result.Kind() {
+	case reflect.Interface:
+		if result.IsNil() || result.Type() == errorType {
+			return result
+		}
+		if traced, ok := t.proxy(result.Type(), productName(result.Elem().Type()), result.Elem()); ok {
+			return traced.Convert(result.Type())
+		}
+		return result
+	case reflect.Ptr:
+		if result.IsNil() || result.Elem().Kind() != reflect.Struct {
+			return result
+		}
+		name := productName(result.Type())
+		t.WrapDeep(result.Interface(), name)
+		if wrapped := reflect.ValueOf(t.WrapWithName(result.Interface(), name)); wrapped.Type().AssignableTo(result.Type()) {
+			return wrapped
+		}
+		return result
+	default:
+		return result
+	}
+}
+
+// productName names an object by its type, without pointers or package
+func productName(objType reflect.Type) string {
+	for objType.Kind() == reflect.Ptr {
+		objType = objType.Elem()
+	}
+	if name := objType.Name(); name != "" {
+		return name
+	}
+	return objType.String()
+}
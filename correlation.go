@@ -0,0 +1,213 @@
+package lens
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Standard correlation tag names stamped onto events
+const (
+	TagRequestID   = "request_id"
+	TagTraceparent = "traceparent"
+	TagW3CTraceID  = "w3c_trace_id"
+)
+
+// contextKey is the type of lens-owned context keys
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	traceparentKey
+	spanKey
+	baggageKey
+	remoteSpanKey
+)
+
+// ContextWithRequestID returns a copy of ctx carrying a request ID
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestIDFromContext returns the request ID stored in ctx, if any
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// ContextWithTraceparent returns a copy of ctx carrying a W3C traceparent value
+func ContextWithTraceparent(ctx context.Context, traceparent string) context.Context {
+	return context.WithValue(ctx, traceparentKey, traceparent)
+}
+
+// TraceparentFromContext returns the W3C traceparent stored in ctx, if any
+func TraceparentFromContext(ctx context.Context) string {
+	tp, _ := ctx.Value(traceparentKey).(string)
+	return tp
+}
+
+// ContextFromHeaders copies the X-Request-ID, traceparent, baggage and
+// lens span headers into ctx, as Extract does
+func ContextFromHeaders(ctx context.Context, header http.Header) context.Context {
+	return Extract(ctx, HeaderCarrier(header))
+}
+
+// ContextWithSpan returns a copy of ctx carrying span
+func ContextWithSpan(ctx context.Context, span Span) context.Context {
+	return context.WithValue(ctx, spanKey, span)
+}
+
+// SpanFromContext returns the span stored in ctx, if any
+func SpanFromContext(ctx context.Context) Span {
+	span, _ := ctx.Value(spanKey).(Span)
+	return span
+}
+
+// CorrelationEnricher stamps correlation IDs found in a context onto events
+type CorrelationEnricher struct {
+	keys map[string]interface{}
+}
+
+// NewCorrelationEnricher creates a new correlation enricher.
+// Request IDs, traceparent values and baggage stored via the lens context
+// helpers are always extracted.
+func NewCorrelationEnricher() *CorrelationEnricher {
+	return &CorrelationEnricher{
+		keys: make(map[string]interface{}),
+	}
+}
+
+// WithContextKey extracts the value stored under key and stamps it as tag
+func (e *CorrelationEnricher) WithContextKey(tag string, key interface{}) *CorrelationEnricher {
+	e.keys[tag] = key
+	return e
+}
+
+// Enrich stamps correlation IDs from ctx onto the event
+func (e *CorrelationEnricher) Enrich(ctx context.Context, event Event) Event {
+	if id := RequestIDFromContext(ctx); id != "" {
+		event = withTag(event, TagRequestID, id)
+	}
+
+	if tp := TraceparentFromContext(ctx); tp != "" {
+		event = withTag(event, TagTraceparent, tp)
+		// traceparent is version-traceid-parentid-flags
+		if parts := strings.Split(tp, "-"); len(parts) == 4 {
+			event = withTag(event, TagW3CTraceID, parts[1])
+		}
+	}
+
+	for key, value := range baggageFromContext(ctx) {
+		event = withTag(event, BaggageTagPrefix+key, value)
+	}
+
+	for tag, key := range e.keys {
+		if value := ctx.Value(key); value != nil {
+			event = withTag(event, tag, value)
+		}
+	}
+
+	return event
+}
+
+// CorrelationIDs creates an enricher for the standard correlation IDs
+func CorrelationIDs() ContextEnricher {
+	return NewCorrelationEnricher()
+}
+
+// withTag returns the event with a tag set, copying the tag map so
+// events sharing a map are never mutated
+func withTag(event Event, key string, value interface{}) Event {
+	tags := make(map[string]interface{}, len(event.Tags)+1)
+	for k, v := range event.Tags {
+		tags[k] = v
+	}
+	tags[key] = value
+	event.Tags = tags
+	return event
+}
+
+// contextFromArgs returns the first non-nil context.Context argument
+func contextFromArgs(args []interface{}) context.Context {
+	for _, arg := range args {
+		if ctx, ok := arg.(context.Context); ok && ctx != nil {
+			return ctx
+		}
+	}
+	return nil
+}
+
+// TagDeadline holds the context deadline recorded by ExtractDeadline
+const TagDeadline = "ctx.deadline"
+
+// WithExternalTraceID records the ID returned by traceID as the
+// ExternalTraceID of events traced with a context, such as calls taking
+// one and spans started with StartSpanContext. It lets lens events carry
+// the correlation ID of a tracing system already in place, e.g. the
+// Datadog trace ID or a request header, next to lens's own IDs.
+func WithExternalTraceID(traceID func(ctx context.Context) string) Option {
+	return func(t *TracerImpl) {
+		t.externalTraceID = traceID
+	}
+}
+
+// SpanExtractor reads values from the context passed to StartSpanContext
+// and returns them as span tags
+type SpanExtractor func(ctx context.Context) map[string]interface{}
+
+// WithSpanExtractors records the tags returned by extractors on every span
+// started with StartSpanContext
+func WithSpanExtractors(extractors ...SpanExtractor) Option {
+	return func(t *TracerImpl) {
+		t.spanExtractors = append(t.spanExtractors, extractors...)
+	}
+}
+
+// ExtractRequestID records the request ID stored with ContextWithRequestID
+func ExtractRequestID() SpanExtractor {
+	return func(ctx context.Context) map[string]interface{} {
+		if id := RequestIDFromContext(ctx); id != "" {
+			return map[string]interface{}{TagRequestID: id}
+		}
+		return nil
+	}
+}
+
+// ExtractDeadline records the context deadline, if it has one
+func ExtractDeadline() SpanExtractor {
+	return func(ctx context.Context) map[string]interface{} {
+		if deadline, ok := ctx.Deadline(); ok {
+			return map[string]interface{}{TagDeadline: deadline.Format(time.RFC3339Nano)}
+		}
+		return nil
+	}
+}
+
+// ExtractContextKey records the value stored under key as tag
+func ExtractContextKey(tag string, key interface{}) SpanExtractor {
+	return func(ctx context.Context) map[string]interface{} {
+		if value := ctx.Value(key); value != nil {
+			return map[string]interface{}{tag: value}
+		}
+		return nil
+	}
+}
+
+// extractSpanTags runs the tracer's span extractors against ctx
+func (t *TracerImpl) extractSpanTags(ctx context.Context) map[string]interface{} {
+	t.mutex.RLock()
+	extractors := t.spanExtractors
+	t.mutex.RUnlock()
+
+	var tags map[string]interface{}
+	for _, extract := range extractors {
+		for k, v := range extract(ctx) {
+			if tags == nil {
+				tags = make(map[string]interface{})
+			}
+			tags[k] = v
+		}
+	}
+	return tags
+}
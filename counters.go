@@ -0,0 +1,113 @@
+package lens
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// errorType is the reflect type of the error interface
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// CallCounts are the counters kept for a function in counts-only mode
+type CallCounts struct {
+	Calls    int64         `json:"calls"`
+	Errors   int64         `json:"errors"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Mean returns the mean duration of a call
+func (c CallCounts) Mean() time.Duration {
+	if c.Calls == 0 {
+		return 0
+	}
+	return c.Duration / time.Duration(c.Calls)
+}
+
+// callCounter counts the calls of one function
+type callCounter struct {
+	calls  atomic.Int64
+	errors atomic.Int64
+	nanos  atomic.Int64
+}
+
+// callCounters holds the counters of every wrapped function by name
+type callCounters struct {
+	counters sync.Map
+}
+
+// WithCountsOnly starts the tracer in counts-only mode: wrapped functions
+// only count their calls, errors and time spent, with no events, filters
+// or writers involved, as an almost free production profile. Read the
+// counts with Counters, and switch to full tracing on demand with
+// SetCountsOnly(false).
+func WithCountsOnly() Option {
+	return func(t *TracerImpl) {
+		t.countsOnly.Store(true)
+	}
+}
+
+// SetCountsOnly switches counts-only mode on or off at runtime, see
+// WithCountsOnly. Counts are kept when it is switched off.
+func (t *TracerImpl) SetCountsOnly(on bool) {
+	t.root().countsOnly.Store(on)
+}
+
+// Counters returns the counts of the functions called in counts-only
+// mode, by function name
+func (t *TracerImpl) Counters() map[string]CallCounts {
+	counts := make(map[string]CallCounts)
+	t.root().counters.counters.Range(func(key, value interface{}) bool {
+		counter := value.(*callCounter)
+		if calls := counter.calls.Load(); calls > 0 {
+			counts[key.(string)] = CallCounts{
+				Calls:    calls,
+				Errors:   counter.errors.Load(),
+				Duration: time.Duration(counter.nanos.Load()),
+			}
+		}
+		return true
+	})
+	return counts
+}
+
+// counter returns the counter of a function, for wrappers to look up once
+// when wrapping
+func (t *TracerImpl) counter(function string) *callCounter {
+	counters := &t.root().counters
+	if counter, ok := counters.counters.Load(function); ok {
+		return counter.(*callCounter)
+	}
+	counter, _ := counters.counters.LoadOrStore(function, &callCounter{})
+	return counter.(*callCounter)
+}
+
+// counting reports whether wrapped calls of component should only be
+// counted. Calls without a component take that of a Named tracer.
+func (t *TracerImpl) counting(component string) bool {
+	if component == "" {
+		component = t.component
+	}
+	root := t.root()
+	return t.enabled && (root.countsOnly.Load() || root.quota.degraded(component))
+}
+
+// record counts a call that took duration
+func (c *callCounter) record(duration time.Duration) {
+	c.nanos.Add(int64(duration))
+	c.calls.Add(1)
+}
+
+// call calls fn, counting the call. Calls of functions whose last result
+// is an error count as errors when it is not nil.
+func (c *callCounter) call(fn reflect.Value, args []reflect.Value) []reflect.Value {
+	start := time.Now()
+	results := callFunc(fn, args)
+	c.record(time.Since(start))
+
+	if n := len(results); n > 0 && results[n-1].Type() == errorType && !results[n-1].IsNil() {
+		c.errors.Add(1)
+	}
+	return results
+}
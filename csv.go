@@ -0,0 +1,119 @@
+package lens
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CSVWriter writes trace events as CSV rows with a flat schema suitable
+// for spreadsheets. Nested values such as arguments and tags are written
+// as JSON text.
+type CSVWriter struct {
+	file   *fileOutput
+	writer *csv.Writer
+	mutex  sync.Mutex
+}
+
+// NewCSVWriter creates a new CSV writer. A header row is written when
+// the file is new or empty.
+func NewCSVWriter(path string) (*CSVWriter, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	output := &fileOutput{file: file}
+	w := &CSVWriter{
+		file:   output,
+		writer: csv.NewWriter(output),
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	if info.Size() == 0 {
+		header := make([]string, len(flatColumns))
+		for i, column := range flatColumns {
+			header[i] = column.name
+		}
+		if err := w.writer.Write(header); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to write header: %w", err)
+		}
+	}
+
+	return w, nil
+}
+
+// Write writes an event as a CSV row
+func (w *CSVWriter) Write(event Event) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.file == nil {
+		return fmt.Errorf("csv writer is closed")
+	}
+
+	record := make([]string, len(flatColumns))
+	for i, column := range flatColumns {
+		record[i] = flatText(column.value(event))
+	}
+	if err := w.writer.Write(record); err != nil {
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+	return nil
+}
+
+// compress compresses the rows written from now on. The header of a new
+// file is still buffered, so it is compressed too.
+func (w *CSVWriter) compress(compressor Compressor) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.file == nil {
+		return fmt.Errorf("csv writer is closed")
+	}
+	return w.file.compress(compressor)
+}
+
+// Flush writes buffered rows to the file
+func (w *CSVWriter) Flush() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	w.writer.Flush()
+	if err := w.writer.Error(); err != nil {
+		return fmt.Errorf("failed to flush csv: %w", err)
+	}
+	return w.file.Sync()
+}
+
+// Close flushes buffered rows and closes the file
+func (w *CSVWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	w.writer.Flush()
+	err := w.writer.Error()
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	w.file = nil
+	return err
+}
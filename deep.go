@@ -0,0 +1,116 @@
+package lens
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// proxies maps interface types to the funcs building their traced proxies
+var proxies sync.Map
+
+// RegisterProxy registers how to trace implementations of interface I.
+// Go cannot implement an interface at run time, so a proxy is a type you
+// write (or generate) once, delegating each method to a wrapped func:
+//
+//	lens.RegisterProxy(func(t *lens.TracerImpl, name string, repo OrderRepo) OrderRepo {
+//		return &tracedOrderRepo{
+//			save: t.WrapWithName(repo.Save, name+".Save").(func(context.Context, Order) error),
+//		}
+//	})
+//
+// WrapDeep then uses it for every field of type I. It panics if I is not
+// an interface type.
+func RegisterProxy[I any](proxy func(t *TracerImpl, name string, impl I) I) {
+	iface := reflect.TypeOf((*I)(nil)).Elem()
+	if iface.Kind() != reflect.Interface {
+		panic(fmt.Sprintf("lens: RegisterProxy needs an interface type, got %s", iface))
+	}
+
+	proxies.Store(iface, proxyFunc(func(t *TracerImpl, name string, impl reflect.Value) reflect.Value {
+		return reflect.ValueOf(proxy(t, name, impl.Interface().(I)))
+	}))
+}
+
+// proxyFunc builds a traced proxy of impl, an implementation of a
+// registered interface
+type proxyFunc func(t *TracerImpl, name string, impl reflect.Value) reflect.Value
+
+// proxyTypes holds the types of the proxies built, which are never
+// proxied again
+var proxyTypes sync.Map
+
+// proxy returns the traced proxy of impl, an implementation of iface,
+// if iface is registered. Proxies are returned as they are, and a proxy
+// func returning nil leaves impl as it was.
+func (t *TracerImpl) proxy(iface reflect.Type, name string, impl reflect.Value) (reflect.Value, bool) {
+	if _, ok := proxyTypes.Load(impl.Type()); ok {
+		return impl, false
+	}
+	proxy, ok := proxies.Load(iface)
+	if !ok {
+		return impl, false
+	}
+	traced := proxy.(proxyFunc)(t, name, impl)
+	if !traced.IsValid() {
+		return impl, false
+	}
+	proxyTypes.Store(traced.Type(), struct{}{})
+	return traced, true
+}
+
+// WrapDeep traces the dependencies of a struct in place: every exported
+// field holding a func is replaced by a traced func, and every exported
+// field of an interface type registered with RegisterProxy by its proxy.
+// Funcs are traced with the field's path, e.g. "OrderService.Hash", as
+// their component, and proxies are given it as their name. Struct
+// fields held by value are walked too; pointers are not followed, so
+// shared dependencies are never modified behind your back.
+//
+// obj must be a pointer to a struct, which is returned for convenience;
+// anything else is returned unchanged.
+func (t *TracerImpl) WrapDeep(obj interface{}, name string) interface{} {
+	if !t.enabled {
+		return obj
+	}
+
+	objValue := reflect.ValueOf(obj)
+	if objValue.Kind() != reflect.Ptr || objValue.IsNil() || objValue.Elem().Kind() != reflect.Struct {
+		return obj
+	}
+
+	if name == "" {
+		name = objValue.Elem().Type().Name()
+	}
+	t.wrapFields(objValue.Elem(), name)
+	return obj
+}
+
+// wrapFields replaces the traceable fields of an addressable struct
+func (t *TracerImpl) wrapFields(structValue reflect.Value, name string) {
+	structType := structValue.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		value := structValue.Field(i)
+		if !field.IsExported() || !value.CanSet() {
+			continue
+		}
+
+		fieldName := joinPath(name, field.Name)
+		switch field.Type.Kind() {
+		case reflect.Func:
+			if !value.IsNil() {
+				value.Set(reflect.ValueOf(t.wrapFunction(value.Interface(), fieldName)))
+			}
+		case reflect.Interface:
+			if value.IsNil() {
+				continue
+			}
+			if traced, ok := t.proxy(field.Type, fieldName, value.Elem()); ok {
+				value.Set(traced)
+			}
+		case reflect.Struct:
+			t.wrapFields(value, fieldName)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package lens
+
+import (
+	"sync"
+)
+
+// depthTracker tracks the traced calls running on each goroutine, giving
+// their nesting and recursion depth and the innermost call's trace ID
+type depthTracker struct {
+	active map[int][]activeCall
+	mutex  sync.Mutex
+}
+
+// activeCall is a traced call still running
+type activeCall struct {
+	traceID  string
+	function string
+}
+
+// newDepthTracker creates a new depth tracker
+func newDepthTracker() *depthTracker {
+	return &depthTracker{
+		active: make(map[int][]activeCall),
+	}
+}
+
+// enter records a call to function on goroutine and returns its depth
+// and its recursion depth, the calls to function it is nested in (0 for
+// outermost)
+func (d *depthTracker) enter(goroutine int, traceID, function string) (int, int) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	calls := d.active[goroutine]
+	recursion := 0
+	for _, call := range calls {
+		if call.function == function {
+			recursion++
+		}
+	}
+	d.active[goroutine] = append(calls, activeCall{traceID: traceID, function: function})
+	return len(calls), recursion
+}
+
+// exit records the return of the innermost call on goroutine
+func (d *depthTracker) exit(goroutine int) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	calls := d.active[goroutine]
+	if len(calls) <= 1 {
+		// Drop finished goroutines so the map does not grow unbounded
+		delete(d.active, goroutine)
+		return
+	}
+	d.active[goroutine] = calls[:len(calls)-1]
+}
+
+// current returns the trace ID of the innermost call running on
+// goroutine, or "" outside traced calls
+func (d *depthTracker) current(goroutine int) string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	calls := d.active[goroutine]
+	if len(calls) == 0 {
+		return ""
+	}
+	return calls[len(calls)-1].traceID
+}
@@ -0,0 +1,8 @@
+//go:build lens_disabled
+
+package lens
+
+// compiledOut is set by the lens_disabled build tag. Tracers are then
+// always disabled, and the checks of it let the compiler drop tracing
+// code entirely.
+const compiledOut = true
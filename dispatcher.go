@@ -0,0 +1,191 @@
+package lens
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// eventSeq numbers events across all tracers in the process
+var eventSeq atomic.Uint64
+
+// dispatcher fans events out to writers. Each writer has its own FIFO
+// queue drained by a single goroutine, so every writer sees events in the
+// order they were emitted and a slow writer does not hold up the others.
+type dispatcher struct {
+	queues map[Writer]*writerQueue
+	mutex  sync.Mutex
+	// writerCounters holds the *writerCounters of each writer
+	writerCounters sync.Map
+	health         *writerHealth
+}
+
+// writerQueue holds the events pending for one writer
+type writerQueue struct {
+	writer  Writer
+	pending []Event
+	// The buffer of the last batch written, reused for the next pending
+	// events so a busy queue stops allocating once its buffers have grown
+	spare    []Event
+	stopping bool
+	mutex    sync.Mutex
+	wake     chan struct{}
+	done     chan struct{}
+	// queued counts the events pushed and not yet written
+	queued   atomic.Int64
+	counters *writerCounters
+	health   *writerHealth
+}
+
+// dispatch numbers an event and queues it for each writer. Events
+// dispatched by concurrent callers are queued in Seq order for every
+// writer. inflight is released as each write completes.
+func (d *dispatcher) dispatch(writers []Writer, event Event, inflight *sync.WaitGroup) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	event.Seq = eventSeq.Add(1)
+
+	for _, writer := range writers {
+		queue, ok := d.queues[writer]
+		if !ok {
+			if d.queues == nil {
+				d.queues = make(map[Writer]*writerQueue)
+			}
+			queue = newWriterQueue(writer, d.counters(writer), d.health, inflight)
+			d.queues[writer] = queue
+		}
+		inflight.Add(1)
+		queue.push(event)
+	}
+}
+
+// remove drains and stops the queues of writers that are no longer in use,
+// so they can be closed without losing queued events
+func (d *dispatcher) remove(writers []Writer) {
+	d.mutex.Lock()
+	var queues []*writerQueue
+	for _, writer := range writers {
+		if queue, ok := d.queues[writer]; ok {
+			queues = append(queues, queue)
+			delete(d.queues, writer)
+		}
+		d.writerCounters.Delete(writer)
+	}
+	d.mutex.Unlock()
+
+	for _, queue := range queues {
+		queue.stop()
+	}
+}
+
+// stop drains and stops every queue
+func (d *dispatcher) stop() {
+	d.mutex.Lock()
+	queues := d.queues
+	d.queues = nil
+	d.mutex.Unlock()
+
+	for _, queue := range queues {
+		queue.stop()
+	}
+}
+
+// newWriterQueue creates a queue and starts its delivery goroutine
+func newWriterQueue(writer Writer, counters *writerCounters, health *writerHealth, inflight *sync.WaitGroup) *writerQueue {
+	q := &writerQueue{
+		writer:   writer,
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		counters: counters,
+		health:   health,
+	}
+	go q.run(inflight)
+	return q
+}
+
+// push appends an event and wakes the delivery goroutine
+func (q *writerQueue) push(event Event) {
+	q.queued.Add(1)
+	q.mutex.Lock()
+	q.pending = append(q.pending, event)
+	q.mutex.Unlock()
+
+	q.signal()
+}
+
+// signal wakes the delivery goroutine without blocking
+func (q *writerQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run writes queued events in order until the queue is stopped and empty
+func (q *writerQueue) run(inflight *sync.WaitGroup) {
+	defer close(q.done)
+
+	for {
+		q.mutex.Lock()
+		batch := q.pending
+		if len(batch) > 0 {
+			q.pending = q.spare
+			q.spare = nil
+		}
+		stopping := q.stopping
+		q.mutex.Unlock()
+
+		for _, event := range batch {
+			writeStart := overhead.write.start()
+			err := q.writer.Write(event)
+			overhead.write.stop(writeStart)
+			q.written(err)
+			inflight.Done()
+		}
+
+		if len(batch) > 0 {
+			// Drop references to the written events before reuse
+			clear(batch)
+			q.mutex.Lock()
+			q.spare = batch[:0]
+			q.mutex.Unlock()
+		}
+
+		if len(batch) == 0 {
+			if stopping {
+				return
+			}
+			<-q.wake
+		}
+	}
+}
+
+// written records the outcome of a write, reporting the writer if it just
+// became unhealthy
+func (q *writerQueue) written(err error) {
+	depth := int(q.queued.Add(-1))
+	maxDepth := 0
+	if q.health != nil {
+		maxDepth = q.health.maxQueueDepth
+	}
+	if q.counters.recordWrite(err, depth, maxDepth) && q.health != nil {
+		// The report is queued for this writer too, so it cannot be
+		// dispatched from its delivery goroutine
+		go q.health.report(q.writer, err, depth)
+	}
+}
+
+// depth returns the number of events waiting to be written
+func (q *writerQueue) depth() int {
+	return int(q.queued.Load())
+}
+
+// stop waits for queued events to be written and ends the goroutine
+func (q *writerQueue) stop() {
+	q.mutex.Lock()
+	q.stopping = true
+	q.mutex.Unlock()
+
+	q.signal()
+	<-q.done
+}
@@ -0,0 +1,110 @@
+package lens
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// DefaultElasticsearchIndex writes events to one index per day
+const DefaultElasticsearchIndex = "lens-{2006.01.02}"
+
+// ElasticsearchWriter ships trace events to Elasticsearch or OpenSearch
+// through the bulk API
+type ElasticsearchWriter struct {
+	*httpShipper
+	url   string
+	index string
+}
+
+// NewElasticsearchWriter creates a new Elasticsearch writer. url is the
+// cluster base URL. index names the target index; a part in braces is a
+// Go time layout applied to the event timestamp, so the default
+// "lens-{2006.01.02}" writes to indexes like lens-2025.10.03. An empty
+// index uses DefaultElasticsearchIndex.
+func NewElasticsearchWriter(url, index string, options ...HTTPWriterOption) *ElasticsearchWriter {
+	if index == "" {
+		index = DefaultElasticsearchIndex
+	}
+	w := &ElasticsearchWriter{
+		url:   strings.TrimSuffix(url, "/") + "/_bulk",
+		index: index,
+	}
+	w.httpShipper = newHTTPShipper(w.send, options)
+	return w
+}
+
+// bulkAction is the action line preceding each document
+type bulkAction struct {
+	Index struct {
+		Index string `json:"_index"`
+	} `json:"index"`
+}
+
+// bulkResponse is the part of the bulk response reporting item failures
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int `json:"status"`
+		Error  struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+// send indexes a batch of events with one bulk request
+func (w *ElasticsearchWriter) send(ctx context.Context, events []Event) error {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+
+	for _, event := range events {
+		var action bulkAction
+		action.Index.Index = w.indexFor(event)
+		if err := encoder.Encode(action); err != nil {
+			return fmt.Errorf("failed to marshal bulk action: %w", err)
+		}
+		if err := encoder.Encode(event); err != nil {
+			return fmt.Errorf("failed to marshal event: %w", err)
+		}
+	}
+
+	respBody, err := w.post(ctx, w.url, "application/x-ndjson", body.Bytes())
+	if err != nil {
+		return err
+	}
+
+	var resp bulkResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return fmt.Errorf("failed to parse bulk response: %w", err)
+	}
+	if resp.Errors {
+		failed := 0
+		var reason string
+		for _, item := range resp.Items {
+			for _, result := range item {
+				if result.Status > 299 {
+					failed++
+					if reason == "" {
+						reason = fmt.Sprintf("%s: %s", result.Error.Type, result.Error.Reason)
+					}
+				}
+			}
+		}
+		return fmt.Errorf("failed to index %d of %d events: %s", failed, len(events), reason)
+	}
+	return nil
+}
+
+// indexFor expands the index template for an event
+func (w *ElasticsearchWriter) indexFor(event Event) string {
+	start := strings.Index(w.index, "{")
+	end := strings.Index(w.index, "}")
+	if start < 0 || end < start {
+		return w.index
+	}
+	layout := w.index[start+1 : end]
+	return w.index[:start] + event.Timestamp.UTC().Format(layout) + w.index[end+1:]
+}
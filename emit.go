@@ -0,0 +1,85 @@
+package lens
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// eventTypeLevels holds the levels of event types registered with
+// RegisterEventType
+var eventTypeLevels sync.Map
+
+// RegisterEventType sets the level of a user-defined event type, so
+// SetLevel keeps or drops its events as it does built-in ones. Event types
+// that are not registered are at LevelInfo. Built-in types keep their
+// levels.
+func RegisterEventType(eventType EventType, level Level) {
+	eventTypeLevels.Store(eventType, level)
+}
+
+// registeredLevel returns the level of a registered event type
+func registeredLevel(eventType EventType) (Level, bool) {
+	level, ok := eventTypeLevels.Load(eventType)
+	if !ok {
+		return LevelInfo, false
+	}
+	return level.(Level), true
+}
+
+// Emit traces a domain event, such as a cache miss, a retry or a circuit
+// breaker opening, as an event of a user-defined type carrying attrs as
+// its tags. The event goes through filters and processors to writers like
+// any other, in the same stream as the calls around it: emitted within a
+// wrapped call, it shares the call's trace ID.
+func (t *TracerImpl) Emit(eventType string, attrs map[string]interface{}) {
+	t.emit(context.Background(), eventType, attrs)
+}
+
+// EmitContext is Emit for a context: the event joins the span in ctx, if
+// any, and enrichers see ctx
+func (t *TracerImpl) EmitContext(ctx context.Context, eventType string, attrs map[string]interface{}) {
+	t.emit(ctx, eventType, attrs)
+}
+
+// emit builds and traces an event for Emit and EmitContext
+func (t *TracerImpl) emit(ctx context.Context, eventType string, attrs map[string]interface{}) {
+	goroutine := getGoroutineID()
+	traceID, spanID := spanContextFromContext(ctx)
+	if traceID == "" {
+		traceID = t.depths.current(goroutine)
+	}
+	if traceID == "" {
+		traceID = generateTraceID()
+	}
+	if !t.shouldCapture(Event{TraceID: traceID}, EventType(eventType)) {
+		return
+	}
+
+	var tags map[string]interface{}
+	if len(attrs) > 0 {
+		tags = make(map[string]interface{}, len(attrs))
+		for key, value := range attrs {
+			tags[key] = t.encodeValue(value)
+		}
+	}
+
+	sourceLocation := getSourceLocation(3)
+	callerLocation := getCallerLocation(3)
+
+	t.TraceEvent(t.enrich(ctx, Event{
+		ID:             generateEventID(),
+		TraceID:        traceID,
+		SpanID:         spanID,
+		Timestamp:      time.Now(),
+		Type:           EventType(eventType),
+		Tags:           tags,
+		Goroutine:      goroutine,
+		SourceFile:     sourceLocation.File,
+		SourceLine:     sourceLocation.Line,
+		SourceFunction: sourceLocation.Function,
+		CallerFile:     callerLocation.File,
+		CallerLine:     callerLocation.Line,
+		CallerFunction: callerLocation.Function,
+	}))
+}
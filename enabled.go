@@ -0,0 +1,6 @@
+//go:build !lens_disabled
+
+package lens
+
+// compiledOut is set by the lens_disabled build tag, see disabled.go
+const compiledOut = false
@@ -0,0 +1,166 @@
+package lens
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// ValueEncoder turns captured arguments, return values and variable values
+// into trace-safe representations before they are recorded. Without one,
+// values are recorded as-is and left to each writer's fmt or JSON
+// formatting, which loses unexported fields and fails on channels nested
+// in other values. Channel and func arguments and results themselves are
+// always recorded as summaries, see summarizeValue.
+type ValueEncoder interface {
+	Encode(value interface{}) interface{}
+}
+
+// ValueEncoderFunc adapts a function to a ValueEncoder
+type ValueEncoderFunc func(value interface{}) interface{}
+
+// Encode calls f
+func (f ValueEncoderFunc) Encode(value interface{}) interface{} {
+	return f(value)
+}
+
+// WithValueEncoder sets the encoder applied to captured values
+func WithValueEncoder(encoder ValueEncoder) Option {
+	return func(t *TracerImpl) {
+		t.encoder = encoder
+	}
+}
+
+// encodeValues returns values encoded with the tracer's encoder; values
+// itself is returned when there is none
+func (t *TracerImpl) encodeValues(values []interface{}) []interface{} {
+	if t.encoder == nil || len(values) == 0 {
+		return values
+	}
+	encoded := make([]interface{}, len(values))
+	for i, value := range values {
+		encoded[i] = t.encodeValue(value)
+	}
+	return encoded
+}
+
+// encodeValue encodes a single value with the tracer's encoder
+func (t *TracerImpl) encodeValue(value interface{}) interface{} {
+	if t.encoder == nil {
+		return value
+	}
+	return t.encoder.Encode(value)
+}
+
+// summarizeValue returns the value recorded for an argument or result.
+// Channels and funcs cannot be marshaled and their contents are not
+// meaningful to record, so they are summarized by type and identity, e.g.
+// "chan int(0xc000020060 len=2 cap=8)" or "func(int) error(main.handle)".
+func summarizeValue(v reflect.Value) interface{} {
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Chan:
+		if v.IsNil() {
+			return fmt.Sprintf("%s(nil)", v.Type())
+		}
+		return fmt.Sprintf("%s(%#x len=%d cap=%d)", v.Type(), v.Pointer(), v.Len(), v.Cap())
+	case reflect.Func:
+		if v.IsNil() {
+			return fmt.Sprintf("%s(nil)", v.Type())
+		}
+		name := "?"
+		if fn := runtime.FuncForPC(v.Pointer()); fn != nil {
+			name = demangle(strings.TrimSuffix(fn.Name(), "-fm"))
+		}
+		return fmt.Sprintf("%s(%s)", v.Type(), name)
+	case reflect.UnsafePointer:
+		return fmt.Sprintf("%s(%#x)", v.Type(), v.Pointer())
+	}
+	return v.Interface()
+}
+
+// StringerValueEncoder records errors by their message and fmt.Stringer values
+// by their String method, leaving other values unchanged
+func StringerValueEncoder() ValueEncoder {
+	return ValueEncoderFunc(func(value interface{}) interface{} {
+		switch v := value.(type) {
+		case error:
+			return v.Error()
+		case fmt.Stringer:
+			return v.String()
+		}
+		return value
+	})
+}
+
+// JSONValueEncoder records values as their JSON encoding, so writers see the
+// same representation regardless of their own formatting. Values that
+// cannot be marshaled, such as channels and funcs, are recorded as
+// "%+v" text.
+func JSONValueEncoder() ValueEncoder {
+	return ValueEncoderFunc(func(value interface{}) interface{} {
+		if err, ok := value.(error); ok {
+			return err.Error()
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Sprintf("%+v", value)
+		}
+		return json.RawMessage(data)
+	})
+}
+
+// TypeEncoder encodes values with functions registered for their dynamic
+// type, falling back to another encoder for unregistered types
+type TypeEncoder struct {
+	encoders map[reflect.Type]func(value interface{}) interface{}
+	fallback ValueEncoder
+	mutex    sync.RWMutex
+}
+
+// NewTypeEncoder creates a type encoder; fallback may be nil to record
+// unregistered types unchanged
+func NewTypeEncoder(fallback ValueEncoder) *TypeEncoder {
+	return &TypeEncoder{
+		encoders: make(map[reflect.Type]func(value interface{}) interface{}),
+		fallback: fallback,
+	}
+}
+
+// Register sets the encoding of values of type typ
+func (e *TypeEncoder) Register(typ reflect.Type, encode func(value interface{}) interface{}) *TypeEncoder {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.encoders[typ] = encode
+	return e
+}
+
+// RegisterEncoder sets the encoding of values of type T
+func RegisterEncoder[T any](e *TypeEncoder, encode func(value T) interface{}) *TypeEncoder {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	return e.Register(typ, func(value interface{}) interface{} {
+		return encode(value.(T))
+	})
+}
+
+// Encode encodes value with the encoder registered for its type
+func (e *TypeEncoder) Encode(value interface{}) interface{} {
+	if value != nil {
+		e.mutex.RLock()
+		encode, ok := e.encoders[reflect.TypeOf(value)]
+		e.mutex.RUnlock()
+		if ok {
+			return encode(value)
+		}
+	}
+	if e.fallback != nil {
+		return e.fallback.Encode(value)
+	}
+	return value
+}
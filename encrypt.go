@@ -0,0 +1,201 @@
+package lens
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// EventEncrypted is the type of the envelope events EncryptedWriter
+// writes in place of the events it encrypts
+const EventEncrypted EventType = "encrypted"
+
+// Tags set on EventEncrypted envelopes
+const (
+	TagEncryptionKeyID  = "encryption.key_id"
+	TagEncryptedPayload = "encryption.payload"
+)
+
+// EncryptionKey is an AES key and the ID envelopes record it by, so
+// traces written before a rotation can still be decrypted
+type EncryptionKey struct {
+	ID string
+	// Key is 16, 24 or 32 bytes, for AES-128, AES-192 or AES-256
+	Key []byte
+}
+
+// ParseEncryptionKey parses a key written as id:base64, e.g. from an
+// environment variable or a key file
+func ParseEncryptionKey(s string) (EncryptionKey, error) {
+	id, encoded, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok || id == "" {
+		return EncryptionKey{}, fmt.Errorf("invalid encryption key: want id:base64")
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return EncryptionKey{}, fmt.Errorf("failed to decode encryption key %s: %w", id, err)
+	}
+	return EncryptionKey{ID: id, Key: key}, nil
+}
+
+// newAEAD creates the AES-GCM cipher for a key
+func newAEAD(key EncryptionKey) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher for key %s: %w", key.ID, err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher for key %s: %w", key.ID, err)
+	}
+	return aead, nil
+}
+
+// sealingKey is the key an EncryptedWriter encrypts with
+type sealingKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// EncryptedWriter encrypts events with AES-GCM before passing them to
+// another writer, since traces capture argument values that may be
+// sensitive. Each event is replaced by an EventEncrypted envelope keeping
+// only its ID, trace ID, timestamp and ordering in the clear; the rest is
+// sealed in the envelope's tags, bound to the event ID. Decrypt envelopes
+// with a Keyring, or read them with the lens CLI given the keys.
+type EncryptedWriter struct {
+	inner Writer
+	key   atomic.Pointer[sealingKey]
+}
+
+// NewEncryptedWriter creates a writer encrypting events with key before
+// writing them to inner
+func NewEncryptedWriter(inner Writer, key EncryptionKey) (*EncryptedWriter, error) {
+	w := &EncryptedWriter{inner: inner}
+	if err := w.RotateKey(key); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// RotateKey encrypts the events written from now on with key. Keep the
+// previous keys to decrypt the events written with them.
+func (w *EncryptedWriter) RotateKey(key EncryptionKey) error {
+	if key.ID == "" {
+		return fmt.Errorf("encryption key has no ID")
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+	w.key.Store(&sealingKey{id: key.ID, aead: aead})
+	return nil
+}
+
+// Write encrypts an event and writes its envelope to the inner writer
+func (w *EncryptedWriter) Write(event Event) error {
+	plaintext, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	key := w.key.Load()
+	nonce := make([]byte, key.aead.NonceSize(), key.aead.NonceSize()+len(plaintext)+key.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := key.aead.Seal(nonce, nonce, plaintext, []byte(event.ID))
+
+	return w.inner.Write(Event{
+		ID:            event.ID,
+		TraceID:       event.TraceID,
+		Timestamp:     event.Timestamp,
+		Type:          EventEncrypted,
+		SchemaVersion: event.SchemaVersion,
+		Seq:           event.Seq,
+		Tags: map[string]interface{}{
+			TagEncryptionKeyID:  key.id,
+			TagEncryptedPayload: base64.StdEncoding.EncodeToString(sealed),
+		},
+	})
+}
+
+// Flush flushes the inner writer
+func (w *EncryptedWriter) Flush() error {
+	return w.inner.Flush()
+}
+
+// Close closes the inner writer
+func (w *EncryptedWriter) Close() error {
+	return w.inner.Close()
+}
+
+// Keyring decrypts the envelopes written by EncryptedWriter with any of
+// the keys used over time
+type Keyring struct {
+	keys map[string]cipher.AEAD
+}
+
+// NewKeyring creates a keyring holding keys
+func NewKeyring(keys ...EncryptionKey) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string]cipher.AEAD, len(keys))}
+	for _, key := range keys {
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[key.ID] = aead
+	}
+	return k, nil
+}
+
+// Open returns the JSON encoding of the event sealed in an envelope
+func (k *Keyring) Open(envelope Event) ([]byte, error) {
+	id, _ := envelope.Tags[TagEncryptionKeyID].(string)
+	payload, _ := envelope.Tags[TagEncryptedPayload].(string)
+	if envelope.Type != EventEncrypted || payload == "" {
+		return nil, fmt.Errorf("event %s is not encrypted", envelope.ID)
+	}
+
+	aead, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("no key %q to decrypt event %s", id, envelope.ID)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode event %s: %w", envelope.ID, err)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("failed to decrypt event %s: payload too short", envelope.ID)
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(envelope.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt event %s: %w", envelope.ID, err)
+	}
+	return plaintext, nil
+}
+
+// Decrypt returns the event sealed in an envelope. Events that are not
+// envelopes are returned unchanged.
+func (k *Keyring) Decrypt(event Event) (Event, error) {
+	if event.Type != EventEncrypted {
+		return event, nil
+	}
+	plaintext, err := k.Open(event)
+	if err != nil {
+		return event, err
+	}
+
+	var decrypted Event
+	if err := json.Unmarshal(plaintext, &decrypted); err != nil {
+		return event, fmt.Errorf("failed to unmarshal event %s: %w", event.ID, err)
+	}
+	return decrypted, nil
+}
@@ -0,0 +1,135 @@
+package lens
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// TracedError is an error recorded by WrapError. It carries the IDs of
+// the trace it was recorded in, and formatting it with %v prints the
+// trace ID after the message, so a logged error leads to its trace.
+type TracedError struct {
+	Msg     string
+	Err     error
+	TraceID string
+	SpanID  string
+	// EventID is the ID of the error event recorded, if any
+	EventID string
+	// Stack is where the error was wrapped, if it was recorded
+	Stack []string
+}
+
+// Error returns the message and the wrapped error's message, without the
+// trace ID
+func (e *TracedError) Error() string {
+	if e.Msg == "" {
+		return e.Err.Error()
+	}
+	return e.Msg + ": " + e.Err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (e *TracedError) Unwrap() error {
+	return e.Err
+}
+
+// Format prints the error followed by its trace ID for %s and %v, and
+// the stack where it was wrapped as well for %+v
+func (e *TracedError) Format(f fmt.State, verb rune) {
+	switch verb {
+	case 'v', 's':
+		io.WriteString(f, e.Error())
+		if e.TraceID != "" {
+			fmt.Fprintf(f, " [trace_id=%s]", e.TraceID)
+		}
+		if verb == 'v' && f.Flag('+') {
+			for _, frame := range e.Stack {
+				io.WriteString(f, "\n\t"+frame)
+			}
+		}
+	case 'q':
+		fmt.Fprintf(f, "%q", e.Error())
+	}
+}
+
+// WrapError wraps err with msg and records it as an error event, with the
+// stack where it was wrapped, in the trace of the span in ctx. The event
+// is traced by the tracer that started the span; without a lens span in
+// ctx, err is wrapped with the remote trace ID in ctx, if any, and nothing
+// is recorded. An error already recorded in the same trace is wrapped
+// without being recorded again. WrapError returns nil if err is nil.
+func WrapError(ctx context.Context, err error, msg string) error {
+	if err == nil {
+		return nil
+	}
+	if span, ok := SpanFromContext(ctx).(*SpanImpl); ok {
+		return span.tracer.wrapError(ctx, err, msg)
+	}
+	traceID, spanID := spanContextFromContext(ctx)
+	return &TracedError{Msg: msg, Err: err, TraceID: traceID, SpanID: spanID}
+}
+
+// WrapError is WrapError recording the error with this tracer. Without a
+// span in ctx, the error joins the trace of the wrapped call running on
+// the goroutine, if any.
+func (t *TracerImpl) WrapError(ctx context.Context, err error, msg string) error {
+	if err == nil {
+		return nil
+	}
+	return t.wrapError(ctx, err, msg)
+}
+
+// wrapError wraps and records an error for WrapError
+func (t *TracerImpl) wrapError(ctx context.Context, err error, msg string) error {
+	goroutine := getGoroutineID()
+	traceID, spanID := spanContextFromContext(ctx)
+	if traceID == "" {
+		traceID = t.depths.current(goroutine)
+	}
+	if traceID == "" {
+		traceID = generateTraceID()
+	}
+	wrapped := &TracedError{Msg: msg, Err: err, TraceID: traceID, SpanID: spanID}
+
+	var recorded *TracedError
+	if errors.As(err, &recorded) && recorded.TraceID == traceID && recorded.EventID != "" {
+		wrapped.EventID = recorded.EventID
+		wrapped.Stack = recorded.Stack
+		return wrapped
+	}
+	if !t.shouldCapture(Event{TraceID: traceID}, EventError) {
+		return wrapped
+	}
+
+	wrapped.EventID = generateEventID()
+	wrapped.Stack = getStackTrace(3)
+	sourceLocation := getSourceLocation(3)
+	callerLocation := getCallerLocation(3)
+
+	var function string
+	if span, ok := SpanFromContext(ctx).(*SpanImpl); ok {
+		function = span.name
+	}
+
+	t.TraceEvent(t.enrich(ctx, Event{
+		ID:             wrapped.EventID,
+		TraceID:        traceID,
+		SpanID:         spanID,
+		Timestamp:      time.Now(),
+		Type:           EventError,
+		Function:       function,
+		Error:          wrapped.Error(),
+		StackTrace:     wrapped.Stack,
+		Goroutine:      goroutine,
+		SourceFile:     sourceLocation.File,
+		SourceLine:     sourceLocation.Line,
+		SourceFunction: sourceLocation.Function,
+		CallerFile:     callerLocation.File,
+		CallerLine:     callerLocation.Line,
+		CallerFunction: callerLocation.Function,
+	}))
+	return wrapped
+}
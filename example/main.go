@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -50,6 +51,8 @@ func main() {
 		lens.WithWriter(lens.NewConsoleWriter(true)),
 		lens.WithWriter(jsonWriter),
 	)
+	// Write pending events before exiting
+	defer tracer.Close(context.Background())
 
 	fmt.Println("\n🎯 Step 1: Trace utility functions!")
 
@@ -58,6 +61,7 @@ func main() {
 	IsAdult := tracer.Wrap(IsAdult).(func(int) bool)
 	ValidateEmail := tracer.Wrap(ValidateEmail).(func(string) bool)
 	FormatCurrency := tracer.Wrap(FormatCurrency).(func(float64, string) string)
+	AverageScore := tracer.Wrap(AverageScore).(func(...float64) float64)
 
 	// Use utility functions - all calls are automatically traced
 	age := CalculateAge(1990)
@@ -72,6 +76,9 @@ func main() {
 	currency := FormatCurrency(123.456, "USD")
 	fmt.Printf("Formatted currency: %s\n", currency)
 
+	average := AverageScore(82, 91.5, 77)
+	fmt.Printf("Average score: %.2f\n", average)
+
 	// Test cross-file function calls
 	processedData := ProcessUserData("john doe", tracer)
 	fmt.Printf("Processed user data: %s\n", processedData)
@@ -91,6 +98,10 @@ func main() {
 	tax := calc.CalculateTax(100.0, 0.08)
 	fmt.Printf("Tax calculation: %.2f\n", tax)
 
+	// Bound method values can be wrapped like any other function
+	multiply := tracer.Wrap(calc.Multiply).(func(float64, float64) float64)
+	fmt.Printf("Wrapped method value: %.2f\n", multiply(2, 4))
+
 	fmt.Println("\n🎯 Step 3: Trace string processor!")
 
 	// Create and wrap string processor - just wrap it!
@@ -105,6 +105,18 @@ func IsAdult(age int) bool {
 	return age >= 18
 }
 
+// AverageScore averages any number of scores
+func AverageScore(scores ...float64) float64 {
+	if len(scores) == 0 {
+		return 0
+	}
+	var total float64
+	for _, score := range scores {
+		total += score
+	}
+	return total / float64(len(scores))
+}
+
 // ProcessUserData processes user data using lib functions
 func ProcessUserData(name string, tracer lens.Tracer) string {
 	// Wrap internal functions for tracing using the passed tracer
@@ -0,0 +1,477 @@
+package lens
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ExprFilter keeps events matching an expression, so complex filtering
+// can be configured without writing Go code:
+//
+//	event.duration > 10ms && event.function matches 'db.*' && !event.error
+//
+// Fields are event.type, function, component, variable, error, trace_id,
+// external_trace_id, span_id, source_file, source_function, duration,
+// depth, recursion_depth, goroutine, source_line, and event.tags.<key>
+// and event.params.<key>. Literals are quoted strings, numbers, durations
+// such as 10ms, true and false.
+// Operators are ==, !=, <, <=, >, >=, matches (a regular expression
+// matching the whole value), contains, !, && and ||, with parentheses for
+// grouping. A field on its own is true when set: non-empty, non-zero.
+// The expression is compiled once, by NewExprFilter.
+type ExprFilter struct {
+	source string
+	eval   exprFunc
+}
+
+// exprFunc evaluates an expression against an event. Values are string,
+// float64, time.Duration, bool or nil.
+type exprFunc func(event *Event) interface{}
+
+// NewExprFilter compiles an expression filter
+func NewExprFilter(expr string) (*ExprFilter, error) {
+	p := &exprParser{source: expr}
+	if err := p.tokenize(); err != nil {
+		return nil, fmt.Errorf("failed to parse filter expression: %w", err)
+	}
+	eval, err := p.parseOr()
+	if err == nil && p.pos < len(p.tokens) {
+		err = p.errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse filter expression: %w", err)
+	}
+	return &ExprFilter{source: expr, eval: eval}, nil
+}
+
+// ShouldTrace reports whether the expression holds for event
+func (f *ExprFilter) ShouldTrace(event Event) bool {
+	return truthy(f.eval(&event))
+}
+
+// String returns the source of the expression
+func (f *ExprFilter) String() string {
+	return f.source
+}
+
+// exprToken is a token of an expression
+type exprToken struct {
+	kind   exprTokenKind
+	text   string
+	offset int
+}
+
+type exprTokenKind int
+
+const (
+	tokenIdent exprTokenKind = iota
+	tokenString
+	tokenNumber
+	tokenOperator
+)
+
+// exprParser is a recursive descent parser compiling an expression into
+// closures
+type exprParser struct {
+	source string
+	tokens []exprToken
+	pos    int
+}
+
+// exprOperators are the symbolic operators, longest first
+var exprOperators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")"}
+
+// tokenize splits the source into tokens
+func (p *exprParser) tokenize() error {
+	s := p.source
+	for i := 0; i < len(s); {
+		r := rune(s[i])
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '\'' || r == '"':
+			end := strings.IndexByte(s[i+1:], s[i])
+			if end < 0 {
+				return fmt.Errorf("offset %d: unterminated string", i)
+			}
+			p.tokens = append(p.tokens, exprToken{tokenString, s[i+1 : i+1+end], i})
+			i += end + 2
+		case r >= '0' && r <= '9':
+			// Numbers, with a unit for durations, e.g. 1.5ms
+			start := i
+			for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '.') {
+				i++
+			}
+			for i < len(s) && (unicode.IsLetter(rune(s[i])) || s[i] >= 0x80) {
+				i++
+			}
+			p.tokens = append(p.tokens, exprToken{tokenNumber, s[start:i], start})
+		case r == '_' || unicode.IsLetter(r):
+			start := i
+			for i < len(s) && (s[i] == '_' || s[i] == '.' || s[i] == '-' || unicode.IsLetter(rune(s[i])) || unicode.IsDigit(rune(s[i]))) {
+				i++
+			}
+			p.tokens = append(p.tokens, exprToken{tokenIdent, s[start:i], start})
+		default:
+			matched := false
+			for _, op := range exprOperators {
+				if strings.HasPrefix(s[i:], op) {
+					p.tokens = append(p.tokens, exprToken{tokenOperator, op, i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return fmt.Errorf("offset %d: unexpected %q", i, s[i])
+			}
+		}
+	}
+	return nil
+}
+
+// errorf reports an error at the current token
+func (p *exprParser) errorf(format string, args ...interface{}) error {
+	offset := len(p.source)
+	if p.pos < len(p.tokens) {
+		offset = p.tokens[p.pos].offset
+	}
+	return fmt.Errorf("offset %d: %s", offset, fmt.Sprintf(format, args...))
+}
+
+// accept consumes the next token if it is an operator or keyword
+func (p *exprParser) accept(text string) bool {
+	if p.pos < len(p.tokens) && p.tokens[p.pos].kind != tokenString && p.tokens[p.pos].text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// parseOr parses a || b || ...
+func (p *exprParser) parseOr() (exprFunc, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(e *Event) interface{} { return truthy(l(e)) || truthy(right(e)) }
+	}
+	return left, nil
+}
+
+// parseAnd parses a && b && ...
+func (p *exprParser) parseAnd() (exprFunc, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(e *Event) interface{} { return truthy(l(e)) && truthy(right(e)) }
+	}
+	return left, nil
+}
+
+// parseUnary parses !a and comparisons
+func (p *exprParser) parseUnary() (exprFunc, error) {
+	if p.accept("!") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(e *Event) interface{} { return !truthy(operand(e)) }, nil
+	}
+	return p.parseComparison()
+}
+
+// parseComparison parses a, a op b, a matches 're' and a contains b
+func (p *exprParser) parseComparison() (exprFunc, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.accept("matches") {
+		if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != tokenString {
+			return nil, p.errorf("matches needs a quoted regular expression")
+		}
+		pattern := p.tokens[p.pos].text
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, p.errorf("invalid regular expression: %v", err)
+		}
+		re := regexp.MustCompile("^(?:" + pattern + ")$")
+		p.pos++
+		return func(e *Event) interface{} {
+			v := left(e)
+			return v != nil && re.MatchString(exprString(v))
+		}, nil
+	}
+
+	if p.accept("contains") {
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return func(e *Event) interface{} {
+			v := left(e)
+			return v != nil && strings.Contains(exprString(v), exprString(right(e)))
+		}, nil
+	}
+
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if !p.accept(op) {
+			continue
+		}
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return func(e *Event) interface{} { return compareValues(op, left(e), right(e)) }, nil
+	}
+	return left, nil
+}
+
+// parseOperand parses a field, literal or parenthesized expression
+func (p *exprParser) parseOperand() (exprFunc, error) {
+	if p.accept("(") {
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.errorf("expected )")
+		}
+		return inner, nil
+	}
+	if p.pos >= len(p.tokens) {
+		return nil, p.errorf("unexpected end of expression")
+	}
+
+	tok := p.tokens[p.pos]
+	switch tok.kind {
+	case tokenString:
+		p.pos++
+		return constant(tok.text), nil
+	case tokenNumber:
+		value, err := parseExprNumber(tok.text)
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		p.pos++
+		return constant(value), nil
+	case tokenIdent:
+		switch tok.text {
+		case "true", "false":
+			p.pos++
+			return constant(tok.text == "true"), nil
+		}
+		field, err := exprField(tok.text)
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		p.pos++
+		return field, nil
+	}
+	return nil, p.errorf("unexpected %q", tok.text)
+}
+
+// constant returns a func evaluating to value
+func constant(value interface{}) exprFunc {
+	return func(*Event) interface{} { return value }
+}
+
+// parseExprNumber parses a number or, with a unit, a duration
+func parseExprNumber(text string) (interface{}, error) {
+	if n, err := strconv.ParseFloat(text, 64); err == nil {
+		return n, nil
+	}
+	d, err := time.ParseDuration(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number or duration %q", text)
+	}
+	return d, nil
+}
+
+// exprField resolves a field name such as event.duration
+func exprField(name string) (exprFunc, error) {
+	path, ok := strings.CutPrefix(name, "event.")
+	if !ok {
+		return nil, fmt.Errorf("unknown name %q, fields start with event.", name)
+	}
+
+	if key, ok := strings.CutPrefix(path, "tags."); ok {
+		return func(e *Event) interface{} { return exprValue(e.Tags[key]) }, nil
+	}
+	if key, ok := strings.CutPrefix(path, "params."); ok {
+		return func(e *Event) interface{} { return exprValue(e.Params[key]) }, nil
+	}
+
+	switch path {
+	case "type":
+		return func(e *Event) interface{} { return string(e.Type) }, nil
+	case "function":
+		return func(e *Event) interface{} { return e.Function }, nil
+	case "component":
+		return func(e *Event) interface{} { return e.Component }, nil
+	case "variable":
+		return func(e *Event) interface{} { return e.Variable }, nil
+	case "error":
+		return func(e *Event) interface{} { return e.Error }, nil
+	case "trace_id":
+		return func(e *Event) interface{} { return e.TraceID }, nil
+	case "external_trace_id":
+		return func(e *Event) interface{} { return e.ExternalTraceID }, nil
+	case "span_id":
+		return func(e *Event) interface{} { return e.SpanID }, nil
+	case "source_file":
+		return func(e *Event) interface{} { return e.SourceFile }, nil
+	case "source_function":
+		return func(e *Event) interface{} { return e.SourceFunction }, nil
+	case "duration":
+		return func(e *Event) interface{} { return e.Duration }, nil
+	case "depth":
+		return func(e *Event) interface{} { return float64(e.Depth) }, nil
+	case "recursion_depth":
+		return func(e *Event) interface{} { return float64(e.RecursionDepth) }, nil
+	case "goroutine":
+		return func(e *Event) interface{} { return float64(e.Goroutine) }, nil
+	case "source_line":
+		return func(e *Event) interface{} { return float64(e.SourceLine) }, nil
+	}
+	return nil, fmt.Errorf("unknown field %q", name)
+}
+
+// exprValue converts a tag or parameter value to an expression value
+func exprValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil, string, bool, float64, time.Duration:
+		return v
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case uint:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case uint32:
+		return float64(v)
+	case float32:
+		return float64(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// exprString formats a value for matches and contains
+func exprString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// truthy reports whether a value is set: true, non-empty or non-zero
+func truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case float64:
+		return v != 0
+	case time.Duration:
+		return v != 0
+	}
+	return true
+}
+
+// compareValues applies a comparison operator. Durations compare with
+// numbers as nanoseconds; values of other different types are never
+// equal nor ordered.
+func compareValues(op string, a, b interface{}) bool {
+	c, ok := orderValues(a, b)
+	if !ok {
+		switch op {
+		case "==":
+			return a == b
+		case "!=":
+			return a != b
+		}
+		return false
+	}
+
+	switch op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	default:
+		return c >= 0
+	}
+}
+
+// orderValues compares two ordered values
+func orderValues(a, b interface{}) (int, bool) {
+	if s, ok := a.(string); ok {
+		t, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(s, t), true
+	}
+
+	x, ok := exprNumber(a)
+	if !ok {
+		return 0, false
+	}
+	y, ok := exprNumber(b)
+	if !ok {
+		return 0, false
+	}
+	switch {
+	case x < y:
+		return -1, true
+	case x > y:
+		return 1, true
+	}
+	return 0, true
+}
+
+// exprNumber returns a number or duration as a float
+func exprNumber(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case time.Duration:
+		return float64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,31 @@
+package lens_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/baretech/lens"
+	"github.com/baretech/lens/lenstest"
+)
+
+// The accessors lensgen -structs generates trace reads with
+// TraceFieldAccess and writes with TraceVariable
+func TestFieldAccessorsTracedAtDefaultLevel(t *testing.T) {
+	tracer, rec := lenstest.Record(t)
+
+	tracer.TraceFieldAccess("Invoice.Total", 10)
+	tracer.TraceVariable("Invoice.Total", 10, 12)
+	if err := tracer.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var types []lens.EventType
+	for _, event := range rec.Events() {
+		if event.Variable == "Invoice.Total" {
+			types = append(types, event.Type)
+		}
+	}
+	if len(types) != 2 || types[0] != lens.EventFieldAccess || types[1] != lens.EventVariableWrite {
+		t.Errorf("traced %v, want [%s %s]", types, lens.EventFieldAccess, lens.EventVariableWrite)
+	}
+}
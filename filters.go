@@ -1,7 +1,14 @@
 package lens
 
 import (
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"path"
 	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -9,6 +16,7 @@ import (
 type PackageFilter struct {
 	includePatterns []string
 	excludePatterns []string
+	matches         matchCache
 }
 
 // NewPackageFilter creates a new package filter
@@ -22,12 +30,14 @@ func NewPackageFilter() *PackageFilter {
 // IncludePackages adds include patterns
 func (f *PackageFilter) IncludePackages(patterns ...string) *PackageFilter {
 	f.includePatterns = append(f.includePatterns, patterns...)
+	f.matches.reset()
 	return f
 }
 
 // ExcludePackages adds exclude patterns
 func (f *PackageFilter) ExcludePackages(patterns ...string) *PackageFilter {
 	f.excludePatterns = append(f.excludePatterns, patterns...)
+	f.matches.reset()
 	return f
 }
 
@@ -38,32 +48,16 @@ func (f *PackageFilter) ShouldTrace(event Event) bool {
 		return true
 	}
 
-	// Check exclude patterns first
-	for _, pattern := range f.excludePatterns {
-		if matched, _ := filepath.Match(pattern, component); matched {
-			return false
-		}
-	}
-
-	// If no include patterns, allow all (that weren't excluded)
-	if len(f.includePatterns) == 0 {
-		return true
-	}
-
-	// Check include patterns
-	for _, pattern := range f.includePatterns {
-		if matched, _ := filepath.Match(pattern, component); matched {
-			return true
-		}
-	}
-
-	return false
+	return f.matches.lookup(component, func() bool {
+		return matchPatterns(component, f.includePatterns, f.excludePatterns)
+	})
 }
 
 // FunctionFilter filters events based on function patterns
 type FunctionFilter struct {
 	includePatterns []string
 	excludePatterns []string
+	matches         matchCache
 }
 
 // NewFunctionFilter creates a new function filter
@@ -77,12 +71,14 @@ func NewFunctionFilter() *FunctionFilter {
 // IncludeFunctions adds include patterns
 func (f *FunctionFilter) IncludeFunctions(patterns ...string) *FunctionFilter {
 	f.includePatterns = append(f.includePatterns, patterns...)
+	f.matches.reset()
 	return f
 }
 
 // ExcludeFunctions adds exclude patterns
 func (f *FunctionFilter) ExcludeFunctions(patterns ...string) *FunctionFilter {
 	f.excludePatterns = append(f.excludePatterns, patterns...)
+	f.matches.reset()
 	return f
 }
 
@@ -93,21 +89,119 @@ func (f *FunctionFilter) ShouldTrace(event Event) bool {
 		return true
 	}
 
+	return f.matches.lookup(function, func() bool {
+		return matchPatterns(function, f.includePatterns, f.excludePatterns)
+	})
+}
+
+// SourceFilter filters events based on the source file they were emitted
+// from, for codebases whose component names are unreliable. Patterns
+// without a slash match the file name, e.g. "*_test.go". Others match the
+// end of the path, e.g. "payments/*.go", and those ending in "/..." match
+// every file below a directory, e.g. "internal/payments/...". Events
+// without a source file are kept.
+type SourceFilter struct {
+	includePatterns []string
+	excludePatterns []string
+	matches         matchCache
+}
+
+// NewSourceFilter creates a new source filter
+func NewSourceFilter() *SourceFilter {
+	return &SourceFilter{
+		includePatterns: make([]string, 0),
+		excludePatterns: make([]string, 0),
+	}
+}
+
+// IncludeSources adds include patterns
+func (f *SourceFilter) IncludeSources(patterns ...string) *SourceFilter {
+	f.includePatterns = append(f.includePatterns, patterns...)
+	f.matches.reset()
+	return f
+}
+
+// ExcludeSources adds exclude patterns
+func (f *SourceFilter) ExcludeSources(patterns ...string) *SourceFilter {
+	f.excludePatterns = append(f.excludePatterns, patterns...)
+	f.matches.reset()
+	return f
+}
+
+// ShouldTrace determines if an event should be traced
+func (f *SourceFilter) ShouldTrace(event Event) bool {
+	file := event.SourceFile
+	if file == "" {
+		return true
+	}
+
+	return f.matches.lookup(file, func() bool {
+		for _, pattern := range f.excludePatterns {
+			if matchSource(pattern, file) {
+				return false
+			}
+		}
+		if len(f.includePatterns) == 0 {
+			return true
+		}
+		for _, pattern := range f.includePatterns {
+			if matchSource(pattern, file) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// matchSource reports whether a SourceFilter pattern matches a file
+func matchSource(pattern, file string) bool {
+	file = filepath.ToSlash(file)
+	if !strings.Contains(pattern, "/") {
+		matched, _ := path.Match(pattern, path.Base(file))
+		return matched
+	}
+
+	parts := strings.Split(file, "/")
+	dirPattern, subtree := strings.CutSuffix(pattern, "/...")
+	if !subtree {
+		// Match as many trailing parts of the path as the pattern has
+		n := strings.Count(pattern, "/") + 1
+		if n > len(parts) {
+			return false
+		}
+		matched, _ := path.Match(pattern, strings.Join(parts[len(parts)-n:], "/"))
+		return matched
+	}
+
+	// Match every run of as many directories as the pattern has
+	dirs := parts[:len(parts)-1]
+	n := strings.Count(dirPattern, "/") + 1
+	for i := 0; i+n <= len(dirs); i++ {
+		if matched, _ := path.Match(dirPattern, strings.Join(dirs[i:i+n], "/")); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPatterns reports whether name is kept by the include and exclude
+// patterns
+func matchPatterns(name string, includePatterns, excludePatterns []string) bool {
 	// Check exclude patterns first
-	for _, pattern := range f.excludePatterns {
-		if matched, _ := filepath.Match(pattern, function); matched {
+	for _, pattern := range excludePatterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
 			return false
 		}
 	}
 
 	// If no include patterns, allow all (that weren't excluded)
-	if len(f.includePatterns) == 0 {
+	if len(includePatterns) == 0 {
 		return true
 	}
 
 	// Check include patterns
-	for _, pattern := range f.includePatterns {
-		if matched, _ := filepath.Match(pattern, function); matched {
+	for _, pattern := range includePatterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
 			return true
 		}
 	}
@@ -115,6 +209,39 @@ func (f *FunctionFilter) ShouldTrace(event Event) bool {
 	return false
 }
 
+// maxMatchCache bounds a matchCache, in case names are unbounded, such
+// as span names built from request paths
+const maxMatchCache = 4096
+
+// matchCache memoizes pattern matches by name, since globbing every name
+// on every call costs more than the rest of a filtered-out call
+type matchCache struct {
+	entries sync.Map
+	size    atomic.Int32
+}
+
+// lookup returns the cached result for name, computing it with match on
+// a miss
+func (c *matchCache) lookup(name string, match func() bool) bool {
+	if cached, ok := c.entries.Load(name); ok {
+		return cached.(bool)
+	}
+
+	matched := match()
+	if c.size.Load() < maxMatchCache {
+		if _, loaded := c.entries.LoadOrStore(name, matched); !loaded {
+			c.size.Add(1)
+		}
+	}
+	return matched
+}
+
+// reset forgets cached results after the patterns change
+func (c *matchCache) reset() {
+	c.entries.Clear()
+	c.size.Store(0)
+}
+
 // DurationFilter filters events based on minimum duration
 type DurationFilter struct {
 	minDuration time.Duration
@@ -213,6 +340,16 @@ func ExcludeFunctions(patterns ...string) Filter {
 	return NewFunctionFilter().ExcludeFunctions(patterns...)
 }
 
+// IncludeSources creates a filter that includes only specified source files
+func IncludeSources(patterns ...string) Filter {
+	return NewSourceFilter().IncludeSources(patterns...)
+}
+
+// ExcludeSources creates a filter that excludes specified source files
+func ExcludeSources(patterns ...string) Filter {
+	return NewSourceFilter().ExcludeSources(patterns...)
+}
+
 // MinDuration creates a filter that only traces events with minimum duration
 func MinDuration(duration time.Duration) Filter {
 	return NewDurationFilter(duration)
@@ -234,3 +371,40 @@ func ExcludeCommonNoise() Filter {
 		"fmt.*",
 	)
 }
+
+// SamplingFilter keeps a fraction of traces. Sampling is decided per trace
+// ID, so the call and return events of a traced call are kept or dropped
+// together.
+type SamplingFilter struct {
+	threshold uint64
+}
+
+// NewSamplingFilter creates a new sampling filter keeping rate (0 to 1) of traces
+func NewSamplingFilter(rate float64) *SamplingFilter {
+	switch {
+	case rate <= 0:
+		return &SamplingFilter{threshold: 0}
+	case rate >= 1:
+		return &SamplingFilter{threshold: math.MaxUint64}
+	}
+	return &SamplingFilter{threshold: uint64(rate * math.MaxUint64)}
+}
+
+// ShouldTrace determines if an event should be traced
+func (f *SamplingFilter) ShouldTrace(event Event) bool {
+	if f.threshold == math.MaxUint64 {
+		return true
+	}
+	if event.TraceID == "" {
+		return rand.Uint64() < f.threshold
+	}
+
+	hash := fnv.New64a()
+	hash.Write([]byte(event.TraceID))
+	return hash.Sum64() < f.threshold
+}
+
+// Sample creates a filter that keeps only rate (0 to 1) of traces
+func Sample(rate float64) Filter {
+	return NewSamplingFilter(rate)
+}
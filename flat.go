@@ -0,0 +1,103 @@
+package lens
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// flatKind is the storage type of a flat export column
+type flatKind int
+
+const (
+	flatString flatKind = iota
+	flatInt64
+	flatTimestamp
+)
+
+// flatColumn is one column of the flat, one-row-per-event schema shared by
+// the CSV and Parquet writers. Nested values are exported as JSON text.
+type flatColumn struct {
+	name  string
+	kind  flatKind
+	value func(event Event) interface{}
+}
+
+// flatColumns lists the exported columns in order
+var flatColumns = []flatColumn{
+	{"timestamp", flatTimestamp, func(e Event) interface{} { return e.Timestamp }},
+	{"id", flatString, func(e Event) interface{} { return e.ID }},
+	{"trace_id", flatString, func(e Event) interface{} { return e.TraceID }},
+	{"span_id", flatString, func(e Event) interface{} { return e.SpanID }},
+	{"type", flatString, func(e Event) interface{} { return string(e.Type) }},
+	{"component", flatString, func(e Event) interface{} { return e.Component }},
+	{"function", flatString, func(e Event) interface{} { return e.Function }},
+	{"variable", flatString, func(e Event) interface{} { return e.Variable }},
+	{"arguments", flatString, func(e Event) interface{} { return flatJSON(e.Arguments) }},
+	{"return_value", flatString, func(e Event) interface{} { return flatJSON(e.ReturnValue) }},
+	{"old_value", flatString, func(e Event) interface{} { return flatJSON(e.OldValue) }},
+	{"new_value", flatString, func(e Event) interface{} { return flatJSON(e.NewValue) }},
+	{"changes", flatString, func(e Event) interface{} { return flatJSON(e.Changes) }},
+	{"error", flatString, func(e Event) interface{} { return e.Error }},
+	{"duration_ns", flatInt64, func(e Event) interface{} { return int64(e.Duration) }},
+	{"goroutine", flatInt64, func(e Event) interface{} { return int64(e.Goroutine) }},
+	{"depth", flatInt64, func(e Event) interface{} { return int64(e.Depth) }},
+	{"source_file", flatString, func(e Event) interface{} { return e.SourceFile }},
+	{"source_line", flatInt64, func(e Event) interface{} { return int64(e.SourceLine) }},
+	{"tags", flatString, func(e Event) interface{} { return flatJSON(e.Tags) }},
+	{"links", flatString, func(e Event) interface{} { return flatJSON(e.Links) }},
+}
+
+// flatJSON encodes a nested value as JSON text, or "" when it is empty
+func flatJSON(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		if len(value) == 0 {
+			return ""
+		}
+		// Errors have no exported fields and would encode as {}
+		converted := make([]interface{}, len(value))
+		for i, item := range value {
+			if err, ok := item.(error); ok && err != nil {
+				converted[i] = err.Error()
+			} else {
+				converted[i] = item
+			}
+		}
+		v = converted
+	case map[string]interface{}:
+		if len(value) == 0 {
+			return ""
+		}
+	case []Link:
+		if len(value) == 0 {
+			return ""
+		}
+	case []FieldChange:
+		// An empty change set, a write of an equal value, is kept as []
+		if value == nil {
+			return ""
+		}
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// flatText renders a column value as text
+func flatText(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return ""
+}
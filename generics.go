@@ -0,0 +1,103 @@
+package lens
+
+import (
+	"reflect"
+	"strings"
+)
+
+// WrapFunc wraps fn as Wrap does, returning it with its own type, so no
+// type assertion is needed:
+//
+//	mapInts := lens.WrapFunc(tracer, Map[int, string], reflect.TypeFor[int](), reflect.TypeFor[string]())
+//
+// The runtime does not record the type arguments of a generic function
+// instantiation, so its events name it Map unless they are given, as
+// typeArgs, to name it Map[int,string].
+func WrapFunc[F any](t *TracerImpl, fn F, typeArgs ...reflect.Type) F {
+	fnValue := reflect.ValueOf(fn)
+	if compiledOut || !t.enabled || fnValue.Kind() != reflect.Func || fnValue.IsNil() {
+		return fn
+	}
+
+	funcName := ""
+	if len(typeArgs) > 0 {
+		args := make([]string, len(typeArgs))
+		for i, typeArg := range typeArgs {
+			args[i] = typeArg.String()
+		}
+		funcName = instantiate(functionName(fnValue, ""), "["+strings.Join(args, ",")+"]")
+	}
+	return t.wrapFunctionAs(fn, "", funcName).(F)
+}
+
+// instantiate inserts type arguments into a demangled function name,
+// after the generic function or receiver type: main.Map becomes
+// main.Map[int], and main.(*Box).Get becomes main.(*Box[int]).Get
+func instantiate(name, typeArgs string) string {
+	slash := strings.LastIndex(name, "/") + 1
+	dot := strings.IndexByte(name[slash:], '.')
+	if dot < 0 {
+		return name + typeArgs
+	}
+	member := slash + dot + 1
+
+	// A method: the type arguments belong to the receiver type
+	if strings.HasPrefix(name[member:], "(") {
+		if end := strings.IndexByte(name[member:], ')'); end >= 0 {
+			return name[:member+end] + typeArgs + name[member+end:]
+		}
+	}
+	if end := strings.IndexByte(name[member:], '.'); end >= 0 {
+		return name[:member+end] + typeArgs + name[member+end:]
+	}
+	return name + typeArgs
+}
+
+// demangle strips from a runtime function name what generic
+// instantiations add to it: the "[...]" placeholder of type arguments,
+// and the shape types of stenciled code such as
+// main.Map[go.shape.int,go.shape.string]. Other names are unchanged.
+func demangle(name string) string {
+	if !strings.Contains(name, "[") {
+		return name
+	}
+
+	var b strings.Builder
+	for {
+		open := strings.IndexByte(name, '[')
+		if open < 0 {
+			b.WriteString(name)
+			return b.String()
+		}
+		closing := matchingBracket(name, open)
+		if closing < 0 {
+			b.WriteString(name)
+			return b.String()
+		}
+
+		b.WriteString(name[:open])
+		args := name[open+1 : closing]
+		if args != "..." && !strings.Contains(args, "go.shape.") {
+			b.WriteString(name[open : closing+1])
+		}
+		name = name[closing+1:]
+	}
+}
+
+// matchingBracket returns the index of the bracket closing the one at
+// open, or -1
+func matchingBracket(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,76 @@
+package lens
+
+import (
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// Go runs fn in a new goroutine, tracing its start and end
+func (t *TracerImpl) Go(fn func()) {
+	go t.WrapGoroutine(fn)()
+}
+
+// WrapGoroutine returns a func that runs fn with goroutine lifecycle
+// tracing, for use with go statements the tracer does not start itself:
+//
+//	go tracer.WrapGoroutine(worker)()
+//
+// The goroutine calling WrapGoroutine is recorded as the parent. A panic
+// in fn is traced with its stack and then re-raised.
+func (t *TracerImpl) WrapGoroutine(fn func()) func() {
+	parent := getGoroutineID()
+	name := functionName(reflect.ValueOf(fn), "")
+	location := getSourceLocation(2)
+
+	return func() {
+		if !t.enabled {
+			fn()
+			return
+		}
+
+		traceID := generateTraceID()
+		goroutine := getGoroutineID()
+		start := time.Now()
+
+		event := Event{
+			TraceID:         traceID,
+			Function:        name,
+			Goroutine:       goroutine,
+			ParentGoroutine: parent,
+			SourceFile:      location.File,
+			SourceLine:      location.Line,
+			SourceFunction:  location.Function,
+		}
+
+		started := event
+		started.ID = generateEventID()
+		started.Timestamp = start
+		started.Type = EventGoroutineStart
+		t.TraceEvent(started)
+
+		defer func() {
+			ended := event
+			ended.ID = generateEventID()
+			ended.Timestamp = time.Now()
+			ended.Type = EventGoroutineEnd
+			ended.Duration = time.Since(start)
+
+			r := recover()
+			if r != nil {
+				ended.Type = EventPanic
+				ended.Error = fmt.Sprint(r)
+				ended.StackTrace = strings.Split(strings.TrimSpace(string(debug.Stack())), "\n")
+			}
+			t.TraceEvent(ended)
+
+			if r != nil {
+				panic(r)
+			}
+		}()
+
+		fn()
+	}
+}
@@ -0,0 +1,127 @@
+package lens
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// IDGenerator produces the IDs of events, traces and spans. Every ID it
+// returns must be unique, including across goroutines.
+type IDGenerator interface {
+	EventID() string
+	TraceID() string
+	SpanID() string
+}
+
+// idGenerator holds the generator in use; atomic.Value needs one
+// concrete type, hence the holder struct
+var idGenerator atomic.Value
+
+type idGeneratorHolder struct {
+	generator IDGenerator
+}
+
+func init() {
+	idGenerator.Store(idGeneratorHolder{NewIDGenerator()})
+}
+
+// SetIDGenerator replaces the generator of all IDs lens assigns, e.g. with
+// NewSequentialIDGenerator to get the same IDs on every test run. Passing
+// nil restores a default generator.
+func SetIDGenerator(generator IDGenerator) {
+	if generator == nil {
+		generator = NewIDGenerator()
+	}
+	idGenerator.Store(idGeneratorHolder{generator})
+}
+
+// randomIDGenerator combines a random prefix, drawn once, with a counter,
+// so IDs never repeat within a process and are very unlikely to collide
+// with those of another process writing to the same store
+type randomIDGenerator struct {
+	prefix  string
+	counter atomic.Uint64
+}
+
+// NewIDGenerator creates the default generator
+func NewIDGenerator() IDGenerator {
+	var b [6]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// crypto/rand does not fail on supported platforms
+		panic(fmt.Sprintf("lens: failed to read random bytes: %v", err))
+	}
+	return &randomIDGenerator{prefix: hex.EncodeToString(b[:])}
+}
+
+// next formats kind_<prefix><counter>, with the counter as at least ten
+// hex digits. IDs are generated on every traced call, hence the single
+// allocation rather than fmt.
+func (g *randomIDGenerator) next(kind string) string {
+	var digits [16]byte
+	counter := strconv.AppendUint(digits[:0], g.counter.Add(1), 16)
+
+	var id strings.Builder
+	id.Grow(len(kind) + 1 + len(g.prefix) + max(len(counter), 10))
+	id.WriteString(kind)
+	id.WriteByte('_')
+	id.WriteString(g.prefix)
+	for i := len(counter); i < 10; i++ {
+		id.WriteByte('0')
+	}
+	id.Write(counter)
+	return id.String()
+}
+
+// EventID returns a new event ID
+func (g *randomIDGenerator) EventID() string { return g.next("evt") }
+
+// TraceID returns a new trace ID
+func (g *randomIDGenerator) TraceID() string { return g.next("trace") }
+
+// SpanID returns a new span ID
+func (g *randomIDGenerator) SpanID() string { return g.next("span") }
+
+// SequentialIDGenerator numbers events, traces and spans separately from
+// one, for deterministic output in tests and golden files
+type SequentialIDGenerator struct {
+	events atomic.Uint64
+	traces atomic.Uint64
+	spans  atomic.Uint64
+}
+
+// NewSequentialIDGenerator creates a generator counting from one
+func NewSequentialIDGenerator() *SequentialIDGenerator {
+	return &SequentialIDGenerator{}
+}
+
+// EventID returns the next event ID, e.g. evt_00000001
+func (g *SequentialIDGenerator) EventID() string {
+	return fmt.Sprintf("evt_%08d", g.events.Add(1))
+}
+
+// TraceID returns the next trace ID, e.g. trace_000001
+func (g *SequentialIDGenerator) TraceID() string {
+	return fmt.Sprintf("trace_%06d", g.traces.Add(1))
+}
+
+// SpanID returns the next span ID, e.g. span_000001
+func (g *SequentialIDGenerator) SpanID() string {
+	return fmt.Sprintf("span_%06d", g.spans.Add(1))
+}
+
+// Helper functions for generating IDs
+func generateEventID() string {
+	return idGenerator.Load().(idGeneratorHolder).generator.EventID()
+}
+
+func generateTraceID() string {
+	return idGenerator.Load().(idGeneratorHolder).generator.TraceID()
+}
+
+func generateSpanID() string {
+	return idGenerator.Load().(idGeneratorHolder).generator.SpanID()
+}
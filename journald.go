@@ -0,0 +1,116 @@
+package lens
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// journaldSocket is the default systemd-journald native protocol socket
+const journaldSocket = "/run/systemd/journal/socket"
+
+// JournaldWriter writes trace events to systemd-journald using native fields
+type JournaldWriter struct {
+	identifier string
+	conn       net.Conn
+	mutex      sync.Mutex
+}
+
+// NewJournaldWriter creates a new journald writer
+func NewJournaldWriter() (*JournaldWriter, error) {
+	conn, err := net.Dial("unixgram", journaldSocket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to journald: %w", err)
+	}
+
+	return &JournaldWriter{
+		identifier: filepath.Base(os.Args[0]),
+		conn:       conn,
+	}, nil
+}
+
+// Write writes an event to journald
+func (w *JournaldWriter) Write(event Event) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.conn == nil {
+		return fmt.Errorf("journald writer is closed")
+	}
+
+	if _, err := w.conn.Write(w.encode(event)); err != nil {
+		return fmt.Errorf("failed to write to journald: %w", err)
+	}
+
+	return nil
+}
+
+// encode encodes an event in the journald native protocol
+func (w *JournaldWriter) encode(event Event) []byte {
+	var buf bytes.Buffer
+
+	field := func(name, value string) {
+		if value == "" {
+			return
+		}
+		if strings.ContainsRune(value, '\n') {
+			// Multi-line values use the binary length-prefixed form
+			buf.WriteString(name)
+			buf.WriteByte('\n')
+			binary.Write(&buf, binary.LittleEndian, uint64(len(value)))
+			buf.WriteString(value)
+			buf.WriteByte('\n')
+			return
+		}
+		buf.WriteString(name)
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+	}
+
+	field("MESSAGE", strings.TrimSpace(string(event.Type)+" "+formatEventDetails(event)))
+	field("PRIORITY", strconv.Itoa(syslogSeverity(event)))
+	field("SYSLOG_IDENTIFIER", w.identifier)
+	field("CODE_FILE", event.CallerFile)
+	if event.CallerLine > 0 {
+		field("CODE_LINE", strconv.Itoa(event.CallerLine))
+	}
+	field("CODE_FUNC", event.CallerFunction)
+	field("LENS_EVENT_ID", event.ID)
+	field("LENS_TRACE_ID", event.TraceID)
+	field("LENS_EVENT_TYPE", string(event.Type))
+	field("LENS_COMPONENT", event.Component)
+	field("LENS_FUNCTION", event.Function)
+	field("LENS_VARIABLE", event.Variable)
+	field("LENS_ERROR", event.Error)
+	if event.Duration > 0 {
+		field("LENS_DURATION_NS", strconv.FormatInt(int64(event.Duration), 10))
+	}
+	field("LENS_GOROUTINE", strconv.Itoa(event.Goroutine))
+
+	return buf.Bytes()
+}
+
+// Flush flushes any buffered data (no-op for journald)
+func (w *JournaldWriter) Flush() error {
+	return nil
+}
+
+// Close closes the writer
+func (w *JournaldWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.conn != nil {
+		err := w.conn.Close()
+		w.conn = nil
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package lens
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// EventEncoder encodes an event into bytes for transport
+type EventEncoder func(event Event) ([]byte, error)
+
+// JSONEncoder encodes events as JSON
+func JSONEncoder(event Event) ([]byte, error) {
+	return json.Marshal(event)
+}
+
+// KafkaMessage is a single record published to Kafka
+type KafkaMessage struct {
+	Topic string
+	Key   []byte
+	Value []byte
+}
+
+// KafkaProducer publishes batches of messages to Kafka.
+// Implement it on top of the Kafka client your application already uses
+// (sarama, kafka-go, franz-go, ...); lens does not depend on any of them.
+type KafkaProducer interface {
+	Produce(ctx context.Context, messages []KafkaMessage) error
+	Close() error
+}
+
+// KafkaWriter publishes trace events to a Kafka topic, keyed by trace ID
+type KafkaWriter struct {
+	producer      KafkaProducer
+	topic         string
+	encoder       EventEncoder
+	batchSize     int
+	flushInterval time.Duration
+	timeout       time.Duration
+	onFailure     func(messages []KafkaMessage, err error)
+	batch         []KafkaMessage
+	mutex         sync.Mutex
+	// Serializes publishes, which come from Write, flushLoop and Close, so
+	// batches reach the producer in the order they were taken
+	publishMutex sync.Mutex
+	done         chan struct{}
+	wg           sync.WaitGroup
+	closed       bool
+}
+
+// KafkaWriterOption is a function that configures a Kafka writer
+type KafkaWriterOption func(*KafkaWriter)
+
+// WithKafkaEncoder sets the event value encoder (JSON by default)
+func WithKafkaEncoder(encoder EventEncoder) KafkaWriterOption {
+	return func(w *KafkaWriter) {
+		w.encoder = encoder
+	}
+}
+
+// WithKafkaBatchSize sets the number of events buffered before publishing
+func WithKafkaBatchSize(size int) KafkaWriterOption {
+	return func(w *KafkaWriter) {
+		w.batchSize = size
+	}
+}
+
+// WithKafkaFlushInterval sets how often partial batches are published
+func WithKafkaFlushInterval(interval time.Duration) KafkaWriterOption {
+	return func(w *KafkaWriter) {
+		w.flushInterval = interval
+	}
+}
+
+// WithKafkaTimeout sets the timeout for publishing a single batch
+func WithKafkaTimeout(timeout time.Duration) KafkaWriterOption {
+	return func(w *KafkaWriter) {
+		w.timeout = timeout
+	}
+}
+
+// WithKafkaDeliveryFailure sets a callback invoked when a batch fails to publish
+func WithKafkaDeliveryFailure(callback func(messages []KafkaMessage, err error)) KafkaWriterOption {
+	return func(w *KafkaWriter) {
+		w.onFailure = callback
+	}
+}
+
+// NewKafkaWriter creates a new Kafka writer
+func NewKafkaWriter(producer KafkaProducer, topic string, options ...KafkaWriterOption) *KafkaWriter {
+	w := &KafkaWriter{
+		producer:      producer,
+		topic:         topic,
+		encoder:       JSONEncoder,
+		batchSize:     100,
+		flushInterval: time.Second,
+		timeout:       10 * time.Second,
+		batch:         make([]KafkaMessage, 0),
+		done:          make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(w)
+	}
+
+	if w.flushInterval > 0 {
+		w.wg.Add(1)
+		go w.flushLoop()
+	}
+
+	return w
+}
+
+// flushLoop periodically publishes partial batches
+func (w *KafkaWriter) flushLoop() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.Flush()
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// Write buffers an event and publishes the batch once it is full
+func (w *KafkaWriter) Write(event Event) error {
+	value, err := w.encoder(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	w.mutex.Lock()
+	if w.closed {
+		w.mutex.Unlock()
+		return fmt.Errorf("kafka writer is closed")
+	}
+	w.batch = append(w.batch, KafkaMessage{
+		Topic: w.topic,
+		Key:   []byte(event.TraceID),
+		Value: value,
+	})
+	if len(w.batch) < w.batchSize {
+		w.mutex.Unlock()
+		return nil
+	}
+	return w.publishBatch()
+}
+
+// publishBatch detaches the current batch and publishes it; the caller
+// must hold the mutex, which is released once the batch is next in line
+func (w *KafkaWriter) publishBatch() error {
+	batch := w.batch
+	w.batch = make([]KafkaMessage, 0, w.batchSize)
+	w.publishMutex.Lock()
+	defer w.publishMutex.Unlock()
+	w.mutex.Unlock()
+
+	return w.publish(batch)
+}
+
+// publish sends a batch to the producer and reports delivery failures
+func (w *KafkaWriter) publish(batch []KafkaMessage) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), w.timeout)
+	defer cancel()
+
+	if err := w.producer.Produce(ctx, batch); err != nil {
+		if w.onFailure != nil {
+			w.onFailure(batch, err)
+		}
+		return fmt.Errorf("failed to publish to kafka: %w", err)
+	}
+	return nil
+}
+
+// Flush publishes any buffered events
+func (w *KafkaWriter) Flush() error {
+	w.mutex.Lock()
+	return w.publishBatch()
+}
+
+// Close flushes buffered events and closes the producer
+func (w *KafkaWriter) Close() error {
+	w.mutex.Lock()
+	if w.closed {
+		w.mutex.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.mutex.Unlock()
+
+	close(w.done)
+	w.wg.Wait()
+
+	flushErr := w.Flush()
+	if err := w.producer.Close(); err != nil {
+		return fmt.Errorf("failed to close kafka producer: %w", err)
+	}
+	return flushErr
+}
@@ -0,0 +1,269 @@
+package lens
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// LazyWriter connects a network-backed writer in the background.
+// Until the connection is established, events are buffered in memory and,
+// once the memory buffer is full, optionally spilled to a file on disk.
+// Writes never block on the connection and construction never fails, so
+// an unavailable telemetry backend cannot stall application startup.
+type LazyWriter struct {
+	connect    func() (Writer, error)
+	inner      Writer
+	connectErr error
+	retries    int
+	backoff    time.Duration
+	capacity   int
+	spillPath  string
+	spill      *os.File
+	buffer     []Event
+	dropped    uint64
+	mutex      sync.Mutex
+	done       chan struct{}
+	closed     bool
+}
+
+// LazyWriterOption is a function that configures a lazy writer
+type LazyWriterOption func(*LazyWriter)
+
+// WithLazyRetries sets how many connection attempts are made before giving
+// up. At least one attempt is always made.
+func WithLazyRetries(retries int) LazyWriterOption {
+	return func(w *LazyWriter) {
+		w.retries = max(retries, 1)
+	}
+}
+
+// WithLazyBackoff sets the initial delay between attempts; it doubles each retry
+func WithLazyBackoff(backoff time.Duration) LazyWriterOption {
+	return func(w *LazyWriter) {
+		w.backoff = backoff
+	}
+}
+
+// WithLazyBufferSize sets how many events are held in memory while connecting
+func WithLazyBufferSize(size int) LazyWriterOption {
+	return func(w *LazyWriter) {
+		w.capacity = size
+	}
+}
+
+// WithLazySpillFile spills events that overflow the memory buffer to path
+func WithLazySpillFile(path string) LazyWriterOption {
+	return func(w *LazyWriter) {
+		w.spillPath = path
+	}
+}
+
+// NewLazyWriter creates a writer that calls connect in the background, e.g.
+//
+//	lens.NewLazyWriter(func() (lens.Writer, error) {
+//		return lens.NewSyslogWriter("tcp", "logs:514", lens.FacilityLocal0)
+//	})
+func NewLazyWriter(connect func() (Writer, error), options ...LazyWriterOption) *LazyWriter {
+	w := &LazyWriter{
+		connect:  connect,
+		retries:  5,
+		backoff:  500 * time.Millisecond,
+		capacity: 10000,
+		buffer:   make([]Event, 0),
+		done:     make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(w)
+	}
+
+	go w.connectLoop()
+
+	return w
+}
+
+// connectLoop attempts to connect with exponential backoff
+func (w *LazyWriter) connectLoop() {
+	delay := w.backoff
+
+	for attempt := 0; attempt < w.retries; attempt++ {
+		inner, err := w.connect()
+		if err == nil {
+			w.attach(inner)
+			return
+		}
+
+		w.mutex.Lock()
+		w.connectErr = err
+		w.mutex.Unlock()
+
+		if attempt == w.retries-1 {
+			break
+		}
+		select {
+		case <-time.After(delay):
+			delay *= 2
+		case <-w.done:
+			return
+		}
+	}
+
+	// Out of retries: discard anything buffered
+	w.mutex.Lock()
+	w.buffer = nil
+	w.closeSpill()
+	w.mutex.Unlock()
+}
+
+// attach installs the connected writer and drains buffered events into it
+func (w *LazyWriter) attach(inner Writer) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		inner.Close()
+		return
+	}
+
+	// Memory holds the oldest events, the spill file the newer ones
+	for _, event := range w.buffer {
+		inner.Write(event)
+	}
+	w.buffer = nil
+
+	if w.spill != nil {
+		w.drainSpill(inner)
+		w.closeSpill()
+	}
+
+	w.inner = inner
+	w.connectErr = nil
+}
+
+// Write forwards the event, or buffers it while still connecting
+func (w *LazyWriter) Write(event Event) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.inner != nil {
+		return w.inner.Write(event)
+	}
+
+	if w.closed || w.buffer == nil {
+		w.dropped++
+		if w.connectErr != nil {
+			return fmt.Errorf("writer not connected: %w", w.connectErr)
+		}
+		return fmt.Errorf("writer not connected")
+	}
+
+	if len(w.buffer) < w.capacity {
+		w.buffer = append(w.buffer, event)
+		return nil
+	}
+
+	if w.spillPath == "" {
+		w.dropped++
+		return fmt.Errorf("lazy writer buffer full")
+	}
+
+	return w.spillEvent(event)
+}
+
+// spillEvent appends an event to the spill file; the caller must hold the mutex
+func (w *LazyWriter) spillEvent(event Event) error {
+	if w.spill == nil {
+		file, err := os.OpenFile(w.spillPath, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0600)
+		if err != nil {
+			w.dropped++
+			return fmt.Errorf("failed to open spill file: %w", err)
+		}
+		w.spill = file
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	if _, err := w.spill.Write(append(data, '\n')); err != nil {
+		w.dropped++
+		return fmt.Errorf("failed to write to spill file: %w", err)
+	}
+	return nil
+}
+
+// drainSpill replays spilled events into inner; the caller must hold the mutex
+func (w *LazyWriter) drainSpill(inner Writer) {
+	if _, err := w.spill.Seek(0, 0); err != nil {
+		return
+	}
+
+	scanner := bufio.NewScanner(w.spill)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err == nil {
+			inner.Write(event)
+		}
+	}
+}
+
+// closeSpill closes and removes the spill file; the caller must hold the mutex
+func (w *LazyWriter) closeSpill() {
+	if w.spill != nil {
+		w.spill.Close()
+		os.Remove(w.spillPath)
+		w.spill = nil
+	}
+}
+
+// Connected reports whether the underlying writer is connected
+func (w *LazyWriter) Connected() bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.inner != nil
+}
+
+// Dropped returns the number of events dropped while not connected
+func (w *LazyWriter) Dropped() uint64 {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.dropped
+}
+
+// Flush flushes the underlying writer once connected
+func (w *LazyWriter) Flush() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.inner != nil {
+		return w.inner.Flush()
+	}
+	return nil
+}
+
+// Close stops connecting and closes the underlying writer
+func (w *LazyWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	close(w.done)
+	w.closeSpill()
+	w.buffer = nil
+
+	if w.inner != nil {
+		err := w.inner.Close()
+		w.inner = nil
+		return err
+	}
+	return nil
+}
@@ -2,7 +2,11 @@
 package lens
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"time"
@@ -29,21 +33,27 @@ type Tracer interface {
 
 // Event represents a single trace event
 type Event struct {
-	ID          string        `json:"id"`
-	TraceID     string        `json:"trace_id"`
-	Timestamp   time.Time     `json:"timestamp"`
-	Type        EventType     `json:"type"`
-	Component   string        `json:"component"`
-	Function    string        `json:"function,omitempty"`
-	Variable    string        `json:"variable,omitempty"`
-	OldValue    interface{}   `json:"old_value,omitempty"`
-	NewValue    interface{}   `json:"new_value,omitempty"`
-	Arguments   []interface{} `json:"arguments,omitempty"`
-	ReturnValue []interface{} `json:"return_value,omitempty"`
-	Error       string        `json:"error,omitempty"`
-	Duration    time.Duration `json:"duration,omitempty"`
-	StackTrace  []string      `json:"stack_trace,omitempty"`
-	Goroutine   int           `json:"goroutine"`
+	ID      string `json:"id"`
+	TraceID string `json:"trace_id"`
+	SpanID  string `json:"span_id,omitempty"`
+	// Correlation ID of another tracing system, see WithExternalTraceID
+	ExternalTraceID string        `json:"external_trace_id,omitempty"`
+	Timestamp       time.Time     `json:"timestamp"`
+	Type            EventType     `json:"type"`
+	Component       string        `json:"component"`
+	Function        string        `json:"function,omitempty"`
+	Variable        string        `json:"variable,omitempty"`
+	OldValue        interface{}   `json:"old_value,omitempty"`
+	NewValue        interface{}   `json:"new_value,omitempty"`
+	Arguments       []interface{} `json:"arguments,omitempty"`
+	ReturnValue     []interface{} `json:"return_value,omitempty"`
+	Error           string        `json:"error,omitempty"`
+	Duration        time.Duration `json:"duration,omitempty"`
+	StackTrace      []string      `json:"stack_trace,omitempty"`
+	Goroutine       int           `json:"goroutine"`
+	Depth           int           `json:"depth,omitempty"`
+	// Calls to the same function the call is nested in on its goroutine
+	RecursionDepth int `json:"recursion_depth,omitempty"`
 	// Enhanced source location information
 	SourceFile     string `json:"source_file,omitempty"`
 	SourceLine     int    `json:"source_line,omitempty"`
@@ -51,8 +61,33 @@ type Event struct {
 	CallerFile     string `json:"caller_file,omitempty"`
 	CallerLine     int    `json:"caller_line,omitempty"`
 	CallerFunction string `json:"caller_function,omitempty"`
+	// Free-form key/value metadata attached by enrichers and spans
+	Tags map[string]interface{} `json:"tags,omitempty"`
+	// Field-level changes for state diff events and struct or map variable writes
+	Changes []FieldChange `json:"changes,omitempty"`
+	// Heap allocations during the call, recorded with WithMemoryStats
+	Allocs     uint64 `json:"allocs,omitempty"`
+	AllocBytes uint64 `json:"alloc_bytes,omitempty"`
+	// Arguments keyed by parameter name, recorded with WithParamNames
+	Params map[string]interface{} `json:"params,omitempty"`
+	// Goroutine that started this one, for goroutine lifecycle events
+	ParentGoroutine int `json:"parent_goroutine,omitempty"`
+	// Version of the event schema, see SchemaVersion
+	SchemaVersion int `json:"schema_version,omitempty"`
+	// Process-wide emission order, which breaks ties between timestamps
+	Seq uint64 `json:"seq,omitempty"`
+	// Spans in other traces referenced with Span.AddLink
+	Links []Link `json:"links,omitempty"`
 }
 
+// SchemaVersion is the version of the JSON event schema written by this
+// release. Events without a schema_version are version 1. The reader
+// package upgrades older events on load.
+//
+//	1  original format; goroutine held the goroutine count, not an ID
+//	2  adds schema_version; goroutine holds the goroutine ID
+const SchemaVersion = 2
+
 // EventType defines the type of trace event
 type EventType string
 
@@ -66,8 +101,23 @@ const (
 	EventSliceOperation   EventType = "slice_operation"
 	EventMapOperation     EventType = "map_operation"
 	EventChannelOperation EventType = "channel_operation"
+	EventLockOperation    EventType = "lock_operation"
 	EventError            EventType = "error"
 	EventPanic            EventType = "panic"
+	EventStateDiff        EventType = "state_diff"
+	EventBlocked          EventType = "blocked"
+	EventTrigger          EventType = "trigger"
+	EventSpanEvent        EventType = "span_event"
+	EventGoroutineStart   EventType = "goroutine_start"
+	EventGoroutineEnd     EventType = "goroutine_end"
+	EventBudgetExceeded   EventType = "budget_exceeded"
+	EventRuntime          EventType = "runtime"
+	EventLog              EventType = "log"
+	EventSlowCall         EventType = "slow_call"
+	EventRecursion        EventType = "recursion"
+	EventRetryAttempt     EventType = "retry_attempt"
+	EventQuotaExceeded    EventType = "quota_exceeded"
+	EventWriterUnhealthy  EventType = "writer_unhealthy"
 )
 
 // Level defines the tracing level
@@ -82,6 +132,35 @@ const (
 	LevelTrace
 )
 
+// levelNames maps levels to their configuration names
+var levelNames = map[Level]string{
+	LevelOff:   "off",
+	LevelError: "error",
+	LevelWarn:  "warn",
+	LevelInfo:  "info",
+	LevelDebug: "debug",
+	LevelTrace: "trace",
+}
+
+// String returns the level name
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("Level(%d)", int(l))
+}
+
+// ParseLevel converts a level name such as "debug" to a Level
+func ParseLevel(name string) (Level, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for level, levelName := range levelNames {
+		if name == levelName {
+			return level, nil
+		}
+	}
+	return LevelOff, fmt.Errorf("unknown level %q", name)
+}
+
 // Span represents a trace span
 type Span interface {
 	End()
@@ -89,6 +168,58 @@ type Span interface {
 	SetError(err error)
 }
 
+// The interfaces below extend Tracer and Span with methods added after
+// them, so implementations outside lens keep satisfying Tracer and Span.
+// TracerImpl and its spans implement all of them, as do Noop tracers; check
+// for one with a type assertion before use.
+
+// FieldAccessTracer traces reads of fields, as the accessors lensgen
+// generates do
+type FieldAccessTracer interface {
+	TraceFieldAccess(name string, value interface{})
+}
+
+// Emitter traces events of user-defined types, see TracerImpl.Emit
+type Emitter interface {
+	Emit(eventType string, attrs map[string]interface{})
+}
+
+// StatusSetter is a span with an OpenTelemetry status
+type StatusSetter interface {
+	SetStatus(code StatusCode, message string)
+}
+
+// EventAdder is a span recording named events, see SpanImpl.AddEvent
+type EventAdder interface {
+	AddEvent(name string, attrs map[string]interface{})
+}
+
+// Marker is a span recording its progress, see SpanImpl.Mark
+type Marker interface {
+	Mark(name string)
+	Annotate(key string, value interface{})
+}
+
+// Linker is a span linking to spans in other traces
+type Linker interface {
+	AddLink(traceID, spanID string, attrs map[string]interface{})
+}
+
+// Link references a span in another trace, such as one of the requests
+// that produced the jobs a worker consumes. Links follow OpenTelemetry
+// semantics and are recorded on the span's end event.
+type Link struct {
+	TraceID    string                 `json:"trace_id"`
+	SpanID     string                 `json:"span_id,omitempty"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// TagEventName holds the name of span events added with AddEvent
+const TagEventName = "event.name"
+
+// TagSpanElapsed holds the time from the start of a span to a mark
+const TagSpanElapsed = "span.elapsed"
+
 // Writer interface for outputting trace events
 type Writer interface {
 	Write(event Event) error
@@ -101,19 +232,40 @@ type Filter interface {
 	ShouldTrace(event Event) bool
 }
 
+// Processor interface for transforming trace events after filtering
+type Processor interface {
+	Process(event Event) Event
+}
+
+// ContextEnricher interface for stamping context values onto trace events
+type ContextEnricher interface {
+	Enrich(ctx context.Context, event Event) Event
+}
+
 // New creates a new tracer instance
 func New(options ...Option) *TracerImpl {
 	tracer := &TracerImpl{
 		level:   LevelInfo,
-		enabled: true,
+		enabled: !compiledOut,
 		writers: make([]Writer, 0),
 		filters: make([]Filter, 0),
+		depths:  newDepthTracker(),
 	}
 
 	for _, option := range options {
 		option(tracer)
 	}
 
+	if tracer.reaper != nil {
+		tracer.reaper.start()
+	}
+	if tracer.stateFile != nil {
+		tracer.stateFile.start(tracer)
+	}
+	if tracer.closeOnInterrupt > 0 {
+		tracer.closeOnSignal(tracer.closeOnInterrupt, os.Interrupt)
+	}
+
 	return tracer
 }
 
@@ -134,6 +286,49 @@ func WithWriter(writer Writer) Option {
 	}
 }
 
+// WithMaxSpanDuration force-ends spans left open longer than max.
+// Abandoned spans are ended with the tag span.status=abandoned. A max of
+// zero or less disables the reaper.
+func WithMaxSpanDuration(max time.Duration) Option {
+	return func(t *TracerImpl) {
+		if max <= 0 {
+			t.reaper = nil
+			return
+		}
+		t.reaper = newSpanReaper(t, max)
+	}
+}
+
+// WithBlockThreshold flags traced channel operations blocked longer than threshold
+func WithBlockThreshold(threshold time.Duration) Option {
+	return func(t *TracerImpl) {
+		t.blockThreshold = threshold
+	}
+}
+
+// WithMemoryStats records heap allocation deltas on function return events.
+// The counters are process-wide, so allocations by concurrently running
+// goroutines are included.
+func WithMemoryStats() Option {
+	return func(t *TracerImpl) {
+		t.memoryStats = true
+	}
+}
+
+// WithProcessor adds a processor to the tracer
+func WithProcessor(processor Processor) Option {
+	return func(t *TracerImpl) {
+		t.processors = append(t.processors, processor)
+	}
+}
+
+// WithEnricher adds a context enricher to the tracer
+func WithEnricher(enricher ContextEnricher) Option {
+	return func(t *TracerImpl) {
+		t.enrichers = append(t.enrichers, enricher)
+	}
+}
+
 // SourceLocation represents source code location information
 type SourceLocation struct {
 	File     string
@@ -155,7 +350,7 @@ func getSourceLocation(skip int) SourceLocation {
 			fn := runtime.FuncForPC(pc)
 			var funcName string
 			if fn != nil {
-				funcName = fn.Name()
+				funcName = demangle(fn.Name())
 			}
 
 			return SourceLocation{
@@ -175,7 +370,7 @@ func getSourceLocation(skip int) SourceLocation {
 	fn := runtime.FuncForPC(pc)
 	var funcName string
 	if fn != nil {
-		funcName = fn.Name()
+		funcName = demangle(fn.Name())
 	}
 
 	return SourceLocation{
@@ -195,9 +390,30 @@ func isInternalFile(file string) bool {
 	if strings.Contains(file, "lens.go") || strings.Contains(file, "tracer.go") {
 		return true
 	}
+	// Skip the rest of the lens package, such as WrapDeep
+	if lensDir != "" && filepath.Dir(file) == lensDir {
+		return true
+	}
+	// Skip wrappers and accessors generated by lensgen
+	if strings.HasSuffix(file, "_traced.go") {
+		return true
+	}
+	// Skip the autotrace hook injected by lens-build
+	if lensDir != "" && strings.HasPrefix(file, lensDir+"/autotrace/") {
+		return true
+	}
 	return false
 }
 
+// lensDir is the directory holding the lens package sources
+var lensDir = func() string {
+	_, file, _, ok := runtime.Caller(0)
+	if !ok {
+		return ""
+	}
+	return filepath.Dir(file)
+}()
+
 // getCallerLocation returns the caller's source location
 func getCallerLocation(skip int) SourceLocation {
 	return getSourceLocation(skip + 1)
@@ -213,7 +429,7 @@ func getStackTrace(skip int) []string {
 		}
 		fn := runtime.FuncForPC(pc)
 		if fn != nil {
-			traces = append(traces, fmt.Sprintf("%s:%d %s", file, line, fn.Name()))
+			traces = append(traces, fmt.Sprintf("%s:%d %s", file, line, demangle(fn.Name())))
 		}
 	}
 	return traces
@@ -221,6 +437,22 @@ func getStackTrace(skip int) []string {
 
 // getGoroutineID returns the current goroutine ID
 func getGoroutineID() int {
-	// Simple implementation - in production, this would be more sophisticated
-	return int(runtime.NumGoroutine())
+	// The runtime does not expose goroutine IDs, but every stack trace
+	// starts with "goroutine N [status]:"
+	var buf [64]byte
+	n := runtime.Stack(buf[:], false)
+	digits, ok := bytes.CutPrefix(buf[:n], []byte("goroutine "))
+	if !ok {
+		return 0
+	}
+
+	// Parse in place; this runs on every traced call
+	id := 0
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			break
+		}
+		id = id*10 + int(c-'0')
+	}
+	return id
 }
@@ -0,0 +1,195 @@
+// Package lensfixtures generates realistic synthetic lens traces for
+// writer benchmarks, viewer development and demos
+package lensfixtures
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/baretech/lens"
+)
+
+// Config controls the shape of generated traces
+type Config struct {
+	// Traces is the number of root calls to generate
+	Traces int
+	// Depth is the maximum nesting depth below each root call
+	Depth int
+	// FanOut is the maximum number of child calls per call
+	FanOut int
+	// ErrorRate is the probability in [0, 1] that a call fails
+	ErrorRate float64
+	// Components are the participants calls are spread across
+	Components []string
+	// MeanDuration is the average self time of a single call
+	MeanDuration time.Duration
+	// Start is the timestamp of the first event
+	Start time.Time
+	// Seed makes generation deterministic
+	Seed int64
+}
+
+// DefaultConfig returns a config producing small, service-like traces
+func DefaultConfig() Config {
+	return Config{
+		Traces:       10,
+		Depth:        3,
+		FanOut:       3,
+		ErrorRate:    0.05,
+		Components:   []string{"api", "auth", "billing", "db", "cache"},
+		MeanDuration: 200 * time.Microsecond,
+		Start:        time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+		Seed:         1,
+	}
+}
+
+// verbs are combined with components to name synthetic functions
+var verbs = []string{"Get", "List", "Create", "Update", "Delete", "Validate", "Load", "Save"}
+
+// generator holds the state of a single generation run
+type generator struct {
+	cfg    Config
+	rng    *rand.Rand
+	now    time.Time
+	nextID int
+	events []lens.Event
+}
+
+// Generate returns the events of cfg.Traces synthetic traces in emission order
+func Generate(cfg Config) []lens.Event {
+	if len(cfg.Components) == 0 {
+		cfg.Components = DefaultConfig().Components
+	}
+	if cfg.FanOut < 1 {
+		cfg.FanOut = 1
+	}
+	if cfg.MeanDuration <= 0 {
+		cfg.MeanDuration = DefaultConfig().MeanDuration
+	}
+	if cfg.Start.IsZero() {
+		cfg.Start = DefaultConfig().Start
+	}
+
+	g := &generator{
+		cfg: cfg,
+		rng: rand.New(rand.NewSource(cfg.Seed)),
+		now: cfg.Start,
+	}
+
+	for i := 0; i < cfg.Traces; i++ {
+		traceID := fmt.Sprintf("trace_%06d", i+1)
+		goroutine := i + 1
+		g.call(traceID, goroutine, 0, "main.main")
+		// Idle gap between requests
+		g.advance(g.duration() * 5)
+	}
+
+	return g.events
+}
+
+// WriteTo generates traces and writes every event to w
+func WriteTo(w lens.Writer, cfg Config) error {
+	for _, event := range Generate(cfg) {
+		if err := w.Write(event); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// call emits a call, its children and its return; it reports whether it failed
+func (g *generator) call(traceID string, goroutine, depth int, caller string) bool {
+	component := g.cfg.Components[g.rng.Intn(len(g.cfg.Components))]
+	function := fmt.Sprintf("%s.%s%s", component, verbs[g.rng.Intn(len(verbs))], title(component))
+	file := fmt.Sprintf("/app/internal/%s/%s.go", component, component)
+	line := 10 + g.rng.Intn(200)
+	start := g.now
+
+	g.emit(lens.Event{
+		TraceID:        traceID,
+		Type:           lens.EventFunctionCall,
+		Component:      component,
+		Function:       function,
+		Arguments:      []interface{}{g.rng.Intn(1000)},
+		Goroutine:      goroutine,
+		SourceFile:     file,
+		SourceLine:     line,
+		SourceFunction: function,
+		CallerFile:     file,
+		CallerLine:     line,
+		CallerFunction: caller,
+	})
+
+	g.advance(g.duration() / 2)
+
+	failed := false
+	if depth < g.cfg.Depth {
+		children := g.rng.Intn(g.cfg.FanOut + 1)
+		for i := 0; i < children && !failed; i++ {
+			// Errors propagate to the caller
+			failed = g.call(traceID, goroutine, depth+1, function)
+		}
+	}
+
+	g.advance(g.duration() / 2)
+
+	if !failed && g.rng.Float64() < g.cfg.ErrorRate {
+		failed = true
+	}
+
+	event := lens.Event{
+		TraceID:        traceID,
+		Type:           lens.EventFunctionReturn,
+		Component:      component,
+		Function:       function,
+		Duration:       g.now.Sub(start),
+		Goroutine:      goroutine,
+		SourceFile:     file,
+		SourceLine:     line,
+		SourceFunction: function,
+		CallerFile:     file,
+		CallerLine:     line,
+		CallerFunction: caller,
+	}
+	if failed {
+		event.Type = lens.EventError
+		event.Error = fmt.Sprintf("%s: synthetic failure", function)
+		event.ReturnValue = []interface{}{nil, event.Error}
+	} else {
+		event.ReturnValue = []interface{}{g.rng.Intn(1000), nil}
+	}
+	g.emit(event)
+
+	return failed
+}
+
+// emit stamps an ID and timestamp on an event and records it
+func (g *generator) emit(event lens.Event) {
+	g.nextID++
+	event.ID = fmt.Sprintf("evt_%08d", g.nextID)
+	event.Timestamp = g.now
+	g.events = append(g.events, event)
+}
+
+// advance moves the synthetic clock forward
+func (g *generator) advance(d time.Duration) {
+	g.now = g.now.Add(d)
+}
+
+// duration returns an exponentially distributed call duration
+func (g *generator) duration() time.Duration {
+	return time.Duration(g.rng.ExpFloat64() * float64(g.cfg.MeanDuration))
+}
+
+// title upper-cases the first letter of s
+func title(s string) string {
+	if s == "" {
+		return s
+	}
+	b := []byte(s)
+	if b[0] >= 'a' && b[0] <= 'z' {
+		b[0] -= 'a' - 'A'
+	}
+	return string(b)
+}
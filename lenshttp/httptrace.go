@@ -0,0 +1,188 @@
+package lenshttp
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/baretech/lens"
+)
+
+// Tags set on connection spans
+const (
+	TagHost       = "net.host"
+	TagAddrs      = "net.addrs"
+	TagNetwork    = "net.network"
+	TagConnReuse  = "net.conn_reused"
+	TagConnIdle   = "net.conn_was_idle"
+	TagTLSVersion = "tls.version"
+	TagTLSResumed = "tls.resumed"
+	// TagTimeToFirstByte is set on request spans: the time from sending
+	// the request to the first byte of the response
+	TagTimeToFirstByte = "http.time_to_first_byte"
+)
+
+// Names of the child spans of a client request
+const (
+	SpanDNS     = "http.dns"
+	SpanConnect = "http.connect"
+	SpanTLS     = "http.tls"
+	SpanWait    = "http.wait"
+)
+
+// connTracer turns the httptrace callbacks of one request into child
+// spans of the request's span. Callbacks may run on other goroutines, and
+// connects to several addresses may run at once.
+type connTracer struct {
+	tracer   *lens.TracerImpl
+	ctx      context.Context
+	span     lens.Span
+	dns      lens.Span
+	tls      lens.Span
+	wait     lens.Span
+	connects map[string]lens.Span
+	wrote    time.Time
+	mutex    sync.Mutex
+}
+
+// newConnTracer creates a tracer for the request whose span ctx carries
+func newConnTracer(tracer *lens.TracerImpl, ctx context.Context, span lens.Span) *connTracer {
+	return &connTracer{tracer: tracer, ctx: ctx, span: span, connects: make(map[string]lens.Span)}
+}
+
+// clientTrace returns the callbacks to install on the request
+func (c *connTracer) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart:             c.dnsStart,
+		DNSDone:              c.dnsDone,
+		ConnectStart:         c.connectStart,
+		ConnectDone:          c.connectDone,
+		TLSHandshakeStart:    c.tlsStart,
+		TLSHandshakeDone:     c.tlsDone,
+		GotConn:              c.gotConn,
+		WroteRequest:         c.wroteRequest,
+		GotFirstResponseByte: c.gotFirstByte,
+	}
+}
+
+// start starts a child span of the request
+func (c *connTracer) start(name string) lens.Span {
+	_, span := c.tracer.StartSpanContext(c.ctx, name)
+	return span
+}
+
+// end ends a child span, recording err
+func end(span lens.Span, err error) {
+	if span == nil {
+		return
+	}
+	if err != nil {
+		span.SetError(err)
+	}
+	span.End()
+}
+
+func (c *connTracer) dnsStart(info httptrace.DNSStartInfo) {
+	span := c.start(SpanDNS)
+	span.SetTag(TagHost, info.Host)
+
+	c.mutex.Lock()
+	c.dns = span
+	c.mutex.Unlock()
+}
+
+func (c *connTracer) dnsDone(info httptrace.DNSDoneInfo) {
+	c.mutex.Lock()
+	span := c.dns
+	c.dns = nil
+	c.mutex.Unlock()
+
+	if span != nil {
+		addrs := make([]string, len(info.Addrs))
+		for i, addr := range info.Addrs {
+			addrs[i] = addr.String()
+		}
+		span.SetTag(TagAddrs, addrs)
+	}
+	end(span, info.Err)
+}
+
+func (c *connTracer) connectStart(network, addr string) {
+	span := c.start(SpanConnect)
+	span.SetTag(TagNetwork, network)
+	span.SetTag(TagRemoteAddr, addr)
+
+	c.mutex.Lock()
+	c.connects[network+" "+addr] = span
+	c.mutex.Unlock()
+}
+
+func (c *connTracer) connectDone(network, addr string, err error) {
+	key := network + " " + addr
+
+	c.mutex.Lock()
+	span := c.connects[key]
+	delete(c.connects, key)
+	c.mutex.Unlock()
+
+	end(span, err)
+}
+
+func (c *connTracer) tlsStart() {
+	span := c.start(SpanTLS)
+
+	c.mutex.Lock()
+	c.tls = span
+	c.mutex.Unlock()
+}
+
+func (c *connTracer) tlsDone(state tls.ConnectionState, err error) {
+	c.mutex.Lock()
+	span := c.tls
+	c.tls = nil
+	c.mutex.Unlock()
+
+	if span != nil && err == nil {
+		span.SetTag(TagTLSVersion, tls.VersionName(state.Version))
+		span.SetTag(TagTLSResumed, state.DidResume)
+	}
+	end(span, err)
+}
+
+// gotConn tags the request with the connection it is sent on
+func (c *connTracer) gotConn(info httptrace.GotConnInfo) {
+	c.span.SetTag(TagConnReuse, info.Reused)
+	c.span.SetTag(TagConnIdle, info.WasIdle)
+	if info.Conn != nil {
+		c.span.SetTag(TagRemoteAddr, info.Conn.RemoteAddr().String())
+	}
+}
+
+// wroteRequest starts waiting for the response
+func (c *connTracer) wroteRequest(info httptrace.WroteRequestInfo) {
+	if info.Err != nil {
+		return
+	}
+	span := c.start(SpanWait)
+
+	c.mutex.Lock()
+	c.wait = span
+	c.wrote = time.Now()
+	c.mutex.Unlock()
+}
+
+// gotFirstByte ends the wait for the response
+func (c *connTracer) gotFirstByte() {
+	c.mutex.Lock()
+	span := c.wait
+	wrote := c.wrote
+	c.wait = nil
+	c.mutex.Unlock()
+
+	if span != nil {
+		c.span.SetTag(TagTimeToFirstByte, time.Since(wrote).String())
+	}
+	end(span, nil)
+}
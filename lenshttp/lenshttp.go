@@ -0,0 +1,172 @@
+// Package lenshttp traces HTTP clients and servers. Every request a
+// client sends is a span, a child of the span in the request's context,
+// so retries and redirects show up as one span per attempt; every request
+// a server handles is a span continuing the caller's trace:
+//
+//	client := lenshttp.NewClient(tracer, nil)
+//	server := lenshttp.NewServer(tracer, mux)
+//
+// Below client spans, DNS lookups, connects, TLS handshakes and the wait
+// for the first response byte are child spans of their own, showing where
+// the latency of a request goes.
+package lenshttp
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+
+	"github.com/baretech/lens"
+)
+
+// Tags set on HTTP spans
+const (
+	TagMethod       = lens.TagHTTPMethod
+	TagURL          = lens.TagHTTPURL
+	TagStatusCode   = lens.TagHTTPStatusCode
+	TagResponseSize = "http.response_size"
+	// TagResendCount is the number of redirects followed before a request
+	TagResendCount = "http.resend_count"
+	TagRemoteAddr  = "net.remote_addr"
+)
+
+// NewClient returns a copy of base, or of a zero http.Client if base is
+// nil, whose requests are traced
+func NewClient(tracer *lens.TracerImpl, base *http.Client) *http.Client {
+	var client http.Client
+	if base != nil {
+		client = *base
+	}
+	client.Transport = NewTransport(tracer, client.Transport)
+	return &client
+}
+
+// Transport is a traced http.RoundTripper
+type Transport struct {
+	tracer *lens.TracerImpl
+	base   http.RoundTripper
+}
+
+// NewTransport wraps base, or http.DefaultTransport if base is nil
+func NewTransport(tracer *lens.TracerImpl, base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{tracer: tracer, base: base}
+}
+
+// RoundTrip sends a request in a span ending when the response headers
+// arrive. The span and correlation headers are added to the request, in
+// the formats of the tracer's propagators, so a server using NewHandler
+// continues the trace.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := t.tracer.StartSpanContext(req.Context(), req.Method+" "+req.URL.Host+req.URL.Path)
+	span.SetTag(TagMethod, req.Method)
+	span.SetTag(TagURL, req.URL.Redacted())
+	if n := resendCount(req); n > 0 {
+		span.SetTag(TagResendCount, n)
+	}
+
+	// A RoundTripper must not modify the request it is given
+	ctx = httptrace.WithClientTrace(ctx, newConnTracer(t.tracer, ctx, span).clientTrace())
+	out := req.Clone(ctx)
+	t.tracer.Inject(ctx, lens.HeaderCarrier(out.Header))
+
+	resp, err := t.base.RoundTrip(out)
+	if err != nil {
+		span.SetError(err)
+		span.End()
+		return nil, err
+	}
+
+	span.SetTag(TagStatusCode, resp.StatusCode)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		span.SetError(fmt.Errorf("server returned %s", resp.Status))
+	}
+	span.End()
+	return resp, nil
+}
+
+// resendCount returns how many redirects led to req
+func resendCount(req *http.Request) int {
+	n := 0
+	for resp := req.Response; resp != nil && resp.Request != nil; resp = resp.Request.Response {
+		n++
+	}
+	return n
+}
+
+// NewServer returns a server whose requests are traced by NewHandler.
+// Set its address and timeouts before starting it.
+func NewServer(tracer *lens.TracerImpl, handler http.Handler) *http.Server {
+	return &http.Server{Handler: NewHandler(tracer, handler)}
+}
+
+// NewHandler wraps handler so every request is handled in a span. The
+// span continues the trace in the caller's headers, if any, read in the
+// formats of the tracer's propagators, and the request's context carries
+// it, with its correlation IDs and baggage.
+func NewHandler(tracer *lens.TracerImpl, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := tracer.Extract(r.Context(), lens.HeaderCarrier(r.Header))
+		ctx, span := tracer.StartSpanContext(ctx, r.Method+" "+r.URL.Path)
+		span.SetTag(TagMethod, r.Method)
+		span.SetTag(TagURL, r.URL.String())
+		span.SetTag(TagRemoteAddr, r.RemoteAddr)
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			if p := recover(); p != nil {
+				span.SetError(fmt.Errorf("panic: %v", p))
+				span.End()
+				panic(p)
+			}
+		}()
+		handler.ServeHTTP(rec, r.WithContext(ctx))
+
+		span.SetTag(TagStatusCode, rec.status)
+		span.SetTag(TagResponseSize, rec.size)
+		if rec.status >= http.StatusInternalServerError {
+			span.SetError(fmt.Errorf("handler returned %d %s", rec.status, http.StatusText(rec.status)))
+		}
+		span.End()
+	})
+}
+
+// responseRecorder records the status and size of a response
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	size        int
+	wroteHeader bool
+}
+
+// WriteHeader records the status code
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the number of bytes written
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(p)
+	r.size += n
+	return n, err
+}
+
+// Flush flushes the underlying writer, if it supports it
+func (r *responseRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		r.wroteHeader = true
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying writer, for http.ResponseController
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
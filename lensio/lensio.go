@@ -0,0 +1,198 @@
+// Package lensio wraps io.Reader, io.Writer, io.ReadWriteCloser and
+// net.Conn so that every Read, Write and Close is traced as a span
+// recording its duration, byte count, EOF and error:
+//
+//	conn = lensio.NewConn(tracer, conn, "payments")
+//
+// Each operation emits one event named after the wrapper and the
+// operation, e.g. "payments.Read".
+package lensio
+
+import (
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/baretech/lens"
+)
+
+// Tags set on I/O events
+const (
+	// TagBytes is the number of bytes read or written
+	TagBytes = "io.bytes"
+	// TagRequested is the size of the buffer passed to Read or Write
+	TagRequested = "io.requested"
+	// TagEOF is set to true when a Read returns io.EOF, which is not
+	// recorded as an error
+	TagEOF = "io.eof"
+	// TagLocalAddr and TagRemoteAddr record the endpoints of a net.Conn
+	TagLocalAddr  = "net.local_addr"
+	TagRemoteAddr = "net.remote_addr"
+)
+
+// ops traces the operations of one wrapped value
+type ops struct {
+	tracer *lens.TracerImpl
+	name   string
+	tags   map[string]interface{}
+}
+
+// transfer traces a Read or Write of buf
+func (o *ops) transfer(op string, buf []byte, fn func([]byte) (int, error)) (int, error) {
+	span := o.start(op)
+	n, err := fn(buf)
+	span.SetTag(TagBytes, n)
+	span.SetTag(TagRequested, len(buf))
+	o.end(span, err)
+	return n, err
+}
+
+// close traces a Close
+func (o *ops) close(fn func() error) error {
+	span := o.start("Close")
+	err := fn()
+	o.end(span, err)
+	return err
+}
+
+// start starts the span of an operation
+func (o *ops) start(op string) lens.Span {
+	span := o.tracer.StartSpan(o.name + "." + op)
+	for k, v := range o.tags {
+		span.SetTag(k, v)
+	}
+	return span
+}
+
+// end records the outcome of an operation and ends its span
+func (o *ops) end(span lens.Span, err error) {
+	if errors.Is(err, io.EOF) {
+		span.SetTag(TagEOF, true)
+	} else if err != nil {
+		span.SetError(err)
+	}
+	span.End()
+}
+
+// Reader is a traced io.Reader
+type Reader struct {
+	r   io.Reader
+	ops ops
+}
+
+// NewReader wraps r for tracing under name
+func NewReader(tracer *lens.TracerImpl, r io.Reader, name string) *Reader {
+	return &Reader{r: r, ops: ops{tracer: tracer, name: name}}
+}
+
+// Read reads from the underlying reader
+func (r *Reader) Read(p []byte) (int, error) {
+	return r.ops.transfer("Read", p, r.r.Read)
+}
+
+// Writer is a traced io.Writer
+type Writer struct {
+	w   io.Writer
+	ops ops
+}
+
+// NewWriter wraps w for tracing under name
+func NewWriter(tracer *lens.TracerImpl, w io.Writer, name string) *Writer {
+	return &Writer{w: w, ops: ops{tracer: tracer, name: name}}
+}
+
+// Write writes to the underlying writer
+func (w *Writer) Write(p []byte) (int, error) {
+	return w.ops.transfer("Write", p, w.w.Write)
+}
+
+// ReadWriteCloser is a traced io.ReadWriteCloser
+type ReadWriteCloser struct {
+	rwc io.ReadWriteCloser
+	ops ops
+}
+
+// NewReadWriteCloser wraps rwc for tracing under name
+func NewReadWriteCloser(tracer *lens.TracerImpl, rwc io.ReadWriteCloser, name string) *ReadWriteCloser {
+	return &ReadWriteCloser{rwc: rwc, ops: ops{tracer: tracer, name: name}}
+}
+
+// Read reads from the underlying value
+func (c *ReadWriteCloser) Read(p []byte) (int, error) {
+	return c.ops.transfer("Read", p, c.rwc.Read)
+}
+
+// Write writes to the underlying value
+func (c *ReadWriteCloser) Write(p []byte) (int, error) {
+	return c.ops.transfer("Write", p, c.rwc.Write)
+}
+
+// Close closes the underlying value
+func (c *ReadWriteCloser) Close() error {
+	return c.ops.close(c.rwc.Close)
+}
+
+// Conn is a traced net.Conn. Reads, writes and Close are traced with the
+// connection's addresses as tags; deadlines are passed through.
+type Conn struct {
+	conn net.Conn
+	ops  ops
+}
+
+// NewConn wraps conn for tracing under name
+func NewConn(tracer *lens.TracerImpl, conn net.Conn, name string) *Conn {
+	tags := make(map[string]interface{}, 2)
+	if addr := conn.LocalAddr(); addr != nil {
+		tags[TagLocalAddr] = addr.String()
+	}
+	if addr := conn.RemoteAddr(); addr != nil {
+		tags[TagRemoteAddr] = addr.String()
+	}
+	return &Conn{conn: conn, ops: ops{tracer: tracer, name: name, tags: tags}}
+}
+
+// Read reads from the connection
+func (c *Conn) Read(p []byte) (int, error) {
+	return c.ops.transfer("Read", p, c.conn.Read)
+}
+
+// Write writes to the connection
+func (c *Conn) Write(p []byte) (int, error) {
+	return c.ops.transfer("Write", p, c.conn.Write)
+}
+
+// Close closes the connection
+func (c *Conn) Close() error {
+	return c.ops.close(c.conn.Close)
+}
+
+// LocalAddr returns the local network address
+func (c *Conn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+// RemoteAddr returns the remote network address
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// SetDeadline sets the read and write deadlines
+func (c *Conn) SetDeadline(t time.Time) error {
+	return c.conn.SetDeadline(t)
+}
+
+// SetReadDeadline sets the read deadline
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the write deadline
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// Unwrap returns the underlying connection
+func (c *Conn) Unwrap() net.Conn {
+	return c.conn
+}
@@ -0,0 +1,155 @@
+// Package lenskv traces the commands of key-value clients, such as Redis
+// or memcached, as spans recording the command, its key, its latency and,
+// for reads, whether the key was found:
+//
+//	commands := lenskv.NewCommandTracer(tracer, "memcached")
+//	err := commands.TraceCommand(ctx, lenskv.Command{Name: "GET", Key: key, Read: true},
+//		func(ctx context.Context) error {
+//			item, err = client.Get(key)
+//			return err
+//		})
+//
+// Client packages such as lensredis are built on it.
+package lenskv
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/baretech/lens"
+)
+
+// Tags set on command spans
+const (
+	TagSystem  = "kv.system"
+	TagCommand = "kv.command"
+	TagKey     = "kv.key"
+	// TagHit is set on reads: true if the key was found
+	TagHit = "kv.hit"
+)
+
+// Command describes a command about to run
+type Command struct {
+	// Name of the command, e.g. GET
+	Name string
+	// Key the command operates on, if any
+	Key string
+	// Read commands are tagged as hits or misses
+	Read bool
+	// Tags are added to the command's span
+	Tags map[string]interface{}
+}
+
+// CommandTracer traces commands of a key-value client. run executes the
+// command with the context of its span.
+type CommandTracer interface {
+	TraceCommand(ctx context.Context, cmd Command, run func(ctx context.Context) error) error
+}
+
+// KeyMode controls how keys are recorded
+type KeyMode int
+
+const (
+	// KeyPlain records keys as they are
+	KeyPlain KeyMode = iota
+	// KeyHashed keeps the namespace of a key, up to its first ':', and
+	// replaces the rest by a short hash, so commands on one key can still
+	// be matched, e.g. "session:h3fa81c2d"
+	KeyHashed
+	// KeyRedacted records every key as lens.DefaultRedaction
+	KeyRedacted
+)
+
+// Tracer is the CommandTracer recording commands as lens spans
+type Tracer struct {
+	tracer *lens.TracerImpl
+	system string
+	keys   KeyMode
+	isMiss func(err error) bool
+}
+
+// Option configures a Tracer
+type Option func(*Tracer)
+
+// WithKeys sets how keys are recorded; they are recorded as they are by
+// default
+func WithKeys(mode KeyMode) Option {
+	return func(t *Tracer) {
+		t.keys = mode
+	}
+}
+
+// WithMissError sets how a client reports a read of a missing key, such
+// as redis.Nil. Misses are tagged kv.hit=false rather than recorded as
+// errors.
+func WithMissError(isMiss func(err error) bool) Option {
+	return func(t *Tracer) {
+		t.isMiss = isMiss
+	}
+}
+
+// NewCommandTracer creates a tracer for the commands of a client of
+// system, e.g. "redis"
+func NewCommandTracer(tracer *lens.TracerImpl, system string, options ...Option) *Tracer {
+	t := &Tracer{
+		tracer: tracer,
+		system: system,
+		isMiss: func(error) bool { return false },
+	}
+	for _, option := range options {
+		option(t)
+	}
+	return t
+}
+
+// TraceCommand runs a command in a span named after the system and
+// command, e.g. "redis GET", and returns its error
+func (t *Tracer) TraceCommand(ctx context.Context, cmd Command, run func(ctx context.Context) error) error {
+	ctx, span := t.tracer.StartSpanContext(ctx, t.system+" "+cmd.Name)
+	span.SetTag(TagSystem, t.system)
+	span.SetTag(TagCommand, cmd.Name)
+	if cmd.Key != "" {
+		span.SetTag(TagKey, t.Key(cmd.Key))
+	}
+	for k, v := range cmd.Tags {
+		span.SetTag(k, v)
+	}
+
+	err := run(ctx)
+	switch {
+	case err != nil && t.isMiss(err):
+		if cmd.Read {
+			span.SetTag(TagHit, false)
+		}
+	case err != nil:
+		span.SetError(err)
+	case cmd.Read:
+		span.SetTag(TagHit, true)
+	}
+	span.End()
+	return err
+}
+
+// Key returns a key as recorded
+func (t *Tracer) Key(key string) string {
+	switch t.keys {
+	case KeyHashed:
+		namespace, rest, ok := strings.Cut(key, ":")
+		if !ok {
+			return hashKey(key)
+		}
+		return namespace + ":" + hashKey(rest)
+	case KeyRedacted:
+		return lens.DefaultRedaction
+	default:
+		return key
+	}
+}
+
+// hashKey returns a short hash of key
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "h" + hex.EncodeToString(sum[:4])
+}
@@ -0,0 +1,95 @@
+// Package lensredis traces go-redis commands with lenskv. It is a module of
+// its own, so only programs using it depend on go-redis:
+//
+//	rdb.AddHook(lensredis.NewHook(tracer, lenskv.WithKeys(lenskv.KeyHashed)))
+package lensredis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/baretech/lens"
+	"github.com/baretech/lens/lenskv"
+	"github.com/redis/go-redis/v9"
+)
+
+// TagPipeline lists the commands of a pipeline
+const TagPipeline = "kv.pipeline"
+
+// readCommands are the commands reporting a missing key with redis.Nil
+var readCommands = map[string]bool{
+	"get": true, "getdel": true, "getex": true, "getrange": true,
+	"hget": true, "lindex": true, "lpop": true, "rpop": true,
+	"spop": true, "srandmember": true, "zscore": true, "zrank": true,
+	"zrevrank": true, "xread": true, "blpop": true, "brpop": true,
+}
+
+// Hook is a redis.Hook tracing commands and pipelines as spans
+type Hook struct {
+	commands *lenskv.Tracer
+}
+
+var _ redis.Hook = (*Hook)(nil)
+
+// NewHook creates a hook recording commands on tracer. redis.Nil replies
+// are recorded as misses unless options say otherwise.
+func NewHook(tracer *lens.TracerImpl, options ...lenskv.Option) *Hook {
+	options = append([]lenskv.Option{lenskv.WithMissError(IsNil)}, options...)
+	return &Hook{commands: lenskv.NewCommandTracer(tracer, "redis", options...)}
+}
+
+// IsNil reports whether err is redis.Nil, the reply to a read of a
+// missing key
+func IsNil(err error) bool {
+	return errors.Is(err, redis.Nil)
+}
+
+// DialHook leaves connecting untraced
+func (h *Hook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+// ProcessHook traces each command
+func (h *Hook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		name := strings.ToLower(cmd.Name())
+		command := lenskv.Command{
+			Name: strings.ToUpper(name),
+			Key:  commandKey(cmd),
+			Read: readCommands[name],
+		}
+		return h.commands.TraceCommand(ctx, command, func(ctx context.Context) error {
+			return next(ctx, cmd)
+		})
+	}
+}
+
+// ProcessPipelineHook traces each pipeline and transaction as one span,
+// listing its commands
+func (h *Hook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		names := make([]string, len(cmds))
+		for i, cmd := range cmds {
+			names[i] = strings.ToUpper(cmd.Name())
+		}
+		command := lenskv.Command{
+			Name: "PIPELINE",
+			Tags: map[string]interface{}{TagPipeline: names},
+		}
+		return h.commands.TraceCommand(ctx, command, func(ctx context.Context) error {
+			return next(ctx, cmds)
+		})
+	}
+}
+
+// commandKey returns the first key of a command, its first argument
+// after the name
+func commandKey(cmd redis.Cmder) string {
+	args := cmd.Args()
+	if len(args) < 2 {
+		return ""
+	}
+	return fmt.Sprint(args[1])
+}
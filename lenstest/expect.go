@@ -0,0 +1,212 @@
+package lenstest
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/baretech/lens"
+)
+
+// Expectation describes calls a test expects to see traced. By default it
+// expects at least one matching call.
+type Expectation struct {
+	function string
+	args     []ArgMatcher
+	hasArgs  bool
+	min      int
+	max      int
+	after    []string
+}
+
+// Expect adds an expectation on calls to function, named as traced or
+// without its package path and pointer receiver, e.g. "User.Save"
+func (w *RecordingWriter) Expect(function string) *Expectation {
+	e := &Expectation{function: function, min: 1, max: -1}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.expectations = append(w.expectations, e)
+	return e
+}
+
+// Times expects exactly n matching calls
+func (e *Expectation) Times(n int) *Expectation {
+	e.min, e.max = n, n
+	return e
+}
+
+// AtLeast expects n or more matching calls
+func (e *Expectation) AtLeast(n int) *Expectation {
+	e.min, e.max = n, -1
+	return e
+}
+
+// Never expects no matching call
+func (e *Expectation) Never() *Expectation {
+	return e.Times(0)
+}
+
+// After expects every matching call to start after function was called
+func (e *Expectation) After(function string) *Expectation {
+	e.after = append(e.after, function)
+	return e
+}
+
+// WithArgs only counts calls whose arguments match, one per argument.
+// Arguments are ArgMatchers or values compared with Eq.
+func (e *Expectation) WithArgs(args ...interface{}) *Expectation {
+	e.hasArgs = true
+	e.args = make([]ArgMatcher, len(args))
+	for i, arg := range args {
+		if matcher, ok := arg.(ArgMatcher); ok {
+			e.args[i] = matcher
+		} else {
+			e.args[i] = Eq(arg)
+		}
+	}
+	return e
+}
+
+// String describes the expectation
+func (e *Expectation) String() string {
+	var b strings.Builder
+	b.WriteString(e.function)
+	if e.hasArgs {
+		args := make([]string, len(e.args))
+		for i, arg := range e.args {
+			args[i] = arg.String()
+		}
+		fmt.Fprintf(&b, "(%s)", strings.Join(args, ", "))
+	}
+
+	if e.min == e.max {
+		fmt.Fprintf(&b, " called %d times", e.min)
+	} else {
+		fmt.Fprintf(&b, " called at least %d times", e.min)
+	}
+	for _, function := range e.after {
+		fmt.Fprintf(&b, " after %s", function)
+	}
+	return b.String()
+}
+
+// matchArgs reports whether a call's arguments satisfy the expectation
+func (e *Expectation) matchArgs(call lens.Event) bool {
+	if !e.hasArgs {
+		return true
+	}
+	if len(call.Arguments) != len(e.args) {
+		return false
+	}
+	for i, matcher := range e.args {
+		if !matcher.Match(call.Arguments[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Verify reports every unmet expectation as a test error
+func (w *RecordingWriter) Verify(t testing.TB) {
+	t.Helper()
+
+	w.mutex.Lock()
+	expectations := append([]*Expectation(nil), w.expectations...)
+	w.mutex.Unlock()
+
+	// Events are written in order per writer, but sort by Seq in case
+	// they were recorded from a merged source
+	events := w.Events()
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Seq < events[j].Seq })
+
+	for _, e := range expectations {
+		if err := e.verify(events); err != nil {
+			t.Errorf("lenstest: %v", err)
+		}
+	}
+}
+
+// verify checks the expectation against recorded events
+func (e *Expectation) verify(events []lens.Event) error {
+	var calls []lens.Event
+	var seen []string
+	for _, event := range events {
+		if !isCall(event) {
+			continue
+		}
+
+		if matchFunction(e.function, event.Function) && e.matchArgs(event) {
+			for _, function := range e.after {
+				if !containsFunction(seen, function) {
+					return fmt.Errorf("expected %s, but it was called before %s", e, function)
+				}
+			}
+			calls = append(calls, event)
+		}
+		seen = append(seen, event.Function)
+	}
+
+	if len(calls) < e.min || (e.max >= 0 && len(calls) > e.max) {
+		return fmt.Errorf("expected %s, but it was called %d times", e, len(calls))
+	}
+	return nil
+}
+
+// containsFunction reports whether any traced name matches function
+func containsFunction(names []string, function string) bool {
+	for _, name := range names {
+		if matchFunction(function, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ArgMatcher matches a recorded argument
+type ArgMatcher interface {
+	Match(arg interface{}) bool
+	String() string
+}
+
+// anyMatcher matches every argument
+type anyMatcher struct{}
+
+func (anyMatcher) Match(interface{}) bool { return true }
+func (anyMatcher) String() string         { return "Any" }
+
+// Any matches any argument
+func Any() ArgMatcher {
+	return anyMatcher{}
+}
+
+// eqMatcher matches arguments deeply equal to a value
+type eqMatcher struct {
+	value interface{}
+}
+
+func (m eqMatcher) Match(arg interface{}) bool { return reflect.DeepEqual(m.value, arg) }
+func (m eqMatcher) String() string             { return fmt.Sprintf("%v", m.value) }
+
+// Eq matches arguments deeply equal to value, as recorded after any
+// ValueEncoder
+func Eq(value interface{}) ArgMatcher {
+	return eqMatcher{value: value}
+}
+
+// funcMatcher matches arguments accepted by a predicate
+type funcMatcher struct {
+	match       func(interface{}) bool
+	description string
+}
+
+func (m funcMatcher) Match(arg interface{}) bool { return m.match(arg) }
+func (m funcMatcher) String() string             { return m.description }
+
+// ArgThat matches arguments accepted by match, described as description
+// in failures
+func ArgThat(description string, match func(arg interface{}) bool) ArgMatcher {
+	return funcMatcher{match: match, description: description}
+}
@@ -0,0 +1,114 @@
+// Package lenstest records lens events in tests and verifies the
+// interactions they show, gomock style but without mocks:
+//
+//	tracer, rec := lenstest.Record(t)
+//	rec.Expect("User.Save").Times(1).After("User.UpdateAge").WithArgs(lenstest.Any())
+//
+// Expectations are verified when the test ends.
+package lenstest
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/baretech/lens"
+)
+
+// RecordingWriter keeps every event written to it in memory
+type RecordingWriter struct {
+	events       []lens.Event
+	expectations []*Expectation
+	mutex        sync.Mutex
+}
+
+// NewRecordingWriter creates an empty recording writer
+func NewRecordingWriter() *RecordingWriter {
+	return &RecordingWriter{}
+}
+
+// Record creates a tracer writing to a new recording writer. When the
+// test ends, the tracer is closed, so every event has been written, and
+// the writer's expectations are verified.
+func Record(t testing.TB, options ...lens.Option) (*lens.TracerImpl, *RecordingWriter) {
+	t.Helper()
+
+	rec := NewRecordingWriter()
+	tracer := lens.New(append(options, lens.WithWriter(rec))...)
+	t.Cleanup(func() {
+		if err := tracer.Close(context.Background()); err != nil {
+			t.Errorf("lenstest: %v", err)
+		}
+		rec.Verify(t)
+	})
+	return tracer, rec
+}
+
+// Write records an event
+func (w *RecordingWriter) Write(event lens.Event) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.events = append(w.events, event)
+	return nil
+}
+
+// Flush is a no-op
+func (w *RecordingWriter) Flush() error {
+	return nil
+}
+
+// Close is a no-op; recorded events stay available
+func (w *RecordingWriter) Close() error {
+	return nil
+}
+
+// Events returns a copy of the recorded events in the order written
+func (w *RecordingWriter) Events() []lens.Event {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return append([]lens.Event(nil), w.events...)
+}
+
+// Calls returns the call events of function, matched as by Expect
+func (w *RecordingWriter) Calls(function string) []lens.Event {
+	var calls []lens.Event
+	for _, event := range w.Events() {
+		if isCall(event) && matchFunction(function, event.Function) {
+			calls = append(calls, event)
+		}
+	}
+	return calls
+}
+
+// Reset forgets the recorded events and expectations
+func (w *RecordingWriter) Reset() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.events = nil
+	w.expectations = nil
+}
+
+// isCall reports whether an event starts a call
+func isCall(event lens.Event) bool {
+	return event.Type == lens.EventFunctionCall || event.Type == lens.EventMethodCall
+}
+
+// matchFunction reports whether a traced function name is the one meant
+// by name. Package paths and pointer receivers may be left out, so
+// "User.Save" matches "github.com/acme/users.(*User).Save".
+func matchFunction(name, function string) bool {
+	if name == function {
+		return true
+	}
+
+	short := function[strings.LastIndex(function, "/")+1:]
+	short = strings.NewReplacer("(*", "", ")", "").Replace(short)
+	if name == short {
+		return true
+	}
+
+	// pkg.Type.Method also matches Type.Method
+	_, rest, ok := strings.Cut(short, ".")
+	return ok && name == rest
+}
@@ -0,0 +1,200 @@
+package lens
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Tags set on EventLockOperation events
+const (
+	TagLockWait      = "lock.wait"
+	TagLockHold      = "lock.hold"
+	TagLockContended = "lock.contended"
+)
+
+// lockTracer traces the operations of one named sync primitive
+type lockTracer struct {
+	tracer *TracerImpl
+	name   string
+}
+
+// acquire takes a lock, trying the non-blocking path first (if any) so
+// uncontended acquisitions are not timed. It returns how long the caller
+// waited.
+func (l lockTracer) acquire(op string, try func() bool, block func()) (time.Duration, bool) {
+	if try != nil && try() {
+		return 0, false
+	}
+
+	start := time.Now()
+	disarm := l.tracer.watchBlocked(l.name, fmt.Sprintf("%s.%s", l.name, op))
+	block()
+	disarm()
+	return time.Since(start), true
+}
+
+// traceAcquire emits the event for a lock acquired after waiting wait
+func (l lockTracer) traceAcquire(op string, wait time.Duration, contended bool) {
+	l.trace(op, wait, map[string]interface{}{
+		TagLockWait:      wait.String(),
+		TagLockContended: contended,
+	})
+}
+
+// traceRelease emits the event for a lock released after being held for hold
+func (l lockTracer) traceRelease(op string, hold time.Duration) {
+	l.trace(op, hold, map[string]interface{}{
+		TagLockHold: hold.String(),
+	})
+}
+
+// trace emits a lock operation event
+func (l lockTracer) trace(op string, duration time.Duration, tags map[string]interface{}) {
+	l.tracer.TraceEvent(Event{
+		ID:        generateEventID(),
+		TraceID:   generateTraceID(),
+		Timestamp: time.Now(),
+		Type:      EventLockOperation,
+		Component: l.name,
+		Function:  fmt.Sprintf("%s.%s", l.name, op),
+		Duration:  duration,
+		Goroutine: getGoroutineID(),
+		Tags:      tags,
+	})
+}
+
+// TracedMutex is a sync.Mutex that traces Lock with the time spent waiting
+// for it and Unlock with the time it was held
+type TracedMutex struct {
+	mu       sync.Mutex
+	lock     lockTracer
+	acquired time.Time
+}
+
+// NewTracedMutex creates a mutex traced under name
+func NewTracedMutex(tracer *TracerImpl, name string) *TracedMutex {
+	return &TracedMutex{lock: lockTracer{tracer: tracer, name: name}}
+}
+
+// Lock locks the mutex
+func (m *TracedMutex) Lock() {
+	wait, contended := m.lock.acquire("Lock", m.mu.TryLock, m.mu.Lock)
+	m.acquired = time.Now()
+	m.lock.traceAcquire("Lock", wait, contended)
+}
+
+// TryLock tries to lock the mutex without blocking
+func (m *TracedMutex) TryLock() bool {
+	if !m.mu.TryLock() {
+		return false
+	}
+	m.acquired = time.Now()
+	m.lock.traceAcquire("Lock", 0, false)
+	return true
+}
+
+// Unlock unlocks the mutex
+func (m *TracedMutex) Unlock() {
+	hold := time.Since(m.acquired)
+	m.mu.Unlock()
+	m.lock.traceRelease("Unlock", hold)
+}
+
+// TracedRWMutex is a sync.RWMutex that traces the wait and hold times of
+// both writers and readers
+type TracedRWMutex struct {
+	mu       sync.RWMutex
+	lock     lockTracer
+	acquired time.Time
+
+	// Read locks are held concurrently, so their acquisition times are
+	// kept per goroutine
+	readers      map[int][]time.Time
+	readersMutex sync.Mutex
+}
+
+// NewTracedRWMutex creates a reader/writer mutex traced under name
+func NewTracedRWMutex(tracer *TracerImpl, name string) *TracedRWMutex {
+	return &TracedRWMutex{
+		lock:    lockTracer{tracer: tracer, name: name},
+		readers: make(map[int][]time.Time),
+	}
+}
+
+// Lock locks the mutex for writing
+func (m *TracedRWMutex) Lock() {
+	wait, contended := m.lock.acquire("Lock", m.mu.TryLock, m.mu.Lock)
+	m.acquired = time.Now()
+	m.lock.traceAcquire("Lock", wait, contended)
+}
+
+// Unlock unlocks the mutex for writing
+func (m *TracedRWMutex) Unlock() {
+	hold := time.Since(m.acquired)
+	m.mu.Unlock()
+	m.lock.traceRelease("Unlock", hold)
+}
+
+// RLock locks the mutex for reading
+func (m *TracedRWMutex) RLock() {
+	wait, contended := m.lock.acquire("RLock", m.mu.TryRLock, m.mu.RLock)
+
+	goroutine := getGoroutineID()
+	m.readersMutex.Lock()
+	m.readers[goroutine] = append(m.readers[goroutine], time.Now())
+	m.readersMutex.Unlock()
+
+	m.lock.traceAcquire("RLock", wait, contended)
+}
+
+// RUnlock undoes a single RLock call. The hold time is only known when the
+// read lock is released by the goroutine that took it.
+func (m *TracedRWMutex) RUnlock() {
+	var hold time.Duration
+
+	goroutine := getGoroutineID()
+	m.readersMutex.Lock()
+	if held := m.readers[goroutine]; len(held) > 0 {
+		hold = time.Since(held[len(held)-1])
+		if len(held) == 1 {
+			delete(m.readers, goroutine)
+		} else {
+			m.readers[goroutine] = held[:len(held)-1]
+		}
+	}
+	m.readersMutex.Unlock()
+
+	m.mu.RUnlock()
+	m.lock.traceRelease("RUnlock", hold)
+}
+
+// TracedWaitGroup is a sync.WaitGroup that traces Wait with the time spent
+// waiting
+type TracedWaitGroup struct {
+	wg   sync.WaitGroup
+	lock lockTracer
+}
+
+// NewTracedWaitGroup creates a wait group traced under name
+func NewTracedWaitGroup(tracer *TracerImpl, name string) *TracedWaitGroup {
+	return &TracedWaitGroup{lock: lockTracer{tracer: tracer, name: name}}
+}
+
+// Add adds delta to the counter
+func (w *TracedWaitGroup) Add(delta int) {
+	w.wg.Add(delta)
+}
+
+// Done decrements the counter by one
+func (w *TracedWaitGroup) Done() {
+	w.wg.Done()
+}
+
+// Wait blocks until the counter is zero
+func (w *TracedWaitGroup) Wait() {
+	wait, _ := w.lock.acquire("Wait", nil, w.wg.Wait)
+	w.lock.trace("Wait", wait, map[string]interface{}{
+		TagLockWait: wait.String(),
+	})
+}
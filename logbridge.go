@@ -0,0 +1,166 @@
+package lens
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Tags set on EventLog events
+const (
+	TagLogMessage = "log.message"
+	TagLogLevel   = "log.level"
+)
+
+// LogBridge captures application logs as trace events, so logs and
+// traces interleave in one timeline. It is an io.Writer for the standard
+// log package and a slog.Handler:
+//
+//	log.SetOutput(io.MultiWriter(os.Stderr, lens.NewLogBridge(tracer, "app")))
+//	logger := slog.New(lens.NewLogBridge(tracer, "app"))
+//
+// Each log line becomes an EventLog event carrying the trace ID of the
+// span in the slog context or, failing that, of the innermost wrapped
+// call running on the logging goroutine. Do not combine a bridge with a
+// SlogWriter logging through the same handler, or every event would log
+// itself again.
+type LogBridge struct {
+	tracer    *TracerImpl
+	component string
+	level     slog.Leveler
+	attrs     map[string]interface{}
+	group     string
+}
+
+// NewLogBridge creates a bridge recording logs under component. slog
+// records below slog.LevelInfo are ignored; see WithLevel.
+func NewLogBridge(tracer *TracerImpl, component string) *LogBridge {
+	return &LogBridge{
+		tracer:    tracer,
+		component: component,
+		level:     slog.LevelInfo,
+	}
+}
+
+// WithLevel returns a bridge handling slog records at level or above
+func (b *LogBridge) WithLevel(level slog.Leveler) *LogBridge {
+	clone := *b
+	clone.level = level
+	return &clone
+}
+
+// Write records p as a log event. The log package writes each message
+// with a single call, so multi-line messages stay one event.
+func (b *LogBridge) Write(p []byte) (int, error) {
+	message := strings.TrimRight(string(p), "\r\n")
+	if message == "" {
+		return len(p), nil
+	}
+
+	b.trace(nil, Event{
+		Timestamp: time.Now(),
+		Goroutine: getGoroutineID(),
+		Tags:      map[string]interface{}{TagLogMessage: message},
+	})
+	return len(p), nil
+}
+
+// Enabled implements slog.Handler
+func (b *LogBridge) Enabled(ctx context.Context, level slog.Level) bool {
+	return b.tracer.enabled && level >= b.level.Level()
+}
+
+// Handle implements slog.Handler
+func (b *LogBridge) Handle(ctx context.Context, record slog.Record) error {
+	tags := make(map[string]interface{}, len(b.attrs)+record.NumAttrs()+2)
+	for k, v := range b.attrs {
+		tags[k] = v
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		addLogAttr(tags, b.group, attr)
+		return true
+	})
+	tags[TagLogMessage] = record.Message
+	tags[TagLogLevel] = record.Level.String()
+
+	event := Event{
+		Timestamp: record.Time,
+		Goroutine: getGoroutineID(),
+		Tags:      tags,
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	if record.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{record.PC}).Next()
+		event.SourceFile = frame.File
+		event.SourceLine = frame.Line
+		event.SourceFunction = frame.Function
+	}
+
+	b.trace(ctx, event)
+	return nil
+}
+
+// WithAttrs implements slog.Handler
+func (b *LogBridge) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *b
+	clone.attrs = make(map[string]interface{}, len(b.attrs)+len(attrs))
+	for k, v := range b.attrs {
+		clone.attrs[k] = v
+	}
+	for _, attr := range attrs {
+		addLogAttr(clone.attrs, b.group, attr)
+	}
+	return &clone
+}
+
+// WithGroup implements slog.Handler
+func (b *LogBridge) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return b
+	}
+	clone := *b
+	clone.group = joinPath(b.group, name)
+	return &clone
+}
+
+// trace completes a log event with its trace and span and traces it
+func (b *LogBridge) trace(ctx context.Context, event Event) {
+	event.ID = generateEventID()
+	event.Type = EventLog
+	event.Component = b.component
+
+	event.TraceID, event.SpanID = spanContextFromContext(ctx)
+	if event.TraceID == "" {
+		event.TraceID = b.tracer.depths.current(event.Goroutine)
+	}
+	if event.TraceID == "" {
+		event.TraceID = generateTraceID()
+	}
+
+	b.tracer.TraceEvent(b.tracer.enrich(ctx, event))
+}
+
+// addLogAttr flattens a slog attribute into tags, prefixing group names
+func addLogAttr(tags map[string]interface{}, group string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+
+	if attr.Value.Kind() == slog.KindGroup {
+		prefix := group
+		if attr.Key != "" {
+			prefix = joinPath(group, attr.Key)
+		}
+		for _, member := range attr.Value.Group() {
+			addLogAttr(tags, prefix, member)
+		}
+		return
+	}
+
+	tags[joinPath(group, strings.TrimSpace(attr.Key))] = attr.Value.Any()
+}
@@ -0,0 +1,114 @@
+package lens
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LokiWriter ships trace events to Grafana Loki through its push API.
+// Events are grouped into streams labeled by component and event type,
+// plus any static labels; the log line is the JSON-encoded event.
+type LokiWriter struct {
+	*httpShipper
+	url    string
+	labels map[string]string
+}
+
+// lokiPush is the body of a Loki push request
+type lokiPush struct {
+	Streams []lokiStream `json:"streams"`
+}
+
+// lokiStream is a set of log lines sharing the same labels
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// NewLokiWriter creates a new Loki writer. url is the Loki base URL
+// (e.g. "http://localhost:3100"); labels are added to every stream.
+func NewLokiWriter(url string, labels map[string]string, options ...HTTPWriterOption) *LokiWriter {
+	w := &LokiWriter{
+		url:    strings.TrimSuffix(url, "/") + "/loki/api/v1/push",
+		labels: make(map[string]string, len(labels)),
+	}
+	for k, v := range labels {
+		w.labels[k] = v
+	}
+	w.httpShipper = newHTTPShipper(w.send, options)
+	return w
+}
+
+// send pushes a batch of events as Loki streams
+func (w *LokiWriter) send(ctx context.Context, events []Event) error {
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.Before(events[j].Timestamp)
+	})
+
+	streams := make(map[string]*lokiStream)
+	var order []string
+	for _, event := range events {
+		labels := w.streamLabels(event)
+		key := lokiStreamKey(labels)
+
+		stream, ok := streams[key]
+		if !ok {
+			stream = &lokiStream{Stream: labels}
+			streams[key] = stream
+			order = append(order, key)
+		}
+
+		line, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to marshal event: %w", err)
+		}
+		stream.Values = append(stream.Values, [2]string{
+			strconv.FormatInt(event.Timestamp.UnixNano(), 10),
+			string(line),
+		})
+	}
+
+	push := lokiPush{Streams: make([]lokiStream, 0, len(order))}
+	for _, key := range order {
+		push.Streams = append(push.Streams, *streams[key])
+	}
+
+	body, err := json.Marshal(push)
+	if err != nil {
+		return fmt.Errorf("failed to marshal loki push: %w", err)
+	}
+	_, err = w.post(ctx, w.url, "application/json", body)
+	return err
+}
+
+// streamLabels returns the stream labels of an event
+func (w *LokiWriter) streamLabels(event Event) map[string]string {
+	labels := make(map[string]string, len(w.labels)+2)
+	for k, v := range w.labels {
+		labels[k] = v
+	}
+	if event.Component != "" {
+		labels["component"] = event.Component
+	}
+	labels["type"] = string(event.Type)
+	return labels
+}
+
+// lokiStreamKey identifies a label set
+func lokiStreamKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s=%q,", k, labels[k])
+	}
+	return b.String()
+}
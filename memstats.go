@@ -0,0 +1,46 @@
+package lens
+
+import (
+	"runtime/metrics"
+)
+
+// Heap allocation metrics; these are cumulative and never decrease
+const (
+	metricAllocBytes   = "/gc/heap/allocs:bytes"
+	metricAllocObjects = "/gc/heap/allocs:objects"
+)
+
+// memSample is a point-in-time reading of cumulative heap allocations
+type memSample struct {
+	bytes   uint64
+	objects uint64
+}
+
+// readMemSample reads cumulative heap allocations via runtime/metrics,
+// which unlike runtime.ReadMemStats does not stop the world
+func readMemSample() memSample {
+	samples := []metrics.Sample{
+		{Name: metricAllocBytes},
+		{Name: metricAllocObjects},
+	}
+	metrics.Read(samples)
+
+	var sample memSample
+	if samples[0].Value.Kind() == metrics.KindUint64 {
+		sample.bytes = samples[0].Value.Uint64()
+	}
+	if samples[1].Value.Kind() == metrics.KindUint64 {
+		sample.objects = samples[1].Value.Uint64()
+	}
+	return sample
+}
+
+// since returns the allocations made between s and now.
+// Heap counters are process-wide, so concurrent goroutines contribute too.
+func (s memSample) since() memSample {
+	now := readMemSample()
+	return memSample{
+		bytes:   now.bytes - s.bytes,
+		objects: now.objects - s.objects,
+	}
+}
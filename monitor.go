@@ -0,0 +1,81 @@
+package lens
+
+import (
+	"reflect"
+	"sync"
+	"time"
+)
+
+// readLocker is implemented by objects guarded by a sync.RWMutex
+type readLocker interface {
+	RLock()
+	RUnlock()
+}
+
+// Monitor captures the exported fields of obj every interval and emits a
+// state_diff event named name whenever some changed since the previous
+// capture, to watch caches, counters and configuration drift over time.
+// obj must be a pointer for changes to be seen. Its fields are read while
+// the program may be changing them; if obj has RLock and RUnlock methods,
+// or else Lock and Unlock, as when it embeds a mutex, they are held while
+// reading. It returns a func that stops monitoring.
+func (t *TracerImpl) Monitor(obj interface{}, name string, interval time.Duration) func() {
+	if compiledOut || obj == nil {
+		return func() {}
+	}
+	component := reflect.TypeOf(obj).String()
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	previous := monitorState(obj)
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				current := monitorState(obj)
+				changes := diffState(previous, current)
+				previous = current
+				if len(changes) == 0 {
+					continue
+				}
+				t.TraceEvent(Event{
+					ID:        generateEventID(),
+					TraceID:   generateTraceID(),
+					Timestamp: time.Now(),
+					Type:      EventStateDiff,
+					Component: component,
+					Variable:  name,
+					Changes:   changes,
+					Goroutine: getGoroutineID(),
+				})
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// monitorState captures the state of a monitored object, holding its lock
+// if it has one
+func monitorState(obj interface{}) map[string]interface{} {
+	switch locker := obj.(type) {
+	case readLocker:
+		locker.RLock()
+		defer locker.RUnlock()
+	case sync.Locker:
+		locker.Lock()
+		defer locker.Unlock()
+	}
+	return captureState(obj)
+}
@@ -0,0 +1,112 @@
+package lens
+
+// Named returns a child tracer for a component of the program, as named
+// loggers do, so a large codebase can organize tracing by subsystem. The
+// child stamps name as the Component of its events that have none, and
+// sends them through the parent, so the parent's filters, processors and
+// writers apply. On top of them, the child can have a level of its own,
+// set with SetLevel, and filters of its own, added with AddFilter.
+// Children of a child are named parent.child, e.g. "billing.invoices".
+//
+// Other settings, such as enrichers, encoders and thresholds, are those of
+// the parent when Named is called. Writers and processors added to a child
+// are added to the root tracer, and Close on a child does nothing: close
+// the root tracer.
+func (t *TracerImpl) Named(name string) *TracerImpl {
+	component := name
+	if t.parent != nil {
+		component = t.component + "." + name
+	}
+
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	return &TracerImpl{
+		parent:            t,
+		component:         component,
+		level:             t.level,
+		enabled:           t.enabled,
+		enrichers:         t.enrichers,
+		spanExtractors:    t.spanExtractors,
+		externalTraceID:   t.externalTraceID,
+		reaper:            t.reaper,
+		depths:            t.depths,
+		blockThreshold:    t.blockThreshold,
+		slowCallThreshold: t.slowCallThreshold,
+		recursionLimit:    t.recursionLimit,
+		memoryStats:       t.memoryStats,
+		pprofLabels:       t.pprofLabels,
+		runtimeTrace:      t.runtimeTrace,
+		paramNames:        t.paramNames,
+		budgets:           t.budgets,
+		paths:             t.paths,
+		encoder:           t.encoder,
+		receiverState:     t.receiverState,
+	}
+}
+
+// Component returns the component name of a tracer created by Named, or
+// "" for a root tracer
+func (t *TracerImpl) Component() string {
+	return t.component
+}
+
+// root returns the tracer at the top of a Named chain
+func (t *TracerImpl) root() *TracerImpl {
+	for t.parent != nil {
+		t = t.parent
+	}
+	return t
+}
+
+// traceChildEvent applies a child's component, level and filters to an
+// event and passes it to the parent. levelChecked is set once a tracer
+// with a level of its own has checked it, so ancestors do not.
+func (t *TracerImpl) traceChildEvent(event Event, levelChecked bool) {
+	if event.Component == "" {
+		event.Component = t.component
+	}
+
+	t.mutex.RLock()
+	if t.ownLevel && !levelChecked {
+		if eventLevel(event.Type) > t.level {
+			t.mutex.RUnlock()
+			return
+		}
+		levelChecked = true
+	}
+	filters := t.filters
+	t.mutex.RUnlock()
+
+	for _, filter := range filters {
+		if !filter.ShouldTrace(event) {
+			return
+		}
+	}
+
+	t.parent.traceEvent(event, levelChecked)
+}
+
+// childCaptures is shouldCapture for a child tracer: the event types its
+// level and pre-filters keep are checked again by the parent
+func (t *TracerImpl) childCaptures(probe Event, types []EventType, levelChecked bool) bool {
+	if probe.Component == "" {
+		probe.Component = t.component
+	}
+
+	t.mutex.RLock()
+	var kept []EventType
+	for _, eventType := range types {
+		if t.ownLevel && !levelChecked && eventLevel(eventType) > t.level {
+			continue
+		}
+		probe.Type = eventType
+		if t.preFilter(probe) {
+			kept = append(kept, eventType)
+		}
+	}
+	levelChecked = levelChecked || t.ownLevel
+	t.mutex.RUnlock()
+
+	return len(kept) > 0 && t.parent.captures(probe, kept, levelChecked)
+}
@@ -0,0 +1,36 @@
+package lens
+
+// Noop returns a Tracer that traces nothing: its Wrap methods return their
+// argument unchanged and everything else does nothing. Libraries can
+// accept a Tracer unconditionally and default to Noop when given none.
+func Noop() Tracer {
+	return noopTracer{}
+}
+
+// noopTracer is the Tracer returned by Noop
+type noopTracer struct{}
+
+func (noopTracer) Wrap(obj interface{}) interface{}                      { return obj }
+func (noopTracer) WrapWithName(obj interface{}, name string) interface{} { return obj }
+func (noopTracer) StartSpan(name string) Span                            { return noopSpan{} }
+func (noopTracer) TraceEvent(event Event)                                {}
+func (noopTracer) TraceVariable(name string, oldVal, newVal interface{}) {}
+func (noopTracer) TraceFieldAccess(name string, value interface{})       {}
+func (noopTracer) Emit(eventType string, attrs map[string]interface{})   {}
+func (noopTracer) SetLevel(level Level)                                  {}
+func (noopTracer) AddWriter(writer Writer)                               {}
+func (noopTracer) AddFilter(filter Filter)                               {}
+func (noopTracer) Enable()                                               {}
+func (noopTracer) Disable()                                              {}
+
+// noopSpan is the Span started by a Noop tracer
+type noopSpan struct{}
+
+func (noopSpan) End()                                                         {}
+func (noopSpan) SetTag(key string, value interface{})                         {}
+func (noopSpan) SetError(err error)                                           {}
+func (noopSpan) SetStatus(code StatusCode, message string)                    {}
+func (noopSpan) AddEvent(name string, attrs map[string]interface{})           {}
+func (noopSpan) Mark(name string)                                             {}
+func (noopSpan) Annotate(key string, value interface{})                       {}
+func (noopSpan) AddLink(traceID, spanID string, attrs map[string]interface{}) {}
@@ -0,0 +1,580 @@
+package lens
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Attributes set on exported spans and log records besides event tags
+const (
+	OTLPAttrEventType = "lens.event_type"
+	OTLPAttrTraceID   = "lens.trace_id"
+	OTLPAttrSpanID    = "lens.span_id"
+	OTLPAttrArguments = "code.arguments"
+	OTLPAttrReturns   = "code.returns"
+)
+
+// maxOTLPOpenSpans bounds the spans an OTLPWriter holds arguments and
+// events for until they end. Past it, events are exported as log records.
+const maxOTLPOpenSpans = 4096
+
+// OTLPWriter exports events to an OpenTelemetry collector over OTLP/HTTP
+// with JSON encoding. Spans and wrapped calls become OTLP spans, with
+// their links. Every other event, such as variable writes, span events
+// and logs, becomes a log record carrying the trace and span IDs of its
+// enclosing span, so the whole lens event taxonomy survives export. With
+// WithSpanEvents, those events are attached to their enclosing span as
+// span events instead.
+//
+// OTLP trace IDs are 16 bytes and span IDs 8 bytes; lens IDs in another
+// format are hashed to those sizes and kept as the lens.trace_id and
+// lens.span_id attributes.
+type OTLPWriter struct {
+	*httpShipper
+	tracesURL  string
+	logsURL    string
+	resource   otlpResource
+	spanEvents bool
+	// Arguments and events of spans that have not ended yet
+	open  map[string]*otlpOpenSpan
+	mutex sync.Mutex
+}
+
+// otlpOpenSpan holds what a span collects before its end event
+type otlpOpenSpan struct {
+	arguments []interface{}
+	events    []Event
+}
+
+// NewOTLPWriter creates a writer exporting to endpoint, the collector's
+// OTLP/HTTP base URL (e.g. "http://localhost:4318"), as service
+func NewOTLPWriter(endpoint, service string, options ...HTTPWriterOption) *OTLPWriter {
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	w := &OTLPWriter{
+		tracesURL: endpoint + "/v1/traces",
+		logsURL:   endpoint + "/v1/logs",
+		resource: otlpResource{Attributes: []otlpKeyValue{
+			{Key: "service.name", Value: otlpValue(service)},
+		}},
+		open: make(map[string]*otlpOpenSpan),
+	}
+	w.httpShipper = newHTTPShipper(w.send, options)
+	return w
+}
+
+// WithSpanEvents attaches events to their enclosing span as span events,
+// rather than exporting them as log records. Events are held until the
+// span ends; those of spans that never end are exported as log records
+// on Close.
+func (w *OTLPWriter) WithSpanEvents() *OTLPWriter {
+	w.spanEvents = true
+	return w
+}
+
+// Close sends buffered events, exports held events as log records and
+// stops the writer
+func (w *OTLPWriter) Close() error {
+	err := w.httpShipper.Close()
+
+	w.mutex.Lock()
+	var held []Event
+	for key, span := range w.open {
+		held = append(held, span.events...)
+		delete(w.open, key)
+	}
+	w.mutex.Unlock()
+
+	if len(held) == 0 {
+		return err
+	}
+	sort.Slice(held, func(i, j int) bool { return held[i].Seq < held[j].Seq })
+	ctx, cancel := context.WithTimeout(context.Background(), w.timeout)
+	defer cancel()
+	return errors.Join(err, w.sendLogs(ctx, held))
+}
+
+// OTLP/HTTP JSON request bodies, as in the opentelemetry-proto JSON
+// mapping: IDs are hex strings and 64-bit integers decimal strings
+
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpLogs struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope       `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Links             []otlpLink     `json:"links,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpLink struct {
+	TraceID    string         `json:"traceId"`
+	SpanID     string         `json:"spanId"`
+	Attributes []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano   string         `json:"timeUnixNano"`
+	SeverityNumber int            `json:"severityNumber"`
+	SeverityText   string         `json:"severityText"`
+	Body           otlpAnyValue   `json:"body"`
+	Attributes     []otlpKeyValue `json:"attributes,omitempty"`
+	TraceID        string         `json:"traceId,omitempty"`
+	SpanID         string         `json:"spanId,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string         `json:"stringValue,omitempty"`
+	BoolValue   *bool           `json:"boolValue,omitempty"`
+	IntValue    *string         `json:"intValue,omitempty"`
+	DoubleValue *float64        `json:"doubleValue,omitempty"`
+	ArrayValue  *otlpArrayValue `json:"arrayValue,omitempty"`
+}
+
+type otlpArrayValue struct {
+	Values []otlpAnyValue `json:"values"`
+}
+
+// OTLP span kind and status codes
+const (
+	otlpSpanKindInternal = 1
+	otlpStatusOK         = 1
+	otlpStatusError      = 2
+)
+
+// otlpScopeName is the instrumentation scope of exported data
+const otlpScopeName = "github.com/baretech/lens"
+
+// send exports a batch of events as spans and log records
+func (w *OTLPWriter) send(ctx context.Context, events []Event) error {
+	var spans []otlpSpan
+	var logs []Event
+
+	w.mutex.Lock()
+	for _, event := range events {
+		key := otlpSpanKey(event)
+		switch {
+		case event.Type == EventFunctionCall || event.Type == EventMethodCall:
+			if span := w.openSpan(key); span != nil {
+				span.arguments = event.Arguments
+			}
+		case endsSpan(event):
+			var held *otlpOpenSpan
+			if span, ok := w.open[key]; ok {
+				held = span
+				delete(w.open, key)
+			}
+			spans = append(spans, otlpSpanFromEvent(event, held))
+		case w.spanEvents && (event.SpanID != "" || w.open[key] != nil):
+			if span := w.openSpan(key); span != nil {
+				span.events = append(span.events, event)
+			} else {
+				logs = append(logs, event)
+			}
+		default:
+			logs = append(logs, event)
+		}
+	}
+	w.mutex.Unlock()
+
+	var errs []error
+	if len(spans) > 0 {
+		errs = append(errs, w.sendSpans(ctx, spans))
+	}
+	if len(logs) > 0 {
+		errs = append(errs, w.sendLogs(ctx, logs))
+	}
+	return errors.Join(errs...)
+}
+
+// openSpan returns the held state of a span, creating it if there is
+// room; the caller must hold the mutex
+func (w *OTLPWriter) openSpan(key string) *otlpOpenSpan {
+	if span, ok := w.open[key]; ok {
+		return span
+	}
+	if len(w.open) >= maxOTLPOpenSpans {
+		return nil
+	}
+	span := &otlpOpenSpan{}
+	w.open[key] = span
+	return span
+}
+
+// sendSpans posts spans to the traces endpoint
+func (w *OTLPWriter) sendSpans(ctx context.Context, spans []otlpSpan) error {
+	body, err := json.Marshal(otlpTraces{ResourceSpans: []otlpResourceSpans{{
+		Resource:   w.resource,
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: otlpScopeName}, Spans: spans}},
+	}}})
+	if err != nil {
+		return fmt.Errorf("failed to marshal spans: %w", err)
+	}
+	_, err = w.post(ctx, w.tracesURL, "application/json", body)
+	return err
+}
+
+// sendLogs posts events as log records to the logs endpoint
+func (w *OTLPWriter) sendLogs(ctx context.Context, events []Event) error {
+	records := make([]otlpLogRecord, len(events))
+	for i, event := range events {
+		records[i] = otlpLogRecordFromEvent(event)
+	}
+
+	body, err := json.Marshal(otlpLogs{ResourceLogs: []otlpResourceLogs{{
+		Resource:  w.resource,
+		ScopeLogs: []otlpScopeLogs{{Scope: otlpScope{Name: otlpScopeName}, LogRecords: records}},
+	}}})
+	if err != nil {
+		return fmt.Errorf("failed to marshal log records: %w", err)
+	}
+	_, err = w.post(ctx, w.logsURL, "application/json", body)
+	return err
+}
+
+// endsSpan reports whether an event is the end of a span or wrapped call
+func endsSpan(event Event) bool {
+	return event.Type == EventFunctionReturn || (event.Type == EventError && event.SpanID != "" && event.Duration > 0)
+}
+
+// otlpSpanKey identifies the span enclosing an event: a span started with
+// StartSpan, or a wrapped call, whose events share a trace ID
+func otlpSpanKey(event Event) string {
+	return event.TraceID + "/" + event.SpanID
+}
+
+// otlpSpanFromEvent converts the end event of a span, with what it held
+func otlpSpanFromEvent(event Event, held *otlpOpenSpan) otlpSpan {
+	traceID, spanID := otlpIDs(event)
+	end := event.Timestamp
+	span := otlpSpan{
+		TraceID:           traceID,
+		SpanID:            spanID,
+		Name:              event.Function,
+		Kind:              otlpSpanKindInternal,
+		StartTimeUnixNano: otlpTime(end.Add(-event.Duration)),
+		EndTimeUnixNano:   otlpTime(end),
+		Attributes:        otlpEventAttributes(event),
+	}
+	// The status set with SetStatus goes in the span's status field
+	attrs := span.Attributes[:0]
+	for _, attr := range span.Attributes {
+		if attr.Key != TagStatusCode && attr.Key != TagStatusMessage {
+			attrs = append(attrs, attr)
+		}
+	}
+	span.Attributes = attrs
+	if parent, ok := event.Tags[TagParentSpanID].(string); ok && parent != "" {
+		span.ParentSpanID = otlpSpanID(parent)
+	}
+	if len(event.ReturnValue) > 0 {
+		span.Attributes = append(span.Attributes, otlpKeyValue{Key: OTLPAttrReturns, Value: otlpValue(event.ReturnValue)})
+	}
+	span.Status = otlpSpanStatus(event)
+	for _, link := range event.Links {
+		span.Links = append(span.Links, otlpLink{
+			TraceID:    otlpTraceID(link.TraceID),
+			SpanID:     otlpSpanID(link.SpanID),
+			Attributes: otlpAttributes(link.Attributes),
+		})
+	}
+
+	if held != nil {
+		if len(held.arguments) > 0 {
+			span.Attributes = append(span.Attributes, otlpKeyValue{Key: OTLPAttrArguments, Value: otlpValue(held.arguments)})
+		}
+		for _, e := range held.events {
+			span.Events = append(span.Events, otlpEvent{
+				TimeUnixNano: otlpTime(e.Timestamp),
+				Name:         otlpEventName(e),
+				Attributes:   otlpEventAttributes(e),
+			})
+		}
+	}
+	return span
+}
+
+// otlpLogRecordFromEvent converts an event to a log record
+func otlpLogRecordFromEvent(event Event) otlpLogRecord {
+	severity, text := otlpSeverity(event.Type)
+	record := otlpLogRecord{
+		TimeUnixNano:   otlpTime(event.Timestamp),
+		SeverityNumber: severity,
+		SeverityText:   text,
+		Body:           otlpValue(otlpEventName(event)),
+		Attributes:     otlpEventAttributes(event),
+	}
+	if event.TraceID != "" {
+		record.TraceID, record.SpanID = otlpIDs(event)
+	}
+	return record
+}
+
+// otlpSpanStatus returns the status of a span from its end event
+func otlpSpanStatus(event Event) otlpStatus {
+	switch {
+	case event.Error != "":
+		return otlpStatus{Code: otlpStatusError, Message: event.Error}
+	case event.Tags[TagStatusCode] == StatusOK.String():
+		return otlpStatus{Code: otlpStatusOK}
+	}
+	return otlpStatus{}
+}
+
+// otlpEventName names an event in a span event or log record body
+func otlpEventName(event Event) string {
+	switch {
+	case event.Type == EventSpanEvent:
+		if name, ok := event.Tags[TagEventName].(string); ok {
+			return name
+		}
+	case event.Type == EventLog:
+		if message, ok := event.Tags[TagLogMessage].(string); ok {
+			return message
+		}
+	case event.Error != "":
+		return event.Error
+	case event.Variable != "":
+		return string(event.Type) + " " + event.Variable
+	case event.Function != "":
+		return string(event.Type) + " " + event.Function
+	}
+	return string(event.Type)
+}
+
+// otlpEventAttributes returns the attributes of an event: its tags and
+// the fields OTLP has no place for
+func otlpEventAttributes(event Event) []otlpKeyValue {
+	attrs := otlpAttributes(event.Tags)
+	add := func(key string, value interface{}) {
+		attrs = append(attrs, otlpKeyValue{Key: key, Value: otlpValue(value)})
+	}
+
+	add(OTLPAttrEventType, string(event.Type))
+	if event.TraceID != "" {
+		add(OTLPAttrTraceID, event.TraceID)
+	}
+	if event.SpanID != "" {
+		add(OTLPAttrSpanID, event.SpanID)
+	}
+	if event.Component != "" {
+		add("lens.component", event.Component)
+	}
+	if event.Function != "" {
+		add("code.function", event.Function)
+	}
+	if event.SourceFile != "" {
+		add("code.filepath", event.SourceFile)
+		add("code.lineno", event.SourceLine)
+	}
+	if event.Goroutine != 0 {
+		add("thread.id", event.Goroutine)
+	}
+	if event.Variable != "" {
+		add("lens.variable", event.Variable)
+		if event.OldValue != nil {
+			add("lens.old_value", event.OldValue)
+		}
+		if event.NewValue != nil {
+			add("lens.new_value", event.NewValue)
+		}
+	}
+	if event.Changes != nil {
+		add("lens.changes", flatJSON(event.Changes))
+	}
+	if event.Error != "" {
+		add("exception.message", event.Error)
+	}
+	if event.Duration > 0 && !endsSpan(event) {
+		add("lens.duration_ns", int64(event.Duration))
+	}
+	if event.ExternalTraceID != "" {
+		add("lens.external_trace_id", event.ExternalTraceID)
+	}
+	return attrs
+}
+
+// otlpAttributes converts tags to attributes, sorted by key
+func otlpAttributes(tags map[string]interface{}) []otlpKeyValue {
+	if len(tags) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]otlpKeyValue, len(keys))
+	for i, key := range keys {
+		attrs[i] = otlpKeyValue{Key: key, Value: otlpValue(tags[key])}
+	}
+	return attrs
+}
+
+// otlpValue converts a value to an attribute value. Types OTLP has no
+// value for are formatted as strings.
+func otlpValue(v interface{}) otlpAnyValue {
+	switch value := v.(type) {
+	case string:
+		return otlpAnyValue{StringValue: &value}
+	case bool:
+		return otlpAnyValue{BoolValue: &value}
+	case int:
+		return otlpInt(int64(value))
+	case int32:
+		return otlpInt(int64(value))
+	case int64:
+		return otlpInt(value)
+	case uint32:
+		return otlpInt(int64(value))
+	case float32:
+		f := float64(value)
+		return otlpAnyValue{DoubleValue: &f}
+	case float64:
+		return otlpAnyValue{DoubleValue: &value}
+	case time.Duration:
+		s := value.String()
+		return otlpAnyValue{StringValue: &s}
+	case error:
+		s := value.Error()
+		return otlpAnyValue{StringValue: &s}
+	case []interface{}:
+		values := make([]otlpAnyValue, len(value))
+		for i, item := range value {
+			values[i] = otlpValue(item)
+		}
+		return otlpAnyValue{ArrayValue: &otlpArrayValue{Values: values}}
+	case []string:
+		values := make([]otlpAnyValue, len(value))
+		for i, item := range value {
+			values[i] = otlpValue(item)
+		}
+		return otlpAnyValue{ArrayValue: &otlpArrayValue{Values: values}}
+	}
+	s := fmt.Sprint(v)
+	return otlpAnyValue{StringValue: &s}
+}
+
+// otlpInt returns an integer value, encoded as a decimal string
+func otlpInt(n int64) otlpAnyValue {
+	s := strconv.FormatInt(n, 10)
+	return otlpAnyValue{IntValue: &s}
+}
+
+// otlpSeverity maps an event type to a log severity number and text
+func otlpSeverity(eventType EventType) (int, string) {
+	switch eventLevel(eventType) {
+	case LevelError:
+		return 17, "ERROR"
+	case LevelWarn:
+		return 13, "WARN"
+	case LevelDebug:
+		return 5, "DEBUG"
+	case LevelTrace:
+		return 1, "TRACE"
+	default:
+		return 9, "INFO"
+	}
+}
+
+// otlpIDs returns the OTLP trace and span IDs of the span enclosing an
+// event. Wrapped calls have no span ID, and take one from their trace ID.
+func otlpIDs(event Event) (string, string) {
+	spanID := event.SpanID
+	if spanID == "" {
+		spanID = event.TraceID
+	}
+	return otlpTraceID(event.TraceID), otlpSpanID(spanID)
+}
+
+// otlpTraceID converts a trace ID to 16 bytes of hex, keeping W3C IDs
+func otlpTraceID(id string) string {
+	return otlpID(id, 16)
+}
+
+// otlpSpanID converts a span ID to 8 bytes of hex, keeping W3C IDs
+func otlpSpanID(id string) string {
+	return otlpID(id, 8)
+}
+
+// otlpID returns id if it is already size bytes of hex, or else the first
+// size bytes of its hash
+func otlpID(id string, size int) string {
+	if len(id) == 2*size {
+		if _, err := hex.DecodeString(id); err == nil {
+			return strings.ToLower(id)
+		}
+	}
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:size])
+}
+
+// otlpTime formats a timestamp as decimal nanoseconds
+func otlpTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
@@ -0,0 +1,91 @@
+package lens
+
+import (
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+)
+
+// overheadSampleMask selects the events timed for Overhead, one in 64, so
+// measuring the overhead adds little to it
+const overheadSampleMask = 63
+
+// OverheadStats reports what tracing costs per event, measured by timing a
+// sample of the events traced by every tracer in the process
+type OverheadStats struct {
+	// Capturing arguments, results, source locations and context for a
+	// call or return event
+	Capture time.Duration `json:"capture"`
+	// Running filters and processors
+	Filter time.Duration `json:"filter"`
+	// Writing the event, for each writer
+	Write time.Duration `json:"write"`
+	// Events timed
+	Samples int64 `json:"samples"`
+}
+
+// Total returns the cost of an event written by one writer
+func (o OverheadStats) Total() time.Duration {
+	return o.Capture + o.Filter + o.Write
+}
+
+// overheadStage accumulates the timings of one stage of tracing an event
+type overheadStage struct {
+	total atomic.Int64
+	count atomic.Int64
+}
+
+// overhead holds the timings reported by Overhead
+var overhead struct {
+	capture overheadStage
+	filter  overheadStage
+	write   overheadStage
+}
+
+// Overhead returns the mean cost of each stage of tracing an event so
+// far, to quantify what enabling lens costs before doing so in
+// production. The wrapped call itself is not included.
+func Overhead() OverheadStats {
+	return OverheadStats{
+		Capture: overhead.capture.mean(),
+		Filter:  overhead.filter.mean(),
+		Write:   overhead.write.mean(),
+		Samples: overhead.capture.count.Load() + overhead.filter.count.Load() + overhead.write.count.Load(),
+	}
+}
+
+// ResetOverhead discards the timings collected so far, e.g. after warming
+// up
+func ResetOverhead() {
+	for _, stage := range []*overheadStage{&overhead.capture, &overhead.filter, &overhead.write} {
+		stage.total.Store(0)
+		stage.count.Store(0)
+	}
+}
+
+// start returns the time a sampled stage starts, or the zero time for
+// events not timed
+func (s *overheadStage) start() time.Time {
+	if rand.Uint32()&overheadSampleMask != 0 {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// stop records a stage timed from start
+func (s *overheadStage) stop(start time.Time) {
+	if start.IsZero() {
+		return
+	}
+	s.total.Add(int64(time.Since(start)))
+	s.count.Add(1)
+}
+
+// mean returns the mean time of the stage
+func (s *overheadStage) mean() time.Duration {
+	count := s.count.Load()
+	if count == 0 {
+		return 0
+	}
+	return time.Duration(s.total.Load() / count)
+}
@@ -0,0 +1,151 @@
+package lens
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// paramRegistry holds parameter names registered explicitly or resolved
+// from source, keyed by runtime function name
+var paramRegistry = struct {
+	names map[string][]string
+	files map[string]*ast.File
+	fset  *token.FileSet
+	mutex sync.Mutex
+}{
+	names: make(map[string][]string),
+	files: make(map[string]*ast.File),
+	fset:  token.NewFileSet(),
+}
+
+// WithParamNames records arguments by parameter name in Event.Params.
+// Names come from RegisterParamNames (as emitted by lensgen) or, failing
+// that, from the function's source file when it is available at runtime.
+func WithParamNames() Option {
+	return func(t *TracerImpl) {
+		t.paramNames = true
+	}
+}
+
+// RegisterParamNames declares the parameter names of fn, for binaries
+// deployed without their source
+func RegisterParamNames(fn interface{}, names ...string) {
+	value := reflect.ValueOf(fn)
+	if value.Kind() != reflect.Func {
+		panic(fmt.Sprintf("lens: RegisterParamNames called with %T, not a function", fn))
+	}
+	f := runtime.FuncForPC(value.Pointer())
+	if f == nil {
+		return
+	}
+
+	paramRegistry.mutex.Lock()
+	defer paramRegistry.mutex.Unlock()
+	paramRegistry.names[f.Name()] = names
+}
+
+// lookupParamNames returns the parameter names of fn, or nil if unknown
+func lookupParamNames(fn reflect.Value) []string {
+	f := runtime.FuncForPC(fn.Pointer())
+	if f == nil {
+		return nil
+	}
+	name := f.Name()
+
+	paramRegistry.mutex.Lock()
+	defer paramRegistry.mutex.Unlock()
+
+	if names, ok := paramRegistry.names[name]; ok {
+		return names
+	}
+
+	file, line := f.FileLine(f.Entry())
+	names := paramNamesFromSource(file, line, fn.Type().NumIn())
+	// Cache misses too, so the source is parsed at most once per function
+	paramRegistry.names[name] = names
+	return names
+}
+
+// paramNamesFromSource finds the function declared at file:line and returns
+// its parameter names. Must be called with the registry lock held.
+func paramNamesFromSource(file string, line, numIn int) []string {
+	if !strings.HasSuffix(file, ".go") {
+		return nil
+	}
+
+	parsed, ok := paramRegistry.files[file]
+	if !ok {
+		parsed, _ = parser.ParseFile(paramRegistry.fset, file, nil, parser.SkipObjectResolution)
+		paramRegistry.files[file] = parsed
+	}
+	if parsed == nil {
+		return nil
+	}
+
+	var names []string
+	ast.Inspect(parsed, func(node ast.Node) bool {
+		if names != nil {
+			return false
+		}
+
+		var funcType *ast.FuncType
+		switch n := node.(type) {
+		case *ast.FuncDecl:
+			funcType = n.Type
+		case *ast.FuncLit:
+			funcType = n.Type
+		default:
+			return true
+		}
+
+		if paramRegistry.fset.Position(funcType.Pos()).Line != line {
+			return true
+		}
+		if candidate := fieldNames(funcType.Params); len(candidate) == numIn {
+			names = candidate
+		}
+		return true
+	})
+	return names
+}
+
+// fieldNames lists the names in a parameter list, using argN for unnamed
+// and blank parameters
+func fieldNames(fields *ast.FieldList) []string {
+	names := make([]string, 0)
+	if fields == nil {
+		return names
+	}
+	for _, field := range fields.List {
+		if len(field.Names) == 0 {
+			names = append(names, fmt.Sprintf("arg%d", len(names)))
+			continue
+		}
+		for _, ident := range field.Names {
+			if ident.Name == "_" {
+				names = append(names, fmt.Sprintf("arg%d", len(names)))
+			} else {
+				names = append(names, ident.Name)
+			}
+		}
+	}
+	return names
+}
+
+// namedArguments pairs argument values with their parameter names
+func namedArguments(names []string, args []interface{}) map[string]interface{} {
+	if len(names) == 0 || len(names) != len(args) {
+		return nil
+	}
+	params := make(map[string]interface{}, len(args))
+	for i, name := range names {
+		params[name] = args[i]
+	}
+	return params
+}
@@ -0,0 +1,311 @@
+package lens
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// parquetMagic starts and ends every Parquet file
+const parquetMagic = "PAR1"
+
+// Parquet enum values from the format specification
+const (
+	parquetTypeInt64     = 2
+	parquetTypeByteArray = 6
+
+	parquetRequired = 0
+
+	parquetConvertedUTF8            = 0
+	parquetConvertedTimestampMicros = 10
+
+	parquetEncodingPlain = 0
+	parquetEncodingRLE   = 3
+
+	parquetCodecUncompressed = 0
+
+	parquetPageData = 0
+)
+
+// parquetChunk records where a column chunk was written
+type parquetChunk struct {
+	offset    int64
+	size      int64
+	numValues int64
+}
+
+// parquetRowGroup records a written row group
+type parquetRowGroup struct {
+	chunks  []parquetChunk
+	numRows int64
+	size    int64
+}
+
+// ParquetWriter writes trace events to an Apache Parquet file for
+// columnar analysis with tools such as DuckDB or Spark. It uses the same
+// flat schema as CSVWriter, stored uncompressed with plain encoding.
+//
+// Events are written in row groups; the file becomes readable once Close
+// writes the footer.
+type ParquetWriter struct {
+	file         *os.File
+	offset       int64
+	pending      []Event
+	rowGroupSize int
+	rowGroups    []parquetRowGroup
+	numRows      int64
+	mutex        sync.Mutex
+}
+
+// ParquetWriterOption configures a ParquetWriter
+type ParquetWriterOption func(*ParquetWriter)
+
+// WithParquetRowGroupSize sets how many events are buffered per row group
+func WithParquetRowGroupSize(size int) ParquetWriterOption {
+	return func(w *ParquetWriter) {
+		if size > 0 {
+			w.rowGroupSize = size
+		}
+	}
+}
+
+// NewParquetWriter creates a new Parquet writer. Parquet files cannot be
+// appended to, so an existing file at path is replaced.
+func NewParquetWriter(path string, options ...ParquetWriterOption) (*ParquetWriter, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	w := &ParquetWriter{
+		file:         file,
+		rowGroupSize: 10000,
+	}
+	for _, option := range options {
+		option(w)
+	}
+
+	if err := w.write([]byte(parquetMagic)); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Write buffers an event, writing a row group once enough are pending
+func (w *ParquetWriter) Write(event Event) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.file == nil {
+		return fmt.Errorf("parquet writer is closed")
+	}
+
+	w.pending = append(w.pending, event)
+	if len(w.pending) >= w.rowGroupSize {
+		return w.writeRowGroup()
+	}
+	return nil
+}
+
+// Flush writes pending events as a row group
+func (w *ParquetWriter) Flush() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	return w.writeRowGroup()
+}
+
+// Close writes pending events and the file footer
+func (w *ParquetWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+
+	err := w.writeRowGroup()
+	if err == nil {
+		err = w.writeFooter()
+	}
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	w.file = nil
+	return err
+}
+
+// write appends data to the file, tracking the offset
+func (w *ParquetWriter) write(data []byte) error {
+	n, err := w.file.Write(data)
+	w.offset += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write to file: %w", err)
+	}
+	return nil
+}
+
+// writeRowGroup writes the pending events as one row group with a single
+// data page per column; the caller must hold the mutex
+func (w *ParquetWriter) writeRowGroup() error {
+	if len(w.pending) == 0 {
+		return nil
+	}
+
+	group := parquetRowGroup{numRows: int64(len(w.pending))}
+	for _, column := range flatColumns {
+		var data bytes.Buffer
+		for _, event := range w.pending {
+			encodeParquetPlain(&data, column.value(event))
+		}
+
+		header := parquetPageHeader(data.Len(), len(w.pending))
+		chunk := parquetChunk{
+			offset:    w.offset,
+			size:      int64(len(header) + data.Len()),
+			numValues: int64(len(w.pending)),
+		}
+		if err := w.write(header); err != nil {
+			return err
+		}
+		if err := w.write(data.Bytes()); err != nil {
+			return err
+		}
+
+		group.chunks = append(group.chunks, chunk)
+		group.size += chunk.size
+	}
+
+	w.rowGroups = append(w.rowGroups, group)
+	w.numRows += group.numRows
+	w.pending = w.pending[:0]
+	return nil
+}
+
+// encodeParquetPlain appends a value in the PLAIN encoding
+func encodeParquetPlain(buf *bytes.Buffer, value interface{}) {
+	var tmp [8]byte
+	switch v := value.(type) {
+	case string:
+		binary.LittleEndian.PutUint32(tmp[:4], uint32(len(v)))
+		buf.Write(tmp[:4])
+		buf.WriteString(v)
+	case int64:
+		binary.LittleEndian.PutUint64(tmp[:], uint64(v))
+		buf.Write(tmp[:])
+	case time.Time:
+		binary.LittleEndian.PutUint64(tmp[:], uint64(v.UnixMicro()))
+		buf.Write(tmp[:])
+	}
+}
+
+// parquetPageHeader encodes the header of an uncompressed data page
+func parquetPageHeader(size, numValues int) []byte {
+	var t thriftWriter
+	t.beginStruct()
+	t.i32(1, parquetPageData)
+	t.i32(2, int32(size))
+	t.i32(3, int32(size))
+	t.structField(5)
+	t.i32(1, int32(numValues))
+	t.i32(2, parquetEncodingPlain)
+	t.i32(3, parquetEncodingRLE)
+	t.i32(4, parquetEncodingRLE)
+	t.endStruct()
+	t.endStruct()
+	return t.buf.Bytes()
+}
+
+// parquetPhysicalType returns the physical type storing a column kind
+func parquetPhysicalType(kind flatKind) int32 {
+	if kind == flatString {
+		return parquetTypeByteArray
+	}
+	return parquetTypeInt64
+}
+
+// writeFooter writes the file metadata, its length and the closing magic
+func (w *ParquetWriter) writeFooter() error {
+	var t thriftWriter
+	t.beginStruct()
+	t.i32(1, 1)
+
+	// Schema: a root group followed by one required leaf per column
+	t.listField(2, thriftStruct, len(flatColumns)+1)
+	t.beginStruct()
+	t.str(4, "schema")
+	t.i32(5, int32(len(flatColumns)))
+	t.endStruct()
+	for _, column := range flatColumns {
+		t.beginStruct()
+		t.i32(1, parquetPhysicalType(column.kind))
+		t.i32(3, parquetRequired)
+		t.str(4, column.name)
+		switch column.kind {
+		case flatString:
+			t.i32(6, parquetConvertedUTF8)
+		case flatTimestamp:
+			t.i32(6, parquetConvertedTimestampMicros)
+		}
+		t.endStruct()
+	}
+
+	t.i64(3, w.numRows)
+
+	t.listField(4, thriftStruct, len(w.rowGroups))
+	for _, group := range w.rowGroups {
+		t.beginStruct()
+		t.listField(1, thriftStruct, len(group.chunks))
+		for i, chunk := range group.chunks {
+			column := flatColumns[i]
+			t.beginStruct()
+			t.i64(2, chunk.offset)
+			t.structField(3)
+			t.i32(1, parquetPhysicalType(column.kind))
+			t.listField(2, thriftI32, 2)
+			t.i32Elem(parquetEncodingPlain)
+			t.i32Elem(parquetEncodingRLE)
+			t.listField(3, thriftBinary, 1)
+			t.rawString(column.name)
+			t.i32(4, parquetCodecUncompressed)
+			t.i64(5, chunk.numValues)
+			t.i64(6, chunk.size)
+			t.i64(7, chunk.size)
+			t.i64(9, chunk.offset)
+			t.endStruct()
+			t.endStruct()
+		}
+		t.i64(2, group.size)
+		t.i64(3, group.numRows)
+		t.endStruct()
+	}
+
+	t.str(6, "lens")
+	t.endStruct()
+
+	footer := t.buf.Bytes()
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(footer)))
+
+	if err := w.write(footer); err != nil {
+		return err
+	}
+	if err := w.write(length[:]); err != nil {
+		return err
+	}
+	return w.write([]byte(parquetMagic))
+}
@@ -0,0 +1,105 @@
+package lens
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Thrift compact protocol field types used by the Parquet metadata
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes structs with the Thrift compact protocol, which
+// Parquet uses for page headers and the file footer
+type thriftWriter struct {
+	buf bytes.Buffer
+	// Last field ID written in each open struct
+	lastField []int16
+}
+
+// beginStruct opens a struct; fields written until endStruct belong to it
+func (w *thriftWriter) beginStruct() {
+	w.lastField = append(w.lastField, 0)
+}
+
+// endStruct writes the stop byte and closes the current struct
+func (w *thriftWriter) endStruct() {
+	w.buf.WriteByte(0)
+	w.lastField = w.lastField[:len(w.lastField)-1]
+}
+
+// fieldHeader writes a field header, using the short delta form when possible
+func (w *thriftWriter) fieldHeader(id int16, fieldType byte) {
+	top := len(w.lastField) - 1
+	delta := id - w.lastField[top]
+	if delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | fieldType)
+	} else {
+		w.buf.WriteByte(fieldType)
+		w.varint(zigzag(int64(id)))
+	}
+	w.lastField[top] = id
+}
+
+// i32 writes an i32 field
+func (w *thriftWriter) i32(id int16, v int32) {
+	w.fieldHeader(id, thriftI32)
+	w.varint(zigzag(int64(v)))
+}
+
+// i64 writes an i64 field
+func (w *thriftWriter) i64(id int16, v int64) {
+	w.fieldHeader(id, thriftI64)
+	w.varint(zigzag(v))
+}
+
+// str writes a string field
+func (w *thriftWriter) str(id int16, s string) {
+	w.fieldHeader(id, thriftBinary)
+	w.rawString(s)
+}
+
+// structField opens a nested struct field
+func (w *thriftWriter) structField(id int16) {
+	w.fieldHeader(id, thriftStruct)
+	w.beginStruct()
+}
+
+// listField writes a list field header for size elements of elemType
+func (w *thriftWriter) listField(id int16, elemType byte, size int) {
+	w.fieldHeader(id, thriftList)
+	if size < 15 {
+		w.buf.WriteByte(byte(size)<<4 | elemType)
+	} else {
+		w.buf.WriteByte(0xF0 | elemType)
+		w.varint(uint64(size))
+	}
+}
+
+// i32Elem writes an i32 list element
+func (w *thriftWriter) i32Elem(v int32) {
+	w.varint(zigzag(int64(v)))
+}
+
+// rawString writes a length-prefixed string without a field header
+func (w *thriftWriter) rawString(s string) {
+	w.varint(uint64(len(s)))
+	w.buf.WriteString(s)
+}
+
+// varint writes an unsigned LEB128 varint
+func (w *thriftWriter) varint(v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	w.buf.Write(tmp[:n])
+}
+
+// zigzag maps signed integers to unsigned so small magnitudes stay short
+func zigzag(v int64) uint64 {
+	return uint64((v << 1) ^ (v >> 63))
+}
@@ -0,0 +1,174 @@
+package lens
+
+import (
+	"bufio"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// PathTrimming controls how source paths are recorded on events
+type PathTrimming int
+
+const (
+	// PathAbsolute records paths as reported by the runtime
+	PathAbsolute PathTrimming = iota
+	// PathRelative replaces the module root with the module path, the
+	// GOROOT with "$GOROOT" and module cache directories with
+	// module@version, e.g. "github.com/acme/app/billing/charge.go"
+	PathRelative
+	// PathHashed records a PathRelative path with its directory replaced
+	// by a short hash, keeping file names readable without revealing the
+	// source layout
+	PathHashed
+)
+
+// WithSourcePathTrimming normalizes the source, caller and stack trace
+// paths of events before they reach writers. Absolute paths are noisy and
+// leak details of the build machine.
+func WithSourcePathTrimming(mode PathTrimming) Option {
+	return func(t *TracerImpl) {
+		if mode == PathAbsolute {
+			t.paths = nil
+			return
+		}
+		t.paths = newPathTrimmer(mode)
+	}
+}
+
+// pathTrimmer rewrites source paths, caching the result for each path
+type pathTrimmer struct {
+	mode   PathTrimming
+	goroot string
+	// Trimmed paths by original path
+	paths sync.Map
+	// Module root and path by directory, found from go.mod files
+	modules sync.Map
+}
+
+// moduleRoot is the directory and import path of a module
+type moduleRoot struct {
+	dir  string
+	path string
+}
+
+// newPathTrimmer creates a path trimmer
+func newPathTrimmer(mode PathTrimming) *pathTrimmer {
+	goroot := os.Getenv("GOROOT")
+	if goroot == "" {
+		goroot = runtime.GOROOT()
+	}
+	return &pathTrimmer{mode: mode, goroot: filepath.ToSlash(goroot)}
+}
+
+// apply trims the paths of an event
+func (p *pathTrimmer) apply(event *Event) {
+	event.SourceFile = p.trim(event.SourceFile)
+	event.CallerFile = p.trim(event.CallerFile)
+
+	if len(event.StackTrace) > 0 {
+		stack := make([]string, len(event.StackTrace))
+		for i, frame := range event.StackTrace {
+			stack[i] = p.trimFrame(frame)
+		}
+		event.StackTrace = stack
+	}
+}
+
+// trimFrame trims the path of a "path:line function" stack frame
+func (p *pathTrimmer) trimFrame(frame string) string {
+	location, function, _ := strings.Cut(frame, " ")
+	colon := strings.LastIndex(location, ":")
+	if colon < 0 {
+		return frame
+	}
+	trimmed := p.trim(location[:colon]) + location[colon:]
+	if function != "" {
+		trimmed += " " + function
+	}
+	return trimmed
+}
+
+// trim returns the normalized form of path
+func (p *pathTrimmer) trim(path string) string {
+	if path == "" {
+		return path
+	}
+	if cached, ok := p.paths.Load(path); ok {
+		return cached.(string)
+	}
+
+	trimmed := p.relative(filepath.ToSlash(path))
+	if p.mode == PathHashed {
+		trimmed = hashDir(trimmed)
+	}
+
+	p.paths.Store(path, trimmed)
+	return trimmed
+}
+
+// relative rewrites an absolute path relative to its module, GOROOT or
+// module cache entry
+func (p *pathTrimmer) relative(path string) string {
+	if p.goroot != "" && strings.HasPrefix(path, p.goroot+"/") {
+		return "$GOROOT" + path[len(p.goroot):]
+	}
+	if _, rest, ok := strings.Cut(path, "/pkg/mod/"); ok {
+		return rest
+	}
+	if module, ok := p.module(filepath.Dir(path)); ok {
+		return module.path + path[len(module.dir):]
+	}
+	return path
+}
+
+// module finds the module containing dir by looking for go.mod files
+func (p *pathTrimmer) module(dir string) (moduleRoot, bool) {
+	if cached, ok := p.modules.Load(dir); ok {
+		module := cached.(moduleRoot)
+		return module, module.dir != ""
+	}
+
+	var module moduleRoot
+	if path, ok := modulePath(filepath.Join(dir, "go.mod")); ok {
+		module = moduleRoot{dir: filepath.ToSlash(dir), path: path}
+	} else if parent := filepath.Dir(dir); parent != dir {
+		module, _ = p.module(parent)
+	}
+
+	p.modules.Store(dir, module)
+	return module, module.dir != ""
+}
+
+// modulePath reads the module path declared in a go.mod file
+func modulePath(gomod string) (string, bool) {
+	file, err := os.Open(gomod)
+	if err != nil {
+		return "", false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if rest, ok := strings.CutPrefix(line, "module"); ok && rest != "" && (rest[0] == ' ' || rest[0] == '\t') {
+			return strings.Trim(strings.TrimSpace(rest), `"`), true
+		}
+	}
+	return "", false
+}
+
+// hashDir replaces the directory of path with a short hash
+func hashDir(path string) string {
+	slash := strings.LastIndex(path, "/")
+	if slash < 0 {
+		return path
+	}
+	h := fnv.New32a()
+	h.Write([]byte(path[:slash]))
+	return fmt.Sprintf("%08x%s", h.Sum32(), path[slash:])
+}
@@ -0,0 +1,278 @@
+package lens
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// stateFileVersion is the version of the state file format
+const stateFileVersion = 1
+
+// tracerState is what a state file holds
+type tracerState struct {
+	Version  int                   `json:"version"`
+	SavedAt  time.Time             `json:"saved_at"`
+	Stats    *collectorState       `json:"stats,omitempty"`
+	Counters map[string]CallCounts `json:"counters,omitempty"`
+	Quota    *quotaState           `json:"quota,omitempty"`
+}
+
+// collectorState is the aggregated statistics of a StatsCollector
+type collectorState struct {
+	Since     time.Time                 `json:"since"`
+	Functions map[string]aggregateState `json:"functions"`
+}
+
+// aggregateState is a functionAggregate
+type aggregateState struct {
+	Count     uint64         `json:"count"`
+	Errors    uint64         `json:"errors"`
+	Min       time.Duration  `json:"min"`
+	Max       time.Duration  `json:"max"`
+	Sum       time.Duration  `json:"sum"`
+	Histogram map[int]uint64 `json:"histogram"`
+}
+
+// quotaState is the usage of the current quota window
+type quotaState struct {
+	WindowStart time.Time             `json:"window_start"`
+	Global      usageState            `json:"global"`
+	Components  map[string]usageState `json:"components,omitempty"`
+}
+
+// usageState is a quotaUsage
+type usageState struct {
+	Events   int   `json:"events"`
+	Bytes    int64 `json:"bytes"`
+	Exceeded bool  `json:"exceeded,omitempty"`
+}
+
+// WithStateFile keeps the tracer's aggregated state across restarts in a
+// state file: the statistics of its StatsCollector, the counts of
+// counts-only mode and the usage of the current quota window. The state
+// is loaded once the tracer is created, saved every interval if positive,
+// and saved by Close. A state file that cannot be loaded is ignored; call
+// Load to see why. Head sampling needs no state, as its decisions follow
+// from trace IDs.
+func WithStateFile(path string, interval time.Duration) Option {
+	return func(t *TracerImpl) {
+		t.stateFile = &stateFile{path: path, interval: interval, done: make(chan struct{})}
+	}
+}
+
+// Save writes the tracer's aggregated state to path, replacing the file
+// atomically, so a crash while saving leaves the previous state intact
+func (t *TracerImpl) Save(path string) error {
+	data, err := json.Marshal(t.root().state())
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	return nil
+}
+
+// Load adds the state saved to path to the tracer's: statistics are
+// merged into those of its StatsCollector, counts added to its counters,
+// and quota usage restored if its window is still current. A missing file
+// is not an error, so the first start of a service loads nothing.
+func (t *TracerImpl) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+
+	var state tracerState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to decode state: %w", err)
+	}
+	if state.Version != stateFileVersion {
+		return fmt.Errorf("unsupported state file version %d", state.Version)
+	}
+
+	t = t.root()
+	if collector := t.statsCollector(); collector != nil && state.Stats != nil {
+		collector.restore(state.Stats)
+	}
+	for function, counts := range state.Counters {
+		counter := t.counter(function)
+		counter.calls.Add(counts.Calls)
+		counter.errors.Add(counts.Errors)
+		counter.nanos.Add(int64(counts.Duration))
+	}
+	if t.quota != nil && state.Quota != nil {
+		t.quota.restore(state.Quota)
+	}
+	return nil
+}
+
+// state captures the tracer's aggregated state
+func (t *TracerImpl) state() *tracerState {
+	state := &tracerState{Version: stateFileVersion, SavedAt: time.Now()}
+	if collector := t.statsCollector(); collector != nil {
+		state.Stats = collector.snapshot()
+	}
+	if counters := t.Counters(); len(counters) > 0 {
+		state.Counters = counters
+	}
+	if t.quota != nil {
+		state.Quota = t.quota.snapshot()
+	}
+	return state
+}
+
+// snapshot captures the collector's aggregates
+func (c *StatsCollector) snapshot() *collectorState {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	state := &collectorState{Since: c.since, Functions: make(map[string]aggregateState, len(c.functions))}
+	for name, agg := range c.functions {
+		histogram := make(map[int]uint64, len(agg.histogram))
+		for index, count := range agg.histogram {
+			histogram[index] = count
+		}
+		state.Functions[name] = aggregateState{
+			Count: agg.count, Errors: agg.errors,
+			Min: agg.min, Max: agg.max, Sum: agg.sum,
+			Histogram: histogram,
+		}
+	}
+	return state
+}
+
+// restore merges saved aggregates into the collector's
+func (c *StatsCollector) restore(state *collectorState) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !state.Since.IsZero() && state.Since.Before(c.since) {
+		c.since = state.Since
+	}
+	for name, saved := range state.Functions {
+		if saved.Count == 0 {
+			continue
+		}
+		agg, ok := c.functions[name]
+		if !ok {
+			agg = &functionAggregate{histogram: make(histogram)}
+			c.functions[name] = agg
+		}
+		if agg.count == 0 || saved.Min < agg.min {
+			agg.min = saved.Min
+		}
+		if saved.Max > agg.max {
+			agg.max = saved.Max
+		}
+		agg.count += saved.Count
+		agg.errors += saved.Errors
+		agg.sum += saved.Sum
+		for index, count := range saved.Histogram {
+			agg.histogram[index] += count
+		}
+	}
+}
+
+// snapshot captures the usage of the current window
+func (g *quotaGuard) snapshot() *quotaState {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	state := &quotaState{WindowStart: g.windowStart, Global: g.global.state()}
+	if len(g.components) > 0 {
+		state.Components = make(map[string]usageState, len(g.components))
+		for component, usage := range g.components {
+			state.Components[component] = usage.state()
+		}
+	}
+	return state
+}
+
+// restore resumes a saved window, unless it is over or one has started
+func (g *quotaGuard) restore(state *quotaState) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if time.Since(state.WindowStart) >= g.quota.Window || !g.windowStart.IsZero() {
+		return
+	}
+	g.setWindowStart(state.WindowStart)
+	g.global = state.Global.usage()
+	g.globalExceeded.Store(g.global.exceeded)
+	for component, usage := range state.Components {
+		restored := usage.usage()
+		g.components[component] = &restored
+		if restored.exceeded {
+			g.exceeded.Store(component, struct{}{})
+		}
+	}
+}
+
+// state returns the saved form of usage
+func (u quotaUsage) state() usageState {
+	return usageState{Events: u.events, Bytes: u.bytes, Exceeded: u.exceeded}
+}
+
+// usage returns the usage saved
+func (s usageState) usage() quotaUsage {
+	return quotaUsage{events: s.Events, bytes: s.Bytes, exceeded: s.Exceeded}
+}
+
+// stateFile saves a tracer's state periodically, see WithStateFile
+type stateFile struct {
+	path     string
+	interval time.Duration
+	done     chan struct{}
+	once     sync.Once
+}
+
+// start loads the state file and starts saving it periodically
+func (f *stateFile) start(t *TracerImpl) {
+	t.Load(f.path)
+	if f.interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(f.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				t.Save(f.path)
+			case <-f.done:
+				return
+			}
+		}
+	}()
+}
+
+// stop stops saving periodically
+func (f *stateFile) stop() {
+	f.once.Do(func() {
+		close(f.done)
+	})
+}
@@ -0,0 +1,34 @@
+package lens
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+// pprof label keys set on goroutines running wrapped calls
+const (
+	PprofLabelTraceID  = "lens_trace_id"
+	PprofLabelFunction = "lens_function"
+)
+
+// WithPprofLabels sets pprof labels with the trace ID and function name for
+// the duration of every wrapped call, so CPU profiles taken concurrently can
+// be sliced by lens trace (e.g. go tool pprof -tagfocus lens_function=...)
+func WithPprofLabels() Option {
+	return func(t *TracerImpl) {
+		t.pprofLabels = true
+	}
+}
+
+// callWithPprofLabels runs fn with lens pprof labels applied to the current
+// goroutine. Labels already carried by ctx are preserved.
+func callWithPprofLabels(ctx context.Context, traceID, function string, fn func()) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	labels := pprof.Labels(PprofLabelTraceID, traceID, PprofLabelFunction, function)
+	pprof.Do(ctx, labels, func(context.Context) {
+		fn()
+	})
+}
@@ -0,0 +1,199 @@
+package lens
+
+import (
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+)
+
+// ProcessorFunc adapts an ordinary function to the Processor interface
+type ProcessorFunc func(event Event) Event
+
+// Process calls f(event)
+func (f ProcessorFunc) Process(event Event) Event {
+	return f(event)
+}
+
+// TagProcessor stamps a fixed set of tags onto every event
+type TagProcessor struct {
+	tags map[string]interface{}
+}
+
+// NewTagProcessor creates a new tag processor
+func NewTagProcessor(tags map[string]interface{}) *TagProcessor {
+	copied := make(map[string]interface{}, len(tags))
+	for k, v := range tags {
+		copied[k] = v
+	}
+
+	return &TagProcessor{
+		tags: copied,
+	}
+}
+
+// Process stamps the configured tags onto the event
+func (p *TagProcessor) Process(event Event) Event {
+	for k, v := range p.tags {
+		event = withTag(event, k, v)
+	}
+	return event
+}
+
+// DefaultRedaction replaces redacted values unless configured otherwise
+const DefaultRedaction = "[REDACTED]"
+
+// RedactionProcessor masks sensitive values before events reach writers
+type RedactionProcessor struct {
+	replacement      string
+	functionPatterns []string
+	variablePatterns []string
+	tagPatterns      []string
+}
+
+// NewRedactionProcessor creates a new redaction processor
+func NewRedactionProcessor() *RedactionProcessor {
+	return &RedactionProcessor{
+		replacement: DefaultRedaction,
+	}
+}
+
+// WithReplacement sets the value substituted for redacted data
+func (p *RedactionProcessor) WithReplacement(replacement string) *RedactionProcessor {
+	p.replacement = replacement
+	return p
+}
+
+// RedactFunctions masks the arguments and return values of matching functions
+func (p *RedactionProcessor) RedactFunctions(patterns ...string) *RedactionProcessor {
+	p.functionPatterns = append(p.functionPatterns, patterns...)
+	return p
+}
+
+// RedactVariables masks the old and new values of matching variables
+func (p *RedactionProcessor) RedactVariables(patterns ...string) *RedactionProcessor {
+	p.variablePatterns = append(p.variablePatterns, patterns...)
+	return p
+}
+
+// RedactTags masks the values of matching tags
+func (p *RedactionProcessor) RedactTags(patterns ...string) *RedactionProcessor {
+	p.tagPatterns = append(p.tagPatterns, patterns...)
+	return p
+}
+
+// Process masks the configured values
+func (p *RedactionProcessor) Process(event Event) Event {
+	if event.Function != "" && matchAny(p.functionPatterns, event.Function) {
+		event.Arguments = p.mask(event.Arguments)
+		event.ReturnValue = p.mask(event.ReturnValue)
+	}
+
+	if event.Variable != "" && matchAny(p.variablePatterns, event.Variable) {
+		if event.OldValue != nil {
+			event.OldValue = p.replacement
+		}
+		if event.NewValue != nil {
+			event.NewValue = p.replacement
+		}
+		if len(event.Changes) > 0 {
+			changes := make([]FieldChange, len(event.Changes))
+			for i, change := range event.Changes {
+				changes[i] = FieldChange{Path: change.Path, Old: p.replacement, New: p.replacement}
+			}
+			event.Changes = changes
+		}
+	}
+
+	for key := range event.Tags {
+		if matchAny(p.tagPatterns, key) {
+			event = withTag(event, key, p.replacement)
+		}
+	}
+
+	return event
+}
+
+// mask returns a copy of values with every element replaced
+func (p *RedactionProcessor) mask(values []interface{}) []interface{} {
+	if len(values) == 0 {
+		return values
+	}
+	masked := make([]interface{}, len(values))
+	for i := range masked {
+		masked[i] = p.replacement
+	}
+	return masked
+}
+
+// matchAny reports whether name matches any of the glob patterns. Since
+// * does not cross slashes, names are also matched without their import
+// path, so "*.Login" matches "example.com/app/auth.Login".
+func matchAny(patterns []string, name string) bool {
+	short := name[strings.LastIndex(name, "/")+1:]
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+		if matched, _ := filepath.Match(pattern, short); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Convenience functions for creating common processors
+
+// StaticTags creates a processor that adds the given tags to every event
+func StaticTags(tags map[string]interface{}) Processor {
+	return NewTagProcessor(tags)
+}
+
+// Hostname creates a processor that tags events with the machine hostname
+func Hostname() Processor {
+	hostname, _ := os.Hostname()
+	return NewTagProcessor(map[string]interface{}{"host.name": hostname})
+}
+
+// PodName creates a processor that tags events with the Kubernetes pod
+// name and namespace, read from the POD_NAME and POD_NAMESPACE variables
+// usually populated through the downward API
+func PodName() Processor {
+	tags := make(map[string]interface{})
+	if name := os.Getenv("POD_NAME"); name != "" {
+		tags["k8s.pod.name"] = name
+	}
+	if namespace := os.Getenv("POD_NAMESPACE"); namespace != "" {
+		tags["k8s.namespace.name"] = namespace
+	}
+	return NewTagProcessor(tags)
+}
+
+// BuildVersion creates a processor that tags events with the main
+// module version and VCS revision embedded by the Go toolchain
+func BuildVersion() Processor {
+	tags := make(map[string]interface{})
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		tags["build.version"] = info.Main.Version
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				tags["build.revision"] = setting.Value
+			}
+		}
+	}
+
+	return NewTagProcessor(tags)
+}
+
+// RedactFunctions creates a processor that masks the arguments and return
+// values of functions matching the given patterns
+func RedactFunctions(patterns ...string) Processor {
+	return NewRedactionProcessor().RedactFunctions(patterns...)
+}
+
+// RedactVariables creates a processor that masks the values of variables
+// matching the given patterns
+func RedactVariables(patterns ...string) Processor {
+	return NewRedactionProcessor().RedactVariables(patterns...)
+}
@@ -0,0 +1,143 @@
+package lens
+
+import (
+	"context"
+	"net/textproto"
+)
+
+// Headers carrying the lens span a message was sent from
+const (
+	TraceIDHeader = "Lens-Trace-Id"
+	SpanIDHeader  = "Lens-Span-Id"
+)
+
+// TagParentSpanID holds the ID of the span a span was started from, in
+// this process or, through Extract, in the one that sent a message
+const TagParentSpanID = "span.parent_id"
+
+// Carrier holds propagated values, such as the headers of a message
+type Carrier interface {
+	Get(key string) string
+	Set(key, value string)
+}
+
+// MapCarrier is a Carrier over a plain map, for brokers whose headers are
+// key/value pairs. Get falls back to a case-insensitive match, since
+// producers and consumers do not always agree on case.
+type MapCarrier map[string]string
+
+// Get returns the value of key
+func (c MapCarrier) Get(key string) string {
+	if value, ok := c[key]; ok {
+		return value
+	}
+	canonical := textproto.CanonicalMIMEHeaderKey(key)
+	for k, value := range c {
+		if textproto.CanonicalMIMEHeaderKey(k) == canonical {
+			return value
+		}
+	}
+	return ""
+}
+
+// Set sets the value of key
+func (c MapCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+// HeaderCarrier is a Carrier over MIME-style headers, such as http.Header
+// or nats.Header, which convert to it directly
+type HeaderCarrier textproto.MIMEHeader
+
+// Get returns the first value of key
+func (c HeaderCarrier) Get(key string) string {
+	return textproto.MIMEHeader(c).Get(key)
+}
+
+// Set replaces the values of key
+func (c HeaderCarrier) Set(key, value string) {
+	textproto.MIMEHeader(c).Set(key, value)
+}
+
+// Values returns every value of key
+func (c HeaderCarrier) Values(key string) []string {
+	return textproto.MIMEHeader(c).Values(key)
+}
+
+// Inject writes the request ID, traceparent, baggage and current span of
+// ctx into carrier, for a message about to be sent. A consumer passes the
+// message's headers to Extract to continue the trace. To write the trace
+// in other formats, use the Inject method of a tracer with propagators.
+func Inject(ctx context.Context, carrier Carrier) {
+	injectCorrelation(ctx, carrier)
+	LensHeaders().Inject(ctx, carrier)
+}
+
+// Extract returns a copy of ctx carrying the values Inject wrote into
+// carrier. Spans started from the returned context belong to the sender's
+// trace, with its span as their parent.
+func Extract(ctx context.Context, carrier Carrier) context.Context {
+	ctx = extractCorrelation(ctx, carrier)
+	return LensHeaders().Extract(ctx, carrier)
+}
+
+// injectCorrelation writes the request ID, traceparent and baggage of ctx
+// into carrier, whatever the propagation format
+func injectCorrelation(ctx context.Context, carrier Carrier) {
+	if id := RequestIDFromContext(ctx); id != "" {
+		carrier.Set("X-Request-ID", id)
+	}
+	if tp := TraceparentFromContext(ctx); tp != "" {
+		carrier.Set(TraceparentHeader, tp)
+	}
+	if baggage := formatBaggage(baggageFromContext(ctx)); baggage != "" {
+		carrier.Set(BaggageHeader, baggage)
+	}
+}
+
+// extractCorrelation returns a copy of ctx carrying the request ID,
+// traceparent and baggage in carrier
+func extractCorrelation(ctx context.Context, carrier Carrier) context.Context {
+	if id := carrier.Get("X-Request-ID"); id != "" {
+		ctx = ContextWithRequestID(ctx, id)
+	}
+	if tp := carrier.Get(TraceparentHeader); tp != "" {
+		ctx = ContextWithTraceparent(ctx, tp)
+	}
+
+	var baggage []string
+	if c, ok := carrier.(interface{ Values(string) []string }); ok {
+		baggage = c.Values(BaggageHeader)
+	} else if value := carrier.Get(BaggageHeader); value != "" {
+		baggage = []string{value}
+	}
+	if len(baggage) > 0 {
+		ctx = parseBaggage(ctx, baggage)
+	}
+	return ctx
+}
+
+// remoteSpan is a span of another process, stored in a context by Extract
+type remoteSpan struct {
+	traceID string
+	spanID  string
+}
+
+// contextWithRemoteSpan returns a copy of ctx carrying a span of another
+// process
+func contextWithRemoteSpan(ctx context.Context, traceID, spanID string) context.Context {
+	return context.WithValue(ctx, remoteSpanKey, remoteSpan{traceID: traceID, spanID: spanID})
+}
+
+// spanContextFromContext returns the trace and span IDs of the span in
+// ctx, preferring a local span over one extracted from a message
+func spanContextFromContext(ctx context.Context) (traceID, spanID string) {
+	if ctx == nil {
+		return "", ""
+	}
+	if span, ok := SpanFromContext(ctx).(*SpanImpl); ok {
+		return span.traceID, span.spanID
+	}
+	remote, _ := ctx.Value(remoteSpanKey).(remoteSpan)
+	return remote.traceID, remote.spanID
+}
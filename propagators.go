@@ -0,0 +1,251 @@
+package lens
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Propagator writes the trace and span of a context into the headers of
+// an outgoing message, in one header format, and reads them back from
+// the headers of incoming ones. Set a tracer's with WithPropagators.
+type Propagator interface {
+	Inject(ctx context.Context, carrier Carrier)
+	Extract(ctx context.Context, carrier Carrier) context.Context
+}
+
+// Headers of the propagation formats
+const (
+	TraceparentHeader             = "traceparent"
+	B3Header                      = "b3"
+	B3TraceIDHeader               = "X-B3-TraceId"
+	B3SpanIDHeader                = "X-B3-SpanId"
+	B3SampledHeader               = "X-B3-Sampled"
+	DatadogTraceIDHeader          = "X-Datadog-Trace-Id"
+	DatadogParentIDHeader         = "X-Datadog-Parent-Id"
+	DatadogSamplingPriorityHeader = "X-Datadog-Sampling-Priority"
+)
+
+// WithPropagators sets the header formats the tracer's Inject writes and
+// its Extract reads, which the integrations use. Every format is written;
+// when a message carries several, the first propagator finding a trace in
+// it wins. Lens IDs are converted to the fixed-size hex IDs other formats
+// expect as the OTLP writer converts them, so spans line up in backends.
+// By default, tracers use LensHeaders only.
+func WithPropagators(propagators ...Propagator) Option {
+	return func(t *TracerImpl) {
+		t.propagator = compositePropagator(propagators)
+	}
+}
+
+// Inject writes the request ID, baggage and trace context of ctx into
+// carrier, in the formats of the tracer's propagators
+func (t *TracerImpl) Inject(ctx context.Context, carrier Carrier) {
+	injectCorrelation(ctx, carrier)
+	t.root().propagation().Inject(ctx, carrier)
+}
+
+// Extract returns a copy of ctx carrying the request ID, baggage and trace
+// context in carrier, read in the formats of the tracer's propagators.
+// Spans started from it continue the sender's trace.
+func (t *TracerImpl) Extract(ctx context.Context, carrier Carrier) context.Context {
+	ctx = extractCorrelation(ctx, carrier)
+	return t.root().propagation().Extract(ctx, carrier)
+}
+
+// propagation returns the tracer's propagator
+func (t *TracerImpl) propagation() Propagator {
+	if t.propagator == nil {
+		return LensHeaders()
+	}
+	return t.propagator
+}
+
+// compositePropagator injects in every format and extracts with the first
+// propagator finding a trace
+type compositePropagator []Propagator
+
+// Inject writes every format
+func (c compositePropagator) Inject(ctx context.Context, carrier Carrier) {
+	for _, propagator := range c {
+		propagator.Inject(ctx, carrier)
+	}
+}
+
+// Extract reads every format in reverse, so the trace found by the first
+// propagator replaces those found by the others
+func (c compositePropagator) Extract(ctx context.Context, carrier Carrier) context.Context {
+	for i := len(c) - 1; i >= 0; i-- {
+		ctx = c[i].Extract(ctx, carrier)
+	}
+	return ctx
+}
+
+// lensPropagator propagates lens IDs as they are
+type lensPropagator struct{}
+
+// LensHeaders propagates lens trace and span IDs unchanged in the
+// Lens-Trace-Id and Lens-Span-Id headers
+func LensHeaders() Propagator {
+	return lensPropagator{}
+}
+
+// Inject writes the lens headers
+func (lensPropagator) Inject(ctx context.Context, carrier Carrier) {
+	if traceID, spanID := spanContextFromContext(ctx); traceID != "" {
+		carrier.Set(TraceIDHeader, traceID)
+		carrier.Set(SpanIDHeader, spanID)
+	}
+}
+
+// Extract reads the lens headers
+func (lensPropagator) Extract(ctx context.Context, carrier Carrier) context.Context {
+	if traceID := carrier.Get(TraceIDHeader); traceID != "" {
+		ctx = contextWithRemoteSpan(ctx, traceID, carrier.Get(SpanIDHeader))
+	}
+	return ctx
+}
+
+// w3cPropagator propagates W3C trace context
+type w3cPropagator struct{}
+
+// W3CTraceContext propagates the trace in the W3C traceparent header, as
+// OpenTelemetry does. Without a span in the context, a traceparent the
+// context carries is passed on.
+func W3CTraceContext() Propagator {
+	return w3cPropagator{}
+}
+
+// Inject writes a traceparent for the span in ctx
+func (w3cPropagator) Inject(ctx context.Context, carrier Carrier) {
+	traceID, spanID := propagatedIDs(ctx)
+	if traceID == "" {
+		if tp := TraceparentFromContext(ctx); tp != "" {
+			carrier.Set(TraceparentHeader, tp)
+		}
+		return
+	}
+	carrier.Set(TraceparentHeader, "00-"+traceID+"-"+spanID+"-01")
+}
+
+// Extract reads a traceparent, keeping it for the correlation enricher
+func (w3cPropagator) Extract(ctx context.Context, carrier Carrier) context.Context {
+	tp := carrier.Get(TraceparentHeader)
+	// version-traceid-parentid-flags
+	parts := strings.Split(tp, "-")
+	if len(parts) != 4 || !isHexID(parts[1], 16) || !isHexID(parts[2], 8) {
+		return ctx
+	}
+	ctx = ContextWithTraceparent(ctx, tp)
+	return contextWithRemoteSpan(ctx, strings.ToLower(parts[1]), strings.ToLower(parts[2]))
+}
+
+// b3Propagator propagates Zipkin B3 headers
+type b3Propagator struct {
+	single bool
+}
+
+// B3SingleHeader propagates the trace in the Zipkin b3 header. Both B3
+// propagators read either encoding.
+func B3SingleHeader() Propagator {
+	return b3Propagator{single: true}
+}
+
+// B3MultiHeader propagates the trace in the Zipkin X-B3-* headers
+func B3MultiHeader() Propagator {
+	return b3Propagator{}
+}
+
+// Inject writes the B3 headers
+func (p b3Propagator) Inject(ctx context.Context, carrier Carrier) {
+	traceID, spanID := propagatedIDs(ctx)
+	if traceID == "" {
+		return
+	}
+	if p.single {
+		carrier.Set(B3Header, traceID+"-"+spanID+"-1")
+		return
+	}
+	carrier.Set(B3TraceIDHeader, traceID)
+	carrier.Set(B3SpanIDHeader, spanID)
+	carrier.Set(B3SampledHeader, "1")
+}
+
+// Extract reads the single or multiple B3 headers
+func (b3Propagator) Extract(ctx context.Context, carrier Carrier) context.Context {
+	traceID, spanID := carrier.Get(B3TraceIDHeader), carrier.Get(B3SpanIDHeader)
+	if single := carrier.Get(B3Header); single != "" {
+		// traceid-spanid[-sampled[-parentspanid]], or only a sampling decision
+		parts := strings.Split(single, "-")
+		if len(parts) < 2 {
+			return ctx
+		}
+		traceID, spanID = parts[0], parts[1]
+	}
+	if !(isHexID(traceID, 16) || isHexID(traceID, 8)) || !isHexID(spanID, 8) {
+		return ctx
+	}
+	// 64-bit trace IDs are padded to 128 bits, which is how other B3 and
+	// W3C implementations widen them, so they are passed on unchanged
+	if len(traceID) == 16 {
+		traceID = strings.Repeat("0", 16) + traceID
+	}
+	return contextWithRemoteSpan(ctx, strings.ToLower(traceID), strings.ToLower(spanID))
+}
+
+// datadogPropagator propagates Datadog headers
+type datadogPropagator struct{}
+
+// Datadog propagates the trace in the X-Datadog-* headers, whose IDs are
+// 64-bit decimal numbers. Only the low 64 bits of trace IDs are sent, as
+// Datadog's own 64-bit IDs.
+func Datadog() Propagator {
+	return datadogPropagator{}
+}
+
+// Inject writes the Datadog headers
+func (datadogPropagator) Inject(ctx context.Context, carrier Carrier) {
+	traceID, spanID := propagatedIDs(ctx)
+	if traceID == "" {
+		return
+	}
+	low, _ := strconv.ParseUint(traceID[16:], 16, 64)
+	span, _ := strconv.ParseUint(spanID, 16, 64)
+	carrier.Set(DatadogTraceIDHeader, strconv.FormatUint(low, 10))
+	carrier.Set(DatadogParentIDHeader, strconv.FormatUint(span, 10))
+	carrier.Set(DatadogSamplingPriorityHeader, "1")
+}
+
+// Extract reads the Datadog headers, as W3C-sized hex IDs
+func (datadogPropagator) Extract(ctx context.Context, carrier Carrier) context.Context {
+	traceID, err := strconv.ParseUint(carrier.Get(DatadogTraceIDHeader), 10, 64)
+	if err != nil || traceID == 0 {
+		return ctx
+	}
+	spanID, _ := strconv.ParseUint(carrier.Get(DatadogParentIDHeader), 10, 64)
+	return contextWithRemoteSpan(ctx, fmt.Sprintf("%032x", traceID), fmt.Sprintf("%016x", spanID))
+}
+
+// propagatedIDs returns the trace and span IDs of the span in ctx as 16
+// and 8 bytes of hex, converted as the OTLP writer converts them
+func propagatedIDs(ctx context.Context) (traceID, spanID string) {
+	traceID, spanID = spanContextFromContext(ctx)
+	if traceID == "" {
+		return "", ""
+	}
+	if spanID == "" {
+		spanID = traceID
+	}
+	return otlpTraceID(traceID), otlpSpanID(spanID)
+}
+
+// isHexID reports whether id is size bytes of hex, not all zero
+func isHexID(id string, size int) bool {
+	if len(id) != 2*size || strings.Trim(id, "0") == "" {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
@@ -0,0 +1,231 @@
+package lens
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Tags set on EventQuotaExceeded events
+const (
+	// TagQuotaScope is the component whose quota was exceeded, or
+	// QuotaGlobal
+	TagQuotaScope  = "quota.scope"
+	TagQuotaEvents = "quota.events"
+	TagQuotaBytes  = "quota.bytes"
+	TagQuotaWindow = "quota.window"
+)
+
+// QuotaGlobal is the scope of the quota on all events
+const QuotaGlobal = "global"
+
+// Quota bounds how much a tracer writes per time window. Zero fields are
+// not bounded. Sizes are estimates of the events' JSON encoding.
+type Quota struct {
+	Events int
+	Bytes  int64
+	// ComponentEvents and ComponentBytes bound each component on its own
+	ComponentEvents int
+	ComponentBytes  int64
+	// Window defaults to a minute
+	Window time.Duration
+}
+
+// WithQuota protects the program from tracing-induced overload. Once the
+// events of a window exceed the quota, the tracer degrades to counts-only
+// mode for the rest of the window, or only the component over its own
+// quota does, and emits a single EventQuotaExceeded. Events other than
+// wrapped calls are dropped meanwhile. Tracing resumes in full with the
+// next window.
+func WithQuota(quota Quota) Option {
+	return func(t *TracerImpl) {
+		if quota.Window <= 0 {
+			quota.Window = time.Minute
+		}
+		t.quota = &quotaGuard{quota: quota, components: make(map[string]*quotaUsage)}
+	}
+}
+
+// quotaUsage is what a scope used in the current window
+type quotaUsage struct {
+	events   int
+	bytes    int64
+	exceeded bool
+}
+
+// quotaGuard enforces a Quota
+type quotaGuard struct {
+	quota       Quota
+	mutex       sync.Mutex
+	windowStart time.Time
+	global      quotaUsage
+	components  map[string]*quotaUsage
+	// Whether a scope is over quota and when the window ends, read by
+	// wrappers without the mutex
+	globalExceeded atomic.Bool
+	exceeded       sync.Map
+	windowEnd      atomic.Int64
+}
+
+// degraded reports whether calls of component are only counted, starting
+// a new window if the current one is over: wrapped calls that are only
+// counted never reach admit. It is safe to call on a nil guard.
+func (g *quotaGuard) degraded(component string) bool {
+	if g == nil || !g.exceededBy(component) {
+		return false
+	}
+	if time.Now().UnixNano() < g.windowEnd.Load() {
+		return true
+	}
+
+	g.mutex.Lock()
+	g.roll(time.Now())
+	g.mutex.Unlock()
+	return g.exceededBy(component)
+}
+
+// exceededBy reports whether component, or all events, are over quota
+func (g *quotaGuard) exceededBy(component string) bool {
+	if g.globalExceeded.Load() {
+		return true
+	}
+	_, ok := g.exceeded.Load(component)
+	return ok
+}
+
+// roll starts a new window if the current one is over; the caller must
+// hold the mutex
+func (g *quotaGuard) roll(now time.Time) {
+	if now.Sub(g.windowStart) < g.quota.Window {
+		return
+	}
+	g.setWindowStart(now)
+	g.global = quotaUsage{}
+	clear(g.components)
+	g.globalExceeded.Store(false)
+	g.exceeded.Clear()
+}
+
+// setWindowStart sets the start of the current window; the caller must
+// hold the mutex
+func (g *quotaGuard) setWindowStart(start time.Time) {
+	g.windowStart = start
+	g.windowEnd.Store(start.Add(g.quota.Window).UnixNano())
+}
+
+// admit accounts for an event about to be written and reports whether it
+// fits the quota. When the event exceeds a quota, notice is the
+// EventQuotaExceeded to write in its place.
+func (g *quotaGuard) admit(event Event) (ok bool, notice *Event) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.roll(time.Now())
+	if g.global.exceeded {
+		return false, nil
+	}
+
+	size := approxEventSize(event)
+	if !g.global.fits(size, g.quota.Events, g.quota.Bytes) {
+		g.global.exceeded = true
+		g.globalExceeded.Store(true)
+		return false, g.notice(event, QuotaGlobal, g.quota.Events, g.quota.Bytes)
+	}
+
+	if event.Component != "" && (g.quota.ComponentEvents > 0 || g.quota.ComponentBytes > 0) {
+		usage := g.components[event.Component]
+		if usage == nil {
+			usage = &quotaUsage{}
+			g.components[event.Component] = usage
+		}
+		if usage.exceeded {
+			return false, nil
+		}
+		if !usage.fits(size, g.quota.ComponentEvents, g.quota.ComponentBytes) {
+			usage.exceeded = true
+			g.exceeded.Store(event.Component, struct{}{})
+			return false, g.notice(event, event.Component, g.quota.ComponentEvents, g.quota.ComponentBytes)
+		}
+		usage.add(size)
+	}
+
+	g.global.add(size)
+	return true, nil
+}
+
+// fits reports whether an event of size keeps usage within the bounds
+func (u *quotaUsage) fits(size int64, events int, bytes int64) bool {
+	return (events <= 0 || u.events+1 <= events) && (bytes <= 0 || u.bytes+size <= bytes)
+}
+
+// add accounts for an event of size
+func (u *quotaUsage) add(size int64) {
+	u.events++
+	u.bytes += size
+}
+
+// notice creates the EventQuotaExceeded for the event exceeding a quota
+func (g *quotaGuard) notice(event Event, scope string, events int, bytes int64) *Event {
+	return &Event{
+		ID:            generateEventID(),
+		TraceID:       event.TraceID,
+		Timestamp:     time.Now(),
+		Type:          EventQuotaExceeded,
+		Component:     event.Component,
+		Goroutine:     event.Goroutine,
+		SchemaVersion: SchemaVersion,
+		Tags: map[string]interface{}{
+			TagQuotaScope:  scope,
+			TagQuotaEvents: events,
+			TagQuotaBytes:  bytes,
+			TagQuotaWindow: g.quota.Window.String(),
+		},
+	}
+}
+
+// approxEventSize estimates the size of an event's JSON encoding without
+// encoding it
+func approxEventSize(event Event) int64 {
+	// Field names, IDs and timestamp
+	size := int64(256)
+	for _, s := range []string{event.Component, event.Function, event.Variable, event.Error,
+		event.SourceFile, event.SourceFunction, event.CallerFile, event.CallerFunction} {
+		size += int64(len(s))
+	}
+	for _, frame := range event.StackTrace {
+		size += int64(len(frame)) + 3
+	}
+	size += approxValueSize(event.Arguments) + approxValueSize(event.ReturnValue)
+	size += approxValueSize(event.OldValue) + approxValueSize(event.NewValue)
+	size += approxValueSize(event.Tags) + approxValueSize(event.Params)
+	for _, change := range event.Changes {
+		size += int64(len(change.Path)) + approxValueSize(change.Old) + approxValueSize(change.New) + 24
+	}
+	return size
+}
+
+// approxValueSize estimates the size of a value's JSON encoding
+func approxValueSize(v interface{}) int64 {
+	switch value := v.(type) {
+	case nil:
+		return 0
+	case string:
+		return int64(len(value)) + 2
+	case []byte:
+		return int64(len(value))*4/3 + 2
+	case []interface{}:
+		size := int64(2)
+		for _, item := range value {
+			size += approxValueSize(item) + 1
+		}
+		return size
+	case map[string]interface{}:
+		size := int64(2)
+		for key, item := range value {
+			size += int64(len(key)) + approxValueSize(item) + 4
+		}
+		return size
+	default:
+		return 16
+	}
+}
@@ -0,0 +1,39 @@
+package lens_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/baretech/lens"
+	"github.com/baretech/lens/lenstest"
+)
+
+func TestQuotaWindowRollsForWrappedCalls(t *testing.T) {
+	const window = 50 * time.Millisecond
+	tracer, rec := lenstest.Record(t, lens.WithQuota(lens.Quota{Events: 4, Window: window}))
+	wrapped := tracer.Wrap(sum).(func(int, ...int) int)
+
+	// Each call is a call and a return event, so the third exceeds the
+	// quota and the rest of the window is only counted
+	for i := 0; i < 5; i++ {
+		wrapped(i)
+	}
+	time.Sleep(2 * window)
+	secondWindow := time.Now()
+	wrapped(1)
+
+	var first, second int
+	for _, event := range calls(t, tracer, rec) {
+		if event.Timestamp.Before(secondWindow) {
+			first++
+		} else {
+			second++
+		}
+	}
+	if first != 2 {
+		t.Errorf("traced %d calls in the first window, want 2", first)
+	}
+	if second != 1 {
+		t.Errorf("traced %d calls in the second window, want 1", second)
+	}
+}
@@ -0,0 +1,55 @@
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// gzipMagic starts every gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// decompress returns r, decompressed if it holds gzip, as written by a
+// lens.CompressedWriter. A stream cut short, as in a file still being
+// written, ends at the last complete block rather than failing.
+func decompress(r io.Reader) io.Reader {
+	buffered := bufio.NewReader(r)
+	magic, _ := buffered.Peek(len(gzipMagic))
+	if !bytes.Equal(magic, gzipMagic) {
+		return buffered
+	}
+
+	gz, err := gzip.NewReader(buffered)
+	if err != nil {
+		return errReader{fmt.Errorf("failed to decompress: %w", err)}
+	}
+	return truncatedReader{gz}
+}
+
+// truncatedReader reports the unexpected end of a compressed stream as
+// the end of input
+type truncatedReader struct {
+	r io.Reader
+}
+
+// Read reads from the stream
+func (t truncatedReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// errReader fails every read with err
+type errReader struct {
+	err error
+}
+
+// Read returns the error
+func (e errReader) Read(p []byte) (int, error) {
+	return 0, e.err
+}
@@ -0,0 +1,225 @@
+// Package reader loads lens trace files of any schema version, upgrading
+// older events to the current lens.Event format
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/baretech/lens"
+)
+
+// maxLineSize bounds a single JSON event line
+const maxLineSize = 16 * 1024 * 1024
+
+// JSONSchema is the JSON Schema describing events of lens.SchemaVersion
+//
+//go:embed event.schema.json
+var JSONSchema []byte
+
+// migration upgrades a raw event from one version to the next
+type migration func(raw map[string]interface{}) error
+
+// migrations maps each schema version to the upgrade to version+1
+var migrations = map[int]migration{
+	1: upgradeV1,
+}
+
+// upgradeV1 drops the goroutine count that version 1 recorded in place of
+// the goroutine ID, since it would otherwise be mistaken for one
+func upgradeV1(raw map[string]interface{}) error {
+	raw["goroutine"] = 0
+	return nil
+}
+
+// Upgrade migrates a raw decoded event to lens.SchemaVersion in place
+func Upgrade(raw map[string]interface{}) error {
+	version, err := schemaVersion(raw)
+	if err != nil {
+		return err
+	}
+	if version > lens.SchemaVersion {
+		return fmt.Errorf("schema version %d is newer than supported version %d", version, lens.SchemaVersion)
+	}
+
+	for ; version < lens.SchemaVersion; version++ {
+		upgrade, ok := migrations[version]
+		if !ok {
+			return fmt.Errorf("no migration from schema version %d", version)
+		}
+		if err := upgrade(raw); err != nil {
+			return fmt.Errorf("failed to upgrade from schema version %d: %w", version, err)
+		}
+	}
+
+	raw["schema_version"] = lens.SchemaVersion
+	return nil
+}
+
+// schemaVersion returns the version of a raw event, 1 if unversioned
+func schemaVersion(raw map[string]interface{}) (int, error) {
+	value, ok := raw["schema_version"]
+	if !ok || value == nil {
+		return 1, nil
+	}
+	number, ok := value.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("invalid schema_version %v", value)
+	}
+	version, err := number.Int64()
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("invalid schema_version %v", value)
+	}
+	return int(version), nil
+}
+
+// Decode parses a single JSON event, upgrading it if necessary
+func Decode(data []byte) (lens.Event, error) {
+	var event lens.Event
+
+	var raw map[string]interface{}
+	if err := unmarshalNumbers(data, &raw); err != nil {
+		return event, err
+	}
+
+	// Current events need no migration and decode directly
+	if version, err := schemaVersion(raw); err == nil && version == lens.SchemaVersion {
+		return event, json.Unmarshal(data, &event)
+	}
+
+	if err := Upgrade(raw); err != nil {
+		return event, err
+	}
+	upgraded, err := json.Marshal(raw)
+	if err != nil {
+		return event, fmt.Errorf("failed to encode upgraded event: %w", err)
+	}
+	return event, json.Unmarshal(upgraded, &event)
+}
+
+// unmarshalNumbers decodes JSON keeping numbers as json.Number, so large
+// integers such as durations survive the round trip through a map
+func unmarshalNumbers(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// keyring decrypts the envelopes of encrypted traces, see SetKeyring
+var keyring atomic.Pointer[lens.Keyring]
+
+// SetKeyring sets the keys readers decrypt events written by a
+// lens.EncryptedWriter with. Without them, such events are read as
+// lens.EventEncrypted envelopes.
+func SetKeyring(k *lens.Keyring) {
+	keyring.Store(k)
+}
+
+// decrypt returns the event sealed in an envelope, upgrading it if
+// necessary, or the event itself if it is not an envelope or there are no
+// keys to open it
+func decrypt(event lens.Event) (lens.Event, error) {
+	k := keyring.Load()
+	if event.Type != lens.EventEncrypted || k == nil {
+		return event, nil
+	}
+	plaintext, err := k.Open(event)
+	if err != nil {
+		return event, err
+	}
+	return Decode(plaintext)
+}
+
+// Reader reads newline-delimited JSON events one at a time
+type Reader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewReader creates a new reader. Input compressed with gzip is
+// decompressed.
+func NewReader(r io.Reader) *Reader {
+	scanner := bufio.NewScanner(decompress(r))
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	return &Reader{scanner: scanner}
+}
+
+// Next returns the next event, or io.EOF when the input is exhausted
+func (r *Reader) Next() (lens.Event, error) {
+	for r.scanner.Scan() {
+		r.line++
+		data := r.scanner.Bytes()
+		if len(data) == 0 {
+			continue
+		}
+
+		event, err := Decode(data)
+		if err != nil {
+			return event, fmt.Errorf("failed to parse event on line %d: %w", r.line, err)
+		}
+		event, err = decrypt(event)
+		if err != nil {
+			return event, fmt.Errorf("failed to read event on line %d: %w", r.line, err)
+		}
+		return event, nil
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return lens.Event{}, fmt.Errorf("failed to read events: %w", err)
+	}
+	return lens.Event{}, io.EOF
+}
+
+// ReadEvents reads all newline-delimited JSON events from r
+func ReadEvents(r io.Reader) ([]lens.Event, error) {
+	var events []lens.Event
+
+	reader := NewReader(r)
+	for {
+		event, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return events, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+}
+
+// ReadFile reads all events from a trace file
+func ReadFile(path string) ([]lens.Event, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	return ReadEvents(file)
+}
+
+// ReadDir reads the events of every .json trace file in dir
+func ReadDir(dir string) ([]lens.Event, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trace files: %w", err)
+	}
+
+	var events []lens.Event
+	for _, path := range paths {
+		fileEvents, err := ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		events = append(events, fileEvents...)
+	}
+	return events, nil
+}
@@ -0,0 +1,35 @@
+package reader
+
+import (
+	"sort"
+
+	"github.com/baretech/lens"
+)
+
+// Sort orders events as they were emitted. When every event carries a
+// sequence number, Seq decides the order; otherwise events are ordered by
+// timestamp, with Seq breaking ties. The sort is stable, so events from
+// older traces without either keep their file order.
+func Sort(events []lens.Event) {
+	bySeq := true
+	for _, event := range events {
+		if event.Seq == 0 {
+			bySeq = false
+			break
+		}
+	}
+
+	if bySeq {
+		sort.SliceStable(events, func(i, j int) bool {
+			return events[i].Seq < events[j].Seq
+		})
+		return
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].Timestamp.Equal(events[j].Timestamp) {
+			return events[i].Timestamp.Before(events[j].Timestamp)
+		}
+		return events[i].Seq < events[j].Seq
+	})
+}
@@ -0,0 +1,103 @@
+package lens
+
+import (
+	"sync"
+	"time"
+)
+
+// Span status tags set by the reaper
+const (
+	TagSpanStatus       = "span.status"
+	TagSpanAbandonedAge = "span.abandoned_after"
+	SpanStatusAbandoned = "abandoned"
+)
+
+// spanReaper force-ends spans that stay open longer than a maximum duration
+type spanReaper struct {
+	tracer      *TracerImpl
+	maxDuration time.Duration
+	spans       map[*SpanImpl]struct{}
+	mutex       sync.Mutex
+	done        chan struct{}
+	once        sync.Once
+}
+
+// newSpanReaper creates a new span reaper
+func newSpanReaper(tracer *TracerImpl, maxDuration time.Duration) *spanReaper {
+	return &spanReaper{
+		tracer:      tracer,
+		maxDuration: maxDuration,
+		spans:       make(map[*SpanImpl]struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// start launches the background sweep loop
+func (r *spanReaper) start() {
+	interval := r.maxDuration / 4
+	if interval < time.Millisecond {
+		interval = time.Millisecond
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				r.sweep()
+			case <-r.done:
+				return
+			}
+		}
+	}()
+}
+
+// stop terminates the sweep loop
+func (r *spanReaper) stop() {
+	r.once.Do(func() {
+		close(r.done)
+	})
+}
+
+// sweep ends every span older than the maximum duration
+func (r *spanReaper) sweep() {
+	now := time.Now()
+
+	r.mutex.Lock()
+	var expired []*SpanImpl
+	for span := range r.spans {
+		if now.Sub(span.startTime) > r.maxDuration {
+			expired = append(expired, span)
+		}
+	}
+	r.mutex.Unlock()
+
+	for _, span := range expired {
+		span.finish(map[string]interface{}{
+			TagSpanStatus:       SpanStatusAbandoned,
+			TagSpanAbandonedAge: r.maxDuration.String(),
+		})
+	}
+}
+
+// trackSpan registers an open span with the reaper
+func (t *TracerImpl) trackSpan(span *SpanImpl) {
+	if t.reaper == nil {
+		return
+	}
+	t.reaper.mutex.Lock()
+	t.reaper.spans[span] = struct{}{}
+	t.reaper.mutex.Unlock()
+}
+
+// untrackSpan removes an ended span from the reaper
+func (t *TracerImpl) untrackSpan(span *SpanImpl) {
+	if t.reaper == nil {
+		return
+	}
+	t.reaper.mutex.Lock()
+	delete(t.reaper.spans, span)
+	t.reaper.mutex.Unlock()
+}
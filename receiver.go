@@ -0,0 +1,79 @@
+package lens
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// WithReceiverState snapshots the exported fields of the receiver before
+// and after each call to a method wrapped with WrapMethod, and emits a
+// variable_write event for every field the call changed
+func WithReceiverState() Option {
+	return func(t *TracerImpl) {
+		t.receiverState = true
+	}
+}
+
+// WrapMethod returns a traced func for the named method of obj, bound to
+// obj, e.g.
+//
+//	deposit := tracer.WrapMethod(account, "Deposit").(func(int) error)
+//
+// Unlike wrapping a method value, the wrapper knows the receiver, so
+// WithReceiverState can record the fields the method changes. It panics if
+// obj has no such exported method.
+func (t *TracerImpl) WrapMethod(obj interface{}, method string) interface{} {
+	objValue := reflect.ValueOf(obj)
+	methodValue := objValue.MethodByName(method)
+	if !methodValue.IsValid() {
+		panic(fmt.Sprintf("lens: %T has no exported method %s", obj, method))
+	}
+
+	name := objValue.Type().String()
+	if objValue.Kind() == reflect.Ptr {
+		name = objValue.Type().Elem().Name()
+	}
+
+	sw := &structWrapper{
+		original: obj,
+		tracer:   t,
+		name:     name,
+		objType:  objValue.Type(),
+		objValue: objValue,
+	}
+	return sw.createMethodWrapper(methodValue, fmt.Sprintf("%s.%s", name, method)).Interface()
+}
+
+// captureReceiver snapshots the receiver's state if receiver state
+// capture is on, returning nil otherwise
+func (sw *structWrapper) captureReceiver() map[string]interface{} {
+	if !sw.tracer.receiverState {
+		return nil
+	}
+	return captureState(sw.original)
+}
+
+// traceReceiverChanges emits a variable_write event for every receiver
+// field that differs from before
+func (sw *structWrapper) traceReceiverChanges(before map[string]interface{}, call Event) {
+	if before == nil {
+		return
+	}
+
+	for _, change := range diffState(before, captureState(sw.original)) {
+		sw.tracer.TraceEvent(Event{
+			ID:        generateEventID(),
+			TraceID:   call.TraceID,
+			Timestamp: time.Now(),
+			Type:      EventVariableWrite,
+			Component: sw.name,
+			Function:  call.Function,
+			Variable:  joinPath(sw.name, change.Path),
+			OldValue:  sw.tracer.encodeValue(change.Old),
+			NewValue:  sw.tracer.encodeValue(change.New),
+			Goroutine: call.Goroutine,
+			Depth:     call.Depth + 1,
+		})
+	}
+}
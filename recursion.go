@@ -0,0 +1,40 @@
+package lens
+
+import "time"
+
+// TagRecursionLimit is set on EventRecursion events
+const TagRecursionLimit = "recursion.limit"
+
+// WithRecursionLimit emits an EventRecursion when a traced call is nested
+// in limit calls to the same function on its goroutine, so runaway
+// recursion shows in the trace long before the stack overflows. It is
+// emitted once per descent, by the call reaching the limit. Every call
+// event records its recursion depth either way.
+func WithRecursionLimit(limit int) Option {
+	return func(t *TracerImpl) {
+		t.recursionLimit = limit
+	}
+}
+
+// checkRecursion emits EventRecursion when a call event reaches the
+// recursion limit
+func (t *TracerImpl) checkRecursion(call Event) {
+	if t.recursionLimit <= 0 || call.RecursionDepth != t.recursionLimit {
+		return
+	}
+
+	tags := make(map[string]interface{}, len(call.Tags)+1)
+	for k, v := range call.Tags {
+		tags[k] = v
+	}
+	tags[TagRecursionLimit] = t.recursionLimit
+
+	event := call
+	event.ID = generateEventID()
+	event.Timestamp = time.Now()
+	event.Type = EventRecursion
+	event.Arguments = nil
+	event.Params = nil
+	event.Tags = tags
+	t.TraceEvent(event)
+}
@@ -0,0 +1,316 @@
+// Package replay re-executes calls recorded in lens trace files against
+// registered implementations and compares their return values, turning
+// production traces into regression tests
+package replay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/baretech/lens"
+	"github.com/baretech/lens/reader"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// Registry maps traced function names to the implementations to replay
+type Registry struct {
+	funcs map[string]reflect.Value
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{funcs: make(map[string]reflect.Value)}
+}
+
+// Register adds the implementation replayed for calls to the named
+// function. name is the function name recorded in the trace, with or
+// without its package path (e.g. "github.com/acme/billing.Charge" or
+// "billing.Charge"). It panics if fn is not a function.
+func (r *Registry) Register(name string, fn interface{}) *Registry {
+	value := reflect.ValueOf(fn)
+	if value.Kind() != reflect.Func {
+		panic(fmt.Sprintf("replay: Register called with non-function %T", fn))
+	}
+	r.funcs[name] = value
+	return r
+}
+
+// lookup finds the implementation of a recorded function
+func (r *Registry) lookup(name string) (reflect.Value, bool) {
+	if fn, ok := r.funcs[name]; ok {
+		return fn, true
+	}
+	if slash := strings.LastIndex(name, "/"); slash >= 0 {
+		fn, ok := r.funcs[name[slash+1:]]
+		return fn, ok
+	}
+	return reflect.Value{}, false
+}
+
+// Result is the outcome of replaying one recorded call
+type Result struct {
+	Function  string        `json:"function"`
+	TraceID   string        `json:"trace_id"`
+	Arguments []interface{} `json:"arguments,omitempty"`
+	Expected  []interface{} `json:"expected,omitempty"`
+	Actual    []interface{} `json:"actual,omitempty"`
+	// Match reports whether the actual return values equal the recorded ones
+	Match bool `json:"match"`
+	// Err is set when the call could not be replayed or panicked
+	Err string `json:"error,omitempty"`
+}
+
+// Report summarizes a replay
+type Report struct {
+	Results []Result `json:"results"`
+	// Unregistered counts recorded calls per function with no registered
+	// implementation
+	Unregistered map[string]int `json:"unregistered,omitempty"`
+	// Incomplete counts calls without a recorded return, e.g. because the
+	// function panicked or the trace was cut off
+	Incomplete int `json:"incomplete,omitempty"`
+}
+
+// Failures returns the results that did not match or could not be replayed
+func (r *Report) Failures() []Result {
+	var failures []Result
+	for _, result := range r.Results {
+		if !result.Match {
+			failures = append(failures, result)
+		}
+	}
+	return failures
+}
+
+// Replay re-invokes every recorded call with a registered implementation,
+// in trace order. Arguments are decoded from their recorded JSON into
+// the implementation's parameter types; context.Context parameters
+// receive ctx. Return values are compared by their JSON encoding, except
+// errors, which only need to agree on being nil, since their messages are
+// not recorded.
+func Replay(ctx context.Context, events []lens.Event, registry *Registry) *Report {
+	returns := make(map[string]lens.Event)
+	for _, event := range events {
+		if event.Type == lens.EventFunctionReturn && event.TraceID != "" {
+			returns[event.TraceID] = event
+		}
+	}
+
+	report := &Report{Unregistered: make(map[string]int)}
+	for _, call := range events {
+		if call.Type != lens.EventFunctionCall && call.Type != lens.EventMethodCall {
+			continue
+		}
+
+		fn, ok := registry.lookup(call.Function)
+		if !ok {
+			report.Unregistered[call.Function]++
+			continue
+		}
+
+		ret, ok := returns[call.TraceID]
+		if !ok || call.TraceID == "" {
+			report.Incomplete++
+			continue
+		}
+
+		report.Results = append(report.Results, replayCall(ctx, fn, call, ret))
+	}
+
+	if len(report.Unregistered) == 0 {
+		report.Unregistered = nil
+	}
+	return report
+}
+
+// ReplayFile replays the calls recorded in a trace file
+func ReplayFile(ctx context.Context, path string, registry *Registry) (*Report, error) {
+	events, err := reader.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Replay(ctx, events, registry), nil
+}
+
+// replayCall invokes fn with the recorded arguments of call and compares
+// the results with ret
+func replayCall(ctx context.Context, fn reflect.Value, call, ret lens.Event) (result Result) {
+	result = Result{
+		Function:  call.Function,
+		TraceID:   call.TraceID,
+		Arguments: call.Arguments,
+		Expected:  ret.ReturnValue,
+	}
+
+	args, err := decodeArguments(ctx, fn.Type(), call.Arguments)
+	if err != nil {
+		result.Err = err.Error()
+		return result
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			result.Err = fmt.Sprintf("panic: %v", r)
+			result.Match = false
+		}
+	}()
+
+	var results []reflect.Value
+	if fn.Type().IsVariadic() {
+		results = fn.CallSlice(args)
+	} else {
+		results = fn.Call(args)
+	}
+
+	result.Actual = make([]interface{}, len(results))
+	for i, value := range results {
+		result.Actual[i] = value.Interface()
+	}
+
+	result.Match, err = equalResults(fn.Type(), ret.ReturnValue, result.Actual)
+	if err != nil {
+		result.Err = err.Error()
+	}
+	return result
+}
+
+// decodeArguments converts recorded arguments to the parameter types of fn.
+// As in the traced call, a variadic parameter is recorded as one slice.
+func decodeArguments(ctx context.Context, fnType reflect.Type, recorded []interface{}) ([]reflect.Value, error) {
+	if len(recorded) != fnType.NumIn() {
+		return nil, fmt.Errorf("recorded %d arguments, implementation takes %d", len(recorded), fnType.NumIn())
+	}
+
+	args := make([]reflect.Value, len(recorded))
+	for i, value := range recorded {
+		paramType := fnType.In(i)
+		if paramType == contextType {
+			args[i] = reflect.ValueOf(ctx)
+			continue
+		}
+
+		arg, err := decodeValue(value, paramType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode argument %d: %w", i, err)
+		}
+		args[i] = arg
+	}
+	return args, nil
+}
+
+// decodeValue converts a value decoded from JSON to typ by re-encoding it
+func decodeValue(value interface{}, typ reflect.Type) (reflect.Value, error) {
+	target := reflect.New(typ)
+	if value == nil {
+		return target.Elem(), nil
+	}
+	if typ.Kind() == reflect.Interface && typ.NumMethod() > 0 {
+		return reflect.Value{}, fmt.Errorf("cannot reconstruct interface type %s", typ)
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(target.Interface()); err != nil {
+		return reflect.Value{}, err
+	}
+	return target.Elem(), nil
+}
+
+// equalResults compares recorded and actual return values
+func equalResults(fnType reflect.Type, expected, actual []interface{}) (bool, error) {
+	if len(expected) != len(actual) {
+		return false, nil
+	}
+
+	for i := range actual {
+		if fnType.Out(i).Implements(errorType) {
+			if (expected[i] == nil) != (actual[i] == nil) {
+				return false, nil
+			}
+			continue
+		}
+
+		want, err := canonicalJSON(expected[i])
+		if err != nil {
+			return false, fmt.Errorf("failed to encode recorded result %d: %w", i, err)
+		}
+		got, err := canonicalJSON(actual[i])
+		if err != nil {
+			return false, fmt.Errorf("failed to encode result %d: %w", i, err)
+		}
+		if want != got {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// canonicalJSON encodes a value so that equal JSON documents compare
+// equal regardless of the Go types they were produced from
+func canonicalJSON(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	var generic interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&generic); err != nil {
+		return "", err
+	}
+
+	data, err = json.Marshal(generic)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// FormatReport renders a replay report as text
+func FormatReport(report *Report) string {
+	var b strings.Builder
+
+	failures := report.Failures()
+	fmt.Fprintf(&b, "%d calls replayed, %d failed\n", len(report.Results), len(failures))
+	for _, failure := range failures {
+		fmt.Fprintf(&b, "  %s [%s]\n", failure.Function, failure.TraceID)
+		if failure.Err != "" {
+			fmt.Fprintf(&b, "    error: %s\n", failure.Err)
+			continue
+		}
+		expected, _ := json.Marshal(failure.Expected)
+		actual, _ := json.Marshal(failure.Actual)
+		fmt.Fprintf(&b, "    expected: %s\n    actual:   %s\n", expected, actual)
+	}
+
+	if len(report.Unregistered) > 0 {
+		names := make([]string, 0, len(report.Unregistered))
+		for name := range report.Unregistered {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		b.WriteString("not registered:\n")
+		for _, name := range names {
+			fmt.Fprintf(&b, "  %s (%d calls)\n", name, report.Unregistered[name])
+		}
+	}
+	if report.Incomplete > 0 {
+		fmt.Fprintf(&b, "%d calls without a recorded return were skipped\n", report.Incomplete)
+	}
+
+	return b.String()
+}
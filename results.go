@@ -0,0 +1,96 @@
+package lens
+
+// ResultFilter keeps only the return events of calls whose results match
+// a predicate, e.g. calls that returned an error. Results are matched as
+// recorded, after any ValueEncoder has converted them. Other events pass.
+type ResultFilter struct {
+	match func(results []interface{}) bool
+	calls bool
+}
+
+// NewResultFilter creates a filter keeping return events whose results
+// satisfy match
+func NewResultFilter(match func(results []interface{}) bool) *ResultFilter {
+	return &ResultFilter{match: match}
+}
+
+// WithCalls also drops the call events of calls whose results do not
+// match. Wrapped calls then hold their call event until they return, so
+// it is traced just before the return event, keeping its timestamp. This
+// applies when the filter is added to the tracer directly rather than
+// inside a CompositeFilter.
+func (f *ResultFilter) WithCalls() *ResultFilter {
+	f.calls = true
+	return f
+}
+
+// ShouldTrace determines if an event should be traced
+func (f *ResultFilter) ShouldTrace(event Event) bool {
+	if event.Type != EventFunctionReturn {
+		return true
+	}
+	return f.match(event.ReturnValue)
+}
+
+// ReturnedError creates a filter keeping calls that returned a non-nil
+// error. Errors recorded as text by a ValueEncoder are not recognized.
+func ReturnedError() *ResultFilter {
+	return NewResultFilter(func(results []interface{}) bool {
+		for _, result := range results {
+			if err, ok := result.(error); ok && err != nil {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// ReturnedFalse creates a filter keeping calls that returned false, such
+// as failed validations and lookups
+func ReturnedFalse() *ResultFilter {
+	return NewResultFilter(func(results []interface{}) bool {
+		for _, result := range results {
+			if ok, isBool := result.(bool); isBool && !ok {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// traceCall traces a call event, or holds it while a ResultFilter added
+// WithCalls has yet to see the call's results. The returned func must be
+// given the return event before it is traced; it traces a held call event
+// if the filters keep the return.
+func (t *TracerImpl) traceCall(call Event) func(ret Event) {
+	t.mutex.RLock()
+	var held []*ResultFilter
+	for _, filter := range t.filters {
+		if result, ok := filter.(*ResultFilter); ok && result.calls {
+			held = append(held, result)
+		}
+	}
+	t.mutex.RUnlock()
+
+	if len(held) == 0 || t.verbose() {
+		t.TraceEvent(call)
+		return releaseNothing
+	}
+	return t.holdCall(call, held)
+}
+
+// releaseNothing is the release func of a call traced right away
+func releaseNothing(Event) {}
+
+// holdCall returns the release func of a held call. It is separate from
+// traceCall so that only held calls move their event to the heap.
+func (t *TracerImpl) holdCall(call Event, held []*ResultFilter) func(ret Event) {
+	return func(ret Event) {
+		for _, filter := range held {
+			if !filter.ShouldTrace(ret) {
+				return
+			}
+		}
+		t.TraceEvent(call)
+	}
+}
@@ -0,0 +1,298 @@
+package lens
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RetentionManager deletes old trace data in the background, so always-on
+// tracing does not slowly fill the disk. It prunes trace files in
+// directories, such as those of JSON and sharded writers, and the events
+// of a SQLiteWriter database, by age and by size.
+type RetentionManager struct {
+	maxAge   time.Duration
+	maxBytes int64
+	maxRows  int64
+	interval time.Duration
+	dirs     []retentionDir
+	db       *sql.DB
+	onError  func(err error)
+	done     chan struct{}
+	started  sync.Once
+	stopped  sync.Once
+	wg       sync.WaitGroup
+}
+
+// retentionDir is a directory of trace files
+type retentionDir struct {
+	dir     string
+	pattern string
+}
+
+// RetentionResult reports what a pruning pass deleted
+type RetentionResult struct {
+	Files int
+	Bytes int64
+	Rows  int64
+}
+
+// RetentionOption configures a RetentionManager
+type RetentionOption func(*RetentionManager)
+
+// WithRetentionMaxAge deletes files last modified, and events recorded,
+// longer than age ago. The newest file of a directory is never deleted, as
+// a writer may still be appending to it.
+func WithRetentionMaxAge(age time.Duration) RetentionOption {
+	return func(m *RetentionManager) {
+		m.maxAge = age
+	}
+}
+
+// WithRetentionMaxBytes deletes the oldest files of each directory until
+// the files left take at most n bytes. The newest file of a directory is
+// never deleted, as a writer may still be appending to it.
+func WithRetentionMaxBytes(n int64) RetentionOption {
+	return func(m *RetentionManager) {
+		m.maxBytes = n
+	}
+}
+
+// WithRetentionMaxRows deletes the oldest events of the database until at
+// most n are left
+func WithRetentionMaxRows(n int64) RetentionOption {
+	return func(m *RetentionManager) {
+		m.maxRows = n
+	}
+}
+
+// WithRetentionInterval sets how often the background loop prunes; the
+// default is every ten minutes
+func WithRetentionInterval(interval time.Duration) RetentionOption {
+	return func(m *RetentionManager) {
+		if interval > 0 {
+			m.interval = interval
+		}
+	}
+}
+
+// WithRetentionFiles prunes the files in dir and its subdirectories whose
+// names match pattern, as in filepath.Match, e.g. "*.jsonl".
+// Subdirectories left empty are removed.
+func WithRetentionFiles(dir, pattern string) RetentionOption {
+	return func(m *RetentionManager) {
+		m.dirs = append(m.dirs, retentionDir{dir: dir, pattern: pattern})
+	}
+}
+
+// WithRetentionSQLite prunes the events of a database written by a
+// SQLiteWriter
+func WithRetentionSQLite(db *sql.DB) RetentionOption {
+	return func(m *RetentionManager) {
+		m.db = db
+	}
+}
+
+// WithRetentionErrorHandler sets a callback invoked when a background
+// pass fails
+func WithRetentionErrorHandler(callback func(err error)) RetentionOption {
+	return func(m *RetentionManager) {
+		m.onError = callback
+	}
+}
+
+// NewRetentionManager creates a retention manager; call Start to prune in
+// the background or Prune to prune once
+func NewRetentionManager(options ...RetentionOption) *RetentionManager {
+	m := &RetentionManager{
+		interval: 10 * time.Minute,
+		done:     make(chan struct{}),
+	}
+	for _, option := range options {
+		option(m)
+	}
+	return m
+}
+
+// Start prunes now and then at every interval, until Stop
+func (m *RetentionManager) Start() {
+	m.started.Do(func() {
+		m.wg.Add(1)
+		go m.loop()
+	})
+}
+
+// loop prunes until the manager is stopped
+func (m *RetentionManager) loop() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := m.Prune(context.Background()); err != nil && m.onError != nil {
+			m.onError(err)
+		}
+		select {
+		case <-ticker.C:
+		case <-m.done:
+			return
+		}
+	}
+}
+
+// Stop stops the background loop, waiting for a pass in progress
+func (m *RetentionManager) Stop() {
+	m.stopped.Do(func() {
+		close(m.done)
+	})
+	m.wg.Wait()
+}
+
+// Prune deletes trace data beyond the configured limits once
+func (m *RetentionManager) Prune(ctx context.Context) (RetentionResult, error) {
+	var result RetentionResult
+	var errs []error
+
+	for _, dir := range m.dirs {
+		files, bytes, err := m.pruneFiles(dir)
+		result.Files += files
+		result.Bytes += bytes
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if m.db != nil {
+		rows, err := m.pruneRows(ctx)
+		result.Rows = rows
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return result, errors.Join(errs...)
+}
+
+// retainedFile is a trace file considered for deletion
+type retainedFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// pruneFiles deletes the files of a directory beyond the limits
+func (m *RetentionManager) pruneFiles(dir retentionDir) (int, int64, error) {
+	var files []retainedFile
+	err := filepath.WalkDir(dir.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		if match, _ := filepath.Match(dir.pattern, entry.Name()); !match {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			// Deleted since the directory was read
+			return nil
+		}
+		files = append(files, retainedFile{path: path, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to list %s: %w", dir.dir, err)
+	}
+
+	// Oldest first
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+	var total int64
+	for _, file := range files {
+		total += file.size
+	}
+
+	// The newest file of each directory may still be open for writing
+	newest := make(map[string]string)
+	for _, file := range files {
+		newest[filepath.Dir(file.path)] = file.path
+	}
+
+	cutoff := time.Now().Add(-m.maxAge)
+	var deleted int
+	var freed int64
+	var errs []error
+	for _, file := range files {
+		if newest[filepath.Dir(file.path)] == file.path {
+			continue
+		}
+		expired := m.maxAge > 0 && file.modTime.Before(cutoff)
+		oversize := m.maxBytes > 0 && total > m.maxBytes
+		if !expired && !oversize {
+			continue
+		}
+		if err := os.Remove(file.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, fmt.Errorf("failed to remove %s: %w", file.path, err))
+			continue
+		}
+		total -= file.size
+		deleted++
+		freed += file.size
+	}
+
+	if deleted > 0 {
+		removeEmptyDirs(dir.dir)
+	}
+	return deleted, freed, errors.Join(errs...)
+}
+
+// removeEmptyDirs removes the empty subdirectories of root, such as hours
+// of a sharded writer whose traces were all pruned
+func removeEmptyDirs(root string) {
+	var dirs []string
+	filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && entry.IsDir() && path != root {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	// Deepest first, so parents emptied by their children go too
+	for i := len(dirs) - 1; i >= 0; i-- {
+		// Fails, as intended, on directories that are not empty
+		os.Remove(dirs[i])
+	}
+}
+
+// pruneRows deletes events of the database beyond the limits
+func (m *RetentionManager) pruneRows(ctx context.Context) (int64, error) {
+	var deleted int64
+
+	if m.maxAge > 0 {
+		cutoff := time.Now().Add(-m.maxAge).UnixNano()
+		res, err := m.db.ExecContext(ctx, `DELETE FROM `+SQLiteTable+` WHERE timestamp < ?`, cutoff)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete expired events: %w", err)
+		}
+		n, _ := res.RowsAffected()
+		deleted += n
+	}
+
+	if m.maxRows > 0 {
+		// LIMIT -1 has no limit in SQLite; the offset skips the rows kept
+		res, err := m.db.ExecContext(ctx, `DELETE FROM `+SQLiteTable+` WHERE rowid IN (
+			SELECT rowid FROM `+SQLiteTable+` ORDER BY timestamp DESC LIMIT -1 OFFSET ?)`, m.maxRows)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete excess events: %w", err)
+		}
+		n, _ := res.RowsAffected()
+		deleted += n
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,150 @@
+package lens
+
+import (
+	"context"
+	"reflect"
+	"time"
+)
+
+// Tags set on retry spans and their EventRetryAttempt events
+const (
+	TagRetryAttempt  = "retry.attempt"
+	TagRetryDelay    = "retry.delay"
+	TagRetryAttempts = "retry.attempts"
+	TagRetryOutcome  = "retry.outcome"
+)
+
+// Outcomes of a retried operation, recorded as TagRetryOutcome
+const (
+	RetrySucceeded = "succeeded"
+	RetryExhausted = "exhausted"
+	RetryAborted   = "aborted"
+	RetryCanceled  = "canceled"
+)
+
+// RetryPolicy decides how often and how soon WrapRetry retries
+type RetryPolicy struct {
+	// MaxAttempts bounds the attempts, the first one included; zero or
+	// less means no limit
+	MaxAttempts int
+	// Backoff returns the delay after failed attempt n, counting from 1;
+	// nil retries at once
+	Backoff func(attempt int) time.Duration
+	// Retryable reports whether an error is worth another attempt; nil
+	// retries every error
+	Retryable func(err error) bool
+}
+
+// ExponentialBackoff returns a Backoff doubling from initial after each
+// attempt, up to max
+func ExponentialBackoff(initial, max time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		delay := initial
+		for i := 1; i < attempt && delay < max; i++ {
+			delay *= 2
+		}
+		if delay > max {
+			return max
+		}
+		return delay
+	}
+}
+
+// WrapRetry returns fn retried according to policy, with every run in a
+// span: each attempt is an EventRetryAttempt with its number, the delay
+// before it, its duration and its error, and the span's end records the
+// number of attempts and the outcome. Retry storms then show in the trace
+// as spans with many attempts. Waiting between attempts stops when the
+// context is done.
+func (t *TracerImpl) WrapRetry(fn func(ctx context.Context) error, policy RetryPolicy) func(ctx context.Context) error {
+	name := functionName(reflect.ValueOf(fn), "retry")
+
+	return func(ctx context.Context) error {
+		span := t.newSpan(ctx, name)
+		ctx = ContextWithSpan(ctx, span)
+
+		var err error
+		var delay time.Duration
+		outcome := RetrySucceeded
+		attempt := 1
+		for ; ; attempt++ {
+			start := time.Now()
+			err = fn(ctx)
+			t.traceAttempt(ctx, span, attempt, delay, time.Since(start), err)
+
+			if err == nil {
+				break
+			}
+			if policy.Retryable != nil && !policy.Retryable(err) {
+				outcome = RetryAborted
+				break
+			}
+			if policy.MaxAttempts > 0 && attempt >= policy.MaxAttempts {
+				outcome = RetryExhausted
+				break
+			}
+
+			delay = 0
+			if policy.Backoff != nil {
+				delay = policy.Backoff(attempt)
+			}
+			if !sleepContext(ctx, delay) {
+				outcome = RetryCanceled
+				break
+			}
+		}
+
+		span.SetTag(TagRetryAttempts, attempt)
+		span.SetTag(TagRetryOutcome, outcome)
+		if err != nil {
+			span.SetError(err)
+		}
+		span.End()
+		return err
+	}
+}
+
+// traceAttempt traces one attempt of a retried operation
+func (t *TracerImpl) traceAttempt(ctx context.Context, span *SpanImpl, attempt int, delay, duration time.Duration, err error) {
+	if !t.shouldCapture(Event{TraceID: span.traceID, Function: span.name}, EventRetryAttempt) {
+		return
+	}
+
+	event := Event{
+		ID:        generateEventID(),
+		TraceID:   span.traceID,
+		SpanID:    span.spanID,
+		Timestamp: time.Now(),
+		Type:      EventRetryAttempt,
+		Function:  span.name,
+		Duration:  duration,
+		Goroutine: getGoroutineID(),
+		Tags: map[string]interface{}{
+			TagRetryAttempt: attempt,
+			TagRetryDelay:   delay.String(),
+		},
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	t.TraceEvent(t.enrich(ctx, event))
+}
+
+// sleepContext waits for d, returning false if ctx is done first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if d <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,120 @@
+package lens
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// RingBufferWriter keeps the last N events in memory so tracing can run
+// always-on and only be materialized when something goes wrong
+type RingBufferWriter struct {
+	events []Event
+	next   int
+	full   bool
+	mutex  sync.Mutex
+}
+
+// NewRingBufferWriter creates a new ring buffer writer holding capacity events
+func NewRingBufferWriter(capacity int) *RingBufferWriter {
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	return &RingBufferWriter{
+		events: make([]Event, capacity),
+	}
+}
+
+// Write stores an event, overwriting the oldest one when full
+func (w *RingBufferWriter) Write(event Event) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.events[w.next] = event
+	w.next++
+	if w.next == len(w.events) {
+		w.next = 0
+		w.full = true
+	}
+	return nil
+}
+
+// Events returns the buffered events, oldest first
+func (w *RingBufferWriter) Events() []Event {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if !w.full {
+		events := make([]Event, w.next)
+		copy(events, w.events[:w.next])
+		return events
+	}
+
+	events := make([]Event, 0, len(w.events))
+	events = append(events, w.events[w.next:]...)
+	events = append(events, w.events[:w.next]...)
+	return events
+}
+
+// Dump writes the buffered events to out as newline-delimited JSON,
+// the same format as JSONFileWriter
+func (w *RingBufferWriter) Dump(out io.Writer) error {
+	encoder := json.NewEncoder(out)
+	for _, event := range w.Events() {
+		if err := encoder.Encode(event); err != nil {
+			return fmt.Errorf("failed to dump event: %w", err)
+		}
+	}
+	return nil
+}
+
+// DumpOnSignal dumps the buffer to out every time one of sigs is received.
+// The returned function stops listening.
+func (w *RingBufferWriter) DumpOnSignal(out io.Writer, sigs ...os.Signal) func() {
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sigs...)
+
+	go func() {
+		for {
+			select {
+			case <-ch:
+				w.Dump(out)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
+
+// Reset discards all buffered events
+func (w *RingBufferWriter) Reset() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.events = make([]Event, len(w.events))
+	w.next = 0
+	w.full = false
+}
+
+// Flush flushes any buffered data (no-op for ring buffer)
+func (w *RingBufferWriter) Flush() error {
+	return nil
+}
+
+// Close closes the writer (no-op for ring buffer)
+func (w *RingBufferWriter) Close() error {
+	return nil
+}
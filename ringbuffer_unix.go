@@ -0,0 +1,14 @@
+//go:build unix
+
+package lens
+
+import (
+	"io"
+	"syscall"
+)
+
+// DumpOnSIGUSR1 dumps the buffer to out whenever the process receives
+// SIGUSR1 (kill -USR1 <pid>). The returned function stops listening.
+func (w *RingBufferWriter) DumpOnSIGUSR1(out io.Writer) func() {
+	return w.DumpOnSignal(out, syscall.SIGUSR1)
+}
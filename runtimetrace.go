@@ -0,0 +1,224 @@
+package lens
+
+import (
+	"context"
+	"math"
+	"runtime/metrics"
+	"runtime/trace"
+	"time"
+)
+
+// RuntimeTraceCategory is the runtime/trace log category under which
+// wrapped calls record their lens trace ID
+const RuntimeTraceCategory = "lens_trace_id"
+
+// Tags set on EventRuntime events
+const (
+	TagGCCycles        = "gc.cycles"
+	TagGCPauses        = "gc.pauses"
+	TagGCPauseMax      = "gc.pause_max"
+	TagGoroutines      = "sched.goroutines"
+	TagSchedLatencyP99 = "sched.latency_p99"
+	TagSchedLatencyMax = "sched.latency_max"
+)
+
+// Runtime metrics read for EventRuntime events
+const (
+	runtimeMetricCycles     = "/gc/cycles/total:gc-cycles"
+	runtimeMetricGoroutines = "/sched/goroutines:goroutines"
+	runtimeMetricPauses     = "/sched/pauses/total/gc:seconds"
+	runtimeMetricLatency    = "/sched/latencies:seconds"
+)
+
+// WithRuntimeTrace bridges wrapped calls to runtime/trace. While an
+// execution trace is being recorded (e.g. with trace.Start or
+// net/http/pprof), each outermost wrapped call on a goroutine runs as a
+// task and every wrapped call as a region named after the function, with
+// the lens trace ID logged, so go tool trace shows lens calls alongside
+// GC and scheduling.
+func WithRuntimeTrace() Option {
+	return func(t *TracerImpl) {
+		t.runtimeTrace = true
+	}
+}
+
+// invoke runs a wrapped call, applying pprof labels and runtime trace
+// regions when enabled
+func (t *TracerImpl) invoke(ctx context.Context, traceID, function string, depth int, fn func()) {
+	if t.runtimeTrace && trace.IsEnabled() {
+		fn = runtimeTraceRegion(ctx, traceID, function, depth, fn)
+	}
+	if t.pprofLabels {
+		callWithPprofLabels(ctx, traceID, function, fn)
+		return
+	}
+	fn()
+}
+
+// runtimeTraceRegion wraps fn in a runtime trace region, inside a new task
+// for outermost calls
+func runtimeTraceRegion(ctx context.Context, traceID, function string, depth int, fn func()) func() {
+	return func() {
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		if depth == 0 {
+			var task *trace.Task
+			ctx, task = trace.NewTask(ctx, function)
+			defer task.End()
+		}
+		trace.Log(ctx, RuntimeTraceCategory, traceID)
+		trace.WithRegion(ctx, function, fn)
+	}
+}
+
+// StartRuntimeMetrics emits an EventRuntime event every interval with the
+// GC cycles and pauses and the scheduling latency observed since the
+// previous one, read from runtime/metrics without stopping the world. The
+// event's duration is the total GC pause time. It returns a func that
+// stops sampling.
+func (t *TracerImpl) StartRuntimeMetrics(interval time.Duration) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		previous := readRuntimeSample()
+		for {
+			select {
+			case <-ticker.C:
+				current := readRuntimeSample()
+				t.TraceEvent(runtimeEvent(previous, current))
+				previous = current
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// runtimeSample is a reading of the runtime metrics lens reports
+type runtimeSample struct {
+	cycles     uint64
+	goroutines uint64
+	pauses     *metrics.Float64Histogram
+	latencies  *metrics.Float64Histogram
+}
+
+// readRuntimeSample reads the current runtime metrics
+func readRuntimeSample() runtimeSample {
+	samples := []metrics.Sample{
+		{Name: runtimeMetricCycles},
+		{Name: runtimeMetricGoroutines},
+		{Name: runtimeMetricPauses},
+		{Name: runtimeMetricLatency},
+	}
+	metrics.Read(samples)
+
+	var sample runtimeSample
+	if samples[0].Value.Kind() == metrics.KindUint64 {
+		sample.cycles = samples[0].Value.Uint64()
+	}
+	if samples[1].Value.Kind() == metrics.KindUint64 {
+		sample.goroutines = samples[1].Value.Uint64()
+	}
+	if samples[2].Value.Kind() == metrics.KindFloat64Histogram {
+		sample.pauses = samples[2].Value.Float64Histogram()
+	}
+	if samples[3].Value.Kind() == metrics.KindFloat64Histogram {
+		sample.latencies = samples[3].Value.Float64Histogram()
+	}
+	return sample
+}
+
+// runtimeEvent builds the event reporting the change between two samples
+func runtimeEvent(previous, current runtimeSample) Event {
+	pauses := histogramDelta(previous.pauses, current.pauses)
+	latencies := histogramDelta(previous.latencies, current.latencies)
+
+	tags := map[string]interface{}{
+		TagGCCycles:   current.cycles - previous.cycles,
+		TagGCPauses:   pauses.count,
+		TagGoroutines: current.goroutines,
+	}
+	if pauses.count > 0 {
+		tags[TagGCPauseMax] = pauses.max.String()
+	}
+	if latencies.count > 0 {
+		tags[TagSchedLatencyP99] = latencies.p99.String()
+		tags[TagSchedLatencyMax] = latencies.max.String()
+	}
+
+	return Event{
+		ID:        generateEventID(),
+		TraceID:   generateTraceID(),
+		Timestamp: time.Now(),
+		Type:      EventRuntime,
+		Function:  "runtime",
+		Duration:  pauses.total,
+		Goroutine: getGoroutineID(),
+		Tags:      tags,
+	}
+}
+
+// histogramSummary approximates the distribution of histogram samples
+type histogramSummary struct {
+	count uint64
+	total time.Duration
+	max   time.Duration
+	p99   time.Duration
+}
+
+// histogramDelta summarizes the samples added to a cumulative histogram
+// of seconds between two readings. Each sample is counted at its bucket's
+// upper bound, or lower bound for the unbounded last bucket.
+func histogramDelta(previous, current *metrics.Float64Histogram) histogramSummary {
+	var summary histogramSummary
+	if current == nil {
+		return summary
+	}
+
+	counts := make([]uint64, len(current.Counts))
+	for i, count := range current.Counts {
+		counts[i] = count
+		if previous != nil && i < len(previous.Counts) {
+			counts[i] -= previous.Counts[i]
+		}
+		summary.count += counts[i]
+	}
+	if summary.count == 0 {
+		return summary
+	}
+
+	bound := func(i int) time.Duration {
+		upper := current.Buckets[i+1]
+		if math.IsInf(upper, 1) {
+			upper = current.Buckets[i]
+		}
+		return time.Duration(upper * float64(time.Second))
+	}
+
+	threshold := summary.count - summary.count/100
+	var seen uint64
+	for i, count := range counts {
+		if count == 0 {
+			continue
+		}
+		summary.total += time.Duration(count) * bound(i)
+		summary.max = bound(i)
+		if seen < threshold && seen+count >= threshold {
+			summary.p99 = bound(i)
+		}
+		seen += count
+	}
+	return summary
+}
@@ -0,0 +1,87 @@
+package lens
+
+import (
+	"errors"
+	"net/http"
+)
+
+// StatusCode is the status of a span, as in OpenTelemetry
+type StatusCode int
+
+const (
+	// StatusUnset is the status of a span nothing set one on
+	StatusUnset StatusCode = iota
+	// StatusOK marks a span as successful, even if an error was set on it
+	StatusOK
+	// StatusError marks a span as failed
+	StatusError
+)
+
+// String returns the status name used by OpenTelemetry
+func (c StatusCode) String() string {
+	switch c {
+	case StatusOK:
+		return "OK"
+	case StatusError:
+		return "ERROR"
+	default:
+		return "UNSET"
+	}
+}
+
+// Tags holding the status set with Span.SetStatus, named as OpenTelemetry
+// names them for formats without a status field
+const (
+	TagStatusCode    = "otel.status_code"
+	TagStatusMessage = "otel.status_description"
+	// TagExceptionMessage keeps the error of a span whose status is OK
+	TagExceptionMessage = "exception.message"
+)
+
+// Tags of the OpenTelemetry semantic conventions set by the helpers below
+const (
+	TagHTTPMethod           = "http.method"
+	TagHTTPURL              = "http.url"
+	TagHTTPStatusCode       = "http.status_code"
+	TagDBSystem             = "db.system"
+	TagDBStatement          = "db.statement"
+	TagMessagingSystem      = "messaging.system"
+	TagMessagingDestination = "messaging.destination"
+)
+
+// SetHTTPRequest tags a span with the method and URL of an HTTP request
+func SetHTTPRequest(span Span, method, url string) {
+	span.SetTag(TagHTTPMethod, method)
+	span.SetTag(TagHTTPURL, url)
+}
+
+// SetHTTPResponse tags a span with the status code of an HTTP response,
+// and sets the span's status to error for server errors. Spans without a
+// status get the error instead.
+func SetHTTPResponse(span Span, statusCode int) {
+	span.SetTag(TagHTTPStatusCode, statusCode)
+	if statusCode < http.StatusInternalServerError {
+		return
+	}
+	if status, ok := span.(StatusSetter); ok {
+		status.SetStatus(StatusError, http.StatusText(statusCode))
+	} else {
+		span.SetError(errors.New(http.StatusText(statusCode)))
+	}
+}
+
+// SetDBQuery tags a span with the database system, e.g. "postgresql",
+// and the statement it ran
+func SetDBQuery(span Span, system, statement string) {
+	span.SetTag(TagDBSystem, system)
+	if statement != "" {
+		span.SetTag(TagDBStatement, statement)
+	}
+}
+
+// SetMessaging tags a span with the messaging system, e.g. "kafka", and
+// the topic or queue a message is sent to or received from
+func SetMessaging(span Span, system, destination string) {
+	span.SetTag(TagMessagingSystem, system)
+	span.SetTag(TagMessagingDestination, destination)
+}
@@ -0,0 +1,204 @@
+package lens
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// TraceShardedWriter writes each trace to its own JSON lines file, named
+// after the trace ID, so one request's trace can be picked from a busy
+// server without searching a combined file. Files are kept open while
+// traces are active, up to a limit; a trace written to after its file was
+// closed appends to it again.
+type TraceShardedWriter struct {
+	dir     string
+	byHour  bool
+	maxOpen int
+	files   map[string]*shardFile
+	clock   uint64
+	closed  bool
+	mutex   sync.Mutex
+}
+
+// shardFile is an open trace file
+type shardFile struct {
+	file     *os.File
+	writer   *bufio.Writer
+	lastUsed uint64
+}
+
+// TraceShardedWriterOption configures a TraceShardedWriter
+type TraceShardedWriterOption func(*TraceShardedWriter)
+
+// WithShardByHour groups trace files in one directory per hour, e.g.
+// 2024-05-01T14/, taken from the timestamp of each event in UTC. A trace
+// spanning the turn of an hour is split across two directories.
+func WithShardByHour() TraceShardedWriterOption {
+	return func(w *TraceShardedWriter) {
+		w.byHour = true
+	}
+}
+
+// WithShardMaxOpenFiles sets how many trace files are kept open at once;
+// the least recently written one is closed to open another
+func WithShardMaxOpenFiles(n int) TraceShardedWriterOption {
+	return func(w *TraceShardedWriter) {
+		if n > 0 {
+			w.maxOpen = n
+		}
+	}
+}
+
+// NewTraceShardedWriter creates a writer sharding traces into dir
+func NewTraceShardedWriter(dir string, options ...TraceShardedWriterOption) (*TraceShardedWriter, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	w := &TraceShardedWriter{
+		dir:     dir,
+		maxOpen: 64,
+		files:   make(map[string]*shardFile),
+	}
+	for _, option := range options {
+		option(w)
+	}
+	return w, nil
+}
+
+// Write appends an event to the file of its trace
+func (w *TraceShardedWriter) Write(event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return fmt.Errorf("trace sharded writer is closed")
+	}
+
+	shard, err := w.open(w.Path(event))
+	if err != nil {
+		return err
+	}
+	shard.writer.Write(data)
+	if err := shard.writer.WriteByte('\n'); err != nil {
+		return fmt.Errorf("failed to write to file: %w", err)
+	}
+	return nil
+}
+
+// Path returns the file an event is written to
+func (w *TraceShardedWriter) Path(event Event) string {
+	name := "untraced"
+	if event.TraceID != "" {
+		name = shardFileName(event.TraceID)
+	}
+	if w.byHour {
+		return filepath.Join(w.dir, event.Timestamp.UTC().Format("2006-01-02T15"), name+".jsonl")
+	}
+	return filepath.Join(w.dir, name+".jsonl")
+}
+
+// shardFileName makes a trace ID safe to use as a file name
+func shardFileName(traceID string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, traceID)
+}
+
+// open returns the open file at path, opening it and closing the least
+// recently used one if needed. The caller holds the mutex.
+func (w *TraceShardedWriter) open(path string) (*shardFile, error) {
+	w.clock++
+	if shard, ok := w.files[path]; ok {
+		shard.lastUsed = w.clock
+		return shard, nil
+	}
+
+	if len(w.files) >= w.maxOpen {
+		if err := w.closeLeastRecent(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	shard := &shardFile{file: file, writer: bufio.NewWriter(file), lastUsed: w.clock}
+	w.files[path] = shard
+	return shard, nil
+}
+
+// closeLeastRecent closes the file written to the longest ago. The
+// caller holds the mutex.
+func (w *TraceShardedWriter) closeLeastRecent() error {
+	var oldest string
+	for path, shard := range w.files {
+		if oldest == "" || shard.lastUsed < w.files[oldest].lastUsed {
+			oldest = path
+		}
+	}
+	shard := w.files[oldest]
+	delete(w.files, oldest)
+	return shard.close()
+}
+
+// close writes buffered events and closes the file
+func (f *shardFile) close() error {
+	err := f.writer.Flush()
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to close trace file: %w", err)
+	}
+	return nil
+}
+
+// Flush writes buffered events of every open file
+func (w *TraceShardedWriter) Flush() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for _, shard := range w.files {
+		if err := shard.writer.Flush(); err != nil {
+			return fmt.Errorf("failed to write to file: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close writes buffered events and closes every open file
+func (w *TraceShardedWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	var firstErr error
+	for path, shard := range w.files {
+		if err := shard.close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(w.files, path)
+	}
+	w.closed = true
+	return firstErr
+}
@@ -0,0 +1,193 @@
+package lens
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// httpShipper batches events and sends them to an HTTP endpoint. It
+// implements Writer for the log-shipping writers that embed it.
+type httpShipper struct {
+	client        *http.Client
+	header        http.Header
+	batchSize     int
+	flushInterval time.Duration
+	timeout       time.Duration
+	send          func(ctx context.Context, events []Event) error
+	batch         []Event
+	mutex         sync.Mutex
+	done          chan struct{}
+	wg            sync.WaitGroup
+	closed        bool
+}
+
+// HTTPWriterOption configures an HTTP log-shipping writer
+type HTTPWriterOption func(*httpShipper)
+
+// WithHTTPBatchSize sets how many events are sent per request
+func WithHTTPBatchSize(size int) HTTPWriterOption {
+	return func(s *httpShipper) {
+		if size > 0 {
+			s.batchSize = size
+		}
+	}
+}
+
+// WithHTTPFlushInterval sets how often partial batches are sent
+func WithHTTPFlushInterval(interval time.Duration) HTTPWriterOption {
+	return func(s *httpShipper) {
+		s.flushInterval = interval
+	}
+}
+
+// WithHTTPTimeout bounds each request
+func WithHTTPTimeout(timeout time.Duration) HTTPWriterOption {
+	return func(s *httpShipper) {
+		s.timeout = timeout
+	}
+}
+
+// WithHTTPClient sets the client used for requests
+func WithHTTPClient(client *http.Client) HTTPWriterOption {
+	return func(s *httpShipper) {
+		s.client = client
+	}
+}
+
+// WithHTTPHeader adds a header to every request, e.g. for authentication
+// or a Loki tenant (X-Scope-OrgID)
+func WithHTTPHeader(key, value string) HTTPWriterOption {
+	return func(s *httpShipper) {
+		s.header.Add(key, value)
+	}
+}
+
+// newHTTPShipper creates a shipper and starts its flush loop
+func newHTTPShipper(send func(ctx context.Context, events []Event) error, options []HTTPWriterOption) *httpShipper {
+	s := &httpShipper{
+		client:        http.DefaultClient,
+		header:        make(http.Header),
+		batchSize:     500,
+		flushInterval: time.Second,
+		timeout:       10 * time.Second,
+		send:          send,
+		done:          make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	if s.flushInterval > 0 {
+		s.wg.Add(1)
+		go s.flushLoop()
+	}
+
+	return s
+}
+
+// flushLoop periodically sends partial batches
+func (s *httpShipper) flushLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.Flush()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Write buffers an event and sends the batch once it is full
+func (s *httpShipper) Write(event Event) error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return fmt.Errorf("writer is closed")
+	}
+	s.batch = append(s.batch, event)
+	var batch []Event
+	if len(s.batch) >= s.batchSize {
+		batch = s.batch
+		s.batch = nil
+	}
+	s.mutex.Unlock()
+
+	return s.deliver(batch)
+}
+
+// Flush sends any buffered events
+func (s *httpShipper) Flush() error {
+	s.mutex.Lock()
+	batch := s.batch
+	s.batch = nil
+	s.mutex.Unlock()
+
+	return s.deliver(batch)
+}
+
+// Close sends buffered events and stops the flush loop
+func (s *httpShipper) Close() error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mutex.Unlock()
+
+	close(s.done)
+	s.wg.Wait()
+
+	return s.Flush()
+}
+
+// deliver sends a batch within the request timeout
+func (s *httpShipper) deliver(batch []Event) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	return s.send(ctx, batch)
+}
+
+// post sends body to url and returns the response body, failing on
+// non-2xx statuses
+func (s *httpShipper) post(ctx context.Context, url, contentType string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, values := range s.header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send events: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("failed to send events: %s: %s", resp.Status, bytes.TrimSpace(respBody))
+	}
+	return respBody, nil
+}
@@ -0,0 +1,108 @@
+package lens
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// WithCloseOnInterrupt closes the tracer when the process receives
+// os.Interrupt, allowing timeout for pending events to be written. The
+// signal is then raised again, so the program exits as it would have, or
+// its own handlers see it.
+func WithCloseOnInterrupt(timeout time.Duration) Option {
+	return func(t *TracerImpl) {
+		t.closeOnInterrupt = timeout
+	}
+}
+
+// Close stops accepting events, waits for pending writes, then flushes and
+// closes every writer, in the reverse of the order they were added, so
+// writers added later, which may wrap earlier ones, go first. Errors from
+// all writers are joined. If ctx expires first, Close returns its error
+// and writers still busy are abandoned. Close may be called more than
+// once; later calls wait for the first to finish and return its result.
+// Close on a child created by Named does nothing.
+func (t *TracerImpl) Close(ctx context.Context) error {
+	if t.parent != nil {
+		return nil
+	}
+	t.closeOnce.Do(func() {
+		t.mutex.Lock()
+		t.closed = true
+		writers := append([]Writer(nil), t.writers...)
+		t.mutex.Unlock()
+
+		if t.reaper != nil {
+			t.reaper.stop()
+		}
+		if t.stateFile != nil {
+			t.stateFile.stop()
+		}
+
+		t.closeDone = make(chan struct{})
+		go func() {
+			defer close(t.closeDone)
+
+			t.inflight.Wait()
+			t.dispatcher.stop()
+
+			var errs []error
+			for i := len(writers) - 1; i >= 0; i-- {
+				writer := writers[i]
+				err := writer.Flush()
+				t.dispatcher.flushed(writer, err)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("failed to flush %T: %w", writer, err))
+				}
+				if err := writer.Close(); err != nil {
+					errs = append(errs, fmt.Errorf("failed to close %T: %w", writer, err))
+				}
+			}
+			// Saved last, once flushing has brought the statistics up to date
+			if t.stateFile != nil {
+				if err := t.Save(t.stateFile.path); err != nil {
+					errs = append(errs, err)
+				}
+			}
+			t.closeErr = errors.Join(errs...)
+		}()
+	})
+
+	select {
+	case <-t.closeDone:
+		if t.closeErr != nil {
+			return fmt.Errorf("failed to close writers: %w", t.closeErr)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to close tracer: %w", ctx.Err())
+	}
+}
+
+// closeOnSignal closes the tracer on the first of sigs and re-raises it
+func (t *TracerImpl) closeOnSignal(timeout time.Duration, sigs ...os.Signal) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+
+	go func() {
+		sig := <-ch
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		t.Close(ctx)
+		cancel()
+
+		// Restore the default behavior and deliver the signal again
+		signal.Stop(ch)
+		process, err := os.FindProcess(os.Getpid())
+		if err == nil {
+			err = process.Signal(sig)
+		}
+		if err != nil {
+			os.Exit(1)
+		}
+	}()
+}
@@ -0,0 +1,142 @@
+package lens
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sort"
+)
+
+// SlogWriter writes trace events through a log/slog logger, so they merge
+// into the application's logs and follow its handler's level, format and
+// destination. Event fields become structured attributes.
+type SlogWriter struct {
+	handler slog.Handler
+}
+
+// NewSlogWriter creates a writer logging to logger, or to slog.Default()
+// when logger is nil
+func NewSlogWriter(logger *slog.Logger) *SlogWriter {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &SlogWriter{handler: logger.Handler()}
+}
+
+// NewLogfmtWriter creates a writer emitting events as logfmt lines
+// (key=value pairs) to w, at every level
+func NewLogfmtWriter(w io.Writer) *SlogWriter {
+	handler := slog.NewTextHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug})
+	return &SlogWriter{handler: handler}
+}
+
+// Write logs an event at the level matching its type, keeping the event's
+// timestamp
+func (w *SlogWriter) Write(event Event) error {
+	ctx := context.Background()
+	level := slogLevel(event)
+	if !w.handler.Enabled(ctx, level) {
+		return nil
+	}
+
+	record := slog.NewRecord(event.Timestamp, level, string(event.Type), 0)
+	record.AddAttrs(slogAttrs(event)...)
+	return w.handler.Handle(ctx, record)
+}
+
+// Flush is a no-op; the handler owns its output
+func (w *SlogWriter) Flush() error {
+	return nil
+}
+
+// Close is a no-op; the handler owns its output
+func (w *SlogWriter) Close() error {
+	return nil
+}
+
+// slogLevel maps an event type to a log level
+func slogLevel(event Event) slog.Level {
+	switch event.Type {
+	case EventError, EventPanic:
+		return slog.LevelError
+	case EventBlocked, EventBudgetExceeded, EventRecursion, EventQuotaExceeded, EventWriterUnhealthy:
+		return slog.LevelWarn
+	case EventVariableRead, EventVariableWrite, EventFieldAccess:
+		return slog.LevelDebug
+	}
+
+	switch level, _ := registeredLevel(event.Type); level {
+	case LevelError:
+		return slog.LevelError
+	case LevelWarn:
+		return slog.LevelWarn
+	case LevelDebug, LevelTrace:
+		return slog.LevelDebug
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// slogAttrs returns the attributes of the non-empty event fields
+func slogAttrs(event Event) []slog.Attr {
+	attrs := make([]slog.Attr, 0, 16)
+	str := func(key, value string) {
+		if value != "" {
+			attrs = append(attrs, slog.String(key, value))
+		}
+	}
+	values := func(key string, value []interface{}) {
+		if len(value) > 0 {
+			attrs = append(attrs, slog.Any(key, value))
+		}
+	}
+
+	str("trace_id", event.TraceID)
+	str("external_trace_id", event.ExternalTraceID)
+	str("span_id", event.SpanID)
+	str("component", event.Component)
+	str("function", event.Function)
+	str("variable", event.Variable)
+	values("args", event.Arguments)
+	values("returns", event.ReturnValue)
+	if event.Changes != nil {
+		attrs = append(attrs, slog.Any("changes", event.Changes))
+	} else if event.Type == EventVariableWrite || event.Type == EventVariableRead {
+		attrs = append(attrs, slog.Any("old", event.OldValue), slog.Any("new", event.NewValue))
+	}
+	if event.Duration > 0 {
+		attrs = append(attrs, slog.Duration("duration", event.Duration))
+	}
+	str("error", event.Error)
+	if event.Goroutine > 0 {
+		attrs = append(attrs, slog.Int("goroutine", event.Goroutine))
+	}
+	if event.Depth > 0 {
+		attrs = append(attrs, slog.Int("depth", event.Depth))
+	}
+	if event.RecursionDepth > 0 {
+		attrs = append(attrs, slog.Int("recursion_depth", event.RecursionDepth))
+	}
+	str("source_file", event.SourceFile)
+	if event.SourceLine > 0 {
+		attrs = append(attrs, slog.Int("source_line", event.SourceLine))
+	}
+
+	if len(event.Tags) > 0 {
+		keys := make([]string, 0, len(event.Tags))
+		for k := range event.Tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		tags := make([]interface{}, len(keys))
+		for i, k := range keys {
+			tags[i] = slog.Any(k, event.Tags[k])
+		}
+		attrs = append(attrs, slog.Group("tags", tags...))
+	}
+	if event.Seq > 0 {
+		attrs = append(attrs, slog.Uint64("seq", event.Seq))
+	}
+	return attrs
+}
@@ -0,0 +1,75 @@
+package lens_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/baretech/lens"
+)
+
+// minimalSpan implements only the methods Span has always had
+type minimalSpan struct {
+	err error
+}
+
+func (s *minimalSpan) End()                                 {}
+func (s *minimalSpan) SetTag(key string, value interface{}) {}
+func (s *minimalSpan) SetError(err error)                   { s.err = err }
+
+// minimalTracer implements only the methods Tracer has always had
+type minimalTracer struct{}
+
+func (minimalTracer) Wrap(obj interface{}) interface{}                      { return obj }
+func (minimalTracer) WrapWithName(obj interface{}, name string) interface{} { return obj }
+func (minimalTracer) StartSpan(name string) lens.Span                       { return &minimalSpan{} }
+func (minimalTracer) TraceEvent(event lens.Event)                           {}
+func (minimalTracer) TraceVariable(name string, oldVal, newVal interface{}) {}
+func (minimalTracer) SetLevel(level lens.Level)                             {}
+func (minimalTracer) AddWriter(writer lens.Writer)                          {}
+func (minimalTracer) AddFilter(filter lens.Filter)                          {}
+func (minimalTracer) Enable()                                               {}
+func (minimalTracer) Disable()                                              {}
+
+var (
+	_ lens.Span   = (*minimalSpan)(nil)
+	_ lens.Tracer = minimalTracer{}
+)
+
+func TestSpanExtensions(t *testing.T) {
+	impl := lens.New()
+	defer impl.Close(context.Background())
+
+	tracers := map[string]lens.Tracer{"TracerImpl": impl, "Noop": lens.Noop()}
+	for name, tracer := range tracers {
+		if _, ok := tracer.(lens.FieldAccessTracer); !ok {
+			t.Errorf("%s is not a FieldAccessTracer", name)
+		}
+		if _, ok := tracer.(lens.Emitter); !ok {
+			t.Errorf("%s is not an Emitter", name)
+		}
+
+		span := tracer.StartSpan("extensions")
+		if _, ok := span.(lens.StatusSetter); !ok {
+			t.Errorf("%s span is not a StatusSetter", name)
+		}
+		if _, ok := span.(lens.EventAdder); !ok {
+			t.Errorf("%s span is not an EventAdder", name)
+		}
+		if _, ok := span.(lens.Marker); !ok {
+			t.Errorf("%s span is not a Marker", name)
+		}
+		if _, ok := span.(lens.Linker); !ok {
+			t.Errorf("%s span is not a Linker", name)
+		}
+		span.End()
+	}
+}
+
+func TestSetHTTPResponseWithoutStatus(t *testing.T) {
+	span := &minimalSpan{}
+	lens.SetHTTPResponse(span, http.StatusBadGateway)
+	if span.err == nil {
+		t.Error("a 5xx response did not fail a span without a status")
+	}
+}
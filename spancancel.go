@@ -0,0 +1,39 @@
+package lens
+
+import (
+	"context"
+	"errors"
+)
+
+// Span statuses set on spans ended by the cancellation of their context
+const (
+	SpanStatusCanceled         = "canceled"
+	SpanStatusDeadlineExceeded = "deadline_exceeded"
+)
+
+// endOnCancel ends the span when ctx is cancelled or its deadline passes,
+// failing it with the cause unless an error or status was set, so an
+// abandoned request does not leave its span open
+func (s *SpanImpl) endOnCancel(ctx context.Context) {
+	if ctx.Done() == nil {
+		return
+	}
+	stop := context.AfterFunc(ctx, func() {
+		status := SpanStatusCanceled
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			status = SpanStatusDeadlineExceeded
+		}
+
+		s.mutex.Lock()
+		if s.error == nil && s.status == StatusUnset {
+			s.error = context.Cause(ctx)
+		}
+		s.mutex.Unlock()
+
+		s.finish(map[string]interface{}{TagSpanStatus: status})
+	})
+
+	s.mutex.Lock()
+	s.stopCancel = stop
+	s.mutex.Unlock()
+}
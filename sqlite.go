@@ -0,0 +1,190 @@
+package lens
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// SQLiteTable is the table SQLiteWriter stores events in
+const SQLiteTable = "lens_events"
+
+// sqliteSchema creates the events table and the indexes used by the store
+// package. The full event is kept as JSON in data; the other columns are
+// copies for indexing and filtering.
+var sqliteSchema = []string{
+	`CREATE TABLE IF NOT EXISTS ` + SQLiteTable + ` (
+		id        TEXT NOT NULL,
+		trace_id  TEXT NOT NULL,
+		span_id   TEXT,
+		timestamp INTEGER NOT NULL,
+		type      TEXT NOT NULL,
+		component TEXT,
+		function  TEXT,
+		variable  TEXT,
+		duration  INTEGER,
+		error     TEXT,
+		goroutine INTEGER,
+		data      TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS ` + SQLiteTable + `_trace_id ON ` + SQLiteTable + ` (trace_id)`,
+	`CREATE INDEX IF NOT EXISTS ` + SQLiteTable + `_function ON ` + SQLiteTable + ` (function, duration)`,
+	`CREATE INDEX IF NOT EXISTS ` + SQLiteTable + `_timestamp ON ` + SQLiteTable + ` (timestamp)`,
+	`CREATE INDEX IF NOT EXISTS ` + SQLiteTable + `_duration ON ` + SQLiteTable + ` (duration)`,
+}
+
+// sqliteInsert inserts a single event
+const sqliteInsert = `INSERT INTO ` + SQLiteTable + ` (id, trace_id, span_id, timestamp, type, component,
+	function, variable, duration, error, goroutine, data) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+// SQLiteWriter stores trace events in an indexed SQLite database.
+// Lens does not depend on a SQLite driver: open the database with the
+// driver of your choice and pass the *sql.DB in. Events are inserted in
+// batched transactions; query them with the store package.
+type SQLiteWriter struct {
+	db        *sql.DB
+	batchSize int
+	pending   []Event
+	mutex     sync.Mutex
+}
+
+// SQLiteWriterOption configures a SQLiteWriter
+type SQLiteWriterOption func(*SQLiteWriter)
+
+// WithSQLiteBatchSize sets how many events are buffered before they are
+// inserted in one transaction
+func WithSQLiteBatchSize(size int) SQLiteWriterOption {
+	return func(w *SQLiteWriter) {
+		if size > 0 {
+			w.batchSize = size
+		}
+	}
+}
+
+// NewSQLiteWriter creates a new SQLite writer, creating the events table
+// and its indexes if needed
+func NewSQLiteWriter(db *sql.DB, options ...SQLiteWriterOption) (*SQLiteWriter, error) {
+	w := &SQLiteWriter{
+		db:        db,
+		batchSize: 500,
+	}
+	for _, option := range options {
+		option(w)
+	}
+
+	for _, statement := range sqliteSchema {
+		if _, err := db.Exec(statement); err != nil {
+			return nil, fmt.Errorf("failed to create schema: %w", err)
+		}
+	}
+
+	return w, nil
+}
+
+// Write buffers an event, inserting the batch once it is full
+func (w *SQLiteWriter) Write(event Event) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.pending = append(w.pending, event)
+	if len(w.pending) >= w.batchSize {
+		return w.flushLocked()
+	}
+	return nil
+}
+
+// Flush inserts all buffered events
+func (w *SQLiteWriter) Flush() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.flushLocked()
+}
+
+// flushLocked inserts the pending events in a single transaction.
+// Events that cannot be encoded are skipped, and a batch that cannot be
+// inserted is dropped, so a failing database does not make the pending
+// events grow without bound.
+func (w *SQLiteWriter) flushLocked() error {
+	if len(w.pending) == 0 {
+		return nil
+	}
+	defer func() { w.pending = w.pending[:0] }()
+
+	var errs []error
+	events := make([]Event, 0, len(w.pending))
+	encoded := make([]string, 0, len(w.pending))
+	for _, event := range w.pending {
+		data, err := json.Marshal(event)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to marshal event %s: %w", event.ID, err))
+			continue
+		}
+		events = append(events, event)
+		encoded = append(encoded, string(data))
+	}
+	if len(events) == 0 {
+		return errors.Join(errs...)
+	}
+
+	if err := w.insert(events, encoded); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// insert inserts events, with their JSON encoding, in a single transaction
+func (w *SQLiteWriter) insert(events []Event, encoded []string) error {
+	ctx := context.Background()
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, sqliteInsert)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to prepare insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for i, event := range events {
+		_, err = stmt.ExecContext(ctx,
+			event.ID,
+			event.TraceID,
+			nullString(event.SpanID),
+			event.Timestamp.UnixNano(),
+			string(event.Type),
+			nullString(event.Component),
+			nullString(event.Function),
+			nullString(event.Variable),
+			int64(event.Duration),
+			nullString(event.Error),
+			event.Goroutine,
+			encoded[i],
+		)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to insert event %s: %w", event.ID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit events: %w", err)
+	}
+	return nil
+}
+
+// nullString stores empty strings as NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// Close inserts any buffered events. The database is owned by the caller
+// and is not closed.
+func (w *SQLiteWriter) Close() error {
+	return w.Flush()
+}
@@ -0,0 +1,132 @@
+package lens
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeDB is a database/sql driver recording the events inserted, failing
+// inserts while fail is set
+type fakeDB struct {
+	mutex    sync.Mutex
+	inserted []string
+	fail     bool
+}
+
+func (d *fakeDB) Open(string) (driver.Conn, error) { return fakeConn{d}, nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return fakeStmt{c.db, query == sqliteInsert}, nil
+}
+func (c fakeConn) Close() error              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	db     *fakeDB
+	insert bool
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+func (s fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if !s.insert {
+		return driver.RowsAffected(0), nil
+	}
+	s.db.mutex.Lock()
+	defer s.db.mutex.Unlock()
+	if s.db.fail {
+		return nil, errors.New("disk full")
+	}
+	s.db.inserted = append(s.db.inserted, args[0].(string))
+	return driver.RowsAffected(1), nil
+}
+
+// fakeDrivers numbers the drivers registered, as names cannot be reused
+var fakeDrivers atomic.Int64
+
+// openFakeDB opens a new fake database
+func openFakeDB(t *testing.T) (*sql.DB, *fakeDB) {
+	fake := &fakeDB{}
+	name := fmt.Sprintf("fake%d", fakeDrivers.Add(1))
+	sql.Register(name, fake)
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, fake
+}
+
+func TestSQLiteWriterSkipsUnencodableEvents(t *testing.T) {
+	db, fake := openFakeDB(t)
+	w, err := NewSQLiteWriter(db, WithSQLiteBatchSize(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.Write(Event{ID: "bad", ReturnValue: []interface{}{math.Inf(1)}})
+	w.Write(Event{ID: "good1"})
+	if err := w.Write(Event{ID: "good2"}); err == nil {
+		t.Error("inserting the batch did not report the bad event")
+	}
+	if err := w.Write(Event{ID: "good3"}); err != nil {
+		t.Errorf("Write = %v after the bad batch", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("Close = %v after the bad batch", err)
+	}
+
+	want := []string{"good1", "good2", "good3"}
+	if len(fake.inserted) != len(want) {
+		t.Fatalf("inserted %v, want %v", fake.inserted, want)
+	}
+	for i, id := range want {
+		if fake.inserted[i] != id {
+			t.Errorf("inserted %v, want %v", fake.inserted, want)
+			break
+		}
+	}
+}
+
+func TestSQLiteWriterDropsFailedBatches(t *testing.T) {
+	db, fake := openFakeDB(t)
+	w, err := NewSQLiteWriter(db, WithSQLiteBatchSize(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake.fail = true
+	w.Write(Event{ID: "lost1"})
+	if err := w.Write(Event{ID: "lost2"}); err == nil {
+		t.Error("Write did not report the failed insert")
+	}
+	if len(w.pending) != 0 {
+		t.Errorf("%d events still pending after the failed batch", len(w.pending))
+	}
+
+	fake.fail = false
+	w.Write(Event{ID: "kept"})
+	if err := w.Flush(); err != nil {
+		t.Errorf("Flush = %v once inserts succeed", err)
+	}
+	if len(fake.inserted) != 1 || fake.inserted[0] != "kept" {
+		t.Errorf("inserted %v, want [kept]", fake.inserted)
+	}
+}
@@ -0,0 +1,229 @@
+package lens
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// maxStateDepth bounds how deep object state is captured
+const maxStateDepth = 8
+
+// FieldChange describes a single changed field between two snapshots
+type FieldChange struct {
+	Path string      `json:"path"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// snapshotKey identifies a snapshotted object
+type snapshotKey struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// stateSnapshot is a captured copy of an object's exported state
+type stateSnapshot struct {
+	label  string
+	fields map[string]interface{}
+}
+
+// SnapshotState deep-captures the exported fields of obj under label.
+// The first call for an object records a baseline; the next call emits a
+// state_diff event listing every field that changed in between, e.g.
+//
+//	tracer.SnapshotState(user, "before")
+//	user.UpdateAge(31)
+//	tracer.SnapshotState(user, "after")
+func (t *TracerImpl) SnapshotState(obj interface{}, label string) {
+	if !t.enabled || obj == nil {
+		return
+	}
+
+	key := snapshotKeyFor(obj)
+	current := stateSnapshot{label: label, fields: captureState(obj)}
+
+	t.mutex.Lock()
+	previous, ok := t.snapshots[key]
+	if ok {
+		delete(t.snapshots, key)
+	} else {
+		if t.snapshots == nil {
+			t.snapshots = make(map[snapshotKey]stateSnapshot)
+		}
+		t.snapshots[key] = current
+	}
+	t.mutex.Unlock()
+
+	if !ok {
+		return
+	}
+
+	sourceLocation := getSourceLocation(2)
+	callerLocation := getCallerLocation(2)
+
+	event := Event{
+		ID:             generateEventID(),
+		TraceID:        generateTraceID(),
+		Timestamp:      time.Now(),
+		Type:           EventStateDiff,
+		Component:      key.typ.String(),
+		Variable:       fmt.Sprintf("%s->%s", previous.label, current.label),
+		Changes:        diffState(previous.fields, current.fields),
+		Goroutine:      getGoroutineID(),
+		SourceFile:     sourceLocation.File,
+		SourceLine:     sourceLocation.Line,
+		SourceFunction: sourceLocation.Function,
+		CallerFile:     callerLocation.File,
+		CallerLine:     callerLocation.Line,
+		CallerFunction: callerLocation.Function,
+	}
+
+	t.TraceEvent(event)
+}
+
+// snapshotKeyFor returns the identity used to pair snapshots of obj
+func snapshotKeyFor(obj interface{}) snapshotKey {
+	value := reflect.ValueOf(obj)
+	key := snapshotKey{typ: value.Type()}
+	if value.Kind() == reflect.Ptr {
+		key.ptr = value.Pointer()
+	}
+	return key
+}
+
+// captureState flattens the exported state of obj into path -> value
+func captureState(obj interface{}) map[string]interface{} {
+	fields := make(map[string]interface{})
+	captureValue(reflect.ValueOf(obj), "", 0, fields)
+	return fields
+}
+
+// captureValue recursively records leaf values below path
+func captureValue(value reflect.Value, path string, depth int, fields map[string]interface{}) {
+	if !value.IsValid() {
+		fields[path] = nil
+		return
+	}
+
+	if depth > maxStateDepth {
+		if value.CanInterface() {
+			fields[path] = fmt.Sprintf("%v", value.Interface())
+		}
+		return
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			fields[path] = nil
+			return
+		}
+		captureValue(value.Elem(), path, depth+1, fields)
+	case reflect.Struct:
+		structType := value.Type()
+		exported := 0
+		for i := 0; i < structType.NumField(); i++ {
+			field := structType.Field(i)
+			// Locks, such as an embedded sync.Mutex, are not state
+			if !field.IsExported() || field.Type.PkgPath() == "sync" {
+				continue
+			}
+			exported++
+			captureValue(value.Field(i), joinPath(path, field.Name), depth+1, fields)
+		}
+		// Opaque structs such as time.Time are recorded as a whole
+		if exported == 0 && value.CanInterface() {
+			fields[path] = value.Interface()
+		}
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice && value.IsNil() {
+			fields[path] = nil
+			return
+		}
+		fields[path+".len"] = value.Len()
+		for i := 0; i < value.Len(); i++ {
+			captureValue(value.Index(i), fmt.Sprintf("%s[%d]", path, i), depth+1, fields)
+		}
+	case reflect.Map:
+		if value.IsNil() {
+			fields[path] = nil
+			return
+		}
+		fields[path+".len"] = value.Len()
+		iter := value.MapRange()
+		for iter.Next() {
+			captureValue(iter.Value(), fmt.Sprintf("%s[%v]", path, iter.Key()), depth+1, fields)
+		}
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		// Not meaningful as state
+	default:
+		if value.CanInterface() {
+			fields[path] = value.Interface()
+		}
+	}
+}
+
+// joinPath appends a field name to a dotted path
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// diffValues returns the fields changed between two structs or maps of
+// the same type, empty if nothing changed. ok is false if they are not
+// such values.
+func diffValues(oldVal, newVal interface{}) (changes []FieldChange, ok bool) {
+	if oldVal == nil || newVal == nil || reflect.TypeOf(oldVal) != reflect.TypeOf(newVal) {
+		return nil, false
+	}
+	valueType := reflect.TypeOf(oldVal)
+	for valueType.Kind() == reflect.Ptr {
+		valueType = valueType.Elem()
+	}
+	if valueType.Kind() != reflect.Struct && valueType.Kind() != reflect.Map {
+		return nil, false
+	}
+
+	oldFields, newFields := captureState(oldVal), captureState(newVal)
+	// Opaque structs, such as time.Time, and nil pointers or maps are
+	// captured as a whole, leaving nothing to diff
+	_, oldWhole := oldFields[""]
+	_, newWhole := newFields[""]
+	if oldWhole || newWhole {
+		return nil, false
+	}
+
+	changes = diffState(oldFields, newFields)
+	if changes == nil {
+		changes = []FieldChange{}
+	}
+	return changes, true
+}
+
+// diffState returns the changes between two captured states, sorted by path
+func diffState(oldFields, newFields map[string]interface{}) []FieldChange {
+	var changes []FieldChange
+
+	for path, oldValue := range oldFields {
+		newValue, ok := newFields[path]
+		if !ok || !reflect.DeepEqual(oldValue, newValue) {
+			changes = append(changes, FieldChange{Path: path, Old: oldValue, New: newValue})
+		}
+	}
+
+	for path, newValue := range newFields {
+		if _, ok := oldFields[path]; !ok {
+			changes = append(changes, FieldChange{Path: path, New: newValue})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	return changes
+}
@@ -0,0 +1,254 @@
+package lens
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/bits"
+	"sort"
+	"sync"
+	"time"
+)
+
+// histogramSubBits sets histogram precision: 2^6 sub-buckets per power of
+// two keeps the relative error of recorded durations under 1/64
+const histogramSubBits = 6
+
+// FunctionStats summarizes the calls of a single function
+type FunctionStats struct {
+	Count     uint64        `json:"count"`
+	Errors    uint64        `json:"errors"`
+	ErrorRate float64       `json:"error_rate"`
+	Min       time.Duration `json:"min"`
+	Max       time.Duration `json:"max"`
+	Mean      time.Duration `json:"mean"`
+	P50       time.Duration `json:"p50"`
+	P90       time.Duration `json:"p90"`
+	P99       time.Duration `json:"p99"`
+	P999      time.Duration `json:"p999"`
+}
+
+// Stats is a point-in-time snapshot of per-function statistics
+type Stats struct {
+	Since     time.Time                `json:"since"`
+	Functions map[string]FunctionStats `json:"functions"`
+}
+
+// functionAggregate accumulates the raw data behind FunctionStats
+type functionAggregate struct {
+	count     uint64
+	errors    uint64
+	min       time.Duration
+	max       time.Duration
+	sum       time.Duration
+	histogram histogram
+}
+
+// StatsCollector is a writer that aggregates call statistics in memory
+// instead of storing events
+type StatsCollector struct {
+	since     time.Time
+	functions map[string]*functionAggregate
+	mutex     sync.Mutex
+}
+
+// NewStatsCollector creates a new stats collector
+func NewStatsCollector() *StatsCollector {
+	return &StatsCollector{
+		since:     time.Now(),
+		functions: make(map[string]*functionAggregate),
+	}
+}
+
+// Write records the duration and outcome of completed calls
+func (c *StatsCollector) Write(event Event) error {
+	if event.Function == "" {
+		return nil
+	}
+	if event.Type != EventFunctionReturn && event.Type != EventError {
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	agg, ok := c.functions[event.Function]
+	if !ok {
+		agg = &functionAggregate{histogram: make(histogram)}
+		c.functions[event.Function] = agg
+	}
+
+	if agg.count == 0 || event.Duration < agg.min {
+		agg.min = event.Duration
+	}
+	if event.Duration > agg.max {
+		agg.max = event.Duration
+	}
+	agg.count++
+	agg.sum += event.Duration
+	agg.histogram.record(event.Duration)
+
+	if isErrorEvent(event) {
+		agg.errors++
+	}
+
+	return nil
+}
+
+// isErrorEvent reports whether an event represents a failed call
+func isErrorEvent(event Event) bool {
+	if event.Type == EventError || event.Type == EventPanic || event.Error != "" {
+		return true
+	}
+	// Functions conventionally return their error last
+	if n := len(event.ReturnValue); n > 0 {
+		if err, ok := event.ReturnValue[n-1].(error); ok && err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Stats returns a snapshot of the collected statistics
+func (c *StatsCollector) Stats() *Stats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	stats := &Stats{
+		Since:     c.since,
+		Functions: make(map[string]FunctionStats, len(c.functions)),
+	}
+
+	for name, agg := range c.functions {
+		fs := FunctionStats{
+			Count:  agg.count,
+			Errors: agg.errors,
+			Min:    agg.min,
+			Max:    agg.max,
+			P50:    agg.histogram.percentile(0.50, agg.count),
+			P90:    agg.histogram.percentile(0.90, agg.count),
+			P99:    agg.histogram.percentile(0.99, agg.count),
+			P999:   agg.histogram.percentile(0.999, agg.count),
+		}
+		if agg.count > 0 {
+			fs.ErrorRate = float64(agg.errors) / float64(agg.count)
+			fs.Mean = agg.sum / time.Duration(agg.count)
+		}
+		stats.Functions[name] = fs
+	}
+
+	return stats
+}
+
+// WriteJSON exports the current statistics as JSON
+func (c *StatsCollector) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(c.Stats()); err != nil {
+		return fmt.Errorf("failed to encode stats: %w", err)
+	}
+	return nil
+}
+
+// Reset discards all collected statistics
+func (c *StatsCollector) Reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.since = time.Now()
+	c.functions = make(map[string]*functionAggregate)
+}
+
+// Flush flushes any buffered data (no-op for stats)
+func (c *StatsCollector) Flush() error {
+	return nil
+}
+
+// Close closes the writer (no-op for stats)
+func (c *StatsCollector) Close() error {
+	return nil
+}
+
+// Stats returns statistics from the tracer's StatsCollector writer, or nil
+// if none is registered
+func (t *TracerImpl) Stats() *Stats {
+	if collector := t.root().statsCollector(); collector != nil {
+		return collector.Stats()
+	}
+	return nil
+}
+
+// statsCollector returns the tracer's StatsCollector writer, if any
+func (t *TracerImpl) statsCollector() *StatsCollector {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	for _, writer := range t.writers {
+		if collector, ok := writer.(*StatsCollector); ok {
+			return collector
+		}
+	}
+	return nil
+}
+
+// histogram is a sparse log-linear histogram of durations in the style of
+// HDR histograms: exact below 2^subBits, fixed relative precision above
+type histogram map[int]uint64
+
+// record adds a duration to the histogram
+func (h histogram) record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	h[bucketIndex(uint64(d))]++
+}
+
+// percentile returns the value at quantile q given the total count
+func (h histogram) percentile(q float64, total uint64) time.Duration {
+	if total == 0 {
+		return 0
+	}
+
+	indexes := make([]int, 0, len(h))
+	for index := range h {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	target := uint64(q * float64(total))
+	if target >= total {
+		target = total - 1
+	}
+
+	var seen uint64
+	for _, index := range indexes {
+		seen += h[index]
+		if seen > target {
+			return time.Duration(bucketValue(index))
+		}
+	}
+	return time.Duration(bucketValue(indexes[len(indexes)-1]))
+}
+
+// bucketIndex maps a value to its histogram bucket
+func bucketIndex(v uint64) int {
+	const subBuckets = 1 << histogramSubBits
+	if v < subBuckets {
+		return int(v)
+	}
+	shift := bits.Len64(v) - histogramSubBits - 1
+	mantissa := v >> uint(shift)
+	return (shift+1)*subBuckets + int(mantissa-subBuckets)
+}
+
+// bucketValue returns the midpoint of a histogram bucket
+func bucketValue(index int) uint64 {
+	const subBuckets = 1 << histogramSubBits
+	if index < subBuckets {
+		return uint64(index)
+	}
+	shift := index/subBuckets - 1
+	mantissa := uint64(index%subBuckets + subBuckets)
+	lower := mantissa << uint(shift)
+	return lower + (uint64(1)<<uint(shift))/2
+}
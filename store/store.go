@@ -0,0 +1,210 @@
+// Package store queries trace events written by lens.SQLiteWriter without
+// loading whole trace files into memory
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/baretech/lens"
+	"github.com/baretech/lens/reader"
+)
+
+// Store reads events from a database populated by lens.SQLiteWriter
+type Store struct {
+	db *sql.DB
+}
+
+// Open creates a store on db. The database is owned by the caller.
+func Open(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Query selects events. Zero fields are ignored.
+type Query struct {
+	TraceID string
+	// Function matches function names with SQL LIKE syntax, e.g. "main.%"
+	Function    string
+	Type        lens.EventType
+	Since       time.Time
+	Until       time.Time
+	MinDuration time.Duration
+	// OrderBy is "timestamp" (the default) or "duration"
+	OrderBy    string
+	Descending bool
+	Limit      int
+}
+
+// FunctionSummary aggregates the completed calls of one function
+type FunctionSummary struct {
+	Function    string
+	Calls       int64
+	Errors      int64
+	AvgDuration time.Duration
+	MaxDuration time.Duration
+}
+
+// build renders the query as SQL with its arguments
+func (q Query) build() (string, []interface{}, error) {
+	var where []string
+	var args []interface{}
+
+	if q.TraceID != "" {
+		where = append(where, "trace_id = ?")
+		args = append(args, q.TraceID)
+	}
+	if q.Function != "" {
+		where = append(where, "function LIKE ?")
+		args = append(args, q.Function)
+	}
+	if q.Type != "" {
+		where = append(where, "type = ?")
+		args = append(args, string(q.Type))
+	}
+	if !q.Since.IsZero() {
+		where = append(where, "timestamp >= ?")
+		args = append(args, q.Since.UnixNano())
+	}
+	if !q.Until.IsZero() {
+		where = append(where, "timestamp < ?")
+		args = append(args, q.Until.UnixNano())
+	}
+	if q.MinDuration > 0 {
+		where = append(where, "duration >= ?")
+		args = append(args, int64(q.MinDuration))
+	}
+
+	var b strings.Builder
+	b.WriteString("SELECT data FROM " + lens.SQLiteTable)
+	if len(where) > 0 {
+		b.WriteString(" WHERE " + strings.Join(where, " AND "))
+	}
+
+	switch q.OrderBy {
+	case "", "timestamp":
+		b.WriteString(" ORDER BY timestamp")
+	case "duration":
+		b.WriteString(" ORDER BY duration")
+	default:
+		return "", nil, fmt.Errorf("unknown order %q", q.OrderBy)
+	}
+	if q.Descending {
+		b.WriteString(" DESC")
+	}
+	// Insertion order breaks ties between events with equal timestamps
+	b.WriteString(", rowid")
+
+	if q.Limit > 0 {
+		b.WriteString(" LIMIT ?")
+		args = append(args, q.Limit)
+	}
+
+	return b.String(), args, nil
+}
+
+// Each calls fn for every event matching q, stopping at the first error
+func (s *Store) Each(ctx context.Context, q Query, fn func(lens.Event) error) error {
+	query, args, err := q.build()
+	if err != nil {
+		return err
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return fmt.Errorf("failed to scan event: %w", err)
+		}
+		event, err := reader.Decode([]byte(data))
+		if err != nil {
+			return fmt.Errorf("failed to decode event: %w", err)
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read events: %w", err)
+	}
+	return nil
+}
+
+// Events returns every event matching q
+func (s *Store) Events(ctx context.Context, q Query) ([]lens.Event, error) {
+	var events []lens.Event
+	err := s.Each(ctx, q, func(event lens.Event) error {
+		events = append(events, event)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// ViewerSource returns a source for lens.NewViewerHandler that shows the
+// events matching q
+func (s *Store) ViewerSource(q Query) lens.ViewerSource {
+	return func(ctx context.Context) ([]lens.Event, error) {
+		return s.Events(ctx, q)
+	}
+}
+
+// Trace returns the events of one trace in order
+func (s *Store) Trace(ctx context.Context, traceID string) ([]lens.Event, error) {
+	return s.Events(ctx, Query{TraceID: traceID})
+}
+
+// Slowest returns the n slowest completed calls of functions matching
+// the LIKE pattern function, or of all functions if it is empty
+func (s *Store) Slowest(ctx context.Context, function string, n int) ([]lens.Event, error) {
+	return s.Events(ctx, Query{
+		Function:   function,
+		Type:       lens.EventFunctionReturn,
+		OrderBy:    "duration",
+		Descending: true,
+		Limit:      n,
+	})
+}
+
+// Functions summarizes completed calls per function, busiest first
+func (s *Store) Functions(ctx context.Context) ([]FunctionSummary, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT function, COUNT(*),
+		SUM(CASE WHEN error IS NOT NULL THEN 1 ELSE 0 END),
+		AVG(duration), MAX(duration)
+		FROM `+lens.SQLiteTable+`
+		WHERE function IS NOT NULL AND type IN (?, ?)
+		GROUP BY function ORDER BY COUNT(*) DESC, function`,
+		string(lens.EventFunctionReturn), string(lens.EventError))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query functions: %w", err)
+	}
+	defer rows.Close()
+
+	var summaries []FunctionSummary
+	for rows.Next() {
+		var summary FunctionSummary
+		var avg float64
+		var max int64
+		if err := rows.Scan(&summary.Function, &summary.Calls, &summary.Errors, &avg, &max); err != nil {
+			return nil, fmt.Errorf("failed to scan function summary: %w", err)
+		}
+		summary.AvgDuration = time.Duration(avg)
+		summary.MaxDuration = time.Duration(max)
+		summaries = append(summaries, summary)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read function summaries: %w", err)
+	}
+	return summaries, nil
+}
@@ -0,0 +1,110 @@
+package lens
+
+import (
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// functionSwitches mutes functions at runtime. Wrappers consult it on
+// every call, so lookups never lock: each change publishes a new
+// immutable switchState, and each state caches its answers per function.
+type functionSwitches struct {
+	state atomic.Pointer[switchState]
+	mutex sync.Mutex
+}
+
+// switchState is one published set of muted functions
+type switchState struct {
+	// Patterns muted with DisableFunction
+	disabled []string
+	// Names enabled again while a broader pattern stays muted
+	enabled map[string]bool
+	// Lookup results by traced function name
+	cache sync.Map
+}
+
+// DisableFunction stops tracing functions matching pattern, with
+// immediate effect on wrappers already created. pattern is an exact name
+// or a filepath.Match glob, matched like SetBudget names, so
+// "Calculator.*" mutes every Calculator method whatever its package.
+func (t *TracerImpl) DisableFunction(pattern string) {
+	t.switches.update(func(disabled []string, enabled map[string]bool) []string {
+		delete(enabled, pattern)
+		if !slices.Contains(disabled, pattern) {
+			disabled = append(disabled, pattern)
+		}
+		return disabled
+	})
+}
+
+// EnableFunction resumes tracing functions muted with DisableFunction.
+// Passing a pattern given to DisableFunction removes it; passing a name
+// muted by a broader pattern exempts that name alone.
+func (t *TracerImpl) EnableFunction(pattern string) {
+	t.switches.update(func(disabled []string, enabled map[string]bool) []string {
+		if slices.Contains(disabled, pattern) {
+			return slices.DeleteFunc(disabled, func(p string) bool { return p == pattern })
+		}
+		enabled[pattern] = true
+		return disabled
+	})
+}
+
+// update publishes the state produced by change from a copy of the
+// current one
+func (s *functionSwitches) update(change func(disabled []string, enabled map[string]bool) []string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var disabled []string
+	enabled := make(map[string]bool)
+	if current := s.state.Load(); current != nil {
+		disabled = slices.Clone(current.disabled)
+		for name := range current.enabled {
+			enabled[name] = true
+		}
+	}
+
+	disabled = change(disabled, enabled)
+	if len(disabled) == 0 {
+		// Nothing is muted, so no exemptions are needed
+		s.state.Store(nil)
+		return
+	}
+	s.state.Store(&switchState{disabled: disabled, enabled: enabled})
+}
+
+// muted reports whether function was disabled at runtime
+func (s *functionSwitches) muted(function string) bool {
+	state := s.state.Load()
+	if state == nil || function == "" {
+		return false
+	}
+
+	if cached, ok := state.cache.Load(function); ok {
+		return cached.(bool)
+	}
+	muted := state.match(function)
+	state.cache.Store(function, muted)
+	return muted
+}
+
+// match checks function against the state's patterns
+func (s *switchState) match(function string) bool {
+	names := budgetNames(function)
+	for _, name := range names {
+		if s.enabled[name] {
+			return false
+		}
+	}
+	for _, pattern := range s.disabled {
+		for _, name := range names {
+			if matched, _ := filepath.Match(pattern, name); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,201 @@
+package lens
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SyslogFacility is the syslog facility used to compute message priority
+type SyslogFacility int
+
+const (
+	FacilityUser   SyslogFacility = 1
+	FacilityDaemon SyslogFacility = 3
+	FacilityLocal0 SyslogFacility = 16
+	FacilityLocal1 SyslogFacility = 17
+	FacilityLocal2 SyslogFacility = 18
+	FacilityLocal3 SyslogFacility = 19
+	FacilityLocal4 SyslogFacility = 20
+	FacilityLocal5 SyslogFacility = 21
+	FacilityLocal6 SyslogFacility = 22
+	FacilityLocal7 SyslogFacility = 23
+)
+
+// Syslog severities as defined by RFC 5424
+const (
+	severityError = 3
+	severityInfo  = 6
+	severityDebug = 7
+)
+
+// syslogStructuredDataID is the SD-ID used for lens structured data.
+// 32473 is the private enterprise number reserved for documentation.
+const syslogStructuredDataID = "lens@32473"
+
+// syslogTimestamp is the RFC 5424 timestamp layout, which allows at most
+// six fractional digits
+const syslogTimestamp = "2006-01-02T15:04:05.000000Z07:00"
+
+// SyslogWriter writes trace events as RFC 5424 syslog messages
+type SyslogWriter struct {
+	network  string
+	address  string
+	facility SyslogFacility
+	appName  string
+	hostname string
+	conn     net.Conn
+	mutex    sync.Mutex
+}
+
+// NewSyslogWriter creates a new syslog writer.
+// network and address are passed to net.Dial (e.g. "udp", "localhost:514"
+// or "unixgram", "/dev/log").
+func NewSyslogWriter(network, address string, facility SyslogFacility) (*SyslogWriter, error) {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	w := &SyslogWriter{
+		network:  network,
+		address:  address,
+		facility: facility,
+		appName:  filepath.Base(os.Args[0]),
+		hostname: hostname,
+	}
+
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// connect dials the syslog endpoint
+func (w *SyslogWriter) connect() error {
+	conn, err := net.Dial(w.network, w.address)
+	if err != nil {
+		return fmt.Errorf("failed to connect to syslog: %w", err)
+	}
+	w.conn = conn
+	return nil
+}
+
+// Write writes an event to syslog
+func (w *SyslogWriter) Write(event Event) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.conn == nil {
+		if err := w.connect(); err != nil {
+			return err
+		}
+	}
+
+	msg := w.format(event)
+
+	// Stream transports need framing, datagrams carry one message each
+	if w.network == "tcp" || w.network == "tcp4" || w.network == "tcp6" || w.network == "unix" {
+		msg = fmt.Sprintf("%d %s", len(msg), msg)
+	}
+
+	if _, err := w.conn.Write([]byte(msg)); err != nil {
+		w.conn.Close()
+		w.conn = nil
+		return fmt.Errorf("failed to write to syslog: %w", err)
+	}
+
+	return nil
+}
+
+// format formats an event as an RFC 5424 message
+func (w *SyslogWriter) format(event Event) string {
+	priority := int(w.facility)*8 + syslogSeverity(event)
+
+	timestamp := event.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s %s %s",
+		priority,
+		timestamp.Format(syslogTimestamp),
+		w.hostname,
+		w.appName,
+		os.Getpid(),
+		event.Type,
+		syslogStructuredData(event),
+		strings.TrimSpace(string(event.Type)+" "+formatEventDetails(event)),
+	)
+}
+
+// syslogSeverity maps an event type to a syslog severity
+func syslogSeverity(event Event) int {
+	switch event.Type {
+	case EventError, EventPanic:
+		return severityError
+	case EventVariableRead, EventVariableWrite, EventFieldAccess:
+		return severityDebug
+	default:
+		return severityInfo
+	}
+}
+
+// syslogStructuredData renders the lens structured data element
+func syslogStructuredData(event Event) string {
+	var b strings.Builder
+	b.WriteString("[")
+	b.WriteString(syslogStructuredDataID)
+
+	param := func(name, value string) {
+		if value == "" {
+			return
+		}
+		fmt.Fprintf(&b, " %s=\"%s\"", name, escapeSyslogParam(value))
+	}
+
+	param("id", event.ID)
+	param("trace_id", event.TraceID)
+	param("component", event.Component)
+	param("function", event.Function)
+	param("variable", event.Variable)
+	param("error", event.Error)
+	if event.Duration > 0 {
+		param("duration", event.Duration.String())
+	}
+	if event.CallerFile != "" && event.CallerLine > 0 {
+		param("caller", fmt.Sprintf("%s:%d", event.CallerFile, event.CallerLine))
+	}
+
+	b.WriteString("]")
+	return b.String()
+}
+
+// escapeSyslogParam escapes characters not allowed in SD-PARAM values
+func escapeSyslogParam(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+	return replacer.Replace(value)
+}
+
+// Flush flushes any buffered data (no-op for syslog)
+func (w *SyslogWriter) Flush() error {
+	return nil
+}
+
+// Close closes the writer
+func (w *SyslogWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.conn != nil {
+		err := w.conn.Close()
+		w.conn = nil
+		return err
+	}
+	return nil
+}
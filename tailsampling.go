@@ -0,0 +1,287 @@
+package lens
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// TailSamplingWriter holds the events of each trace until it completes,
+// then passes the whole trace to the underlying writer only if it is
+// interesting: it contains an error or panic, lasted at least the minimum
+// duration, or matches a predicate. Unlike SamplingFilter, the decision
+// is made with the outcome known, so rare failures are never sampled away.
+//
+// A trace completes when every call and span in it has returned. Traces
+// still open after the decision wait, or beyond the maximum number held,
+// are decided on the events seen so far.
+type TailSamplingWriter struct {
+	inner        Writer
+	minDuration  time.Duration
+	predicate    func(events []Event) bool
+	decisionWait time.Duration
+	maxTraces    int
+	traces       map[string]*tailTrace
+	order        []string
+	kept         uint64
+	dropped      uint64
+	mutex        sync.Mutex
+	// Serializes writes to inner, which come from Write and expireLoop
+	writeMutex sync.Mutex
+	done       chan struct{}
+	wg         sync.WaitGroup
+	closed     bool
+}
+
+// tailTrace is the buffered state of one trace
+type tailTrace struct {
+	events  []Event
+	open    int
+	created time.Time
+}
+
+// TailSamplingOption configures a TailSamplingWriter
+type TailSamplingOption func(*TailSamplingWriter)
+
+// WithTailMinDuration keeps traces lasting at least d
+func WithTailMinDuration(d time.Duration) TailSamplingOption {
+	return func(w *TailSamplingWriter) {
+		w.minDuration = d
+	}
+}
+
+// WithTailPredicate keeps traces for which keep returns true
+func WithTailPredicate(keep func(events []Event) bool) TailSamplingOption {
+	return func(w *TailSamplingWriter) {
+		w.predicate = keep
+	}
+}
+
+// WithTailDecisionWait sets how long an incomplete trace is held before
+// it is decided anyway
+func WithTailDecisionWait(wait time.Duration) TailSamplingOption {
+	return func(w *TailSamplingWriter) {
+		w.decisionWait = wait
+	}
+}
+
+// WithTailMaxTraces bounds how many traces are held; beyond it the oldest
+// is decided early
+func WithTailMaxTraces(max int) TailSamplingOption {
+	return func(w *TailSamplingWriter) {
+		if max > 0 {
+			w.maxTraces = max
+		}
+	}
+}
+
+// NewTailSamplingWriter creates a tail-sampling writer in front of inner.
+// Traces with errors are always kept.
+func NewTailSamplingWriter(inner Writer, options ...TailSamplingOption) *TailSamplingWriter {
+	w := &TailSamplingWriter{
+		inner:        inner,
+		decisionWait: 30 * time.Second,
+		maxTraces:    10000,
+		traces:       make(map[string]*tailTrace),
+		done:         make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(w)
+	}
+
+	if w.decisionWait > 0 {
+		w.wg.Add(1)
+		go w.expireLoop()
+	}
+
+	return w
+}
+
+// Write buffers an event with its trace, deciding the trace once it
+// completes. Events without a trace ID are written through.
+func (w *TailSamplingWriter) Write(event Event) error {
+	if event.TraceID == "" {
+		w.writeMutex.Lock()
+		defer w.writeMutex.Unlock()
+		return w.inner.Write(event)
+	}
+
+	w.mutex.Lock()
+	if w.closed {
+		w.mutex.Unlock()
+		return errors.New("writer is closed")
+	}
+
+	trace, ok := w.traces[event.TraceID]
+	if !ok {
+		trace = &tailTrace{created: time.Now()}
+		w.traces[event.TraceID] = trace
+		w.order = append(w.order, event.TraceID)
+	}
+	trace.events = append(trace.events, event)
+
+	switch event.Type {
+	case EventFunctionCall, EventMethodCall:
+		trace.open++
+	case EventFunctionReturn, EventError, EventPanic:
+		trace.open--
+	}
+
+	var ready [][]Event
+	if trace.open <= 0 && isReturnEvent(event) {
+		ready = append(ready, w.take(event.TraceID))
+	}
+	for len(w.traces) > w.maxTraces {
+		ready = append(ready, w.take(w.order[0]))
+	}
+	w.mutex.Unlock()
+
+	return w.decide(ready)
+}
+
+// isReturnEvent reports whether an event ends a call or span
+func isReturnEvent(event Event) bool {
+	return event.Type == EventFunctionReturn || event.Type == EventError || event.Type == EventPanic
+}
+
+// take removes a trace from the buffer and returns its events; the caller
+// must hold the mutex
+func (w *TailSamplingWriter) take(traceID string) []Event {
+	trace := w.traces[traceID]
+	delete(w.traces, traceID)
+	// Traces usually complete soon after they start, so search from the end
+	for i := len(w.order) - 1; i >= 0; i-- {
+		if w.order[i] == traceID {
+			w.order = append(w.order[:i], w.order[i+1:]...)
+			break
+		}
+	}
+	return trace.events
+}
+
+// decide writes the traces worth keeping and counts the rest
+func (w *TailSamplingWriter) decide(traces [][]Event) error {
+	var errs []error
+	for _, events := range traces {
+		if !w.keep(events) {
+			w.mutex.Lock()
+			w.dropped++
+			w.mutex.Unlock()
+			continue
+		}
+
+		w.mutex.Lock()
+		w.kept++
+		w.mutex.Unlock()
+
+		w.writeMutex.Lock()
+		for _, event := range events {
+			if err := w.inner.Write(event); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		w.writeMutex.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// keep applies the sampling policy to a complete trace
+func (w *TailSamplingWriter) keep(events []Event) bool {
+	var start, end time.Time
+	for _, event := range events {
+		if event.Type == EventError || event.Type == EventPanic || event.Error != "" {
+			return true
+		}
+		for _, value := range event.ReturnValue {
+			if err, ok := value.(error); ok && err != nil {
+				return true
+			}
+		}
+
+		eventStart := event.Timestamp.Add(-event.Duration)
+		if start.IsZero() || eventStart.Before(start) {
+			start = eventStart
+		}
+		if event.Timestamp.After(end) {
+			end = event.Timestamp
+		}
+	}
+
+	if w.minDuration > 0 && end.Sub(start) >= w.minDuration {
+		return true
+	}
+	return w.predicate != nil && w.predicate(events)
+}
+
+// expireLoop decides traces held longer than the decision wait
+func (w *TailSamplingWriter) expireLoop() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.decisionWait / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.mutex.Lock()
+			var expired [][]Event
+			cutoff := time.Now().Add(-w.decisionWait)
+			for len(w.order) > 0 && w.traces[w.order[0]].created.Before(cutoff) {
+				expired = append(expired, w.take(w.order[0]))
+			}
+			w.mutex.Unlock()
+			w.decide(expired)
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// Kept returns the number of traces written so far
+func (w *TailSamplingWriter) Kept() uint64 {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.kept
+}
+
+// Dropped returns the number of traces discarded so far
+func (w *TailSamplingWriter) Dropped() uint64 {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.dropped
+}
+
+// Flush flushes the underlying writer. Traces still open stay buffered.
+func (w *TailSamplingWriter) Flush() error {
+	w.writeMutex.Lock()
+	defer w.writeMutex.Unlock()
+	return w.inner.Flush()
+}
+
+// Close decides every buffered trace and closes the underlying writer
+func (w *TailSamplingWriter) Close() error {
+	w.mutex.Lock()
+	if w.closed {
+		w.mutex.Unlock()
+		return nil
+	}
+	w.closed = true
+	var pending [][]Event
+	for len(w.order) > 0 {
+		pending = append(pending, w.take(w.order[0]))
+	}
+	w.mutex.Unlock()
+
+	close(w.done)
+	w.wg.Wait()
+
+	err := w.decide(pending)
+	if flushErr := w.inner.Flush(); flushErr != nil {
+		err = errors.Join(err, flushErr)
+	}
+	if closeErr := w.inner.Close(); closeErr != nil {
+		err = errors.Join(err, closeErr)
+	}
+	return err
+}
@@ -0,0 +1,212 @@
+package lens
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TimeWindowFilter only allows tracing within time windows: absolute
+// ranges, such as an incident window, or daily schedules, such as the
+// low-traffic hours of the night. Outside every window all events are
+// dropped before anything is captured. A filter without windows keeps
+// everything.
+type TimeWindowFilter struct {
+	ranges    []timeRange
+	schedules []dailySchedule
+	location  *time.Location
+}
+
+// timeRange is an absolute time window
+type timeRange struct {
+	start, end time.Time
+}
+
+// dailySchedule is a window recurring every day, or on some weekdays.
+// start and end are offsets from midnight; an end before the start ends
+// the window the next day.
+type dailySchedule struct {
+	start, end time.Duration
+	days       [7]bool
+}
+
+// NewTimeWindowFilter creates a time window filter reading schedules in
+// local time
+func NewTimeWindowFilter() *TimeWindowFilter {
+	return &TimeWindowFilter{location: time.Local}
+}
+
+// In reads daily schedules in location instead of local time
+func (f *TimeWindowFilter) In(location *time.Location) *TimeWindowFilter {
+	f.location = location
+	return f
+}
+
+// Between allows tracing from start until end
+func (f *TimeWindowFilter) Between(start, end time.Time) *TimeWindowFilter {
+	f.ranges = append(f.ranges, timeRange{start: start, end: end})
+	return f
+}
+
+// Daily allows tracing every day from start until end, given as times of
+// day, e.g. 22*time.Hour and 6*time.Hour for the night. With days, only
+// windows starting on those weekdays are allowed.
+func (f *TimeWindowFilter) Daily(start, end time.Duration, days ...time.Weekday) *TimeWindowFilter {
+	schedule := dailySchedule{start: start, end: end}
+	for i := range schedule.days {
+		schedule.days[i] = len(days) == 0
+	}
+	for _, day := range days {
+		schedule.days[day] = true
+	}
+	f.schedules = append(f.schedules, schedule)
+	return f
+}
+
+// ShouldTrace determines if an event should be traced
+func (f *TimeWindowFilter) ShouldTrace(event Event) bool {
+	return f.open(time.Now())
+}
+
+// ShouldCapture implements PreFilter
+func (f *TimeWindowFilter) ShouldCapture(probe Event) bool {
+	return f.ShouldTrace(probe)
+}
+
+// open reports whether now is within a window
+func (f *TimeWindowFilter) open(now time.Time) bool {
+	if len(f.ranges) == 0 && len(f.schedules) == 0 {
+		return true
+	}
+	for _, r := range f.ranges {
+		if !now.Before(r.start) && now.Before(r.end) {
+			return true
+		}
+	}
+
+	now = now.In(f.location)
+	offset := time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute +
+		time.Duration(now.Second())*time.Second
+	yesterday := (now.Weekday() + 6) % 7
+	for _, s := range f.schedules {
+		if s.start <= s.end {
+			if s.days[now.Weekday()] && offset >= s.start && offset < s.end {
+				return true
+			}
+			continue
+		}
+		// The window wraps around midnight
+		if s.days[now.Weekday()] && offset >= s.start {
+			return true
+		}
+		if s.days[yesterday] && offset < s.end {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseTimeWindows creates a time window filter from specs, reading daily
+// schedules in location. A spec is a daily schedule, optionally limited
+// to weekdays, or an absolute range of RFC 3339 times:
+//
+//	22:00-06:00
+//	mon-fri 09:00-17:30
+//	sat,sun 00:00-24:00
+//	2024-05-01T10:00:00Z/2024-05-01T12:00:00Z
+func ParseTimeWindows(location *time.Location, specs ...string) (*TimeWindowFilter, error) {
+	f := NewTimeWindowFilter().In(location)
+	for _, spec := range specs {
+		if err := f.parse(strings.TrimSpace(spec)); err != nil {
+			return nil, fmt.Errorf("invalid time window %q: %w", spec, err)
+		}
+	}
+	return f, nil
+}
+
+// parse adds the window described by spec
+func (f *TimeWindowFilter) parse(spec string) error {
+	if startText, endText, ok := strings.Cut(spec, "/"); ok {
+		start, err := time.Parse(time.RFC3339, startText)
+		if err != nil {
+			return err
+		}
+		end, err := time.Parse(time.RFC3339, endText)
+		if err != nil {
+			return err
+		}
+		f.Between(start, end)
+		return nil
+	}
+
+	var days []time.Weekday
+	hours := spec
+	if dayText, rest, ok := strings.Cut(spec, " "); ok {
+		var err error
+		if days, err = parseWeekdays(dayText); err != nil {
+			return err
+		}
+		hours = strings.TrimSpace(rest)
+	}
+
+	startText, endText, ok := strings.Cut(hours, "-")
+	if !ok {
+		return fmt.Errorf("expected start-end, e.g. 22:00-06:00")
+	}
+	start, err := parseTimeOfDay(startText)
+	if err != nil {
+		return err
+	}
+	end, err := parseTimeOfDay(endText)
+	if err != nil {
+		return err
+	}
+	f.Daily(start, end, days...)
+	return nil
+}
+
+// parseTimeOfDay parses hh:mm as an offset from midnight, up to 24:00
+func parseTimeOfDay(text string) (time.Duration, error) {
+	var hours, minutes int
+	if _, err := fmt.Sscanf(text, "%d:%d", &hours, &minutes); err != nil {
+		return 0, fmt.Errorf("invalid time of day %q", text)
+	}
+	offset := time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute
+	if hours < 0 || minutes < 0 || minutes > 59 || offset > 24*time.Hour {
+		return 0, fmt.Errorf("invalid time of day %q", text)
+	}
+	return offset, nil
+}
+
+// weekdayNames maps abbreviated weekday names to weekdays
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parseWeekdays parses comma-separated weekdays and ranges, e.g. mon-fri
+func parseWeekdays(text string) ([]time.Weekday, error) {
+	var days []time.Weekday
+	for _, item := range strings.Split(strings.ToLower(text), ",") {
+		firstName, lastName, isRange := strings.Cut(item, "-")
+		first, ok := weekdayNames[firstName]
+		if !ok {
+			return nil, fmt.Errorf("unknown weekday %q", firstName)
+		}
+		if !isRange {
+			days = append(days, first)
+			continue
+		}
+		last, ok := weekdayNames[lastName]
+		if !ok {
+			return nil, fmt.Errorf("unknown weekday %q", lastName)
+		}
+		for day := first; ; day = (day + 1) % 7 {
+			days = append(days, day)
+			if day == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
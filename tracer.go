@@ -1,20 +1,90 @@
 package lens
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // TracerImpl is the concrete implementation of the Tracer interface
 type TracerImpl struct {
-	level   Level
-	enabled bool
-	writers []Writer
-	filters []Filter
-	mutex   sync.RWMutex
+	level      Level
+	enabled    bool
+	writers    []Writer
+	filters    []Filter
+	processors []Processor
+	enrichers  []ContextEnricher
+	snapshots  map[snapshotKey]stateSnapshot
+	reaper     *spanReaper
+	depths     *depthTracker
+	mutex      sync.RWMutex
+	// Channel operations blocked longer than this emit EventBlocked
+	blockThreshold time.Duration
+	// Wrapped calls running longer than this emit EventSlowCall
+	slowCallThreshold time.Duration
+	// Calls recursing this deep emit EventRecursion, 0 for no limit
+	recursionLimit int
+	// Record heap allocation deltas on return events
+	memoryStats bool
+	// Label goroutines running wrapped calls for CPU profiles
+	pprofLabels bool
+	// Run wrapped calls as runtime/trace tasks and regions
+	runtimeTrace bool
+	// Record arguments by parameter name
+	paramNames bool
+	// Only count wrapped calls, see WithCountsOnly
+	countsOnly atomic.Bool
+	counters   callCounters
+	// Events allowed per window, see WithQuota
+	quota *quotaGuard
+	// Conditional triggers and the verbose window they can open, with the
+	// level to restore once it closes
+	triggers     []*Trigger
+	verboseUntil atomic.Int64
+	verboseLevel Level
+	// Components contributed by the last applied Config
+	configured *configComponents
+	// Extractors recording context values as span tags
+	spanExtractors []SpanExtractor
+	// Reads the trace ID of another tracing system from contexts
+	externalTraceID func(ctx context.Context) string
+	// Header formats of Inject and Extract, see WithPropagators
+	propagator Propagator
+	// Saves aggregated state across restarts, see WithStateFile
+	stateFile *stateFile
+	// Filters installed through the admin handler
+	adminFilters []Filter
+	// Per-writer event queues
+	dispatcher dispatcher
+	// Writes still pending, and whether Close has stopped new ones
+	inflight sync.WaitGroup
+	closed   bool
+	// The first Close runs the shutdown; later calls wait for its result
+	closeOnce sync.Once
+	closeDone chan struct{}
+	closeErr  error
+	// Close automatically on os.Interrupt within this timeout
+	closeOnInterrupt time.Duration
+	// Latency budgets by function name
+	budgets map[string]time.Duration
+	// Normalizes source paths, nil to keep them absolute
+	paths *pathTrimmer
+	// Converts captured values before they are recorded
+	encoder ValueEncoder
+	// Functions muted at runtime
+	switches functionSwitches
+	// Record receiver field changes made by methods wrapped with WrapMethod
+	receiverState bool
+	// The tracer a Named child sends its events through, the component it
+	// stamps on them, and whether SetLevel gave it a level of its own
+	parent    *TracerImpl
+	component string
+	ownLevel  bool
 }
 
 // Wrap wraps any object to enable tracing
@@ -24,7 +94,7 @@ func (t *TracerImpl) Wrap(obj interface{}) interface{} {
 
 // WrapWithName wraps an object with a specific name for tracing
 func (t *TracerImpl) WrapWithName(obj interface{}, name string) interface{} {
-	if !t.enabled {
+	if compiledOut || !t.enabled {
 		return obj
 	}
 
@@ -127,13 +197,27 @@ func (sw *structWrapper) wrapMethods(structPtr reflect.Value) {
 // createMethodWrapper creates a wrapper for a specific method
 func (sw *structWrapper) createMethodWrapper(method reflect.Value, methodName string) reflect.Value {
 	methodType := method.Type()
+	counter := sw.tracer.counter(methodName)
 
 	wrapper := reflect.MakeFunc(methodType, func(args []reflect.Value) []reflect.Value {
-		// Convert args to interface{} slice
-		argInterfaces := make([]interface{}, len(args))
+		if sw.tracer.counting(sw.name) {
+			return counter.call(method, args)
+		}
+
+		// Skip capture entirely for calls that would be dropped
+		traceID, ok := sw.tracer.captureCall(sw.name, methodName, EventMethodCall, EventFunctionReturn)
+		if !ok {
+			return callFunc(method, args)
+		}
+		captureStart := overhead.capture.start()
+
+		// Convert args to interface{} slice, sharing one allocation with
+		// the results
+		values := make([]interface{}, len(args)+methodType.NumOut())
+		argInterfaces := values[:len(args):len(args)]
 		for i, arg := range args {
 			if arg.CanInterface() {
-				argInterfaces[i] = arg.Interface()
+				argInterfaces[i] = summarizeValue(arg)
 			}
 		}
 
@@ -141,7 +225,9 @@ func (sw *structWrapper) createMethodWrapper(method reflect.Value, methodName st
 		sourceLocation := getSourceLocation(2)
 		callerLocation := getCallerLocation(2)
 
-		traceID := generateTraceID()
+		goroutine := getGoroutineID()
+		depth, recursion := sw.tracer.depths.enter(goroutine, traceID, methodName)
+		defer sw.tracer.depths.exit(goroutine)
 
 		// Trace method call
 		callEvent := Event{
@@ -151,8 +237,10 @@ func (sw *structWrapper) createMethodWrapper(method reflect.Value, methodName st
 			Type:           EventMethodCall,
 			Component:      sw.name,
 			Function:       methodName,
-			Arguments:      argInterfaces,
-			Goroutine:      getGoroutineID(),
+			Arguments:      sw.tracer.encodeValues(argInterfaces),
+			Goroutine:      goroutine,
+			Depth:          depth,
+			RecursionDepth: recursion,
 			SourceFile:     sourceLocation.File,
 			SourceLine:     sourceLocation.Line,
 			SourceFunction: sourceLocation.Function,
@@ -161,20 +249,42 @@ func (sw *structWrapper) createMethodWrapper(method reflect.Value, methodName st
 			CallerFunction: callerLocation.Function,
 		}
 
-		sw.tracer.TraceEvent(callEvent)
+		ctx := contextFromArgs(argInterfaces)
+		callEvent = sw.tracer.enrich(ctx, callEvent)
+		overhead.capture.stop(captureStart)
+		release := sw.tracer.traceCall(callEvent)
+		sw.tracer.checkRecursion(callEvent)
+
+		receiver := sw.captureReceiver()
+
+		var mem memSample
+		if sw.tracer.memoryStats {
+			mem = readMemSample()
+		}
 
 		start := time.Now()
 
 		// Call the original method
-		results := method.Call(args)
+		var results []reflect.Value
+		watched := sw.tracer.watchSlowCall(callEvent)
+		sw.tracer.invoke(ctx, traceID, methodName, depth, func() {
+			defer watched()
+			results = callFunc(method, args)
+		})
 
 		duration := time.Since(start)
+		captureStart = overhead.capture.start()
+
+		var allocs memSample
+		if sw.tracer.memoryStats {
+			allocs = mem.since()
+		}
 
 		// Convert results to interface{} slice
-		resultInterfaces := make([]interface{}, len(results))
+		resultInterfaces := values[len(args):]
 		for i, result := range results {
 			if result.CanInterface() {
-				resultInterfaces[i] = result.Interface()
+				resultInterfaces[i] = summarizeValue(result)
 			}
 		}
 
@@ -186,9 +296,13 @@ func (sw *structWrapper) createMethodWrapper(method reflect.Value, methodName st
 			Type:           EventFunctionReturn,
 			Component:      sw.name,
 			Function:       methodName,
-			ReturnValue:    resultInterfaces,
+			ReturnValue:    sw.tracer.encodeValues(resultInterfaces),
 			Duration:       duration,
-			Goroutine:      getGoroutineID(),
+			Allocs:         allocs.objects,
+			AllocBytes:     allocs.bytes,
+			Goroutine:      goroutine,
+			Depth:          depth,
+			RecursionDepth: recursion,
 			SourceFile:     sourceLocation.File,
 			SourceLine:     sourceLocation.Line,
 			SourceFunction: sourceLocation.Function,
@@ -197,7 +311,12 @@ func (sw *structWrapper) createMethodWrapper(method reflect.Value, methodName st
 			CallerFunction: callerLocation.Function,
 		}
 
+		returnEvent = sw.tracer.enrich(ctx, returnEvent)
+		overhead.capture.stop(captureStart)
+		release(returnEvent)
+		sw.traceReceiverChanges(receiver, callEvent)
 		sw.tracer.TraceEvent(returnEvent)
+		sw.tracer.checkBudget(returnEvent)
 
 		return results
 	})
@@ -207,28 +326,52 @@ func (sw *structWrapper) createMethodWrapper(method reflect.Value, methodName st
 
 // wrapFunction wraps a function type with automatic tracing
 func (t *TracerImpl) wrapFunction(obj interface{}, name string) interface{} {
+	return t.wrapFunctionAs(obj, name, "")
+}
+
+// wrapFunctionAs is wrapFunction tracing the function as funcName, or
+// under its runtime name if funcName is empty
+func (t *TracerImpl) wrapFunctionAs(obj interface{}, name, funcName string) interface{} {
 	objType := reflect.TypeOf(obj)
 	objValue := reflect.ValueOf(obj)
+	if objValue.IsNil() {
+		return obj
+	}
+
+	if funcName == "" {
+		funcName = functionName(objValue, name)
+	}
 
 	// Capture source location at wrap time (when the function is being wrapped)
 	wrapSourceLocation := getSourceLocation(2)
 	wrapCallerLocation := getCallerLocation(2)
 
+	var paramNames []string
+	if t.paramNames {
+		paramNames = lookupParamNames(objValue)
+	}
+	counter := t.counter(funcName)
+
 	// Create a wrapper function that automatically traces calls
 	wrapper := reflect.MakeFunc(objType, func(args []reflect.Value) []reflect.Value {
-		// Get function name from runtime
-		pc := reflect.ValueOf(obj).Pointer()
-		fn := runtime.FuncForPC(pc)
-		funcName := name
-		if fn != nil {
-			funcName = fn.Name()
+		if t.counting(name) {
+			return counter.call(objValue, args)
+		}
+
+		// Skip capture entirely for calls that would be dropped
+		traceID, ok := t.captureCall(name, funcName, EventFunctionCall, EventFunctionReturn)
+		if !ok {
+			return callFunc(objValue, args)
 		}
+		captureStart := overhead.capture.start()
 
-		// Convert args to interface{} slice
-		argInterfaces := make([]interface{}, len(args))
+		// Convert args to interface{} slice, sharing one allocation with
+		// the results
+		values := make([]interface{}, len(args)+objType.NumOut())
+		argInterfaces := values[:len(args):len(args)]
 		for i, arg := range args {
 			if arg.CanInterface() {
-				argInterfaces[i] = arg.Interface()
+				argInterfaces[i] = summarizeValue(arg)
 			}
 		}
 
@@ -236,7 +379,11 @@ func (t *TracerImpl) wrapFunction(obj interface{}, name string) interface{} {
 		sourceLocation := wrapSourceLocation
 		callerLocation := wrapCallerLocation
 
-		traceID := generateTraceID()
+		goroutine := getGoroutineID()
+		depth, recursion := t.depths.enter(goroutine, traceID, funcName)
+		defer t.depths.exit(goroutine)
+
+		arguments := t.encodeValues(argInterfaces)
 
 		// Trace function call
 		callEvent := Event{
@@ -246,8 +393,11 @@ func (t *TracerImpl) wrapFunction(obj interface{}, name string) interface{} {
 			Type:           EventFunctionCall,
 			Component:      name,
 			Function:       funcName,
-			Arguments:      argInterfaces,
-			Goroutine:      getGoroutineID(),
+			Arguments:      arguments,
+			Params:         namedArguments(paramNames, arguments),
+			Goroutine:      goroutine,
+			Depth:          depth,
+			RecursionDepth: recursion,
 			SourceFile:     sourceLocation.File,
 			SourceLine:     sourceLocation.Line,
 			SourceFunction: sourceLocation.Function,
@@ -256,20 +406,40 @@ func (t *TracerImpl) wrapFunction(obj interface{}, name string) interface{} {
 			CallerFunction: callerLocation.Function,
 		}
 
-		t.TraceEvent(callEvent)
+		ctx := contextFromArgs(argInterfaces)
+		callEvent = t.enrich(ctx, callEvent)
+		overhead.capture.stop(captureStart)
+		release := t.traceCall(callEvent)
+		t.checkRecursion(callEvent)
+
+		var mem memSample
+		if t.memoryStats {
+			mem = readMemSample()
+		}
 
 		start := time.Now()
 
 		// Call the original function
-		results := objValue.Call(args)
+		var results []reflect.Value
+		watched := t.watchSlowCall(callEvent)
+		t.invoke(ctx, traceID, funcName, depth, func() {
+			defer watched()
+			results = callFunc(objValue, args)
+		})
 
 		duration := time.Since(start)
+		captureStart = overhead.capture.start()
+
+		var allocs memSample
+		if t.memoryStats {
+			allocs = mem.since()
+		}
 
 		// Convert results to interface{} slice
-		resultInterfaces := make([]interface{}, len(results))
+		resultInterfaces := values[len(args):]
 		for i, result := range results {
 			if result.CanInterface() {
-				resultInterfaces[i] = result.Interface()
+				resultInterfaces[i] = summarizeValue(result)
 			}
 		}
 
@@ -281,9 +451,13 @@ func (t *TracerImpl) wrapFunction(obj interface{}, name string) interface{} {
 			Type:           EventFunctionReturn,
 			Component:      name,
 			Function:       funcName,
-			ReturnValue:    resultInterfaces,
+			ReturnValue:    t.encodeValues(resultInterfaces),
 			Duration:       duration,
-			Goroutine:      getGoroutineID(),
+			Allocs:         allocs.objects,
+			AllocBytes:     allocs.bytes,
+			Goroutine:      goroutine,
+			Depth:          depth,
+			RecursionDepth: recursion,
 			SourceFile:     sourceLocation.File,
 			SourceLine:     sourceLocation.Line,
 			SourceFunction: sourceLocation.Function,
@@ -292,7 +466,11 @@ func (t *TracerImpl) wrapFunction(obj interface{}, name string) interface{} {
 			CallerFunction: callerLocation.Function,
 		}
 
+		returnEvent = t.enrich(ctx, returnEvent)
+		overhead.capture.stop(captureStart)
+		release(returnEvent)
 		t.TraceEvent(returnEvent)
+		t.checkBudget(returnEvent)
 
 		return results
 	})
@@ -300,6 +478,28 @@ func (t *TracerImpl) wrapFunction(obj interface{}, name string) interface{} {
 	return wrapper.Interface()
 }
 
+// functionName returns the runtime name of fn, falling back to name.
+// Bound method values are reported under their method's name rather than
+// the compiler-generated "-fm" wrapper, and generic instantiations without
+// their placeholder type arguments.
+func functionName(fn reflect.Value, name string) string {
+	f := runtime.FuncForPC(fn.Pointer())
+	if f == nil {
+		return name
+	}
+	return demangle(strings.TrimSuffix(f.Name(), "-fm"))
+}
+
+// callFunc calls fn with the arguments received by a reflect.MakeFunc
+// wrapper. Those pass variadic arguments as a single trailing slice, which
+// must be spread with CallSlice rather than passed as one element.
+func callFunc(fn reflect.Value, args []reflect.Value) []reflect.Value {
+	if fn.Type().IsVariadic() {
+		return fn.CallSlice(args)
+	}
+	return fn.Call(args)
+}
+
 // wrapInterface wraps an interface type
 func (t *TracerImpl) wrapInterface(obj interface{}, name string) interface{} {
 	// For now, return the object as-is
@@ -323,59 +523,220 @@ func (t *TracerImpl) wrapMap(obj interface{}, name string) interface{} {
 
 // wrapChannel wraps a channel type
 func (t *TracerImpl) wrapChannel(obj interface{}, name string) interface{} {
-	// For now, return the channel as-is
-	// In a full implementation, we would create a proxy that traces channel operations
+	// Channels cannot be substituted transparently, so return the channel as-is
+	// Use NewTracedChan to opt in to traced sends and receives
 	return obj
 }
 
 // StartSpan starts a new trace span
 func (t *TracerImpl) StartSpan(name string) Span {
-	return &SpanImpl{
+	return t.newSpan(nil, name)
+}
+
+// StartSpanContext starts a new trace span bound to ctx.
+// Events emitted by the span are enriched from ctx, and the returned
+// context carries the span. If ctx is cancelled or its deadline passes
+// before the span ends, the span is ended then, tagged with its status.
+func (t *TracerImpl) StartSpanContext(ctx context.Context, name string) (context.Context, Span) {
+	span := t.newSpan(ctx, name)
+	span.endOnCancel(ctx)
+	return ContextWithSpan(ctx, span), span
+}
+
+// newSpan creates a span and registers it with the reaper, if any
+func (t *TracerImpl) newSpan(ctx context.Context, name string) *SpanImpl {
+	span := &SpanImpl{
 		name:      name,
 		startTime: time.Now(),
 		tracer:    t,
 		traceID:   generateTraceID(),
+		spanID:    generateSpanID(),
+		ctx:       ctx,
+	}
+	if ctx != nil {
+		span.tags = t.extractSpanTags(ctx)
+		// Continue the trace of a parent span, local or extracted
+		if traceID, spanID := spanContextFromContext(ctx); traceID != "" {
+			span.traceID = traceID
+			if spanID != "" {
+				if span.tags == nil {
+					span.tags = make(map[string]interface{})
+				}
+				span.tags[TagParentSpanID] = spanID
+			}
+		}
 	}
+	t.trackSpan(span)
+	return span
+}
+
+// TraceEventContext traces a single event after enriching it from ctx
+func (t *TracerImpl) TraceEventContext(ctx context.Context, event Event) {
+	t.TraceEvent(t.enrich(ctx, event))
+}
+
+// enrich applies all context enrichers to an event
+func (t *TracerImpl) enrich(ctx context.Context, event Event) Event {
+	if ctx == nil {
+		return event
+	}
+
+	t.mutex.RLock()
+	enrichers := t.enrichers
+	t.mutex.RUnlock()
+
+	for _, enricher := range enrichers {
+		event = enricher.Enrich(ctx, event)
+	}
+	if t.externalTraceID != nil && event.ExternalTraceID == "" {
+		event.ExternalTraceID = t.externalTraceID(ctx)
+	}
+	return event
 }
 
 // TraceEvent traces a single event
 func (t *TracerImpl) TraceEvent(event Event) {
-	if !t.enabled {
+	t.traceEvent(event, false)
+}
+
+// traceEvent traces an event, skipping the level check if a child tracer
+// with a level of its own already made it
+func (t *TracerImpl) traceEvent(event Event, levelChecked bool) {
+	if compiledOut || !t.enabled || t.switches.muted(event.Function) {
+		return
+	}
+	if t.parent != nil {
+		t.traceChildEvent(event, levelChecked)
 		return
 	}
 
+	// Evaluate triggers before filtering so they see every event
+	t.evaluateTriggers(event)
+
 	t.mutex.RLock()
-	defer t.mutex.RUnlock()
+	if t.closed || (!levelChecked && eventLevel(event.Type) > t.level) {
+		t.mutex.RUnlock()
+		return
+	}
+	filters, processors := t.filters, t.processors
+	t.mutex.RUnlock()
+
+	// Filters and processors run without the mutex, so they may use the
+	// tracer themselves
+	filterStart := overhead.filter.start()
+	if !t.verbose() {
+		for _, filter := range filters {
+			if !filter.ShouldTrace(event) {
+				overhead.filter.stop(filterStart)
+				return
+			}
+		}
+	}
 
-	// Apply filters
-	for _, filter := range t.filters {
-		if !filter.ShouldTrace(event) {
+	if t.paths != nil {
+		t.paths.apply(&event)
+	}
+
+	for _, processor := range processors {
+		event = processor.Process(event)
+	}
+	overhead.filter.stop(filterStart)
+	event.SchemaVersion = SchemaVersion
+
+	// Queue for all writers in emission order. The writers are read again
+	// so a writer removed meanwhile gets nothing more, and under the mutex
+	// so Close waits for the event.
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	if t.closed {
+		return
+	}
+	if t.quota != nil {
+		ok, notice := t.quota.admit(event)
+		if notice != nil {
+			t.dispatcher.dispatch(t.writers, *notice, &t.inflight)
+		}
+		if !ok {
 			return
 		}
 	}
+	t.dispatcher.dispatch(t.writers, event, &t.inflight)
+}
 
-	// Write to all writers
-	for _, writer := range t.writers {
-		go func(w Writer) {
-			w.Write(event)
-		}(writer)
+// Enter traces a call to function and returns a func that traces its return:
+//
+//	defer tracer.Enter("billing.Charge")()
+func (t *TracerImpl) Enter(function string) func() {
+	if t.counting("") {
+		counter := t.counter(function)
+		start := time.Now()
+		return func() { counter.record(time.Since(start)) }
+	}
+
+	traceID, ok := t.captureCall("", function, EventFunctionCall, EventFunctionReturn)
+	if !ok {
+		return func() {}
+	}
+
+	sourceLocation := getSourceLocation(2)
+	callerLocation := getCallerLocation(2)
+	goroutine := getGoroutineID()
+	depth, recursion := t.depths.enter(goroutine, traceID, function)
+	start := time.Now()
+
+	event := Event{
+		ID:             generateEventID(),
+		TraceID:        traceID,
+		Timestamp:      start,
+		Type:           EventFunctionCall,
+		Function:       function,
+		Goroutine:      goroutine,
+		Depth:          depth,
+		RecursionDepth: recursion,
+		SourceFile:     sourceLocation.File,
+		SourceLine:     sourceLocation.Line,
+		SourceFunction: sourceLocation.Function,
+		CallerFile:     callerLocation.File,
+		CallerLine:     callerLocation.Line,
+		CallerFunction: callerLocation.Function,
+	}
+	release := t.traceCall(event)
+	t.checkRecursion(event)
+	watched := t.watchSlowCall(event)
+
+	return func() {
+		watched()
+		t.depths.exit(goroutine)
+		event.ID = generateEventID()
+		event.Timestamp = time.Now()
+		event.Type = EventFunctionReturn
+		event.Duration = time.Since(start)
+		release(event)
+		t.TraceEvent(event)
+		t.checkBudget(event)
 	}
 }
 
-// TraceVariable traces a variable change
+// TraceVariable traces a variable change. When both values are structs
+// or maps of the same type, only the fields that changed are recorded, as
+// the event's Changes, instead of both values; Changes is empty, not nil,
+// if nothing changed.
 func (t *TracerImpl) TraceVariable(name string, oldVal, newVal interface{}) {
+	traceID := generateTraceID()
+	if !t.shouldCapture(Event{TraceID: traceID, Variable: name}, EventVariableWrite) {
+		return
+	}
+
 	// Get source location information
 	sourceLocation := getSourceLocation(2)
 	callerLocation := getCallerLocation(2)
 
 	event := Event{
 		ID:             generateEventID(),
-		TraceID:        generateTraceID(),
+		TraceID:        traceID,
 		Timestamp:      time.Now(),
 		Type:           EventVariableWrite,
 		Variable:       name,
-		OldValue:       oldVal,
-		NewValue:       newVal,
 		Goroutine:      getGoroutineID(),
 		SourceFile:     sourceLocation.File,
 		SourceLine:     sourceLocation.Line,
@@ -385,35 +746,128 @@ func (t *TracerImpl) TraceVariable(name string, oldVal, newVal interface{}) {
 		CallerFunction: callerLocation.Function,
 	}
 
+	// Structs and maps are recorded as the fields that changed, which
+	// stays readable however large the value; none if an equal value was
+	// written
+	if changes, ok := diffValues(oldVal, newVal); ok {
+		for i := range changes {
+			changes[i].Old = t.encodeValue(changes[i].Old)
+			changes[i].New = t.encodeValue(changes[i].New)
+		}
+		event.Changes = changes
+	} else {
+		event.OldValue = t.encodeValue(oldVal)
+		event.NewValue = t.encodeValue(newVal)
+	}
+
 	t.TraceEvent(event)
 }
 
+// TraceFieldAccess traces a read of a field, as done by the accessors
+// lensgen generates
+func (t *TracerImpl) TraceFieldAccess(name string, value interface{}) {
+	traceID := generateTraceID()
+	if !t.shouldCapture(Event{TraceID: traceID, Variable: name}, EventFieldAccess) {
+		return
+	}
+
+	sourceLocation := getSourceLocation(2)
+	callerLocation := getCallerLocation(2)
+
+	t.TraceEvent(Event{
+		ID:             generateEventID(),
+		TraceID:        traceID,
+		Timestamp:      time.Now(),
+		Type:           EventFieldAccess,
+		Variable:       name,
+		NewValue:       t.encodeValue(value),
+		Goroutine:      getGoroutineID(),
+		SourceFile:     sourceLocation.File,
+		SourceLine:     sourceLocation.Line,
+		SourceFunction: sourceLocation.Function,
+		CallerFile:     callerLocation.File,
+		CallerLine:     callerLocation.Line,
+		CallerFunction: callerLocation.Function,
+	})
+}
+
 // SetLevel sets the tracing level
 func (t *TracerImpl) SetLevel(level Level) {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
 	t.level = level
+	t.ownLevel = true
 }
 
-// AddWriter adds a writer to the tracer
+// AddWriter adds a writer to the tracer. Writers can be added and removed
+// while events are being traced.
 func (t *TracerImpl) AddWriter(writer Writer) {
+	t = t.root()
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
-	t.writers = append(t.writers, writer)
+	t.writers = appendCopy(t.writers, writer)
+}
+
+// RemoveWriter removes a writer from the tracer, waiting for the events
+// already queued for it to be written, and flushes it. The writer is not
+// closed: having removed it, the caller owns it.
+func (t *TracerImpl) RemoveWriter(writer Writer) error {
+	t = t.root()
+	t.mutex.Lock()
+	t.writers = removeItems(t.writers, []Writer{writer})
+	t.mutex.Unlock()
+
+	// Events dispatched from now on no longer include the writer
+	t.dispatcher.remove([]Writer{writer})
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("failed to flush %T: %w", writer, err)
+	}
+	return nil
+}
+
+// Writers returns the tracer's writers in the order they were added
+func (t *TracerImpl) Writers() []Writer {
+	t = t.root()
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return append([]Writer(nil), t.writers...)
 }
 
 // AddFilter adds a filter to the tracer
 func (t *TracerImpl) AddFilter(filter Filter) {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
-	t.filters = append(t.filters, filter)
+	t.filters = appendCopy(t.filters, filter)
+}
+
+// RemoveFilter removes a filter added with AddFilter. Events traced
+// concurrently may still be checked against it.
+func (t *TracerImpl) RemoveFilter(filter Filter) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.filters = removeItems(t.filters, []Filter{filter})
+}
+
+// AddProcessor adds a processor to the tracer
+func (t *TracerImpl) AddProcessor(processor Processor) {
+	t = t.root()
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.processors = appendCopy(t.processors, processor)
+}
+
+// appendCopy appends to a copy of items. The writer, filter and processor
+// slices are never modified in place, so events being traced can keep
+// using the slices they started with without holding the mutex.
+func appendCopy[T any](items []T, add ...T) []T {
+	return append(items[:len(items):len(items)], add...)
 }
 
-// Enable enables tracing
+// Enable enables tracing, unless built with the lens_disabled tag
 func (t *TracerImpl) Enable() {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
-	t.enabled = true
+	t.enabled = !compiledOut
 }
 
 // Disable disables tracing
@@ -429,17 +883,56 @@ type SpanImpl struct {
 	startTime time.Time
 	tracer    *TracerImpl
 	traceID   string
+	spanID    string
 	tags      map[string]interface{}
+	links     []Link
 	error     error
+	status    StatusCode
+	statusMsg string
+	ctx       context.Context
+	// stopCancel stops ending the span when ctx is cancelled
+	stopCancel func() bool
+	ended      bool
+	mutex      sync.Mutex
 }
 
 // End ends the span
 func (s *SpanImpl) End() {
+	s.finish(nil)
+}
+
+// finish emits the span's end event once, merging extra tags into it
+func (s *SpanImpl) finish(extraTags map[string]interface{}) {
+	s.mutex.Lock()
+	if s.ended {
+		s.mutex.Unlock()
+		return
+	}
+	s.ended = true
+	if s.stopCancel != nil {
+		s.stopCancel()
+	}
+	spanErr := s.error
+	status, statusMsg := s.status, s.statusMsg
+	links := s.links
+	tags := make(map[string]interface{}, len(s.tags)+len(extraTags))
+	for k, v := range s.tags {
+		tags[k] = v
+	}
+	s.mutex.Unlock()
+
+	for k, v := range extraTags {
+		tags[k] = v
+	}
+
+	s.tracer.untrackSpan(s)
+
 	duration := time.Since(s.startTime)
 
 	event := Event{
 		ID:        generateEventID(),
 		TraceID:   s.traceID,
+		SpanID:    s.spanID,
 		Timestamp: time.Now(),
 		Type:      EventFunctionReturn,
 		Function:  s.name,
@@ -447,32 +940,108 @@ func (s *SpanImpl) End() {
 		Goroutine: getGoroutineID(),
 	}
 
-	if s.error != nil {
-		event.Error = s.error.Error()
+	if status != StatusUnset {
+		tags[TagStatusCode] = status.String()
+		if statusMsg != "" {
+			tags[TagStatusMessage] = statusMsg
+		}
+	}
+	switch {
+	case status == StatusOK && spanErr != nil:
+		tags[TagExceptionMessage] = spanErr.Error()
+	case spanErr != nil:
+		event.Error = spanErr.Error()
+		event.Type = EventError
+	case status == StatusError:
+		event.Error = statusMsg
+		if event.Error == "" {
+			event.Error = "error"
+		}
 		event.Type = EventError
 	}
 
-	s.tracer.TraceEvent(event)
+	if len(tags) > 0 {
+		event.Tags = tags
+	}
+	event.Links = links
+
+	s.tracer.TraceEvent(s.tracer.enrich(s.ctx, event))
 }
 
 // SetTag sets a tag on the span
 func (s *SpanImpl) SetTag(key string, value interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 	if s.tags == nil {
 		s.tags = make(map[string]interface{})
 	}
 	s.tags[key] = value
 }
 
+// AddEvent emits an intermediate event within the span
+func (s *SpanImpl) AddEvent(name string, attrs map[string]interface{}) {
+	tags := make(map[string]interface{}, len(attrs)+1)
+	for k, v := range attrs {
+		tags[k] = v
+	}
+	tags[TagEventName] = name
+
+	event := Event{
+		ID:        generateEventID(),
+		TraceID:   s.traceID,
+		SpanID:    s.spanID,
+		Timestamp: time.Now(),
+		Type:      EventSpanEvent,
+		Function:  s.name,
+		Goroutine: getGoroutineID(),
+		Tags:      tags,
+	}
+
+	s.tracer.TraceEvent(s.tracer.enrich(s.ctx, event))
+}
+
+// Mark emits a progress marker within the span, an event named name
+// recording the time since the span started, so a long operation can be
+// broken into phases without a child span for each
+func (s *SpanImpl) Mark(name string) {
+	s.AddEvent(name, map[string]interface{}{TagSpanElapsed: time.Since(s.startTime).String()})
+}
+
+// Annotate emits an event named key within the span, carrying value as
+// the key's tag. Unlike SetTag, which sets a tag on the span's end event,
+// it records when the value became known.
+func (s *SpanImpl) Annotate(key string, value interface{}) {
+	s.AddEvent(key, map[string]interface{}{key: value})
+}
+
+// AddLink records a reference to a span in another trace
+func (s *SpanImpl) AddLink(traceID, spanID string, attrs map[string]interface{}) {
+	link := Link{TraceID: traceID, SpanID: spanID}
+	if len(attrs) > 0 {
+		link.Attributes = make(map[string]interface{}, len(attrs))
+		for k, v := range attrs {
+			link.Attributes[k] = v
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.links = append(s.links, link)
+}
+
 // SetError sets an error on the span
 func (s *SpanImpl) SetError(err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 	s.error = err
 }
 
-// Helper functions for generating IDs
-func generateEventID() string {
-	return fmt.Sprintf("evt_%d", time.Now().UnixNano())
-}
-
-func generateTraceID() string {
-	return fmt.Sprintf("trace_%d", time.Now().UnixNano())
+// SetStatus sets the status of the span. StatusError fails the span with
+// message as its error, unless SetError set one; StatusOK keeps an error
+// set with SetError as a tag rather than failing the span.
+func (s *SpanImpl) SetStatus(code StatusCode, message string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.status = code
+	s.statusMsg = message
 }
@@ -0,0 +1,231 @@
+package lens
+
+import (
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TriggerAction is run when a trigger's condition matches an event
+type TriggerAction func(tracer *TracerImpl, trigger *Trigger, event Event)
+
+// Trigger reacts to events matching a condition by running actions,
+// turning the tracer into a conditional "printf debugger"
+type Trigger struct {
+	Name      string
+	Condition func(event Event) bool
+	Actions   []TriggerAction
+	// Cooldown is the minimum time between two firings
+	Cooldown time.Duration
+
+	lastFired time.Time
+	mutex     sync.Mutex
+}
+
+// NewTrigger creates a new trigger
+func NewTrigger(name string, condition func(event Event) bool, actions ...TriggerAction) *Trigger {
+	return &Trigger{
+		Name:      name,
+		Condition: condition,
+		Actions:   actions,
+	}
+}
+
+// WithCooldown sets the minimum time between two firings
+func (tr *Trigger) WithCooldown(cooldown time.Duration) *Trigger {
+	tr.Cooldown = cooldown
+	return tr
+}
+
+// shouldFire checks the condition and cooldown, recording the firing
+func (tr *Trigger) shouldFire(event Event) bool {
+	if !tr.Condition(event) {
+		return false
+	}
+
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	now := time.Now()
+	if tr.Cooldown > 0 && !tr.lastFired.IsZero() && now.Sub(tr.lastFired) < tr.Cooldown {
+		return false
+	}
+	tr.lastFired = now
+	return true
+}
+
+// AddTrigger registers a trigger with the tracer
+func (t *TracerImpl) AddTrigger(trigger *Trigger) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.triggers = append(t.triggers, trigger)
+}
+
+// WithTrigger registers a trigger with the tracer
+func WithTrigger(trigger *Trigger) Option {
+	return func(t *TracerImpl) {
+		t.triggers = append(t.triggers, trigger)
+	}
+}
+
+// evaluateTriggers fires every trigger whose condition matches event
+func (t *TracerImpl) evaluateTriggers(event Event) {
+	// Events emitted by triggers never re-trigger
+	if event.Type == EventTrigger {
+		return
+	}
+
+	t.mutex.RLock()
+	triggers := t.triggers
+	t.mutex.RUnlock()
+
+	for _, trigger := range triggers {
+		if trigger.shouldFire(event) {
+			for _, action := range trigger.Actions {
+				action(t, trigger, event)
+			}
+		}
+	}
+}
+
+// verbose reports whether a trigger-opened verbose window is active
+func (t *TracerImpl) verbose() bool {
+	until := t.verboseUntil.Load()
+	return until != 0 && time.Now().UnixNano() < until
+}
+
+// Built-in trigger actions
+
+// DumpStack emits a trigger event carrying the stacks of all goroutines
+func DumpStack() TriggerAction {
+	return func(tracer *TracerImpl, trigger *Trigger, event Event) {
+		buf := make([]byte, 64*1024)
+		for {
+			n := runtime.Stack(buf, true)
+			if n < len(buf) {
+				buf = buf[:n]
+				break
+			}
+			buf = make([]byte, len(buf)*2)
+		}
+
+		tracer.TraceEvent(triggerEvent(trigger, event, strings.Split(string(buf), "\n")))
+	}
+}
+
+// EnableVerbose bypasses all filters and raises the level to LevelTrace for
+// duration, then restores the level set before the window opened. Firing
+// again while the window is open extends it.
+func EnableVerbose(duration time.Duration) TriggerAction {
+	return func(tracer *TracerImpl, trigger *Trigger, event Event) {
+		tracer = tracer.root()
+		until := time.Now().Add(duration).UnixNano()
+
+		tracer.mutex.Lock()
+		if tracer.verboseUntil.Load() == 0 {
+			tracer.verboseLevel = tracer.level
+			tracer.level = LevelTrace
+		}
+		tracer.verboseUntil.Store(until)
+		tracer.mutex.Unlock()
+
+		tracer.TraceEvent(triggerEvent(trigger, event, nil))
+
+		time.AfterFunc(duration, func() {
+			tracer.mutex.Lock()
+			defer tracer.mutex.Unlock()
+			// A later firing extended the window and restores the level
+			if tracer.verboseUntil.Load() == until {
+				tracer.level = tracer.verboseLevel
+				tracer.verboseUntil.Store(0)
+			}
+		})
+	}
+}
+
+// Callback calls fn with the matching event
+func Callback(fn func(event Event)) TriggerAction {
+	return func(tracer *TracerImpl, trigger *Trigger, event Event) {
+		fn(event)
+	}
+}
+
+// triggerEvent builds the event recording a trigger firing
+func triggerEvent(trigger *Trigger, cause Event, stack []string) Event {
+	return Event{
+		ID:         generateEventID(),
+		TraceID:    cause.TraceID,
+		Timestamp:  time.Now(),
+		Type:       EventTrigger,
+		Component:  cause.Component,
+		Function:   cause.Function,
+		Variable:   trigger.Name,
+		Duration:   cause.Duration,
+		StackTrace: stack,
+		Goroutine:  getGoroutineID(),
+	}
+}
+
+// Convenience functions for building trigger conditions
+
+// FunctionIs matches events for the given function, by full name or by
+// the last name segment (e.g. "Save" matches "main.(*User).Save")
+func FunctionIs(name string) func(event Event) bool {
+	return func(event Event) bool {
+		if event.Function == name {
+			return true
+		}
+		return strings.HasSuffix(event.Function, "."+name)
+	}
+}
+
+// DurationOver matches events whose duration exceeds d
+func DurationOver(d time.Duration) func(event Event) bool {
+	return func(event Event) bool {
+		return event.Duration > d
+	}
+}
+
+// TypeIs matches events of any of the given types
+func TypeIs(types ...EventType) func(event Event) bool {
+	return func(event Event) bool {
+		for _, t := range types {
+			if event.Type == t {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// HasError matches events that carry an error
+func HasError() func(event Event) bool {
+	return func(event Event) bool {
+		return event.Error != "" || event.Type == EventError || event.Type == EventPanic
+	}
+}
+
+// All matches when every condition matches
+func All(conditions ...func(event Event) bool) func(event Event) bool {
+	return func(event Event) bool {
+		for _, condition := range conditions {
+			if !condition(event) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Any matches when at least one condition matches
+func Any(conditions ...func(event Event) bool) func(event Event) bool {
+	return func(event Event) bool {
+		for _, condition := range conditions {
+			if condition(event) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,37 @@
+package lens
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// levels returns the tracer's level and whether it was set explicitly
+func levels(t *TracerImpl) (Level, bool) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return t.level, t.ownLevel
+}
+
+func TestEnableVerboseRestoresLevelAfterRefiring(t *testing.T) {
+	const window = 50 * time.Millisecond
+	tracer := New(WithTrigger(NewTrigger("errors", TypeIs(EventError), EnableVerbose(window))))
+	defer tracer.Close(context.Background())
+	before, _ := levels(tracer)
+
+	tracer.TraceEvent(Event{Type: EventError})
+	if level, _ := levels(tracer); level != LevelTrace {
+		t.Fatalf("level = %v in the window, want %v", level, LevelTrace)
+	}
+	time.Sleep(window / 2)
+	tracer.TraceEvent(Event{Type: EventError})
+
+	deadline := time.Now().Add(10 * window)
+	for tracer.verbose() && time.Now().Before(deadline) {
+		time.Sleep(window / 10)
+	}
+	time.Sleep(window / 2)
+	if level, own := levels(tracer); level != before || own {
+		t.Errorf("level = %v (explicit %t) after the window, want %v", level, own, before)
+	}
+}
@@ -0,0 +1,194 @@
+package lens
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed viewer.html
+var viewerPage []byte
+
+// ViewerSource loads the events shown by a viewer. It is called for every
+// API request, so traces written since the page was opened appear on
+// reload.
+type ViewerSource func(ctx context.Context) ([]Event, error)
+
+// TraceSummary describes one trace in the viewer's trace list
+type TraceSummary struct {
+	TraceID  string        `json:"trace_id"`
+	Root     string        `json:"root"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Events   int           `json:"events"`
+	Errors   int           `json:"errors"`
+}
+
+// NewViewerHandler returns an HTTP handler serving a trace viewer for the
+// events returned by source: a searchable trace list, a waterfall of the
+// selected trace and the details of each event.
+//
+//	GET /                   the viewer page
+//	GET /api/traces         trace summaries, newest first; ?q= searches
+//	                        functions, components, errors and tags
+//	GET /api/traces/{id}    the events of one trace in emission order
+//
+// Mount it under a prefix with http.StripPrefix:
+//
+//	mux.Handle("/debug/lens/viewer/", http.StripPrefix("/debug/lens/viewer", lens.NewViewerHandler(source)))
+func NewViewerHandler(source ViewerSource) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(viewerPage)
+	})
+
+	mux.HandleFunc("GET /api/traces", func(w http.ResponseWriter, r *http.Request) {
+		events, err := source(r.Context())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to load events: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		limit := 500
+		if value := r.URL.Query().Get("limit"); value != "" {
+			if limit, err = strconv.Atoi(value); err != nil || limit < 1 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+		}
+
+		summaries := summarizeTraces(events, r.URL.Query().Get("q"))
+		if len(summaries) > limit {
+			summaries = summaries[:limit]
+		}
+		writeAdminJSON(w, summaries)
+	})
+
+	mux.HandleFunc("GET /api/traces/{id}", func(w http.ResponseWriter, r *http.Request) {
+		events, err := source(r.Context())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to load events: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		traceID := r.PathValue("id")
+		trace := []Event{}
+		for _, event := range events {
+			if event.TraceID == traceID {
+				trace = append(trace, event)
+			}
+		}
+		if len(trace) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+		sortViewerEvents(trace)
+		writeAdminJSON(w, trace)
+	})
+
+	return mux
+}
+
+// ViewerHandler returns a viewer for the events held by the tracer's
+// RingBufferWriter writers, for looking at recent traces of a running
+// process. See NewViewerHandler.
+func (t *TracerImpl) ViewerHandler() http.Handler {
+	t = t.root()
+	return NewViewerHandler(func(ctx context.Context) ([]Event, error) {
+		t.mutex.RLock()
+		writers := append([]Writer(nil), t.writers...)
+		t.mutex.RUnlock()
+
+		var events []Event
+		for _, writer := range writers {
+			if buffer, ok := writer.(*RingBufferWriter); ok {
+				events = append(events, buffer.Events()...)
+			}
+		}
+		return events, nil
+	})
+}
+
+// summarizeTraces groups events by trace, keeping traces with an event
+// matching query, newest first
+func summarizeTraces(events []Event, query string) []TraceSummary {
+	query = strings.ToLower(strings.TrimSpace(query))
+
+	traces := make(map[string]*TraceSummary)
+	ends := make(map[string]time.Time)
+	matched := make(map[string]bool)
+	var order []string
+
+	for _, event := range events {
+		summary, ok := traces[event.TraceID]
+		if !ok {
+			summary = &TraceSummary{TraceID: event.TraceID}
+			traces[event.TraceID] = summary
+			order = append(order, event.TraceID)
+		}
+
+		start := event.Timestamp.Add(-event.Duration)
+		if summary.Start.IsZero() || start.Before(summary.Start) {
+			summary.Start = start
+			summary.Root = event.Function
+		}
+		if event.Timestamp.After(ends[event.TraceID]) {
+			ends[event.TraceID] = event.Timestamp
+		}
+		summary.Events++
+		if event.Type == EventError || event.Type == EventPanic || event.Error != "" {
+			summary.Errors++
+		}
+
+		if query == "" || viewerMatch(event, query) {
+			matched[event.TraceID] = true
+		}
+	}
+
+	summaries := make([]TraceSummary, 0, len(matched))
+	for _, traceID := range order {
+		if !matched[traceID] {
+			continue
+		}
+		summary := traces[traceID]
+		summary.Duration = ends[traceID].Sub(summary.Start)
+		summaries = append(summaries, *summary)
+	}
+
+	sort.SliceStable(summaries, func(i, j int) bool {
+		return summaries[i].Start.After(summaries[j].Start)
+	})
+	return summaries
+}
+
+// viewerMatch reports whether an event contains the lower-cased query
+func viewerMatch(event Event, query string) bool {
+	fields := []string{event.TraceID, event.Function, event.Component, event.Variable, event.Error, string(event.Type)}
+	for _, value := range event.Tags {
+		fields = append(fields, fmt.Sprint(value))
+	}
+	for _, field := range fields {
+		if strings.Contains(strings.ToLower(field), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortViewerEvents orders events as they were emitted, by sequence number
+// where recorded and otherwise by timestamp
+func sortViewerEvents(events []Event) {
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].Seq != 0 && events[j].Seq != 0 {
+			return events[i].Seq < events[j].Seq
+		}
+		return events[i].Timestamp.Before(events[j].Timestamp)
+	})
+}
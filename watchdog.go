@@ -0,0 +1,112 @@
+package lens
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Tags set on EventSlowCall events
+const (
+	TagSlowCallThreshold = "slow_call.threshold"
+	TagSlowCallReturned  = "slow_call.returned"
+)
+
+// WithSlowCallThreshold starts a watchdog for every traced call. A call
+// still running after threshold emits an EventSlowCall with the stack of
+// its goroutine, showing where it is stuck, and another tagged
+// slow_call.returned once it returns, if it ever does. Unlike budgets,
+// which are checked on return, this catches calls that hang.
+func WithSlowCallThreshold(threshold time.Duration) Option {
+	return func(t *TracerImpl) {
+		t.slowCallThreshold = threshold
+	}
+}
+
+// watchSlowCall starts the watchdog for a traced call. The returned func
+// must be called when the call returns.
+func (t *TracerImpl) watchSlowCall(call Event) func() {
+	threshold := t.slowCallThreshold
+	if threshold <= 0 {
+		return func() {}
+	}
+
+	start := time.Now()
+	fired := make(chan struct{})
+	timer := time.AfterFunc(threshold, func() {
+		defer close(fired)
+		event := slowCallEvent(call, time.Since(start), threshold)
+		event.StackTrace = goroutineStack(call.Goroutine)
+		t.TraceEvent(event)
+	})
+
+	return func() {
+		if timer.Stop() {
+			return
+		}
+		// Report the return after the watchdog's own event
+		<-fired
+		event := slowCallEvent(call, time.Since(start), threshold)
+		event.Tags[TagSlowCallReturned] = true
+		t.TraceEvent(event)
+	}
+}
+
+// slowCallEvent creates an EventSlowCall for a call running for elapsed
+func slowCallEvent(call Event, elapsed, threshold time.Duration) Event {
+	tags := make(map[string]interface{}, len(call.Tags)+2)
+	for k, v := range call.Tags {
+		tags[k] = v
+	}
+	tags[TagSlowCallThreshold] = threshold.String()
+
+	event := call
+	event.ID = generateEventID()
+	event.Timestamp = time.Now()
+	event.Type = EventSlowCall
+	event.Arguments = nil
+	event.Params = nil
+	event.Duration = elapsed
+	event.Tags = tags
+	return event
+}
+
+// goroutineStack returns the stack of another goroutine, in the format of
+// getStackTrace, by finding it in a dump of all goroutines
+func goroutineStack(goroutine int) []string {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	header := []byte(fmt.Sprintf("goroutine %d [", goroutine))
+	start := bytes.Index(buf, header)
+	if start < 0 {
+		return nil
+	}
+	dump := buf[start:]
+	if end := bytes.Index(dump, []byte("\n\n")); end >= 0 {
+		dump = dump[:end]
+	}
+
+	// After the header, each frame is a function line followed by a
+	// tab-indented "file:line +offset" line
+	lines := strings.Split(string(dump), "\n")[1:]
+	var frames []string
+	for i := 0; i+1 < len(lines); i += 2 {
+		function := lines[i]
+		if open := strings.LastIndex(function, "("); open > 0 && strings.HasSuffix(function, ")") {
+			function = function[:open]
+		}
+		location, _, _ := strings.Cut(strings.TrimSpace(lines[i+1]), " +")
+		frames = append(frames, fmt.Sprintf("%s %s", location, function))
+	}
+	return frames
+}
@@ -0,0 +1,119 @@
+package lens_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/baretech/lens"
+	"github.com/baretech/lens/lenstest"
+)
+
+// sum is variadic
+func sum(base int, values ...int) int {
+	for _, v := range values {
+		base += v
+	}
+	return base
+}
+
+// counter has a method to bind
+type counter struct {
+	n int
+}
+
+// Add adds to the counter
+func (c *counter) Add(delta int) int {
+	c.n += delta
+	return c.n
+}
+
+// calls returns the functions called, in order, once the tracer is closed
+func calls(t *testing.T, tracer *lens.TracerImpl, rec *lenstest.RecordingWriter) []lens.Event {
+	t.Helper()
+	if err := tracer.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var called []lens.Event
+	for _, event := range rec.Events() {
+		if event.Type == lens.EventFunctionCall {
+			called = append(called, event)
+		}
+	}
+	return called
+}
+
+func TestWrapVariadic(t *testing.T) {
+	tracer, rec := lenstest.Record(t)
+	wrapped := tracer.Wrap(sum).(func(int, ...int) int)
+
+	if got := wrapped(1, 2, 3); got != 6 {
+		t.Errorf("wrapped(1, 2, 3) = %d, want 6", got)
+	}
+	if got := wrapped(1); got != 1 {
+		t.Errorf("wrapped(1) = %d, want 1", got)
+	}
+	if got := wrapped(1, []int{4, 5}...); got != 10 {
+		t.Errorf("wrapped(1, []int{4, 5}...) = %d, want 10", got)
+	}
+
+	called := calls(t, tracer, rec)
+	if len(called) != 3 {
+		t.Fatalf("got %d calls, want 3", len(called))
+	}
+	if !strings.HasSuffix(called[0].Function, ".sum") {
+		t.Errorf("function = %q, want the name of sum", called[0].Function)
+	}
+}
+
+func TestWrapBoundMethod(t *testing.T) {
+	tracer, rec := lenstest.Record(t)
+	c := &counter{}
+	add := tracer.Wrap(c.Add).(func(int) int)
+
+	add(2)
+	if got := add(3); got != 5 {
+		t.Errorf("add(3) = %d, want 5", got)
+	}
+
+	called := calls(t, tracer, rec)
+	if len(called) != 2 {
+		t.Fatalf("got %d calls, want 2", len(called))
+	}
+	function := called[0].Function
+	if strings.HasSuffix(function, "-fm") || !strings.HasSuffix(function, ".Add") {
+		t.Errorf("function = %q, want the method's name", function)
+	}
+}
+
+func TestWrapClosure(t *testing.T) {
+	tracer, rec := lenstest.Record(t)
+	offset := 10
+	addOffset := tracer.Wrap(func(v int) int { return v + offset }).(func(int) int)
+
+	offset = 20
+	if got := addOffset(1); got != 21 {
+		t.Errorf("addOffset(1) = %d, want 21", got)
+	}
+
+	called := calls(t, tracer, rec)
+	if len(called) != 1 {
+		t.Fatalf("got %d calls, want 1", len(called))
+	}
+	if !strings.Contains(called[0].Function, "TestWrapClosure.func") {
+		t.Errorf("function = %q, want the closure's name", called[0].Function)
+	}
+}
+
+func TestWrapNilFunc(t *testing.T) {
+	tracer, _ := lenstest.Record(t)
+	var fn func(int) int
+
+	wrapped, ok := tracer.Wrap(fn).(func(int) int)
+	if !ok {
+		t.Fatalf("Wrap(nil func) returned %T", tracer.Wrap(fn))
+	}
+	if wrapped != nil {
+		t.Error("Wrap(nil func) returned a non-nil func")
+	}
+}
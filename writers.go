@@ -1,23 +1,56 @@
 package lens
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
+	"text/template"
+	"time"
 )
 
-// JSONFileWriter writes trace events to JSON files
+// JSONFileWriter writes trace events to JSON files.
+// Events are buffered and written in batches, once the batch is full or
+// the flush interval elapses; Flush forces a write.
 type JSONFileWriter struct {
-	path   string
-	file   *os.File
-	mutex  sync.Mutex
-	buffer []Event
+	path          string
+	file          *fileOutput
+	mutex         sync.Mutex
+	buffer        []Event
+	batchSize     int
+	flushInterval time.Duration
+	done          chan struct{}
+	wg            sync.WaitGroup
+}
+
+// JSONFileWriterOption configures a JSONFileWriter
+type JSONFileWriterOption func(*JSONFileWriter)
+
+// WithJSONBatchSize sets how many events are buffered before a write.
+// A size of 1 writes every event immediately.
+func WithJSONBatchSize(size int) JSONFileWriterOption {
+	return func(w *JSONFileWriter) {
+		if size > 0 {
+			w.batchSize = size
+		}
+	}
+}
+
+// WithJSONFlushInterval sets how often partial batches are written;
+// zero disables time-based flushing
+func WithJSONFlushInterval(interval time.Duration) JSONFileWriterOption {
+	return func(w *JSONFileWriter) {
+		w.flushInterval = interval
+	}
 }
 
 // NewJSONFileWriter creates a new JSON file writer
-func NewJSONFileWriter(path string) (*JSONFileWriter, error) {
+func NewJSONFileWriter(path string, options ...JSONFileWriterOption) (*JSONFileWriter, error) {
 	// Ensure directory exists
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -29,66 +62,156 @@ func NewJSONFileWriter(path string) (*JSONFileWriter, error) {
 		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
 
-	return &JSONFileWriter{
-		path:   path,
-		file:   file,
-		buffer: make([]Event, 0),
-	}, nil
+	w := &JSONFileWriter{
+		path:          path,
+		file:          &fileOutput{file: file},
+		buffer:        make([]Event, 0),
+		batchSize:     256,
+		flushInterval: time.Second,
+		done:          make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(w)
+	}
+
+	if w.flushInterval > 0 && w.batchSize > 1 {
+		w.wg.Add(1)
+		go w.flushLoop()
+	}
+
+	return w, nil
+}
+
+// flushLoop periodically writes partial batches
+func (w *JSONFileWriter) flushLoop() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.mutex.Lock()
+			w.writeBuffer()
+			w.mutex.Unlock()
+		case <-w.done:
+			return
+		}
+	}
 }
 
-// Write writes an event to the JSON file
+// Write buffers an event and writes the batch once it is full
 func (w *JSONFileWriter) Write(event Event) error {
 	w.mutex.Lock()
 	defer w.mutex.Unlock()
 
-	data, err := json.Marshal(event)
-	if err != nil {
-		return fmt.Errorf("failed to marshal event: %w", err)
+	if w.file == nil {
+		return fmt.Errorf("json file writer is closed")
 	}
 
-	_, err = w.file.Write(append(data, '\n'))
-	if err != nil {
-		return fmt.Errorf("failed to write to file: %w", err)
+	w.buffer = append(w.buffer, event)
+	if len(w.buffer) >= w.batchSize {
+		return w.writeBuffer()
 	}
-
 	return nil
 }
 
-// Flush flushes any buffered data
+// writeBuffer writes all buffered events in a single write; the caller
+// must hold the mutex. Events that cannot be marshaled are dropped and
+// reported, so one bad event does not hold back the rest of the batch.
+func (w *JSONFileWriter) writeBuffer() error {
+	if len(w.buffer) == 0 || w.file == nil {
+		return nil
+	}
+
+	var data bytes.Buffer
+	var errs []error
+	encoder := json.NewEncoder(&data)
+	for _, event := range w.buffer {
+		size := data.Len()
+		if err := encoder.Encode(event); err != nil {
+			data.Truncate(size)
+			errs = append(errs, fmt.Errorf("failed to marshal event %s: %w", event.ID, err))
+		}
+	}
+	w.buffer = w.buffer[:0]
+
+	if _, err := w.file.Write(data.Bytes()); err != nil {
+		errs = append(errs, fmt.Errorf("failed to write to file: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Flush writes buffered events and syncs the file
 func (w *JSONFileWriter) Flush() error {
 	w.mutex.Lock()
 	defer w.mutex.Unlock()
 
+	if err := w.writeBuffer(); err != nil {
+		return err
+	}
 	if w.file != nil {
 		return w.file.Sync()
 	}
 	return nil
 }
 
-// Close closes the writer
+// Close writes buffered events and closes the writer
 func (w *JSONFileWriter) Close() error {
+	w.mutex.Lock()
+	if w.file == nil {
+		w.mutex.Unlock()
+		return nil
+	}
+	err := w.writeBuffer()
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	w.file = nil
+	w.mutex.Unlock()
+
+	close(w.done)
+	w.wg.Wait()
+	return err
+}
+
+// compress compresses the events written from now on
+func (w *JSONFileWriter) compress(compressor Compressor) error {
 	w.mutex.Lock()
 	defer w.mutex.Unlock()
 
-	if w.file != nil {
-		err := w.file.Close()
-		w.file = nil
-		return err
+	if w.file == nil {
+		return fmt.Errorf("json file writer is closed")
 	}
-	return nil
+	return w.file.compress(compressor)
 }
 
 // ConsoleWriter writes trace events to the console
 type ConsoleWriter struct {
-	colored bool
-	mutex   sync.Mutex
+	colored  bool
+	template *template.Template
+	format   valueFormat
+	collapse bool
+	// The last event printed and how often it was repeated since, when
+	// collapsing repeats
+	lastKey    string
+	lastRepeat Event
+	repeats    int
+	mutex      sync.Mutex
 }
 
 // NewConsoleWriter creates a new console writer
-func NewConsoleWriter(colored bool) *ConsoleWriter {
-	return &ConsoleWriter{
+func NewConsoleWriter(colored bool, options ...ConsoleWriterOption) *ConsoleWriter {
+	w := &ConsoleWriter{
 		colored: colored,
 	}
+	for _, option := range options {
+		option(w)
+	}
+	return w
 }
 
 // Write writes an event to the console
@@ -96,61 +219,70 @@ func (w *ConsoleWriter) Write(event Event) error {
 	w.mutex.Lock()
 	defer w.mutex.Unlock()
 
-	var output string
-	if w.colored {
-		output = w.formatColored(event)
-	} else {
-		output = w.formatPlain(event)
+	if w.collapse {
+		key, err := w.repeatKey(event)
+		if err != nil {
+			return err
+		}
+		if key == w.lastKey {
+			w.repeats++
+			w.lastRepeat = event
+			return nil
+		}
+		w.writeRepeats()
+		w.lastKey = key
 	}
 
+	output, err := w.formatEvent(event)
+	if err != nil {
+		return err
+	}
 	fmt.Println(output)
 	return nil
 }
 
-// formatColored formats an event with colors
-func (w *ConsoleWriter) formatColored(event Event) string {
-	const (
-		colorReset  = "\033[0m"
-		colorRed    = "\033[31m"
-		colorGreen  = "\033[32m"
-		colorYellow = "\033[33m"
-		colorBlue   = "\033[34m"
-		colorPurple = "\033[35m"
-		colorCyan   = "\033[36m"
-	)
-
-	var color string
-	switch event.Type {
-	case EventError, EventPanic:
-		color = colorRed
-	case EventFunctionCall, EventMethodCall:
-		color = colorBlue
-	case EventFunctionReturn:
-		color = colorGreen
-	case EventVariableRead, EventVariableWrite:
-		color = colorYellow
+// formatEvent formats an event as configured
+func (w *ConsoleWriter) formatEvent(event Event) (string, error) {
+	switch {
+	case w.template != nil:
+		return w.formatTemplate(event)
+	case w.colored:
+		return w.formatColored(event), nil
 	default:
-		color = colorCyan
+		return w.formatPlain(event), nil
 	}
+}
 
+// formatColored formats an event with colors
+func (w *ConsoleWriter) formatColored(event Event) string {
 	timestamp := event.Timestamp.Format("15:04:05.000")
-	return fmt.Sprintf("%s[%s] %s %s%s", color, timestamp, event.Type, w.formatEventDetails(event), colorReset)
+	return colorize(eventColor(event.Type), fmt.Sprintf("[%s] %s%s %s", timestamp, indent(event), event.Type, w.format.details(event)))
 }
 
 // formatPlain formats an event without colors
 func (w *ConsoleWriter) formatPlain(event Event) string {
 	timestamp := event.Timestamp.Format("15:04:05.000")
-	return fmt.Sprintf("[%s] %s %s", timestamp, event.Type, w.formatEventDetails(event))
+	return fmt.Sprintf("[%s] %s%s %s", timestamp, indent(event), event.Type, w.format.details(event))
+}
+
+// indent returns the indentation for an event's call depth
+func indent(event Event) string {
+	return strings.Repeat("  ", event.Depth)
 }
 
 // formatEventDetails formats the details of an event
-func (w *ConsoleWriter) formatEventDetails(event Event) string {
+func formatEventDetails(event Event) string {
+	return valueFormat{}.details(event)
+}
+
+// details formats the details of an event, rendering values with f
+func (f valueFormat) details(event Event) string {
 	var details string
 
 	switch event.Type {
 	case EventFunctionCall, EventMethodCall:
 		if event.Function != "" {
-			details = fmt.Sprintf("func=%s args=%v", event.Function, event.Arguments)
+			details = fmt.Sprintf("func=%s args=%s", event.Function, f.values(event.Arguments))
 		}
 	case EventFunctionReturn:
 		if event.Function != "" {
@@ -158,18 +290,61 @@ func (w *ConsoleWriter) formatEventDetails(event Event) string {
 			if event.Duration > 0 {
 				duration = fmt.Sprintf(" duration=%v", event.Duration)
 			}
-			details = fmt.Sprintf("func=%s returns=%v%s", event.Function, event.ReturnValue, duration)
+			if event.Allocs > 0 {
+				duration += fmt.Sprintf(" allocs=%d bytes=%d", event.Allocs, event.AllocBytes)
+			}
+			details = fmt.Sprintf("func=%s returns=%s%s", event.Function, f.values(event.ReturnValue), duration)
 		}
-	case EventVariableRead, EventVariableWrite:
+	case EventVariableRead, EventVariableWrite, EventFieldAccess:
 		if event.Variable != "" {
-			if event.Type == EventVariableWrite {
-				details = fmt.Sprintf("var=%s old=%v new=%v", event.Variable, event.OldValue, event.NewValue)
+			if event.Type == EventVariableWrite && event.Changes != nil {
+				details = fmt.Sprintf("var=%s changes=%v", event.Variable, f.changes(event.Changes))
+			} else if event.Type == EventVariableWrite {
+				details = fmt.Sprintf("var=%s old=%s new=%s", event.Variable, f.value(event.OldValue), f.value(event.NewValue))
 			} else {
-				details = fmt.Sprintf("var=%s value=%v", event.Variable, event.NewValue)
+				details = fmt.Sprintf("var=%s value=%s", event.Variable, f.value(event.NewValue))
 			}
 		}
-	case EventError:
+	case EventError, EventPanic:
 		details = fmt.Sprintf("error=%s", event.Error)
+	case EventSpanEvent:
+		details = fmt.Sprintf("span=%s event=%v", event.Function, event.Tags[TagEventName])
+	case EventBlocked:
+		details = fmt.Sprintf("blocked=%s for=%v", event.Function, event.Duration)
+	case EventBudgetExceeded:
+		details = fmt.Sprintf("func=%s duration=%v", event.Function, event.Duration)
+	case EventSlowCall:
+		details = fmt.Sprintf("func=%s running=%v", event.Function, event.Duration)
+		if event.Tags[TagSlowCallReturned] == true {
+			details = fmt.Sprintf("func=%s returned=%v", event.Function, event.Duration)
+		}
+	case EventRecursion:
+		details = fmt.Sprintf("func=%s recursion=%d", event.Function, event.RecursionDepth)
+	case EventRetryAttempt:
+		details = fmt.Sprintf("func=%s duration=%v", event.Function, event.Duration)
+		if event.Error != "" {
+			details += fmt.Sprintf(" error=%s", event.Error)
+		}
+	case EventQuotaExceeded:
+		details = fmt.Sprintf("quota=%v window=%v", event.Tags[TagQuotaScope], event.Tags[TagQuotaWindow])
+	case EventWriterUnhealthy:
+		details = fmt.Sprintf("writer=%v queue=%v", event.Tags[TagWriter], event.Tags[TagWriterQueueDepth])
+		if event.Error != "" {
+			details += fmt.Sprintf(" error=%s", event.Error)
+		}
+	case EventLog:
+		details = fmt.Sprintf("log=%q", event.Tags[TagLogMessage])
+	case EventLockOperation:
+		details = fmt.Sprintf("lock=%s for=%v", event.Function, event.Duration)
+	case EventRuntime:
+		details = fmt.Sprintf("gc_pause=%v", event.Duration)
+	case EventGoroutineStart, EventGoroutineEnd:
+		details = fmt.Sprintf("goroutine=%d parent=%d func=%s", event.Goroutine, event.ParentGoroutine, event.Function)
+		if event.Duration > 0 {
+			details += fmt.Sprintf(" lifetime=%v", event.Duration)
+		}
+	case EventStateDiff:
+		details = fmt.Sprintf("state=%s %s changes=%v", event.Component, event.Variable, f.changes(event.Changes))
 	default:
 		if event.Component != "" {
 			details = fmt.Sprintf("component=%s", event.Component)
@@ -192,15 +367,54 @@ func (w *ConsoleWriter) formatEventDetails(event Event) string {
 		sourceInfo = fmt.Sprintf(" [%s:%d]", filename, event.CallerLine)
 	}
 
-	return details + sourceInfo
+	details += f.tags(event) + sourceInfo
+	if f.pretty {
+		// Indent multi-line values under the event
+		details = strings.ReplaceAll(details, "\n", "\n"+indent(event)+"    ")
+	}
+	return details
+}
+
+// formatTags formats event tags as a sorted key=value list
+func formatTags(event Event) string {
+	return valueFormat{}.tags(event)
+}
+
+// tags formats event tags as a sorted key=value list, rendering values
+// with f
+func (f valueFormat) tags(event Event) string {
+	if len(event.Tags) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(event.Tags))
+	for k := range event.Tags {
+		if event.Type == EventSpanEvent && k == TagEventName {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = fmt.Sprintf("%s=%s", k, f.value(event.Tags[k]))
+	}
+	return fmt.Sprintf(" tags={%s}", strings.Join(pairs, " "))
 }
 
-// Flush flushes any buffered data (no-op for console)
+// Flush prints the count of a collapsed repeat, if any
 func (w *ConsoleWriter) Flush() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.writeRepeats()
 	return nil
 }
 
-// Close closes the writer (no-op for console)
+// Close closes the writer, printing the count of a collapsed repeat
 func (w *ConsoleWriter) Close() error {
-	return nil
+	return w.Flush()
 }
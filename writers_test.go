@@ -0,0 +1,109 @@
+package lens
+
+import (
+	"bufio"
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestJSONFileWriterDropsUnencodableEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.json")
+	w, err := NewJSONFileWriter(path, WithJSONBatchSize(3), WithJSONFlushInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The bad event is in the first batch, with two good ones
+	if err := w.Write(Event{ID: "bad", ReturnValue: []interface{}{math.Inf(1)}}); err != nil {
+		t.Fatalf("Write buffered the bad event but returned %v", err)
+	}
+	w.Write(Event{ID: "good1"})
+	if err := w.Write(Event{ID: "good2"}); err == nil {
+		t.Error("writing the batch did not report the bad event")
+	}
+
+	for _, id := range []string{"good3", "good4", "good5"} {
+		if err := w.Write(Event{ID: id}); err != nil {
+			t.Errorf("Write(%s) = %v after the bad batch", id, err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Errorf("Flush = %v after the bad batch", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("Close = %v after the bad batch", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	lines := 0
+	for scanner := bufio.NewScanner(file); scanner.Scan(); {
+		lines++
+	}
+	if lines != 5 {
+		t.Errorf("file has %d events, want the 5 good ones", lines)
+	}
+}
+
+// orderProducer records the messages it is given, in the order given
+type orderProducer struct {
+	mutex    sync.Mutex
+	messages []KafkaMessage
+}
+
+func (p *orderProducer) Produce(ctx context.Context, messages []KafkaMessage) error {
+	p.mutex.Lock()
+	p.messages = append(p.messages, messages...)
+	p.mutex.Unlock()
+	return nil
+}
+
+func (p *orderProducer) Close() error { return nil }
+
+func TestKafkaWriterPublishesInOrder(t *testing.T) {
+	producer := &orderProducer{}
+	w := NewKafkaWriter(producer, "trace",
+		WithKafkaBatchSize(3),
+		WithKafkaFlushInterval(time.Microsecond),
+		WithKafkaEncoder(func(event Event) ([]byte, error) {
+			return []byte(event.ID), nil
+		}))
+
+	// Each goroutine's events must reach the producer in the order written
+	const goroutines, events = 8, 2000
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < events; i++ {
+				w.Write(Event{ID: strconv.Itoa(g), TraceID: strconv.Itoa(i)})
+			}
+		}()
+	}
+	wg.Wait()
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(producer.messages) != goroutines*events {
+		t.Fatalf("producer got %d messages, want %d", len(producer.messages), goroutines*events)
+	}
+	next := make(map[string]int)
+	for _, message := range producer.messages {
+		g, i := string(message.Value), string(message.Key)
+		if i != strconv.Itoa(next[g]) {
+			t.Fatalf("goroutine %s: got event %s, want %d", g, i, next[g])
+		}
+		next[g]++
+	}
+}
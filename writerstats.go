@@ -0,0 +1,174 @@
+package lens
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Tags set on EventWriterUnhealthy events
+const (
+	TagWriter           = "writer"
+	TagWriterError      = "writer.error"
+	TagWriterQueueDepth = "writer.queue_depth"
+)
+
+// WriterStats describes how a writer is keeping up
+type WriterStats struct {
+	Writer Writer `json:"-"`
+	// Name is the writer's type, e.g. *lens.JSONFileWriter
+	Name    string `json:"name"`
+	Written uint64 `json:"written"`
+	Errors  uint64 `json:"errors"`
+	// Dropped is reported by writers that drop events themselves, such as
+	// LazyWriter and TailSamplingWriter
+	Dropped uint64 `json:"dropped"`
+	// QueueDepth is the number of events waiting to be written
+	QueueDepth int       `json:"queue_depth"`
+	LastWrite  time.Time `json:"last_write"`
+	// LastFlush is when the tracer last flushed the writer, through the
+	// admin handler or Close; writers also flush on their own
+	LastFlush time.Time `json:"last_flush"`
+	LastError string    `json:"last_error,omitempty"`
+	Healthy   bool      `json:"healthy"`
+}
+
+// droppedCounter is implemented by writers that drop events
+type droppedCounter interface {
+	Dropped() uint64
+}
+
+// WithWriterHealth emits an EventWriterUnhealthy when a writer becomes
+// unhealthy: its writes start failing, or more than maxQueueDepth events
+// wait for it, if maxQueueDepth is positive. The event is emitted once,
+// until the writer writes successfully with its queue back under the
+// limit. It goes to every writer, so the others record the trouble.
+func WithWriterHealth(maxQueueDepth int) Option {
+	return func(t *TracerImpl) {
+		t.dispatcher.health = &writerHealth{tracer: t, maxQueueDepth: maxQueueDepth}
+	}
+}
+
+// WriterStats returns the statistics of the tracer's writers, in the
+// order they were added
+func (t *TracerImpl) WriterStats() []WriterStats {
+	writers := t.Writers()
+	stats := make([]WriterStats, len(writers))
+	for i, writer := range writers {
+		stats[i] = t.root().dispatcher.stats(writer)
+	}
+	return stats
+}
+
+// writerCounters holds the statistics of one writer
+type writerCounters struct {
+	written   atomic.Uint64
+	errors    atomic.Uint64
+	lastWrite atomic.Int64
+	lastFlush atomic.Int64
+	lastError atomic.Pointer[string]
+	unhealthy atomic.Bool
+}
+
+// recordWrite accounts for a write, returning true if the writer just
+// became unhealthy
+func (c *writerCounters) recordWrite(err error, depth, maxDepth int) bool {
+	c.lastWrite.Store(time.Now().UnixNano())
+	if err != nil {
+		c.errors.Add(1)
+		message := err.Error()
+		c.lastError.Store(&message)
+		return !c.unhealthy.Swap(true)
+	}
+
+	c.written.Add(1)
+	if maxDepth > 0 && depth > maxDepth {
+		return !c.unhealthy.Swap(true)
+	}
+	c.unhealthy.Store(false)
+	return false
+}
+
+// recordFlush accounts for a flush by the tracer
+func (c *writerCounters) recordFlush(err error) {
+	c.lastFlush.Store(time.Now().UnixNano())
+	if err != nil {
+		message := err.Error()
+		c.lastError.Store(&message)
+	}
+}
+
+// writerHealth reports writers becoming unhealthy
+type writerHealth struct {
+	tracer        *TracerImpl
+	maxQueueDepth int
+}
+
+// report emits an EventWriterUnhealthy
+func (h *writerHealth) report(writer Writer, err error, depth int) {
+	tags := map[string]interface{}{
+		TagWriter:           fmt.Sprintf("%T", writer),
+		TagWriterQueueDepth: depth,
+	}
+	event := Event{
+		ID:        generateEventID(),
+		TraceID:   generateTraceID(),
+		Timestamp: time.Now(),
+		Type:      EventWriterUnhealthy,
+		Goroutine: getGoroutineID(),
+		Tags:      tags,
+	}
+	if err != nil {
+		tags[TagWriterError] = err.Error()
+		event.Error = err.Error()
+	}
+	h.tracer.TraceEvent(event)
+}
+
+// stats returns the statistics of a writer
+func (d *dispatcher) stats(writer Writer) WriterStats {
+	stats := WriterStats{Writer: writer, Name: fmt.Sprintf("%T", writer), Healthy: true}
+	if dropper, ok := writer.(droppedCounter); ok {
+		stats.Dropped = dropper.Dropped()
+	}
+
+	d.mutex.Lock()
+	queue := d.queues[writer]
+	d.mutex.Unlock()
+	counters := d.counters(writer)
+
+	if queue != nil {
+		stats.QueueDepth = queue.depth()
+	}
+	stats.Written = counters.written.Load()
+	stats.Errors = counters.errors.Load()
+	stats.LastWrite = unixTime(counters.lastWrite.Load())
+	stats.LastFlush = unixTime(counters.lastFlush.Load())
+	if message := counters.lastError.Load(); message != nil {
+		stats.LastError = *message
+	}
+	stats.Healthy = !counters.unhealthy.Load()
+	return stats
+}
+
+// counters returns the statistics counters of a writer, creating them
+func (d *dispatcher) counters(writer Writer) *writerCounters {
+	if counters, ok := d.writerCounters.Load(writer); ok {
+		return counters.(*writerCounters)
+	}
+	counters, _ := d.writerCounters.LoadOrStore(writer, &writerCounters{})
+	return counters.(*writerCounters)
+}
+
+// flushed records that the tracer flushed a writer
+func (d *dispatcher) flushed(writer Writer, err error) {
+	d.counters(writer).recordFlush(err)
+}
+
+// unixTime converts nanoseconds since the epoch, zero for the zero time
+func unixTime(nanos int64) time.Time {
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
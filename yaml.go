@@ -0,0 +1,220 @@
+package lens
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// yamlLine is a significant line of a YAML document
+type yamlLine struct {
+	indent int
+	text   string
+	number int
+}
+
+// parseYAML decodes the block-style YAML subset used by lens config files:
+// nested mappings, sequences, scalars, flow sequences and comments.
+// Anchors, multi-document streams and multi-line scalars are not supported.
+func parseYAML(data []byte) (interface{}, error) {
+	var lines []yamlLine
+	for i, raw := range strings.Split(string(data), "\n") {
+		text := stripYAMLComment(strings.TrimRight(raw, " \t\r"))
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", i+1)
+		}
+		lines = append(lines, yamlLine{
+			indent: len(text) - len(trimmed),
+			text:   trimmed,
+			number: i + 1,
+		})
+	}
+
+	if len(lines) == 0 {
+		return map[string]interface{}{}, nil
+	}
+
+	value, next, err := parseYAMLBlock(lines, 0, lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if next < len(lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", lines[next].number)
+	}
+	return value, nil
+}
+
+// parseYAMLBlock parses the mapping or sequence starting at lines[i]
+func parseYAMLBlock(lines []yamlLine, i, indent int) (interface{}, int, error) {
+	if strings.HasPrefix(lines[i].text, "- ") || lines[i].text == "-" {
+		return parseYAMLSequence(lines, i, indent)
+	}
+	return parseYAMLMapping(lines, i, indent)
+}
+
+// parseYAMLMapping parses consecutive "key: value" lines at indent
+func parseYAMLMapping(lines []yamlLine, i, indent int) (interface{}, int, error) {
+	result := make(map[string]interface{})
+
+	for i < len(lines) && lines[i].indent == indent {
+		line := lines[i]
+		if strings.HasPrefix(line.text, "- ") {
+			break
+		}
+
+		key, rest, ok := splitYAMLKey(line.text)
+		if !ok {
+			return nil, 0, fmt.Errorf("line %d: expected \"key: value\"", line.number)
+		}
+		i++
+
+		if rest != "" {
+			result[key] = parseYAMLScalar(rest)
+			continue
+		}
+
+		// Nested block: deeper indentation, or a sequence at the same level
+		if i < len(lines) && (lines[i].indent > indent ||
+			(lines[i].indent == indent && strings.HasPrefix(lines[i].text, "- "))) {
+			value, next, err := parseYAMLBlock(lines, i, lines[i].indent)
+			if err != nil {
+				return nil, 0, err
+			}
+			result[key] = value
+			i = next
+			continue
+		}
+
+		result[key] = nil
+	}
+
+	if i < len(lines) && lines[i].indent > indent {
+		return nil, 0, fmt.Errorf("line %d: unexpected indentation", lines[i].number)
+	}
+
+	return result, i, nil
+}
+
+// parseYAMLSequence parses consecutive "- item" lines at indent
+func parseYAMLSequence(lines []yamlLine, i, indent int) (interface{}, int, error) {
+	var result []interface{}
+
+	for i < len(lines) && lines[i].indent == indent && (strings.HasPrefix(lines[i].text, "- ") || lines[i].text == "-") {
+		item := strings.TrimSpace(strings.TrimPrefix(lines[i].text, "-"))
+		number := lines[i].number
+		i++
+
+		if item == "" {
+			if i < len(lines) && lines[i].indent > indent {
+				value, next, err := parseYAMLBlock(lines, i, lines[i].indent)
+				if err != nil {
+					return nil, 0, err
+				}
+				result = append(result, value)
+				i = next
+			} else {
+				result = append(result, nil)
+			}
+			continue
+		}
+
+		if _, _, ok := splitYAMLKey(item); ok {
+			// "- key: value" opens a mapping whose remaining keys are
+			// aligned with the first one
+			itemIndent := indent + 2
+			sub := append([]yamlLine{{indent: itemIndent, text: item, number: number}}, lines[i:]...)
+			value, next, err := parseYAMLMapping(sub, 0, itemIndent)
+			if err != nil {
+				return nil, 0, err
+			}
+			result = append(result, value)
+			i += next - 1
+			continue
+		}
+
+		result = append(result, parseYAMLScalar(item))
+	}
+
+	return result, i, nil
+}
+
+// splitYAMLKey splits "key: value" into its parts
+func splitYAMLKey(text string) (string, string, bool) {
+	if strings.HasPrefix(text, `"`) || strings.HasPrefix(text, "'") {
+		return "", "", false
+	}
+	index := strings.Index(text, ":")
+	if index <= 0 {
+		return "", "", false
+	}
+	if index+1 < len(text) && text[index+1] != ' ' {
+		return "", "", false
+	}
+	return strings.TrimSpace(text[:index]), strings.TrimSpace(text[index+1:]), true
+}
+
+// parseYAMLScalar converts a scalar or flow sequence to a Go value
+func parseYAMLScalar(text string) interface{} {
+	switch {
+	case strings.HasPrefix(text, `"`):
+		if s, err := strconv.Unquote(text); err == nil {
+			return s
+		}
+		return strings.Trim(text, `"`)
+	case strings.HasPrefix(text, "'"):
+		return strings.ReplaceAll(strings.Trim(text, "'"), "''", "'")
+	case strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]"):
+		inner := strings.TrimSpace(text[1 : len(text)-1])
+		items := []interface{}{}
+		if inner == "" {
+			return items
+		}
+		for _, part := range strings.Split(inner, ",") {
+			items = append(items, parseYAMLScalar(strings.TrimSpace(part)))
+		}
+		return items
+	}
+
+	switch text {
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	case "null", "Null", "NULL", "~":
+		return nil
+	}
+
+	if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(text, 64); err == nil {
+		return f
+	}
+	return text
+}
+
+// stripYAMLComment removes a trailing # comment outside of quotes
+func stripYAMLComment(line string) string {
+	inSingle, inDouble := false, false
+	for i, r := range line {
+		switch r {
+		case '\'':
+			if !inDouble {
+				inSingle = !inSingle
+			}
+		case '"':
+			if !inSingle {
+				inDouble = !inDouble
+			}
+		case '#':
+			if !inSingle && !inDouble && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t') {
+				return strings.TrimRight(line[:i], " \t")
+			}
+		}
+	}
+	return line
+}